@@ -1,15 +1,44 @@
 package main
 
 import (
-	"github.com/T-Prohmpossadhorn/flight-booking/internal/route"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/config"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/server"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	r := gin.Default()
-	r.POST("/flights", route.AddFlightHandler)
-	r.GET("/flights/:flight_id", route.GetFlightHandler)
-	r.POST("/book", route.BookFlightHandler)
-	r.POST("/cancel", route.CancelBookingHandler)
-	r.Run(":8080")
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := cfg.Logger(os.Stderr)
+	slog.SetDefault(logger)
+	if cfg.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	srv, err := server.New(cfg, logger)
+	if err != nil {
+		logger.Error("starting", "err", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := srv.Run(ctx); err != nil {
+		logger.Error("serving", "err", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/idempotency"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/quote"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+)
+
+// Default returns the built-in configuration, matching the service's own
+// defaults.
+func Default() Config {
+	return Config{
+		Server:  Server{Addr: ":8080", ShutdownTimeout: Duration(30 * time.Second)},
+		Storage: Storage{Backend: StorageMemory},
+		Pricing: Pricing{
+			EarlyDays:            flight.DefaultPricing.EarlyDays,
+			EarlyDiscountPercent: flight.DefaultPricing.EarlyDiscount,
+			LateDays:             flight.DefaultPricing.LateDays,
+			LateSurchargePercent: flight.DefaultPricing.LateSurcharge,
+		},
+		Refunds: Refunds{
+			Refundable:        fare.DefaultRules.Refundable,
+			RefundPercent:     fare.DefaultRules.RefundPercent,
+			LateRefundPercent: fare.DefaultRules.LateRefundPercent,
+			Changeable:        fare.DefaultRules.Changeable,
+			ChangeFee:         Money(fare.DefaultRules.ChangeFee),
+		},
+		Holds: Holds{
+			QuoteTTL:          Duration(quote.DefaultTTL),
+			IdempotencyWindow: Duration(idempotency.DefaultWindow),
+		},
+		Workers: Workers{
+			ScheduleInterval: Duration(time.Hour),
+			ReapInterval:     Duration(time.Minute),
+		},
+		FrequentFlyer: FrequentFlyer{
+			MinBookings:     usecase.DefaultFrequentFlyerBookings,
+			DiscountPercent: flight.DefaultPricing.FrequentFlyerDiscount,
+		},
+		Inventory: Inventory{Mode: string(usecase.InventoryLocked)},
+		Webhooks: Webhooks{
+			MaxAttempts:    webhook.DefaultMaxAttempts,
+			InitialBackoff: Duration(webhook.DefaultInitialBackoff),
+			MaxBackoff:     Duration(webhook.DefaultMaxBackoff),
+			Timeout:        Duration(webhook.DefaultTimeout),
+		},
+		Log: Log{Level: "info", Format: "text"},
+	}
+}
+
+// Load builds the configuration from args and getenv on top of Default. The
+// file is named by the -config flag or the CONFIG_FILE variable. Every
+// problem found is reported together, wrapped in ErrInvalidConfig; asking for
+// -help returns flag.ErrHelp after printing the usage to output.
+func Load(args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("flight-booking", flag.ContinueOnError)
+	fs.SetOutput(output)
+	path := fs.String("config", getenv("CONFIG_FILE"), "YAML or JSON configuration file")
+	flags := make(map[string]string)
+	for _, s := range settings {
+		fs.Func(s.key, "overrides "+s.key+" (env "+s.env+")", func(v string) error {
+			flags[s.key] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return cfg, err
+		}
+		return cfg, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	var problems []error
+	if *path != "" {
+		if err := readFile(*path, &cfg); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %v", *path, err))
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				problems = append(problems, fmt.Errorf("%s: %v", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flags[s.key]; ok {
+			if err := s.set(&cfg, v); err != nil {
+				problems = append(problems, fmt.Errorf("-%s: %v", s.key, err))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		problems = cfg.problems()
+	}
+	if len(problems) > 0 {
+		return cfg, report(problems)
+	}
+	return cfg, nil
+}
+
+// Validate reports every invalid value, wrapped in ErrInvalidConfig.
+func (c Config) Validate() error {
+	if problems := c.problems(); len(problems) > 0 {
+		return report(problems)
+	}
+	return nil
+}
+
+func (c Config) problems() []error {
+	var problems []error
+	add := func(key, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		add("server.addr", "%v", err)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout", "must be positive")
+	}
+	switch c.Storage.Backend {
+	case StorageMemory:
+		if c.Storage.DSN != "" {
+			add("storage.dsn", "must be empty for the %s backend", StorageMemory)
+		}
+	default:
+		add("storage.backend", "unsupported backend %q, supported: %s", c.Storage.Backend, StorageMemory)
+	}
+	if err := c.FlightPricing().Validate(); err != nil {
+		add("pricing", "%v", err)
+	}
+	if err := c.RefundRules().Validate(); err != nil {
+		add("refunds", "%v", err)
+	}
+	if c.Holds.QuoteTTL <= 0 {
+		add("holds.quote_ttl", "must be positive")
+	}
+	if c.Holds.IdempotencyWindow <= 0 {
+		add("holds.idempotency_window", "must be positive")
+	}
+	if c.Workers.ScheduleInterval <= 0 {
+		add("workers.schedule_interval", "must be positive")
+	}
+	if c.Workers.ReapInterval <= 0 {
+		add("workers.reap_interval", "must be positive")
+	}
+	if c.FrequentFlyer.MinBookings < 1 {
+		add("frequent_flyer.min_bookings", "must be at least 1")
+	}
+	switch usecase.InventoryMode(c.Inventory.Mode) {
+	case usecase.InventoryLocked, usecase.InventoryOptimistic, usecase.InventoryActor:
+	default:
+		add("inventory.mode", "must be %s, %s or %s, got %q", usecase.InventoryLocked, usecase.InventoryOptimistic, usecase.InventoryActor, c.Inventory.Mode)
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		add("webhooks.max_attempts", "must be at least 1")
+	}
+	if c.Webhooks.InitialBackoff <= 0 {
+		add("webhooks.initial_backoff", "must be positive")
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.InitialBackoff {
+		add("webhooks.max_backoff", "must be at least webhooks.initial_backoff")
+	}
+	if c.Webhooks.Timeout <= 0 {
+		add("webhooks.timeout", "must be positive")
+	}
+	if _, err := logLevel(c.Log.Level); err != nil {
+		add("log.level", "%v", err)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		add("log.format", "must be text or json, got %q", c.Log.Format)
+	}
+	return problems
+}
+
+// FlightPricing returns the pricing thresholds and frequent flyer discount.
+func (c Config) FlightPricing() flight.Pricing {
+	return flight.Pricing{
+		EarlyDays:             c.Pricing.EarlyDays,
+		EarlyDiscount:         c.Pricing.EarlyDiscountPercent,
+		LateDays:              c.Pricing.LateDays,
+		LateSurcharge:         c.Pricing.LateSurchargePercent,
+		FrequentFlyerDiscount: c.FrequentFlyer.DiscountPercent,
+	}
+}
+
+// RefundRules returns the fare rules for cabins without fare families.
+func (c Config) RefundRules() fare.Rules {
+	return fare.Rules{
+		Refundable:        c.Refunds.Refundable,
+		RefundPercent:     c.Refunds.RefundPercent,
+		LateRefundPercent: c.Refunds.LateRefundPercent,
+		Changeable:        c.Refunds.Changeable,
+		ChangeFee:         money.Money(c.Refunds.ChangeFee),
+	}
+}
+
+// Apply sets the service's pricing, refund, frequent flyer, quote, inventory
+// and webhook policies.
+func (c Config) Apply(s *usecase.Service) {
+	s.Pricing = c.FlightPricing()
+	s.DefaultRules = c.RefundRules()
+	s.FrequentFlyerBookings = c.FrequentFlyer.MinBookings
+	s.QuoteTTL = time.Duration(c.Holds.QuoteTTL)
+	s.Inventory = usecase.InventoryMode(c.Inventory.Mode)
+	s.Webhooks.Retry = webhook.RetryPolicy{
+		MaxAttempts:    c.Webhooks.MaxAttempts,
+		InitialBackoff: time.Duration(c.Webhooks.InitialBackoff),
+		MaxBackoff:     time.Duration(c.Webhooks.MaxBackoff),
+	}
+	s.Webhooks.Client.Timeout = time.Duration(c.Webhooks.Timeout)
+	s.Webhooks.AllowPrivate = c.Webhooks.AllowPrivate
+}
+
+// Logger returns a logger writing to w at the configured level and format.
+func (c Config) Logger(w io.Writer) *slog.Logger {
+	level, _ := logLevel(c.Log.Level)
+	opts := &slog.HandlerOptions{Level: level}
+	if c.Log.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (m Money) MarshalText() ([]byte, error) {
+	if m == (Money{}) {
+		return []byte{}, nil
+	}
+	return []byte(money.Money(m).String()), nil
+}
+
+func (m *Money) UnmarshalText(text []byte) error {
+	fields := strings.Fields(string(text))
+	switch len(fields) {
+	case 0:
+		*m = Money{}
+		return nil
+	case 2:
+		if _, err := money.LookupCurrency(fields[1]); err != nil {
+			return err
+		}
+		parsed, err := money.Parse(fields[0], fields[1])
+		if err != nil {
+			return err
+		}
+		*m = Money(parsed)
+		return nil
+	}
+	return fmt.Errorf("%w: want an amount and a currency such as \"25.00 USD\", got %q", money.ErrInvalidAmount, text)
+}
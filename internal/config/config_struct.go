@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// StorageMemory keeps bookings in process memory; it is the only backend.
+const StorageMemory = "memory"
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config is the server's configuration. Load layers it from Default, a YAML
+// or JSON file, environment variables and command-line flags, in that order.
+type Config struct {
+	Server        Server        `json:"server" yaml:"server"`
+	Storage       Storage       `json:"storage" yaml:"storage"`
+	RatesFile     string        `json:"rates_file" yaml:"rates_file"` // "currency,rate" CSV, the bundled rates when empty
+	Pricing       Pricing       `json:"pricing" yaml:"pricing"`
+	Refunds       Refunds       `json:"refunds" yaml:"refunds"`
+	Holds         Holds         `json:"holds" yaml:"holds"`
+	Workers       Workers       `json:"workers" yaml:"workers"`
+	FrequentFlyer FrequentFlyer `json:"frequent_flyer" yaml:"frequent_flyer"`
+	Inventory     Inventory     `json:"inventory" yaml:"inventory"`
+	Webhooks      Webhooks      `json:"webhooks" yaml:"webhooks"`
+	Log           Log           `json:"log" yaml:"log"`
+}
+
+type Server struct {
+	Addr            string   `json:"addr" yaml:"addr"`                         // host:port to listen on
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"` // how long in-flight requests may drain
+}
+
+type Storage struct {
+	Backend string `json:"backend" yaml:"backend"`
+	DSN     string `json:"dsn" yaml:"dsn"`
+}
+
+// Pricing are the booking window thresholds, see flight.Pricing.
+type Pricing struct {
+	EarlyDays            int   `json:"early_days" yaml:"early_days"`
+	EarlyDiscountPercent int64 `json:"early_discount_percent" yaml:"early_discount_percent"`
+	LateDays             int   `json:"late_days" yaml:"late_days"`
+	LateSurchargePercent int64 `json:"late_surcharge_percent" yaml:"late_surcharge_percent"`
+}
+
+// Refunds are the fare rules of cabins without fare families, see
+// fare.Rules.
+type Refunds struct {
+	Refundable        bool  `json:"refundable" yaml:"refundable"`
+	RefundPercent     int64 `json:"refund_percent" yaml:"refund_percent"`
+	LateRefundPercent int64 `json:"late_refund_percent" yaml:"late_refund_percent"`
+	Changeable        bool  `json:"changeable" yaml:"changeable"`
+	ChangeFee         Money `json:"change_fee" yaml:"change_fee"` // none when empty
+}
+
+// Holds are how long prices and responses are held for a client.
+type Holds struct {
+	QuoteTTL          Duration `json:"quote_ttl" yaml:"quote_ttl"`
+	IdempotencyWindow Duration `json:"idempotency_window" yaml:"idempotency_window"`
+}
+
+// Workers are how often background jobs run.
+type Workers struct {
+	ScheduleInterval Duration `json:"schedule_interval" yaml:"schedule_interval"` // materializing schedules up to the horizon
+	ReapInterval     Duration `json:"reap_interval" yaml:"reap_interval"`         // forgetting expired quotes and idempotency keys
+}
+
+type FrequentFlyer struct {
+	MinBookings     int   `json:"min_bookings" yaml:"min_bookings"`
+	DiscountPercent int64 `json:"discount_percent" yaml:"discount_percent"`
+}
+
+// Inventory is how bookings take seats, see usecase.InventoryMode.
+type Inventory struct {
+	Mode string `json:"mode" yaml:"mode"` // locked, optimistic or actor
+}
+
+// Webhooks is how events are delivered to webhook subscribers, see
+// webhook.RetryPolicy.
+type Webhooks struct {
+	MaxAttempts    int      `json:"max_attempts" yaml:"max_attempts"`
+	InitialBackoff Duration `json:"initial_backoff" yaml:"initial_backoff"` // wait after the first failed attempt, doubling after each one
+	MaxBackoff     Duration `json:"max_backoff" yaml:"max_backoff"`
+	Timeout        Duration `json:"timeout" yaml:"timeout"`             // per attempt
+	AllowPrivate   bool     `json:"allow_private" yaml:"allow_private"` // plain http and private addresses, for local development
+}
+
+type Log struct {
+	Level  string `json:"level" yaml:"level"`   // debug, info, warn or error
+	Format string `json:"format" yaml:"format"` // text or json
+}
+
+// Duration is a time.Duration written as a string such as "90s" or "24h".
+type Duration time.Duration
+
+// Money is a money.Money written as a string such as "25.00 USD", the zero
+// Money when empty.
+type Money money.Money
+
+// setting is one configuration value that environment variables and flags
+// can override. Key is its dotted path in the file and its flag name.
+type setting struct {
+	key string
+	env string
+	set func(c *Config, value string) error
+}
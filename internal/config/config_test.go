@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefault(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FlightPricing() != flight.DefaultPricing {
+		t.Errorf("expected the default pricing, got %+v", cfg.FlightPricing())
+	}
+	if cfg.RefundRules() != fare.DefaultRules {
+		t.Errorf("expected the default fare rules, got %+v", cfg.RefundRules())
+	}
+}
+
+func TestExampleFile(t *testing.T) {
+	cfg, err := Load([]string{"-config", "../../config.example.yaml"}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg != Default() {
+		t.Errorf("expected the example to show the defaults, got %+v", cfg)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+pricing:
+  early_days: 45
+holds:
+  quote_ttl: 10m
+log:
+  format: json
+`)
+
+	t.Run("Layers", func(t *testing.T) {
+		cfg, err := Load(
+			[]string{"-config", yamlFile, "-server.addr", ":9200", "-frequent_flyer.min_bookings=3"},
+			env(map[string]string{"LISTEN_ADDR": ":9100", "PRICING_EARLY_DAYS": "40", "IDEMPOTENCY_WINDOW": "1h"}),
+			io.Discard,
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Server.Addr != ":9200" {
+			t.Errorf("expected the flag to win, got %q", cfg.Server.Addr)
+		}
+		if cfg.Pricing.EarlyDays != 40 {
+			t.Errorf("expected the environment over the file, got %d", cfg.Pricing.EarlyDays)
+		}
+		if cfg.Holds.QuoteTTL != Duration(10*time.Minute) || cfg.Log.Format != "json" {
+			t.Errorf("expected the file's values, got %+v", cfg)
+		}
+		if cfg.Holds.IdempotencyWindow != Duration(time.Hour) || cfg.FrequentFlyer.MinBookings != 3 {
+			t.Errorf("expected the overrides, got %+v", cfg)
+		}
+		if cfg.Pricing.LateDays != 7 || cfg.Storage.Backend != StorageMemory {
+			t.Errorf("expected defaults for the rest, got %+v", cfg)
+		}
+	})
+
+	t.Run("FileFromEnvironment", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"refunds": {"refundable": true, "refund_percent": 90}}`)
+		cfg, err := Load(nil, env(map[string]string{"CONFIG_FILE": path}), io.Discard)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Refunds.RefundPercent != 90 || cfg.Refunds.LateRefundPercent != 80 {
+			t.Errorf("unexpected refunds %+v", cfg.Refunds)
+		}
+	})
+
+	t.Run("ReportsEveryProblem", func(t *testing.T) {
+		path := writeFile(t, "config.yml", `
+storage:
+  backend: postgres
+log:
+  level: loud
+inventory:
+  mode: fast
+webhooks:
+  initial_backoff: 1m
+  max_backoff: 1s
+holds:
+  quote_ttl: 0s
+pricing:
+  early_days: 5
+`)
+		_, err := Load([]string{"-config", path}, env(nil), io.Discard)
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("expected ErrInvalidConfig, got %v", err)
+		}
+		for _, key := range []string{"storage.backend", "log.level", "inventory.mode", "webhooks.max_backoff", "holds.quote_ttl", "pricing"} {
+			if !strings.Contains(err.Error(), key) {
+				t.Errorf("expected %s in %q", key, err)
+			}
+		}
+	})
+
+	t.Run("BadValues", func(t *testing.T) {
+		_, err := Load([]string{"-refunds.changeable=maybe"}, env(map[string]string{"QUOTE_TTL": "soon"}), io.Discard)
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("expected ErrInvalidConfig, got %v", err)
+		}
+		for _, key := range []string{"QUOTE_TTL", "-refunds.changeable"} {
+			if !strings.Contains(err.Error(), key) {
+				t.Errorf("expected %s in %q", key, err)
+			}
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", "server:\n  port: 80\n")
+		if _, err := Load([]string{"-config", path}, env(nil), io.Discard); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig, got %v", err)
+		}
+	})
+
+	t.Run("Help", func(t *testing.T) {
+		if _, err := Load([]string{"-help"}, env(nil), io.Discard); !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("expected flag.ErrHelp, got %v", err)
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	cfg := Default()
+	cfg.Pricing.EarlyDays = 60
+	cfg.FrequentFlyer.MinBookings = 2
+	cfg.Holds.QuoteTTL = Duration(time.Minute)
+	cfg.Inventory.Mode = "optimistic"
+	cfg.Webhooks.MaxAttempts = 3
+	cfg.Webhooks.Timeout = Duration(time.Second)
+	cfg.Webhooks.AllowPrivate = true
+	svc := usecase.NewService(nil, nil)
+	cfg.Apply(svc)
+	if svc.Pricing.EarlyDays != 60 || svc.FrequentFlyerBookings != 2 || svc.QuoteTTL != time.Minute ||
+		svc.Inventory != usecase.InventoryOptimistic {
+		t.Errorf("unexpected service settings %+v, %d, %v", svc.Pricing, svc.FrequentFlyerBookings, svc.QuoteTTL)
+	}
+	if svc.Webhooks.Retry.MaxAttempts != 3 || svc.Webhooks.Retry.MaxBackoff != webhook.DefaultMaxBackoff || svc.Webhooks.Client.Timeout != time.Second || !svc.Webhooks.AllowPrivate {
+		t.Errorf("unexpected webhook settings %+v, %v", svc.Webhooks.Retry, svc.Webhooks.Client.Timeout)
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var settings = []setting{
+	{"server.addr", "LISTEN_ADDR", field(func(c *Config) *string { return &c.Server.Addr }, parseString)},
+	{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", field(func(c *Config) *Duration { return &c.Server.ShutdownTimeout }, parseDuration)},
+	{"storage.backend", "STORAGE_BACKEND", field(func(c *Config) *string { return &c.Storage.Backend }, parseString)},
+	{"storage.dsn", "STORAGE_DSN", field(func(c *Config) *string { return &c.Storage.DSN }, parseString)},
+	{"rates_file", "RATES_FILE", field(func(c *Config) *string { return &c.RatesFile }, parseString)},
+	{"pricing.early_days", "PRICING_EARLY_DAYS", field(func(c *Config) *int { return &c.Pricing.EarlyDays }, strconv.Atoi)},
+	{"pricing.early_discount_percent", "PRICING_EARLY_DISCOUNT_PERCENT", field(func(c *Config) *int64 { return &c.Pricing.EarlyDiscountPercent }, parseInt64)},
+	{"pricing.late_days", "PRICING_LATE_DAYS", field(func(c *Config) *int { return &c.Pricing.LateDays }, strconv.Atoi)},
+	{"pricing.late_surcharge_percent", "PRICING_LATE_SURCHARGE_PERCENT", field(func(c *Config) *int64 { return &c.Pricing.LateSurchargePercent }, parseInt64)},
+	{"refunds.refundable", "REFUNDS_REFUNDABLE", field(func(c *Config) *bool { return &c.Refunds.Refundable }, strconv.ParseBool)},
+	{"refunds.refund_percent", "REFUNDS_REFUND_PERCENT", field(func(c *Config) *int64 { return &c.Refunds.RefundPercent }, parseInt64)},
+	{"refunds.late_refund_percent", "REFUNDS_LATE_REFUND_PERCENT", field(func(c *Config) *int64 { return &c.Refunds.LateRefundPercent }, parseInt64)},
+	{"refunds.changeable", "REFUNDS_CHANGEABLE", field(func(c *Config) *bool { return &c.Refunds.Changeable }, strconv.ParseBool)},
+	{"refunds.change_fee", "REFUNDS_CHANGE_FEE", field(func(c *Config) *Money { return &c.Refunds.ChangeFee }, parseMoney)},
+	{"holds.quote_ttl", "QUOTE_TTL", field(func(c *Config) *Duration { return &c.Holds.QuoteTTL }, parseDuration)},
+	{"holds.idempotency_window", "IDEMPOTENCY_WINDOW", field(func(c *Config) *Duration { return &c.Holds.IdempotencyWindow }, parseDuration)},
+	{"workers.schedule_interval", "SCHEDULE_INTERVAL", field(func(c *Config) *Duration { return &c.Workers.ScheduleInterval }, parseDuration)},
+	{"workers.reap_interval", "REAP_INTERVAL", field(func(c *Config) *Duration { return &c.Workers.ReapInterval }, parseDuration)},
+	{"frequent_flyer.min_bookings", "FREQUENT_FLYER_MIN_BOOKINGS", field(func(c *Config) *int { return &c.FrequentFlyer.MinBookings }, strconv.Atoi)},
+	{"frequent_flyer.discount_percent", "FREQUENT_FLYER_DISCOUNT_PERCENT", field(func(c *Config) *int64 { return &c.FrequentFlyer.DiscountPercent }, parseInt64)},
+	{"inventory.mode", "INVENTORY_MODE", field(func(c *Config) *string { return &c.Inventory.Mode }, parseString)},
+	{"webhooks.max_attempts", "WEBHOOKS_MAX_ATTEMPTS", field(func(c *Config) *int { return &c.Webhooks.MaxAttempts }, strconv.Atoi)},
+	{"webhooks.initial_backoff", "WEBHOOKS_INITIAL_BACKOFF", field(func(c *Config) *Duration { return &c.Webhooks.InitialBackoff }, parseDuration)},
+	{"webhooks.max_backoff", "WEBHOOKS_MAX_BACKOFF", field(func(c *Config) *Duration { return &c.Webhooks.MaxBackoff }, parseDuration)},
+	{"webhooks.timeout", "WEBHOOKS_TIMEOUT", field(func(c *Config) *Duration { return &c.Webhooks.Timeout }, parseDuration)},
+	{"webhooks.allow_private", "WEBHOOKS_ALLOW_PRIVATE", field(func(c *Config) *bool { return &c.Webhooks.AllowPrivate }, strconv.ParseBool)},
+	{"log.level", "LOG_LEVEL", field(func(c *Config) *string { return &c.Log.Level }, parseString)},
+	{"log.format", "LOG_FORMAT", field(func(c *Config) *string { return &c.Log.Format }, parseString)},
+}
+
+// field returns a setter parsing a value into the field get points to.
+func field[T any](get func(*Config) *T, parse func(string) (T, error)) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		v, err := parse(value)
+		if err != nil {
+			return err
+		}
+		*get(c) = v
+		return nil
+	}
+}
+
+func parseString(s string) (string, error) { return s, nil }
+
+func parseInt64(s string) (int64, error) { return strconv.ParseInt(s, 10, 64) }
+
+func parseDuration(s string) (Duration, error) {
+	var d Duration
+	err := d.UnmarshalText([]byte(s))
+	return d, err
+}
+
+func parseMoney(s string) (Money, error) {
+	var m Money
+	err := m.UnmarshalText([]byte(s))
+	return m, err
+}
+
+// readFile decodes a YAML or JSON file, by extension, over c. Unknown keys
+// are errors so typos do not pass silently.
+func readFile(path string, c *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	case ".json":
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("unsupported file type %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+func logLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("must be debug, info, warn or error, got %q", name)
+	}
+	return level, nil
+}
+
+// report lists every problem, one per line, under ErrInvalidConfig.
+func report(problems []error) error {
+	lines := make([]string, len(problems))
+	for i, p := range problems {
+		lines[i] = "  " + p.Error()
+	}
+	return fmt.Errorf("%w:\n%s", ErrInvalidConfig, strings.Join(lines, "\n"))
+}
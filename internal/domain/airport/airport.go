@@ -0,0 +1,189 @@
+package airport
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // zone data must not depend on the host
+)
+
+//go:embed airports.csv
+var bundledAirports []byte
+
+func NewRegistry() *Registry {
+	return &Registry{airports: make(map[string]*Airport)}
+}
+
+// NewDefaultRegistry returns a registry loaded with the bundled dataset.
+func NewDefaultRegistry() *Registry {
+	airports, err := LoadCSV(bytes.NewReader(bundledAirports))
+	if err != nil {
+		panic(err)
+	}
+	r := NewRegistry()
+	for _, a := range airports {
+		if err := r.Add(a); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// LoadCSV reads airports with the header
+// code,name,city,country,time_zone,latitude,longitude,metro.
+func LoadCSV(r io.Reader) ([]*Airport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 8
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAirport, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	airports := make([]*Airport, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		lat, err := strconv.ParseFloat(rec[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid latitude", ErrInvalidAirport, i+2)
+		}
+		lon, err := strconv.ParseFloat(rec[6], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid longitude", ErrInvalidAirport, i+2)
+		}
+		airports = append(airports, &Airport{
+			Code:      rec[0],
+			Name:      rec[1],
+			City:      rec[2],
+			Country:   rec[3],
+			TimeZone:  rec[4],
+			Latitude:  lat,
+			Longitude: lon,
+			Metro:     rec[7],
+		})
+	}
+	return airports, nil
+}
+
+// NormalizeCode trims and upper-cases an airport or metro code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Add validates an airport and adds or replaces it in the registry.
+func (r *Registry) Add(a *Airport) error {
+	a.Code = NormalizeCode(a.Code)
+	a.Metro = NormalizeCode(a.Metro)
+	if !isIATACode(a.Code) {
+		return fmt.Errorf("%w: code %q is not a 3-letter IATA code", ErrInvalidAirport, a.Code)
+	}
+	if a.Metro != "" && !isIATACode(a.Metro) {
+		return fmt.Errorf("%w: metro %q is not a 3-letter IATA code", ErrInvalidAirport, a.Metro)
+	}
+	loc, err := time.LoadLocation(a.TimeZone)
+	if err != nil || a.TimeZone == "" {
+		return fmt.Errorf("%w: unknown time zone %q", ErrInvalidAirport, a.TimeZone)
+	}
+	if a.Latitude < -90 || a.Latitude > 90 || a.Longitude < -180 || a.Longitude > 180 {
+		return fmt.Errorf("%w: coordinates out of range", ErrInvalidAirport)
+	}
+	a.location = loc
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.airports[a.Code] = a
+	return nil
+}
+
+func (r *Registry) Get(code string) (*Airport, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.airports[NormalizeCode(code)]
+	if !ok {
+		return nil, ErrAirportNotFound
+	}
+	return a, nil
+}
+
+// List returns all airports ordered by code.
+func (r *Registry) List() []*Airport {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]*Airport, 0, len(r.airports))
+	for _, a := range r.airports {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// Resolve expands a code into airport codes: an airport code resolves to
+// itself and a metropolitan area code to all of its airports. Unknown codes
+// resolve to nil.
+func (r *Registry) Resolve(code string) []string {
+	code = NormalizeCode(code)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.airports[code]; ok {
+		return []string{code}
+	}
+	var result []string
+	for _, a := range r.airports {
+		if a.Metro == code {
+			result = append(result, a.Code)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Location returns the time zone of an airport or metropolitan area, or UTC
+// when the code is unknown.
+func (r *Registry) Location(code string) *time.Location {
+	codes := r.Resolve(code)
+	if len(codes) == 0 {
+		return time.UTC
+	}
+	a, err := r.Get(codes[0])
+	if err != nil {
+		return time.UTC
+	}
+	return a.Location()
+}
+
+// Distance returns the great-circle distance in kilometres between two
+// airports.
+func (r *Registry) Distance(from, to string) (float64, error) {
+	a, err := r.Get(from)
+	if err != nil {
+		return 0, err
+	}
+	b, err := r.Get(to)
+	if err != nil {
+		return 0, err
+	}
+	return a.DistanceTo(b), nil
+}
+
+func (a *Airport) Location() *time.Location {
+	if a.location == nil {
+		return time.UTC
+	}
+	return a.location
+}
+
+// DistanceTo returns the haversine distance to b in kilometres.
+func (a *Airport) DistanceTo(b *Airport) float64 {
+	lat1, lat2 := toRadians(a.Latitude), toRadians(b.Latitude)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
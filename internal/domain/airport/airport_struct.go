@@ -0,0 +1,33 @@
+package airport
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances.
+const earthRadiusKm = 6371.0
+
+var (
+	ErrAirportNotFound = errors.New("airport not found")
+	ErrInvalidAirport  = errors.New("invalid airport")
+)
+
+type Airport struct {
+	Code      string  `json:"code"` // IATA, e.g. "BKK"
+	Name      string  `json:"name"`
+	City      string  `json:"city"`
+	Country   string  `json:"country"`   // ISO 3166-1 alpha-2
+	TimeZone  string  `json:"time_zone"` // IANA name, e.g. "Asia/Bangkok"
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Metro     string  `json:"metro,omitempty"` // IATA metropolitan area, e.g. "NYC"
+
+	location *time.Location
+}
+
+type Registry struct {
+	mu       sync.RWMutex
+	airports map[string]*Airport
+}
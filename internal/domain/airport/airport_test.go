@@ -0,0 +1,134 @@
+package airport
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("AddAndGet", func(t *testing.T) {
+		r := NewRegistry()
+		err := r.Add(&Airport{Code: " bkk", Name: "Suvarnabhumi Airport", City: "Bangkok", Country: "TH", TimeZone: "Asia/Bangkok", Latitude: 13.69, Longitude: 100.75})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		a, err := r.Get("BKK ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if a.Code != "BKK" || a.TimeZone != "Asia/Bangkok" || a.Location().String() != "Asia/Bangkok" {
+			t.Errorf("unexpected airport %+v", a)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := map[string]*Airport{
+			"UnknownTimeZone": {Code: "XXX", TimeZone: "Mars/Olympus"},
+			"EmptyTimeZone":   {Code: "XXX"},
+			"EmptyCode":       {TimeZone: "UTC"},
+			"LongCode":        {Code: "XXXX", TimeZone: "UTC"},
+			"DigitsInCode":    {Code: "X1X", TimeZone: "UTC"},
+			"BadMetro":        {Code: "XXX", TimeZone: "UTC", Metro: "NY"},
+			"BadLatitude":     {Code: "XXX", TimeZone: "UTC", Latitude: 91},
+			"BadLongitude":    {Code: "XXX", TimeZone: "UTC", Longitude: -181},
+		}
+		for name, a := range cases {
+			if err := NewRegistry().Add(a); !errors.Is(err, ErrInvalidAirport) {
+				t.Errorf("%s: expected ErrInvalidAirport, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("UnknownAirport", func(t *testing.T) {
+		r := NewRegistry()
+		if _, err := r.Get("NOPE"); !errors.Is(err, ErrAirportNotFound) {
+			t.Errorf("expected ErrAirportNotFound, got %v", err)
+		}
+		if r.Location("NOPE") != time.UTC {
+			t.Errorf("expected UTC for unknown airport")
+		}
+		if r.Resolve("NOPE") != nil {
+			t.Errorf("expected nil resolution for unknown code")
+		}
+	})
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	r := NewDefaultRegistry()
+
+	t.Run("BundledDataset", func(t *testing.T) {
+		a, err := r.Get("JFK")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if a.City != "New York" || a.Country != "US" || a.Metro != "NYC" {
+			t.Errorf("unexpected airport %+v", a)
+		}
+		if r.Location("JFK").String() != "America/New_York" {
+			t.Errorf("expected JFK in America/New_York, got %s", r.Location("JFK"))
+		}
+		if len(r.List()) < 30 {
+			t.Errorf("expected bundled dataset to be loaded, got %d airports", len(r.List()))
+		}
+	})
+
+	t.Run("MetroResolution", func(t *testing.T) {
+		got := strings.Join(r.Resolve("nyc"), ",")
+		if got != "EWR,JFK,LGA" {
+			t.Errorf("expected EWR,JFK,LGA, got %s", got)
+		}
+		if got := r.Resolve("JFK"); len(got) != 1 || got[0] != "JFK" {
+			t.Errorf("expected airport code to resolve to itself, got %v", got)
+		}
+		if r.Location("LON").String() != "Europe/London" {
+			t.Errorf("expected metro location Europe/London, got %s", r.Location("LON"))
+		}
+	})
+
+	t.Run("Distance", func(t *testing.T) {
+		d, err := r.Distance("JFK", "LAX")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Published great-circle distance is about 3,983 km
+		if math.Abs(d-3983) > 20 {
+			t.Errorf("expected about 3983 km, got %.1f", d)
+		}
+		if d2, _ := r.Distance("LAX", "JFK"); math.Abs(d-d2) > 1e-9 {
+			t.Errorf("distance should be symmetric")
+		}
+		if _, err := r.Distance("JFK", "XYZ"); !errors.Is(err, ErrAirportNotFound) {
+			t.Errorf("expected ErrAirportNotFound, got %v", err)
+		}
+	})
+}
+
+func TestLoadCSV(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		data := "code,name,city,country,time_zone,latitude,longitude,metro\n" +
+			"CNX,Chiang Mai International Airport,Chiang Mai,TH,Asia/Bangkok,18.7668,98.9626,\n"
+		airports, err := LoadCSV(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(airports) != 1 || airports[0].Code != "CNX" || airports[0].Latitude != 18.7668 {
+			t.Errorf("unexpected airports %+v", airports)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		bad := []string{
+			"code,name\nCNX,Chiang Mai\n",
+			"code,name,city,country,time_zone,latitude,longitude,metro\nCNX,a,b,TH,Asia/Bangkok,north,98.9,\n",
+			"code,name,city,country,time_zone,latitude,longitude,metro\nCNX,a,b,TH,Asia/Bangkok,18.7,east,\n",
+		}
+		for _, data := range bad {
+			if _, err := LoadCSV(strings.NewReader(data)); !errors.Is(err, ErrInvalidAirport) {
+				t.Errorf("expected ErrInvalidAirport for %q, got %v", data, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package airport
+
+import "math"
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func isIATACode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
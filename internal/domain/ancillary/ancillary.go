@@ -0,0 +1,187 @@
+package ancillary
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func NewCatalogue() *Catalogue {
+	return &Catalogue{products: make(map[string]*Product), sold: make(map[string]map[string]int)}
+}
+
+// NormalizeCode trims and upper-cases a product or airport code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Set validates a product and adds or replaces it. Units already sold count
+// against a replaced product's inventory.
+func (c *Catalogue) Set(p *Product) error {
+	p.Code = NormalizeCode(p.Code)
+	p.Price.Currency = money.NormalizeCode(p.Price.Currency)
+	for i := range p.Airports {
+		p.Airports[i] = NormalizeCode(p.Airports[i])
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.products[p.Code] = p
+	return nil
+}
+
+func (c *Catalogue) Delete(code string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	code = NormalizeCode(code)
+	if _, ok := c.products[code]; !ok {
+		return ErrProductNotFound
+	}
+	delete(c.products, code)
+	return nil
+}
+
+func (c *Catalogue) Get(code string) (*Product, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.products[NormalizeCode(code)]
+	if !ok {
+		return nil, ErrProductNotFound
+	}
+	return p, nil
+}
+
+// List returns all products ordered by code.
+func (c *Catalogue) List() []*Product {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list()
+}
+
+// Offers returns the products on sale for trip, ordered by code, leaving out
+// those sold out on its flight.
+func (c *Catalogue) Offers(trip Trip) []Offer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	offers := make([]Offer, 0)
+	for _, p := range c.list() {
+		if !p.appliesTo(trip) {
+			continue
+		}
+		offer := Offer{Product: p}
+		if p.Inventory > 0 {
+			remaining := c.remaining(p, trip.FlightID)
+			if remaining == 0 {
+				continue
+			}
+			offer.Remaining = &remaining
+		}
+		offers = append(offers, offer)
+	}
+	return offers
+}
+
+// Preview prices reqs for a booking already holding owned the way Reserve
+// would, without taking any inventory.
+func (c *Catalogue) Preview(trip Trip, owned []Item, reqs []Request, currency string, convert Converter, now time.Time) ([]Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.price(trip, owned, reqs, currency, convert, now)
+}
+
+// Reserve prices reqs in currency and takes their units from the flight's
+// inventory. Either every request is reserved or, on error, none is.
+func (c *Catalogue) Reserve(trip Trip, owned []Item, reqs []Request, currency string, convert Converter, now time.Time) ([]Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items, err := c.price(trip, owned, reqs, currency, convert, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > 0 && c.sold[trip.FlightID] == nil {
+		c.sold[trip.FlightID] = make(map[string]int)
+	}
+	for _, item := range items {
+		c.sold[trip.FlightID][item.Code] += item.Quantity
+	}
+	return items, nil
+}
+
+// Release gives the units of items back to the flight's inventory.
+func (c *Catalogue) Release(flightID string, items []Item) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sold := c.sold[flightID]
+	for _, item := range items {
+		if sold[item.Code] -= item.Quantity; sold[item.Code] <= 0 {
+			delete(sold, item.Code)
+		}
+	}
+}
+
+// Move carries a booking's items over to another flight, class or seat.
+// Either every item is still offered for to, with inventory left on its
+// flight, and all units move there, or nothing changes. Items whose product
+// has been deleted move without checks.
+func (c *Catalogue) Move(from, to Trip, items []Item) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	quantities := make(map[string]int)
+	for _, item := range items {
+		quantities[item.Code] += item.Quantity
+	}
+	for code, quantity := range quantities {
+		p, ok := c.products[code]
+		if !ok {
+			continue
+		}
+		if !p.appliesTo(to) {
+			return fmt.Errorf("%w: %s", ErrNotApplicable, code)
+		}
+		if to.FlightID != from.FlightID && p.Inventory > 0 && c.remaining(p, to.FlightID) < quantity {
+			return fmt.Errorf("%w: %s", ErrSoldOut, code)
+		}
+	}
+	if to.FlightID == from.FlightID {
+		return nil
+	}
+	if len(quantities) > 0 && c.sold[to.FlightID] == nil {
+		c.sold[to.FlightID] = make(map[string]int)
+	}
+	for code, quantity := range quantities {
+		if c.sold[from.FlightID][code] -= quantity; c.sold[from.FlightID][code] <= 0 {
+			delete(c.sold[from.FlightID], code)
+		}
+		c.sold[to.FlightID][code] += quantity
+	}
+	return nil
+}
+
+func (p *Product) Validate() error {
+	if p.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidProduct)
+	}
+	switch p.Kind {
+	case KindCheckedBag, KindMeal, KindLounge, KindPriorityBoarding, KindSeatSelection:
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidProduct, p.Kind)
+	}
+	switch {
+	case p.Price.Amount < 0:
+		return fmt.Errorf("%w: price must not be negative", ErrInvalidProduct)
+	case p.Inventory < 0 || p.MaxPerBooking < 0:
+		return fmt.Errorf("%w: inventory and max_per_booking must not be negative", ErrInvalidProduct)
+	case len(p.Seats) > 0 && p.Kind != KindSeatSelection:
+		return fmt.Errorf("%w: seats only apply to seat selection", ErrInvalidProduct)
+	case p.Kind == KindSeatSelection && p.MaxPerBooking > 1:
+		return fmt.Errorf("%w: a booking holds one seat", ErrInvalidProduct)
+	}
+	if _, err := money.LookupCurrency(p.Price.Currency); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+	}
+	return nil
+}
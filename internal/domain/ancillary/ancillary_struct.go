@@ -0,0 +1,90 @@
+package ancillary
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+const (
+	KindCheckedBag       = "checked_bag"
+	KindMeal             = "meal"
+	KindLounge           = "lounge"
+	KindPriorityBoarding = "priority_boarding"
+	KindSeatSelection    = "seat_selection" // only sold with a chosen seat
+)
+
+var (
+	ErrInvalidProduct  = errors.New("invalid ancillary")
+	ErrProductNotFound = errors.New("ancillary not found")
+	ErrNotApplicable   = errors.New("ancillary not available for this booking")
+	ErrSoldOut         = errors.New("ancillary sold out")
+	ErrLimitExceeded   = errors.New("ancillary limit per booking exceeded")
+)
+
+// Product is an extra sold with a booking at a fixed Price per unit. Empty Airports, FlightIDs and SeatClasses lists offer it everywhere;
+// Airports are matched against the departure airport. Seats limits a seat
+// selection product to the listed seats. Inventory caps the units sold per
+// flight and MaxPerBooking the units one booking may hold; zero means no
+// inventory limit and one unit per booking.
+type Product struct {
+	Code          string      `json:"code"`
+	Name          string      `json:"name,omitempty"`
+	Kind          string      `json:"kind"`
+	Price         money.Money `json:"price"`
+	Airports      []string    `json:"airports,omitempty"`
+	FlightIDs     []string    `json:"flight_ids,omitempty"`
+	SeatClasses   []string    `json:"seat_classes,omitempty"`
+	Seats         []string    `json:"seats,omitempty"`
+	Inventory     int         `json:"inventory,omitempty"`
+	MaxPerBooking int         `json:"max_per_booking,omitempty"`
+	Refundable    bool        `json:"refundable"` // refunded in full on cancellation
+}
+
+// Trip is the booking ancillaries are sold with. SeatSelected is set when
+// the passenger chose SeatID, which only happens at booking time.
+type Trip struct {
+	FlightID     string
+	Origin       string
+	Destination  string
+	SeatClass    string
+	SeatID       string
+	SeatSelected bool
+}
+
+// Request asks for Quantity units of a product, one when zero.
+type Request struct {
+	Code     string `json:"code"`
+	Quantity int    `json:"quantity,omitempty"`
+}
+
+// Item is a purchased ancillary. Amount is the price of all its units in the
+// fare's currency.
+type Item struct {
+	Code        string      `json:"code"`
+	Name        string      `json:"name,omitempty"`
+	Kind        string      `json:"kind"`
+	Quantity    int         `json:"quantity"`
+	Amount      money.Money `json:"amount"`
+	Refundable  bool        `json:"refundable"`
+	PurchasedAt time.Time   `json:"purchased_at"`
+}
+
+// Offer is a product on sale for a trip. Remaining is nil when the product
+// has no inventory limit.
+type Offer struct {
+	*Product
+	Remaining *int `json:"remaining,omitempty"`
+}
+
+// Converter converts money into another currency.
+type Converter func(m money.Money, currency string) (money.Money, error)
+
+// Catalogue holds the products by code and the units sold per flight.
+type Catalogue struct {
+	mu       sync.Mutex
+	products map[string]*Product
+	sold     map[string]map[string]int // flight ID -> product code -> units
+}
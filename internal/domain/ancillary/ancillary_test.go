@@ -0,0 +1,257 @@
+package ancillary
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// toTHB converts at 40 THB per USD.
+func toTHB(m money.Money, currency string) (money.Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+	if currency != "THB" {
+		return money.Money{}, money.ErrRateNotFound
+	}
+	return money.New(m.MulRatio(40, 1).Amount, "THB"), nil
+}
+
+func newCatalogue(t *testing.T) *Catalogue {
+	t.Helper()
+	c := NewCatalogue()
+	for _, p := range []*Product{
+		{Code: "bag20", Kind: KindCheckedBag, Price: money.New(2500, "USD"), MaxPerBooking: 3, Refundable: true},
+		{Code: "MEAL", Kind: KindMeal, Price: money.New(35000, "THB"), Airports: []string{"bkk"}},
+		{Code: "LOUNGE", Kind: KindLounge, Price: money.New(4000, "USD"), Inventory: 2, SeatClasses: []string{"Business"}},
+		{Code: "XLEG", Kind: KindSeatSelection, Price: money.New(1500, "USD"), Seats: []string{"A1"}},
+	} {
+		if err := c.Set(p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]*Product{
+		"NoCode":            {Kind: KindMeal, Price: money.New(500, "USD")},
+		"UnknownKind":       {Code: "X", Kind: "wifi", Price: money.New(500, "USD")},
+		"NegativePrice":     {Code: "X", Kind: KindMeal, Price: money.New(-500, "USD")},
+		"NoCurrency":        {Code: "X", Kind: KindMeal, Price: money.New(500, "")},
+		"NegativeInventory": {Code: "X", Kind: KindMeal, Price: money.New(500, "USD"), Inventory: -1},
+		"SeatsOnMeal":       {Code: "X", Kind: KindMeal, Price: money.New(500, "USD"), Seats: []string{"A1"}},
+		"TwoSeats":          {Code: "X", Kind: KindSeatSelection, Price: money.New(500, "USD"), MaxPerBooking: 2},
+	}
+	for name, p := range cases {
+		if err := NewCatalogue().Set(p); !errors.Is(err, ErrInvalidProduct) {
+			t.Errorf("%s: expected ErrInvalidProduct, got %v", name, err)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	now := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	economy := Trip{FlightID: "FL1", Origin: "BKK", SeatClass: "Economy"}
+	business := Trip{FlightID: "FL1", Origin: "BKK", SeatClass: "Business"}
+
+	t.Run("PricesInFareCurrency", func(t *testing.T) {
+		c := newCatalogue(t)
+		items, err := c.Reserve(economy, nil, []Request{{Code: "bag20", Quantity: 2}, {Code: "MEAL"}}, "THB", toTHB, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(items) != 2 {
+			t.Fatalf("expected 2 items, got %+v", items)
+		}
+		if items[0].Code != "BAG20" || items[0].Quantity != 2 || items[0].Amount != money.FromFloat(2000, "THB") || !items[0].Refundable {
+			t.Errorf("unexpected bag item %+v", items[0])
+		}
+		if items[1].Amount != money.FromFloat(350, "THB") || !items[1].PurchasedAt.Equal(now) {
+			t.Errorf("unexpected meal item %+v", items[1])
+		}
+		if total := Total(items, "THB"); total != money.FromFloat(2350, "THB") {
+			t.Errorf("expected total 2350, got %v", total)
+		}
+		if refundable := RefundableTotal(items, "THB"); refundable != money.FromFloat(2000, "THB") {
+			t.Errorf("expected refundable 2000, got %v", refundable)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		c := newCatalogue(t)
+		for name, tc := range map[string]struct {
+			trip Trip
+			code string
+		}{
+			"Airport":        {Trip{FlightID: "FL2", Origin: "HKT", SeatClass: "Economy"}, "MEAL"},
+			"SeatClass":      {economy, "LOUNGE"},
+			"SeatNotChosen":  {economy, "XLEG"},
+			"OtherSeatChose": {Trip{FlightID: "FL1", Origin: "BKK", SeatClass: "Economy", SeatID: "A2", SeatSelected: true}, "XLEG"},
+		} {
+			if _, err := c.Reserve(tc.trip, nil, []Request{{Code: tc.code}}, "USD", toTHB, now); !errors.Is(err, ErrNotApplicable) {
+				t.Errorf("%s: expected ErrNotApplicable, got %v", name, err)
+			}
+		}
+		chosen := Trip{FlightID: "FL1", Origin: "BKK", SeatClass: "Economy", SeatID: "A1", SeatSelected: true}
+		if _, err := c.Reserve(chosen, nil, []Request{{Code: "XLEG"}}, "USD", toTHB, now); err != nil {
+			t.Errorf("expected the chosen seat to be sold, got %v", err)
+		}
+		if _, err := c.Reserve(economy, nil, []Request{{Code: "WIFI"}}, "USD", toTHB, now); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("expected ErrProductNotFound, got %v", err)
+		}
+	})
+
+	t.Run("LimitPerBooking", func(t *testing.T) {
+		c := newCatalogue(t)
+		owned := []Item{{Code: "BAG20", Quantity: 2}}
+		if _, err := c.Reserve(economy, owned, []Request{{Code: "bag20"}, {Code: "BAG20"}}, "USD", toTHB, now); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("expected ErrLimitExceeded, got %v", err)
+		}
+		if _, err := c.Reserve(economy, owned, []Request{{Code: "BAG20"}}, "USD", toTHB, now); err != nil {
+			t.Errorf("expected a third bag to be sold, got %v", err)
+		}
+		if _, err := c.Reserve(economy, nil, []Request{{Code: "BAG20", Quantity: -1}}, "USD", toTHB, now); !errors.Is(err, ErrInvalidProduct) {
+			t.Errorf("expected ErrInvalidProduct, got %v", err)
+		}
+	})
+
+	t.Run("InventoryIsAllOrNothing", func(t *testing.T) {
+		c := newCatalogue(t)
+		if _, err := c.Reserve(business, nil, []Request{{Code: "LOUNGE"}}, "USD", toTHB, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// The lounge pass is fine but the meal has no USD price, so nothing is taken.
+		if _, err := c.Reserve(business, nil, []Request{{Code: "LOUNGE"}, {Code: "MEAL"}}, "USD", toTHB, now); err == nil {
+			t.Fatal("expected a conversion error")
+		}
+		offers := c.Offers(business)
+		if got := remainingOf(offers, "LOUNGE"); got != 1 {
+			t.Errorf("expected 1 lounge pass left, got %d", got)
+		}
+		if _, err := c.Reserve(business, nil, []Request{{Code: "LOUNGE"}}, "USD", toTHB, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.Reserve(business, nil, []Request{{Code: "LOUNGE"}}, "USD", toTHB, now); !errors.Is(err, ErrSoldOut) {
+			t.Errorf("expected ErrSoldOut, got %v", err)
+		}
+		if remainingOf(c.Offers(business), "LOUNGE") != -1 {
+			t.Error("expected a sold out product to be left out of the offers")
+		}
+		// Another flight has its own inventory.
+		if _, err := c.Reserve(Trip{FlightID: "FL2", Origin: "BKK", SeatClass: "Business"}, nil, []Request{{Code: "LOUNGE"}}, "USD", toTHB, now); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		c.Release("FL1", []Item{{Code: "LOUNGE", Quantity: 1}})
+		if got := remainingOf(c.Offers(business), "LOUNGE"); got != 1 {
+			t.Errorf("expected a released pass to be back on sale, got %d", got)
+		}
+	})
+
+	t.Run("PreviewTakesNothing", func(t *testing.T) {
+		c := newCatalogue(t)
+		for i := 0; i < 3; i++ {
+			if _, err := c.Preview(business, nil, []Request{{Code: "LOUNGE", Quantity: 1}}, "USD", toTHB, now); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if got := remainingOf(c.Offers(business), "LOUNGE"); got != 2 {
+			t.Errorf("expected 2 lounge passes left, got %d", got)
+		}
+	})
+
+	t.Run("ConcurrentReservations", func(t *testing.T) {
+		c := newCatalogue(t)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		sold := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.Reserve(business, nil, []Request{{Code: "LOUNGE"}}, "USD", toTHB, now); err == nil {
+					mu.Lock()
+					sold++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if sold != 2 {
+			t.Errorf("expected exactly 2 passes sold, got %d", sold)
+		}
+	})
+}
+
+func TestCatalogue(t *testing.T) {
+	c := newCatalogue(t)
+	if len(c.List()) != 4 || c.List()[0].Code != "BAG20" {
+		t.Errorf("expected products ordered by code, got %+v", c.List())
+	}
+	if p, err := c.Get("bag20"); err != nil || p.Code != "BAG20" {
+		t.Errorf("expected BAG20, got %+v, %v", p, err)
+	}
+	if err := c.Delete("bag20"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := c.Get("BAG20"); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+	if err := c.Delete("BAG20"); !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+}
+
+// remainingOf returns the units left of code in offers, 0 when unlimited and
+// -1 when it is not offered.
+func remainingOf(offers []Offer, code string) int {
+	for _, o := range offers {
+		if o.Code == code {
+			if o.Remaining == nil {
+				return 0
+			}
+			return *o.Remaining
+		}
+	}
+	return -1
+}
+
+func TestMove(t *testing.T) {
+	now := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	fl1 := Trip{FlightID: "FL1", Origin: "BKK", SeatClass: "Business"}
+	fl2 := Trip{FlightID: "FL2", Origin: "BKK", SeatClass: "Business"}
+	c := newCatalogue(t)
+	items, err := c.Reserve(fl1, nil, []Request{{Code: "LOUNGE", Quantity: 1}, {Code: "BAG20"}}, "USD", toTHB, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Reserve(fl2, nil, []Request{{Code: "LOUNGE", Quantity: 1}}, "USD", toTHB, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	downgrade := Trip{FlightID: "FL1", Origin: "BKK", SeatClass: "Economy"}
+	if err := c.Move(fl1, downgrade, items); !errors.Is(err, ErrNotApplicable) {
+		t.Errorf("expected ErrNotApplicable, got %v", err)
+	}
+	if err := c.Move(fl1, fl2, items); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := remainingOf(c.Offers(fl1), "LOUNGE"); got != 2 {
+		t.Errorf("expected the pass back on FL1, got %d left", got)
+	}
+	if got := remainingOf(c.Offers(fl2), "LOUNGE"); got != -1 {
+		t.Errorf("expected FL2 to be sold out, got %d left", got)
+	}
+	// FL2 is sold out now, so another booking cannot move its pass there.
+	other, _ := c.Reserve(fl1, nil, []Request{{Code: "LOUNGE"}}, "USD", toTHB, now)
+	if err := c.Move(fl1, fl2, other); !errors.Is(err, ErrSoldOut) {
+		t.Errorf("expected ErrSoldOut, got %v", err)
+	}
+	if got := remainingOf(c.Offers(fl1), "LOUNGE"); got != 1 {
+		t.Errorf("expected a failed move to leave FL1 alone, got %d left", got)
+	}
+}
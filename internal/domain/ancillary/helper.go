@@ -0,0 +1,125 @@
+package ancillary
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// list requires c.mu to be held.
+func (c *Catalogue) list() []*Product {
+	result := make([]*Product, 0, len(c.products))
+	for _, p := range c.products {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// remaining requires c.mu to be held.
+func (c *Catalogue) remaining(p *Product, flightID string) int {
+	if left := p.Inventory - c.sold[flightID][p.Code]; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// price checks reqs against the catalogue, the trip, the booking's limits and
+// the flight's inventory, merging requests for the same product. It requires
+// c.mu to be held.
+func (c *Catalogue) price(trip Trip, owned []Item, reqs []Request, currency string, convert Converter, now time.Time) ([]Item, error) {
+	var codes []string
+	quantities := make(map[string]int)
+	for _, r := range reqs {
+		code := NormalizeCode(r.Code)
+		quantity := r.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		if quantity < 0 {
+			return nil, fmt.Errorf("%w: %s quantity must be positive", ErrInvalidProduct, code)
+		}
+		if _, ok := quantities[code]; !ok {
+			codes = append(codes, code)
+		}
+		quantities[code] += quantity
+	}
+	held := make(map[string]int)
+	for _, item := range owned {
+		held[item.Code] += item.Quantity
+	}
+
+	items := make([]Item, 0, len(codes))
+	for _, code := range codes {
+		p, ok := c.products[code]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrProductNotFound, code)
+		}
+		if !p.appliesTo(trip) {
+			return nil, fmt.Errorf("%w: %s", ErrNotApplicable, code)
+		}
+		quantity := quantities[code]
+		if limit := max(p.MaxPerBooking, 1); held[code]+quantity > limit {
+			return nil, fmt.Errorf("%w: %s allows %d", ErrLimitExceeded, code, limit)
+		}
+		if p.Inventory > 0 && c.remaining(p, trip.FlightID) < quantity {
+			return nil, fmt.Errorf("%w: %s", ErrSoldOut, code)
+		}
+		unit, err := convert(p.Price, currency)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", code, err)
+		}
+		items = append(items, Item{
+			Code:        code,
+			Name:        p.Name,
+			Kind:        p.Kind,
+			Quantity:    quantity,
+			Amount:      money.New(unit.Amount*int64(quantity), unit.Currency),
+			Refundable:  p.Refundable,
+			PurchasedAt: now,
+		})
+	}
+	return items, nil
+}
+
+func (p *Product) appliesTo(trip Trip) bool {
+	if p.Kind == KindSeatSelection && (!trip.SeatSelected || !allowed(p.Seats, trip.SeatID)) {
+		return false
+	}
+	return allowed(p.Airports, NormalizeCode(trip.Origin)) && allowed(p.FlightIDs, trip.FlightID) &&
+		allowed(p.SeatClasses, trip.SeatClass)
+}
+
+func allowed(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Total adds up items in currency.
+func Total(items []Item, currency string) money.Money {
+	total := money.New(0, currency)
+	for _, item := range items {
+		total = money.New(total.Amount+item.Amount.Amount, currency)
+	}
+	return total
+}
+
+// RefundableTotal adds up the refundable items in currency.
+func RefundableTotal(items []Item, currency string) money.Money {
+	refundable := make([]Item, 0, len(items))
+	for _, item := range items {
+		if item.Refundable {
+			refundable = append(refundable, item)
+		}
+	}
+	return Total(refundable, currency)
+}
@@ -2,12 +2,14 @@ package booking
 
 import (
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
-func BookBestSeat(f Flight, seatClass string, bestSeat func([]Seat, int, int) Seat, calculatePrice func(base float64, departure, bookingDate time.Time, bookedRatio float64, isFrequentFlyer bool) float64, isFrequentFlyer bool) (Seat, float64, error) {
+func BookBestSeat(f Flight, seatClass string, bookingDate time.Time, bestSeat func([]Seat, int, int) Seat, calculatePrice func(base money.Money, departure, bookingDate time.Time, booked, total int, isFrequentFlyer bool) money.Money, isFrequentFlyer bool) (Seat, money.Money, error) {
 	mutex := f.GetMutex(seatClass)
 	if mutex == nil {
-		return nil, 0, ErrNoSeatAvailable
+		return nil, money.Money{}, ErrNoSeatAvailable
 	}
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -21,10 +23,14 @@ func BookBestSeat(f Flight, seatClass string, bestSeat func([]Seat, int, int) Se
 	}
 	totalSeats := len(seats)
 	if len(availableSeats) == 0 || totalSeats == 0 {
-		return nil, 0, ErrNoSeatAvailable
+		return nil, money.Money{}, ErrNoSeatAvailable
 	}
 
+	// bestSeat returns nil when a seat the passenger asked for is taken.
 	seat := bestSeat(availableSeats, f.GetColumns(seatClass), f.GetRows(seatClass))
+	if seat == nil {
+		return nil, money.Money{}, ErrSeatUnavailable
+	}
 	seat.SetBooked(true)
 
 	bookedCount := 0
@@ -33,9 +39,66 @@ func BookBestSeat(f Flight, seatClass string, bestSeat func([]Seat, int, int) Se
 			bookedCount++
 		}
 	}
-	bookedRatio := float64(bookedCount) / float64(totalSeats)
 	basePrice := f.GetBasePrice(seatClass)
-	price := calculatePrice(basePrice, f.GetDeparture(), time.Now(), bookedRatio, isFrequentFlyer)
+	price := calculatePrice(basePrice, f.GetDeparture(), bookingDate, bookedCount, totalSeats, isFrequentFlyer)
 
 	return seat, price, nil
 }
+
+// BookOptimistic books like BookBestSeat without locking the seat class, so
+// bookings of different seats go ahead side by side. It picks from the seats
+// it sees free and books the pick only if it has not changed since; when
+// another booking got there first it looks again, up to OptimisticAttempts
+// times before failing with ErrSeatConflict.
+func BookOptimistic(f VersionedFlight, seatClass string, bookingDate time.Time, bestSeat func([]Seat, int, int) Seat, calculatePrice func(base money.Money, departure, bookingDate time.Time, booked, total int, isFrequentFlyer bool) money.Money, isFrequentFlyer bool) (Seat, money.Money, error) {
+	for attempt := 0; attempt < OptimisticAttempts; attempt++ {
+		seats := f.GetVersionedSeats(seatClass)
+		availableSeats := make([]Seat, 0, len(seats))
+		versions := make([]uint64, 0, len(seats))
+		for _, seat := range seats {
+			if booked, version := seat.BookedVersion(); !booked && seat.GetSpecial() == "" {
+				availableSeats = append(availableSeats, seat)
+				versions = append(versions, version)
+			}
+		}
+		totalSeats := len(seats)
+		if len(availableSeats) == 0 || totalSeats == 0 {
+			return nil, money.Money{}, ErrNoSeatAvailable
+		}
+
+		// bestSeat gets a copy, so sorting it leaves versions lined up.
+		seat := bestSeat(append([]Seat(nil), availableSeats...), f.GetColumns(seatClass), f.GetRows(seatClass))
+		version, ok := versionOf(seat, availableSeats, versions)
+		if !ok {
+			return nil, money.Money{}, ErrSeatUnavailable
+		}
+		if !seat.(VersionedSeat).CompareAndSetBooked(version, true) {
+			continue
+		}
+
+		bookedCount := 0
+		for _, s := range seats {
+			if s.IsBookedSeat() {
+				bookedCount++
+			}
+		}
+		basePrice := f.GetBasePrice(seatClass)
+		price := calculatePrice(basePrice, f.GetDeparture(), bookingDate, bookedCount, totalSeats, isFrequentFlyer)
+		return seat, price, nil
+	}
+	return nil, money.Money{}, ErrSeatConflict
+}
+
+// versionOf is the version seat had when it was seen free. It reports false
+// for a seat that was not among them, nil included.
+func versionOf(seat Seat, seats []Seat, versions []uint64) (uint64, bool) {
+	if seat == nil {
+		return 0, false
+	}
+	for i, s := range seats {
+		if s == seat {
+			return versions[i], true
+		}
+	}
+	return 0, false
+}
@@ -3,9 +3,19 @@ package booking
 import (
 	"errors"
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
-var ErrNoSeatAvailable = errors.New("no seat available")
+// OptimisticAttempts is how many times BookOptimistic looks for a seat
+// before giving up on a busy seat class.
+const OptimisticAttempts = 8
+
+var (
+	ErrNoSeatAvailable = errors.New("no seat available")
+	ErrSeatUnavailable = errors.New("requested seat not available")
+	ErrSeatConflict    = errors.New("seats kept changing, try again")
+)
 
 type Seat interface {
 	IsBookedSeat() bool
@@ -17,7 +27,7 @@ type Flight interface {
 	GetSeats(seatClass string) []Seat
 	GetColumns(seatClass string) int
 	GetRows(seatClass string) int
-	GetBasePrice(seatClass string) float64
+	GetBasePrice(seatClass string) money.Money
 	GetDeparture() time.Time
 	GetMutex(seatClass string) Mutex
 }
@@ -26,3 +36,20 @@ type Mutex interface {
 	Lock()
 	Unlock()
 }
+
+// VersionedSeat is a Seat whose booked state carries a version that goes up
+// on every change, so it can be booked with compare-and-swap.
+type VersionedSeat interface {
+	Seat
+	BookedVersion() (bool, uint64)
+	CompareAndSetBooked(version uint64, booked bool) bool
+}
+
+// VersionedFlight is a Flight whose seats are booked without a mutex.
+type VersionedFlight interface {
+	GetVersionedSeats(seatClass string) []VersionedSeat
+	GetColumns(seatClass string) int
+	GetRows(seatClass string) int
+	GetBasePrice(seatClass string) money.Money
+	GetDeparture() time.Time
+}
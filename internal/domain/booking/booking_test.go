@@ -6,6 +6,7 @@ import (
 
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking/mocks"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,8 +17,8 @@ func dummyBestSeat(seats []booking.Seat, col, row int) booking.Seat {
 	return seats[0]
 }
 
-func dummyCalcPrice(base float64, departure, bookingDate time.Time, bookedRatio float64, isFrequentFlyer bool) float64 {
-	return base + bookedRatio*100
+func dummyCalcPrice(base money.Money, departure, bookingDate time.Time, booked, total int, isFrequentFlyer bool) money.Money {
+	return money.New(base.Amount+int64(booked*10000/total), base.Currency)
 }
 
 func TestBookBestSeat_Success(t *testing.T) {
@@ -31,7 +32,7 @@ func TestBookBestSeat_Success(t *testing.T) {
 	mockFlight.On("GetSeats", "Economy").Return(seats)
 	mockFlight.On("GetColumns", "Economy").Return(2)
 	mockFlight.On("GetRows", "Economy").Return(1)
-	mockFlight.On("GetBasePrice", "Economy").Return(1000.0)
+	mockFlight.On("GetBasePrice", "Economy").Return(money.New(100000, "USD"))
 	mockFlight.On("GetDeparture").Return(time.Now())
 
 	mockMutex.On("Lock").Return()
@@ -42,10 +43,10 @@ func TestBookBestSeat_Success(t *testing.T) {
 	mockSeat.On("SetBooked", true).Return()
 	mockSeat.On("IsBookedSeat").Return(true)
 
-	seat, price, err := booking.BookBestSeat(mockFlight, "Economy", dummyBestSeat, dummyCalcPrice, false)
+	seat, price, err := booking.BookBestSeat(mockFlight, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
 	assert.NoError(t, err)
 	assert.Equal(t, mockSeat, seat)
-	assert.Equal(t, 1100.0, price)
+	assert.Equal(t, money.New(110000, "USD"), price)
 }
 
 func TestBookBestSeat_AllBooked(t *testing.T) {
@@ -57,7 +58,7 @@ func TestBookBestSeat_AllBooked(t *testing.T) {
 	mockFlight.On("GetSeats", "Economy").Return([]booking.Seat{mockSeat, mockSeat})
 	mockFlight.On("GetColumns", "Economy").Return(2)
 	mockFlight.On("GetRows", "Economy").Return(1)
-	mockFlight.On("GetBasePrice", "Economy").Return(1000.0)
+	mockFlight.On("GetBasePrice", "Economy").Return(money.New(100000, "USD"))
 	mockFlight.On("GetDeparture").Return(time.Now())
 
 	mockMutex.On("Lock").Return()
@@ -67,20 +68,20 @@ func TestBookBestSeat_AllBooked(t *testing.T) {
 	mockSeat.On("IsBookedSeat").Return(true)
 	mockSeat.On("GetSpecial").Return("")
 
-	seat, price, err := booking.BookBestSeat(mockFlight, "Economy", dummyBestSeat, dummyCalcPrice, false)
+	seat, price, err := booking.BookBestSeat(mockFlight, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
 	assert.ErrorIs(t, err, booking.ErrNoSeatAvailable)
 	assert.Nil(t, seat)
-	assert.Equal(t, 0.0, price)
+	assert.True(t, price.IsZero())
 }
 
 func TestBookBestSeat_NoSuchClass(t *testing.T) {
 	mockFlight := new(mocks.Flight)
 	mockFlight.On("GetMutex", "NonExist").Return(nil)
 
-	seat, price, err := booking.BookBestSeat(mockFlight, "NonExist", dummyBestSeat, dummyCalcPrice, false)
+	seat, price, err := booking.BookBestSeat(mockFlight, "NonExist", time.Now(), dummyBestSeat, dummyCalcPrice, false)
 	assert.ErrorIs(t, err, booking.ErrNoSeatAvailable)
 	assert.Nil(t, seat)
-	assert.Equal(t, 0.0, price)
+	assert.True(t, price.IsZero())
 }
 
 func TestBookBestSeat_EmptySeats(t *testing.T) {
@@ -91,14 +92,121 @@ func TestBookBestSeat_EmptySeats(t *testing.T) {
 	mockFlight.On("GetSeats", "Economy").Return([]booking.Seat{})
 	mockFlight.On("GetColumns", "Economy").Return(1)
 	mockFlight.On("GetRows", "Economy").Return(1)
-	mockFlight.On("GetBasePrice", "Economy").Return(1000.0)
+	mockFlight.On("GetBasePrice", "Economy").Return(money.New(100000, "USD"))
 	mockFlight.On("GetDeparture").Return(time.Now())
 
 	mockMutex.On("Lock").Return()
 	mockMutex.On("Unlock").Return()
 
-	seat, price, err := booking.BookBestSeat(mockFlight, "Economy", dummyBestSeat, dummyCalcPrice, false)
+	seat, price, err := booking.BookBestSeat(mockFlight, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
 	assert.ErrorIs(t, err, booking.ErrNoSeatAvailable)
 	assert.Nil(t, seat)
-	assert.Equal(t, 0.0, price)
+	assert.True(t, price.IsZero())
+}
+
+func TestBookBestSeat_RequestedSeatTaken(t *testing.T) {
+	mockFlight := new(mocks.Flight)
+	mockSeat := new(mocks.Seat)
+	mockMutex := new(mocks.Mutex)
+
+	mockFlight.On("GetMutex", "Economy").Return(mockMutex)
+	mockFlight.On("GetSeats", "Economy").Return([]booking.Seat{mockSeat})
+	mockFlight.On("GetColumns", "Economy").Return(1)
+	mockFlight.On("GetRows", "Economy").Return(1)
+
+	mockMutex.On("Lock").Return()
+	mockMutex.On("Unlock").Return()
+
+	mockSeat.On("IsBookedSeat").Return(false)
+	mockSeat.On("GetSpecial").Return("")
+
+	noMatch := func([]booking.Seat, int, int) booking.Seat { return nil }
+	seat, _, err := booking.BookBestSeat(mockFlight, "Economy", time.Now(), noMatch, dummyCalcPrice, false)
+	assert.ErrorIs(t, err, booking.ErrSeatUnavailable)
+	assert.Nil(t, seat)
+	mockSeat.AssertNotCalled(t, "SetBooked", true)
+}
+
+// versionedSeat loses its next conflicts compare-and-swaps, as if another
+// booking took the seat and gave it back each time.
+type versionedSeat struct {
+	booked    bool
+	version   uint64
+	special   string
+	conflicts int
+}
+
+func (s *versionedSeat) IsBookedSeat() bool            { return s.booked }
+func (s *versionedSeat) SetBooked(b bool)              { s.booked = b; s.version++ }
+func (s *versionedSeat) GetSpecial() string            { return s.special }
+func (s *versionedSeat) BookedVersion() (bool, uint64) { return s.booked, s.version }
+
+func (s *versionedSeat) CompareAndSetBooked(version uint64, b bool) bool {
+	if s.conflicts > 0 {
+		s.conflicts--
+		s.version++
+		return false
+	}
+	if version != s.version || s.booked == b {
+		return false
+	}
+	s.SetBooked(b)
+	return true
+}
+
+type versionedFlight struct {
+	seats []booking.VersionedSeat
+}
+
+func (f *versionedFlight) GetVersionedSeats(string) []booking.VersionedSeat { return f.seats }
+func (f *versionedFlight) GetColumns(string) int                            { return len(f.seats) }
+func (f *versionedFlight) GetRows(string) int                               { return 1 }
+func (f *versionedFlight) GetBasePrice(string) money.Money                  { return money.New(100000, "USD") }
+func (f *versionedFlight) GetDeparture() time.Time                          { return time.Now() }
+
+func TestBookOptimistic(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		first, second := &versionedSeat{}, &versionedSeat{}
+		f := &versionedFlight{seats: []booking.VersionedSeat{first, second}}
+		seat, price, err := booking.BookOptimistic(f, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
+		assert.NoError(t, err)
+		assert.Same(t, first, seat)
+		assert.True(t, first.booked)
+		assert.False(t, second.booked)
+		assert.Equal(t, money.New(105000, "USD"), price)
+	})
+
+	t.Run("RetriesAfterConflicts", func(t *testing.T) {
+		seat := &versionedSeat{conflicts: 2}
+		f := &versionedFlight{seats: []booking.VersionedSeat{seat}}
+		got, _, err := booking.BookOptimistic(f, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
+		assert.NoError(t, err)
+		assert.Same(t, seat, got)
+		assert.Equal(t, uint64(3), seat.version)
+	})
+
+	t.Run("GivesUpOnBusyClass", func(t *testing.T) {
+		seat := &versionedSeat{conflicts: booking.OptimisticAttempts}
+		f := &versionedFlight{seats: []booking.VersionedSeat{seat}}
+		got, price, err := booking.BookOptimistic(f, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
+		assert.ErrorIs(t, err, booking.ErrSeatConflict)
+		assert.Nil(t, got)
+		assert.True(t, price.IsZero())
+		assert.False(t, seat.booked)
+	})
+
+	t.Run("AllBookedOrSpecial", func(t *testing.T) {
+		f := &versionedFlight{seats: []booking.VersionedSeat{&versionedSeat{booked: true}, &versionedSeat{special: "Blocked"}}}
+		_, _, err := booking.BookOptimistic(f, "Economy", time.Now(), dummyBestSeat, dummyCalcPrice, false)
+		assert.ErrorIs(t, err, booking.ErrNoSeatAvailable)
+	})
+
+	t.Run("RequestedSeatTaken", func(t *testing.T) {
+		seat := &versionedSeat{}
+		f := &versionedFlight{seats: []booking.VersionedSeat{seat}}
+		noMatch := func([]booking.Seat, int, int) booking.Seat { return nil }
+		_, _, err := booking.BookOptimistic(f, "Economy", time.Now(), noMatch, dummyCalcPrice, false)
+		assert.ErrorIs(t, err, booking.ErrSeatUnavailable)
+		assert.False(t, seat.booked)
+	})
 }
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	booking "github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking"
+	money "github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 	mock "github.com/stretchr/testify/mock"
 
 	time "time"
@@ -15,18 +16,18 @@ type Flight struct {
 }
 
 // GetBasePrice provides a mock function with given fields: seatClass
-func (_m *Flight) GetBasePrice(seatClass string) float64 {
+func (_m *Flight) GetBasePrice(seatClass string) money.Money {
 	ret := _m.Called(seatClass)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetBasePrice")
 	}
 
-	var r0 float64
-	if rf, ok := ret.Get(0).(func(string) float64); ok {
+	var r0 money.Money
+	if rf, ok := ret.Get(0).(func(string) money.Money); ok {
 		r0 = rf(seatClass)
 	} else {
-		r0 = ret.Get(0).(float64)
+		r0 = ret.Get(0).(money.Money)
 	}
 
 	return r0
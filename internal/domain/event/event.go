@@ -0,0 +1,132 @@
+package event
+
+import (
+	"time"
+)
+
+func (BookingConfirmed) Kind() Kind  { return KindBookingConfirmed }
+func (BookingCancelled) Kind() Kind  { return KindBookingCancelled }
+func (SeatReleased) Kind() Kind      { return KindSeatReleased }
+func (FlightRescheduled) Kind() Kind { return KindFlightRescheduled }
+func (PriceChanged) Kind() Kind      { return KindPriceChanged }
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls h with every record of the given kinds, or of every kind
+// when none are given, in the publisher's goroutine. h must not publish. The
+// returned function unsubscribes.
+func (b *Bus) Subscribe(h Handler, kinds ...Kind) func() {
+	return b.add(&subscription{kinds: kinds, handle: h})
+}
+
+// SubscribeAsync is Subscribe with h run in a goroutine of its own, fed by a
+// queue of queueSize records, DefaultQueueSize when not positive. Publishing
+// waits while the queue is full. Unsubscribing, or closing the bus, lets h
+// finish the records already queued.
+func (b *Bus) SubscribeAsync(h Handler, queueSize int, kinds ...Kind) func() {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	sub := &subscription{
+		kinds:  kinds,
+		handle: h,
+		queue:  make(chan Record, queueSize),
+		done:   make(chan struct{}),
+	}
+	go sub.run()
+	return b.add(sub)
+}
+
+// Subscribed reports whether anyone subscribes to kind.
+func (b *Bus) Subscribed(kind Kind) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if sub.wants(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish hands r to its subscribers, returning once the synchronous ones
+// have run and the asynchronous ones have it queued. Records published
+// after Close are dropped.
+func (b *Bus) Publish(r Record) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		if !sub.wants(r.Event.Kind()) {
+			continue
+		}
+		if sub.queue == nil {
+			sub.handle(r)
+		} else {
+			sub.queue <- r
+		}
+	}
+}
+
+// Relay publishes o's pending records in order, marking each published once
+// it has been handed to the subscribers. Records left pending by an error
+// are published by the next relay.
+func (b *Bus) Relay(o Outbox) error {
+	b.relayMu.Lock()
+	defer b.relayMu.Unlock()
+	records, err := o.Pending()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		b.Publish(r)
+		if err := o.MarkPublished(r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close unsubscribes everyone, waiting for asynchronous subscribers to
+// handle what they have queued.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs, b.closed = nil, true
+	b.mu.Unlock()
+	for _, sub := range subs {
+		sub.stop()
+	}
+}
+
+func NewInMemoryOutbox() *InMemoryOutbox {
+	return &InMemoryOutbox{}
+}
+
+func (o *InMemoryOutbox) Add(at time.Time, events ...Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, e := range events {
+		o.lastID++
+		o.pending = append(o.pending, Record{ID: o.lastID, At: at, Event: e})
+	}
+	return nil
+}
+
+func (o *InMemoryOutbox) Pending() ([]Record, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]Record(nil), o.pending...), nil
+}
+
+func (o *InMemoryOutbox) MarkPublished(id uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for n < len(o.pending) && o.pending[n].ID <= id {
+		n++
+	}
+	o.pending = append(o.pending[:0], o.pending[n:]...)
+	return nil
+}
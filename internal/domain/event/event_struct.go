@@ -0,0 +1,125 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// DefaultQueueSize is how many records an asynchronous subscriber may fall
+// behind before publishing waits for it.
+const DefaultQueueSize = 256
+
+// Kind names a type of event.
+type Kind string
+
+const (
+	KindBookingConfirmed  Kind = "BookingConfirmed"
+	KindBookingCancelled  Kind = "BookingCancelled"
+	KindSeatReleased      Kind = "SeatReleased"
+	KindFlightRescheduled Kind = "FlightRescheduled"
+	KindPriceChanged      Kind = "PriceChanged"
+)
+
+// Kinds lists every kind of event.
+var Kinds = []Kind{KindBookingConfirmed, KindBookingCancelled, KindSeatReleased, KindFlightRescheduled, KindPriceChanged}
+
+// Event is something that happened to a booking or a flight.
+type Event interface {
+	Kind() Kind
+}
+
+// BookingConfirmed is a booking that was paid for and stored as confirmed.
+type BookingConfirmed struct {
+	BookingID   string      `json:"booking_id"`
+	PassengerID string      `json:"passenger_id"`
+	FlightID    string      `json:"flight_id"`
+	SeatClass   string      `json:"seat_class"`
+	SeatID      string      `json:"seat_id"`
+	Fare        string      `json:"fare,omitempty"`
+	Total       money.Money `json:"total"`
+}
+
+// BookingCancelled is a confirmed booking stored as cancelled, with what was
+// refunded for it.
+type BookingCancelled struct {
+	BookingID   string      `json:"booking_id"`
+	PassengerID string      `json:"passenger_id"`
+	FlightID    string      `json:"flight_id"`
+	SeatClass   string      `json:"seat_class"`
+	SeatID      string      `json:"seat_id"`
+	Refund      money.Money `json:"refund"`
+}
+
+// SeatReleased is a seat back on sale after its booking was cancelled or
+// moved to another seat.
+type SeatReleased struct {
+	FlightID  string `json:"flight_id"`
+	SeatClass string `json:"seat_class"`
+	SeatID    string `json:"seat_id"`
+	BookingID string `json:"booking_id"`
+}
+
+// FlightRescheduled is a dated flight given new departure and arrival times.
+type FlightRescheduled struct {
+	FlightID          string    `json:"flight_id"`
+	Departure         time.Time `json:"departure"`
+	Arrival           time.Time `json:"arrival"`
+	PreviousDeparture time.Time `json:"previous_departure"`
+	PreviousArrival   time.Time `json:"previous_arrival"`
+}
+
+// PriceChanged is a new fare for the next seat sold in a flight's seat
+// class, in the flight's currency. Price or Previous is nil while the class
+// has nothing left to sell.
+type PriceChanged struct {
+	FlightID  string       `json:"flight_id"`
+	SeatClass string       `json:"seat_class"`
+	Price     *money.Money `json:"price"`
+	Previous  *money.Money `json:"previous"`
+}
+
+// Record is an event in an outbox. IDs increase in the order events were
+// added, and At is when they were added.
+type Record struct {
+	ID    uint64
+	At    time.Time
+	Event Event
+}
+
+// Handler receives published records.
+type Handler func(Record)
+
+// Bus delivers published records to the subscribers of their kinds.
+// Synchronous subscribers run in the publisher's goroutine, asynchronous ones
+// in a goroutine of their own that takes records from a queue in order.
+type Bus struct {
+	mu      sync.RWMutex
+	subs    []*subscription
+	closed  bool
+	relayMu sync.Mutex // one relay at a time, so records go out in order
+}
+
+type subscription struct {
+	kinds  []Kind // every kind when empty
+	handle Handler
+	queue  chan Record   // nil for synchronous subscribers
+	done   chan struct{} // closed once an asynchronous queue is drained
+}
+
+// Outbox keeps events until they are published. Records are published in ID
+// order, and MarkPublished removes a record and every one before it.
+type Outbox interface {
+	Add(at time.Time, events ...Event) error
+	Pending() ([]Record, error)
+	MarkPublished(id uint64) error
+}
+
+// InMemoryOutbox is an Outbox that keeps records in a slice and is safe for
+// concurrent use.
+type InMemoryOutbox struct {
+	mu      sync.Mutex
+	lastID  uint64
+	pending []Record
+}
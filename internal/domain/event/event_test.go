@@ -0,0 +1,141 @@
+package event
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func TestBus(t *testing.T) {
+	confirmed := Record{ID: 1, Event: BookingConfirmed{BookingID: "B1", Total: money.New(10000, "USD")}}
+	released := Record{ID: 2, Event: SeatReleased{FlightID: "F1", SeatID: "1A"}}
+
+	t.Run("Synchronous", func(t *testing.T) {
+		b := NewBus()
+		var all, seats []uint64
+		b.Subscribe(func(r Record) { all = append(all, r.ID) })
+		b.Subscribe(func(r Record) { seats = append(seats, r.ID) }, KindSeatReleased)
+		b.Publish(confirmed)
+		b.Publish(released)
+		if len(all) != 2 || all[0] != 1 || all[1] != 2 {
+			t.Errorf("expected every record in order, got %v", all)
+		}
+		if len(seats) != 1 || seats[0] != 2 {
+			t.Errorf("expected only SeatReleased, got %v", seats)
+		}
+		if !b.Subscribed(KindBookingConfirmed) {
+			t.Errorf("expected a subscriber to every kind to count")
+		}
+	})
+
+	t.Run("Asynchronous", func(t *testing.T) {
+		b := NewBus()
+		var mu sync.Mutex
+		var got []uint64
+		b.SubscribeAsync(func(r Record) {
+			mu.Lock()
+			got = append(got, r.ID)
+			mu.Unlock()
+		}, 1)
+		for id := uint64(1); id <= 50; id++ {
+			b.Publish(Record{ID: id, Event: SeatReleased{}})
+		}
+		b.Close()
+		if len(got) != 50 {
+			t.Fatalf("expected Close to wait for all 50 records, got %d", len(got))
+		}
+		for i, id := range got {
+			if id != uint64(i+1) {
+				t.Fatalf("expected records in order, got %v", got)
+			}
+		}
+		b.Publish(confirmed)
+		if len(got) != 50 {
+			t.Errorf("expected records published after Close to be dropped")
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		b := NewBus()
+		calls := 0
+		unsubscribe := b.Subscribe(func(Record) { calls++ }, KindPriceChanged)
+		b.Publish(Record{Event: PriceChanged{}})
+		unsubscribe()
+		unsubscribe()
+		b.Publish(Record{Event: PriceChanged{}})
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+		if b.Subscribed(KindPriceChanged) {
+			t.Errorf("expected no subscribers left")
+		}
+	})
+}
+
+func TestOutbox(t *testing.T) {
+	at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Relay", func(t *testing.T) {
+		o := NewInMemoryOutbox()
+		b := NewBus()
+		var got []Record
+		b.Subscribe(func(r Record) { got = append(got, r) })
+		_ = o.Add(at, BookingCancelled{BookingID: "B1"}, SeatReleased{BookingID: "B1"})
+		_ = o.Add(at.Add(time.Minute), FlightRescheduled{FlightID: "F1"})
+		if err := b.Relay(o); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 3 || got[0].ID != 1 || got[2].ID != 3 || got[2].Event.Kind() != KindFlightRescheduled {
+			t.Fatalf("expected 3 records in order, got %+v", got)
+		}
+		if !got[2].At.Equal(at.Add(time.Minute)) {
+			t.Errorf("expected the time the record was added, got %v", got[2].At)
+		}
+		if pending, _ := o.Pending(); len(pending) != 0 {
+			t.Errorf("expected nothing pending, got %d", len(pending))
+		}
+		_ = b.Relay(o)
+		if len(got) != 3 {
+			t.Errorf("expected records to be published once, got %d", len(got))
+		}
+	})
+
+	t.Run("MarkPublished", func(t *testing.T) {
+		o := NewInMemoryOutbox()
+		_ = o.Add(at, SeatReleased{}, SeatReleased{}, SeatReleased{})
+		_ = o.MarkPublished(2)
+		pending, _ := o.Pending()
+		if len(pending) != 1 || pending[0].ID != 3 {
+			t.Errorf("expected record 3 left, got %+v", pending)
+		}
+	})
+
+	t.Run("ConcurrentRelays", func(t *testing.T) {
+		o := NewInMemoryOutbox()
+		b := NewBus()
+		var got []uint64
+		b.Subscribe(func(r Record) { got = append(got, r.ID) })
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					_ = o.Add(at, SeatReleased{})
+					_ = b.Relay(o)
+				}
+			}()
+		}
+		wg.Wait()
+		if len(got) != 200 {
+			t.Fatalf("expected 200 records, got %d", len(got))
+		}
+		for i, id := range got {
+			if id != uint64(i+1) {
+				t.Fatalf("expected records once each, in order, got %d at %d", id, i)
+			}
+		}
+	})
+}
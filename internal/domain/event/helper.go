@@ -0,0 +1,57 @@
+package event
+
+// add registers sub and returns a function that removes it once.
+func (b *Bus) add(sub *subscription) func() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		sub.stop()
+		return func() {}
+	}
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		removed := false
+		for i, s := range b.subs {
+			if s == sub {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		b.mu.Unlock()
+		if removed {
+			sub.stop()
+		}
+	}
+}
+
+func (s *subscription) wants(kind Kind) bool {
+	if len(s.kinds) == 0 {
+		return true
+	}
+	for _, k := range s.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *subscription) run() {
+	defer close(s.done)
+	for r := range s.queue {
+		s.handle(r)
+	}
+}
+
+// stop closes an asynchronous subscriber's queue and waits for it to drain.
+// Callers remove sub from the bus first, so nothing sends to it any more.
+func (s *subscription) stop() {
+	if s.queue == nil {
+		return
+	}
+	close(s.queue)
+	<-s.done
+}
@@ -0,0 +1,148 @@
+package fare
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// NewCabin validates families, which must all be priced in one currency, and
+// returns a cabin with nothing sold.
+func NewCabin(families []Family) (*Cabin, error) {
+	if len(families) == 0 {
+		return nil, fmt.Errorf("%w: no fare families", ErrInvalidFare)
+	}
+	seen := make(map[string]bool)
+	sorted := make([]Family, 0, len(families))
+	for _, f := range families {
+		if f.Code == "" {
+			return nil, fmt.Errorf("%w: code is required", ErrInvalidFare)
+		}
+		if seen[f.Code] {
+			return nil, fmt.Errorf("%w: duplicate code %s", ErrInvalidFare, f.Code)
+		}
+		if f.Price.Amount < 0 || f.Allotment < 0 {
+			return nil, fmt.Errorf("%w: %s has a negative price or allotment", ErrInvalidFare, f.Code)
+		}
+		if f.Price.Currency != families[0].Price.Currency {
+			return nil, fmt.Errorf("%w: %s is priced in %s, %s in %s", ErrInvalidFare, f.Code, f.Price.Currency, families[0].Code, families[0].Price.Currency)
+		}
+		if err := f.Rules.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Code, err)
+		}
+		seen[f.Code] = true
+		sorted = append(sorted, f)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Price.Less(sorted[j].Price) })
+	return &Cabin{families: sorted, sold: make(map[string]int)}, nil
+}
+
+// Families returns the cabin's families from cheapest to most expensive.
+func (c *Cabin) Families() []Family {
+	return append([]Family(nil), c.families...)
+}
+
+// Availability lists every family with the seats it can still sell.
+func (c *Cabin) Availability() []Availability {
+	return c.AvailabilityOpen(0)
+}
+
+// AvailabilityOpen is Availability with the closed cheapest families marked
+// as closed.
+func (c *Cabin) AvailabilityOpen(closed int) []Availability {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]Availability, len(c.families))
+	for i, f := range c.families {
+		result[i] = Availability{Family: f, Available: c.available(i), Closed: c.isClosed(i, closed)}
+	}
+	return result
+}
+
+// Lowest returns the cheapest family that still has availability.
+func (c *Cabin) Lowest() (Family, bool) {
+	return c.LowestOpen(0)
+}
+
+// LowestOpen returns the cheapest family that is neither closed nor sold out.
+func (c *Cabin) LowestOpen(closed int) (Family, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, f := range c.families {
+		if !c.isClosed(i, closed) && c.available(i) != 0 {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// Reserve sells one seat in the family with the given code, or in the
+// cheapest available family when code is empty.
+func (c *Cabin) Reserve(code string) (Family, error) {
+	return c.ReserveOpen(code, 0)
+}
+
+// ReserveOpen is Reserve with the closed cheapest families unavailable
+// regardless of their inventory. The most expensive family never closes.
+func (c *Cabin) ReserveOpen(code string, closed int) (Family, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, err := c.find(code, closed)
+	if err != nil {
+		return Family{}, err
+	}
+	c.sold[c.families[i].Code]++
+	return c.families[i], nil
+}
+
+// FindOpen returns the family ReserveOpen would sell without selling it.
+func (c *Cabin) FindOpen(code string, closed int) (Family, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, err := c.find(code, closed)
+	if err != nil {
+		return Family{}, err
+	}
+	return c.families[i], nil
+}
+
+// Release returns a seat previously reserved in the family.
+func (c *Cabin) Release(code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sold[code] > 0 {
+		c.sold[code]--
+	}
+}
+
+// Refund returns the refundable part of price for a cancellation made
+// daysBefore local days ahead of departure, rounded half away from zero to
+// the currency's increment.
+func (r Rules) Refund(price money.Money, daysBefore int) money.Money {
+	if !r.Refundable {
+		return money.New(0, price.Currency)
+	}
+	if daysBefore >= 1 {
+		return price.MulRatio(r.RefundPercent, 100)
+	}
+	return price.MulRatio(r.LateRefundPercent, 100)
+}
+
+// Validate checks the refund percentages, change fee and baggage.
+func (r Rules) Validate() error {
+	for _, p := range []int64{r.RefundPercent, r.LateRefundPercent} {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("%w: refund percentages must be between 0 and 100", ErrInvalidFare)
+		}
+	}
+	if r.ChangeFee.Amount < 0 || r.CheckedBags < 0 {
+		return fmt.Errorf("%w: negative change fee or baggage", ErrInvalidFare)
+	}
+	if !r.ChangeFee.IsZero() {
+		if _, err := money.LookupCurrency(r.ChangeFee.Currency); err != nil {
+			return fmt.Errorf("%w: change fee: %v", ErrInvalidFare, err)
+		}
+	}
+	return nil
+}
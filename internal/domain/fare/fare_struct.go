@@ -0,0 +1,65 @@
+package fare
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+var (
+	ErrInvalidFare  = errors.New("invalid fare family")
+	ErrFareNotFound = errors.New("fare family not found")
+	ErrFareSoldOut  = errors.New("fare family sold out")
+	ErrFareClosed   = errors.New("fare family closed")
+)
+
+// DefaultRules apply to bookings made in a cabin without fare families. They
+// refund 80% of the price whenever the booking is cancelled.
+var DefaultRules = Rules{
+	Refundable:        true,
+	RefundPercent:     80,
+	LateRefundPercent: 80,
+	Changeable:        true,
+}
+
+// Rules are the change, refund and baggage conditions of a fare family.
+// RefundPercent, a whole percentage of the price, applies when cancelling at
+// least one local day before departure and LateRefundPercent afterwards.
+// ChangeFee is converted to the booking's currency when charged; the zero
+// Money charges nothing.
+type Rules struct {
+	Refundable        bool        `json:"refundable"`
+	RefundPercent     int64       `json:"refund_percent"`
+	LateRefundPercent int64       `json:"late_refund_percent"`
+	Changeable        bool        `json:"changeable"`
+	ChangeFee         money.Money `json:"change_fee"`
+	CheckedBags       int         `json:"checked_bags"`
+}
+
+// Family is a fare bucket within a cabin, e.g. Economy Basic, priced in the
+// flight's selling currency. Allotment is its nested booking limit: the most
+// seats that may be sold in this family and all cheaper ones together. Zero
+// means no limit beyond the cabin.
+type Family struct {
+	Code      string      `json:"code"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	Allotment int         `json:"allotment"`
+	Rules     Rules       `json:"rules"`
+}
+
+// Cabin tracks nested fare family inventory for one seat class. Families
+// are kept ordered from cheapest to most expensive.
+type Cabin struct {
+	mu       sync.Mutex
+	families []Family
+	sold     map[string]int
+}
+
+// Availability is a family together with the seats it can still sell.
+type Availability struct {
+	Family
+	Available int  `json:"available"` // -1 when only the cabin limits sales
+	Closed    bool `json:"closed,omitempty"`
+}
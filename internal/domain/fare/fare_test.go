@@ -0,0 +1,173 @@
+package fare
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func economyFamilies() []Family {
+	return []Family{
+		{Code: "FLEX", Price: usd(600), Rules: Rules{Refundable: true, RefundPercent: 100, LateRefundPercent: 80, Changeable: true}},
+		{Code: "BASIC", Price: usd(200), Allotment: 2},
+		{Code: "STANDARD", Price: usd(350), Allotment: 4, Rules: Rules{Refundable: true, RefundPercent: 50, Changeable: true, ChangeFee: usd(50), CheckedBags: 1}},
+	}
+}
+
+func usd(amount float64) money.Money {
+	return money.FromFloat(amount, "USD")
+}
+
+func TestNewCabin(t *testing.T) {
+	t.Run("SortsByPrice", func(t *testing.T) {
+		cabin, err := NewCabin(economyFamilies())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		families := cabin.Families()
+		if families[0].Code != "BASIC" || families[1].Code != "STANDARD" || families[2].Code != "FLEX" {
+			t.Errorf("unexpected order %v", families)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := map[string][]Family{
+			"Empty":          nil,
+			"NoCode":         {{Price: usd(100)}},
+			"Duplicate":      {{Code: "A", Price: usd(1)}, {Code: "A", Price: usd(2)}},
+			"NegativePrice":  {{Code: "A", Price: usd(-1)}},
+			"NegativeLimit":  {{Code: "A", Allotment: -1}},
+			"RefundOverFull": {{Code: "A", Rules: Rules{RefundPercent: 150}}},
+			"NegativeFee":    {{Code: "A", Rules: Rules{ChangeFee: usd(-10)}}},
+			"UnknownFeeCode": {{Code: "A", Rules: Rules{ChangeFee: money.New(1000, "XYZ")}}},
+			"MixedCurrency":  {{Code: "A", Price: usd(100)}, {Code: "B", Price: money.FromFloat(100, "EUR")}},
+		}
+		for name, families := range cases {
+			if _, err := NewCabin(families); !errors.Is(err, ErrInvalidFare) {
+				t.Errorf("%s: expected ErrInvalidFare, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestNestedAvailability(t *testing.T) {
+	t.Run("LowerSalesConsumeHigherLimits", func(t *testing.T) {
+		cabin, _ := NewCabin(economyFamilies())
+		for i := 0; i < 2; i++ {
+			if f, err := cabin.Reserve(""); err != nil || f.Code != "BASIC" {
+				t.Fatalf("expected BASIC, got %v, %v", f.Code, err)
+			}
+		}
+		// BASIC is closed, the next cheapest sale goes to STANDARD
+		if f, _ := cabin.Reserve(""); f.Code != "STANDARD" {
+			t.Errorf("expected STANDARD once BASIC is sold out, got %s", f.Code)
+		}
+		avail := cabin.Availability()
+		if avail[0].Available != 0 || avail[1].Available != 1 || avail[2].Available != -1 {
+			t.Errorf("unexpected availability %+v", avail)
+		}
+	})
+
+	t.Run("HigherSalesCloseLowerFamilies", func(t *testing.T) {
+		cabin, _ := NewCabin(economyFamilies())
+		for i := 0; i < 3; i++ {
+			if _, err := cabin.Reserve("STANDARD"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		// Only one seat left under the STANDARD limit, so BASIC can sell one
+		avail := cabin.Availability()
+		if avail[0].Available != 1 {
+			t.Errorf("expected BASIC limited to 1, got %d", avail[0].Available)
+		}
+		if _, err := cabin.Reserve("BASIC"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := cabin.Reserve("BASIC"); !errors.Is(err, ErrFareSoldOut) {
+			t.Errorf("expected ErrFareSoldOut, got %v", err)
+		}
+		if _, err := cabin.Reserve("STANDARD"); !errors.Is(err, ErrFareSoldOut) {
+			t.Errorf("expected ErrFareSoldOut, got %v", err)
+		}
+		if f, err := cabin.Reserve(""); err != nil || f.Code != "FLEX" {
+			t.Errorf("expected FLEX to stay open, got %s, %v", f.Code, err)
+		}
+	})
+
+	t.Run("ReleaseReopens", func(t *testing.T) {
+		cabin, _ := NewCabin([]Family{{Code: "ONLY", Price: usd(100), Allotment: 1}})
+		if _, err := cabin.Reserve("ONLY"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, ok := cabin.Lowest(); ok {
+			t.Error("expected no availability")
+		}
+		if _, err := cabin.Reserve(""); !errors.Is(err, ErrFareSoldOut) {
+			t.Errorf("expected ErrFareSoldOut, got %v", err)
+		}
+		cabin.Release("ONLY")
+		cabin.Release("ONLY") // releasing more than sold is ignored
+		if f, ok := cabin.Lowest(); !ok || f.Code != "ONLY" {
+			t.Errorf("expected ONLY to be available again")
+		}
+		if avail := cabin.Availability(); avail[0].Available != 1 {
+			t.Errorf("expected 1 available, got %d", avail[0].Available)
+		}
+	})
+
+	t.Run("UnknownFamily", func(t *testing.T) {
+		cabin, _ := NewCabin(economyFamilies())
+		if _, err := cabin.Reserve("PREMIUM"); !errors.Is(err, ErrFareNotFound) {
+			t.Errorf("expected ErrFareNotFound, got %v", err)
+		}
+	})
+}
+
+func TestClosedFamilies(t *testing.T) {
+	cabin, _ := NewCabin(economyFamilies())
+	if f, err := cabin.ReserveOpen("", 1); err != nil || f.Code != "STANDARD" {
+		t.Errorf("expected STANDARD with BASIC closed, got %s, %v", f.Code, err)
+	}
+	if _, err := cabin.ReserveOpen("BASIC", 1); !errors.Is(err, ErrFareClosed) {
+		t.Errorf("expected ErrFareClosed, got %v", err)
+	}
+	// Closing more families than exist still leaves the most expensive open
+	if f, ok := cabin.LowestOpen(10); !ok || f.Code != "FLEX" {
+		t.Errorf("expected FLEX to stay open, got %s", f.Code)
+	}
+	avail := cabin.AvailabilityOpen(2)
+	if !avail[0].Closed || !avail[1].Closed || avail[2].Closed {
+		t.Errorf("unexpected closures %+v", avail)
+	}
+	if avail[1].Available != 3 {
+		t.Errorf("closure should not change inventory, got %d", avail[1].Available)
+	}
+	// FindOpen picks like ReserveOpen without selling
+	if f, err := cabin.FindOpen("", 1); err != nil || f.Code != "STANDARD" {
+		t.Errorf("expected STANDARD, got %s, %v", f.Code, err)
+	}
+	if avail := cabin.Availability(); avail[1].Available != 3 {
+		t.Errorf("FindOpen should not sell, got %d left", avail[1].Available)
+	}
+	if _, err := cabin.FindOpen("BASIC", 1); !errors.Is(err, ErrFareClosed) {
+		t.Errorf("expected ErrFareClosed, got %v", err)
+	}
+}
+
+func TestRulesRefund(t *testing.T) {
+	price := money.FromFloat(1000, "USD")
+	rules := Rules{Refundable: true, RefundPercent: 90, LateRefundPercent: 25}
+	if got := rules.Refund(price, 3); got != money.FromFloat(900, "USD") {
+		t.Errorf("expected 900.00 USD, got %s", got)
+	}
+	if got := rules.Refund(price, 0); got != money.FromFloat(250, "USD") {
+		t.Errorf("expected 250.00 USD, got %s", got)
+	}
+	if got := (Rules{}).Refund(price, 10); got != money.New(0, "USD") {
+		t.Errorf("expected non-refundable fare to refund 0.00 USD, got %s", got)
+	}
+	if got := DefaultRules.Refund(price, 1); got != money.FromFloat(800, "USD") {
+		t.Errorf("expected default refund 800.00 USD, got %s", got)
+	}
+}
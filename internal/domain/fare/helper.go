@@ -0,0 +1,60 @@
+package fare
+
+// available applies nested booking limits: family i may sell while every
+// limited family at or above it has seats left after counting sales in all
+// families up to that level. Callers must hold c.mu.
+func (c *Cabin) available(i int) int {
+	result := -1
+	cumulative := 0
+	for j, f := range c.families {
+		cumulative += c.sold[f.Code]
+		if j < i || f.Allotment == 0 {
+			continue
+		}
+		left := f.Allotment - cumulative
+		if left < 0 {
+			left = 0
+		}
+		if result == -1 || left < result {
+			result = left
+		}
+	}
+	return result
+}
+
+// isClosed reports whether family i is among the closed cheapest families.
+// At least the most expensive family always stays open.
+func (c *Cabin) isClosed(i, closed int) bool {
+	if closed > len(c.families)-1 {
+		closed = len(c.families) - 1
+	}
+	return i < closed
+}
+
+// find returns the index of the family with code, or of the cheapest one
+// with seats left when code is empty, skipping closed families. Callers must
+// hold c.mu.
+func (c *Cabin) find(code string, closed int) (int, error) {
+	for i, f := range c.families {
+		if code != "" && f.Code != code {
+			continue
+		}
+		if c.isClosed(i, closed) {
+			if code != "" {
+				return 0, ErrFareClosed
+			}
+			continue
+		}
+		if c.available(i) == 0 {
+			if code != "" {
+				return 0, ErrFareSoldOut
+			}
+			continue
+		}
+		return i, nil
+	}
+	if code != "" {
+		return 0, ErrFareNotFound
+	}
+	return 0, ErrFareSoldOut
+}
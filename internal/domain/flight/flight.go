@@ -4,6 +4,9 @@ import (
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
 func InitializeFlight(flightID, origin, destination, aircraft string, departure, arrival time.Time) *Flight {
@@ -16,18 +19,23 @@ func InitializeFlight(flightID, origin, destination, aircraft string, departure,
 		Columns:     make(map[SeatClass]int),
 		Rows:        make(map[SeatClass]int),
 		Seats:       make(map[SeatClass][]*Seat),
-		BasePrices:  make(map[SeatClass]float64),
+		BasePrices:  make(map[SeatClass]money.Money),
+		Currency:    money.DefaultCurrency,
 		Aircraft:    aircraft,
 		Mutex:       make(map[SeatClass]*sync.Mutex),
+		Fares:       make(map[SeatClass]*fare.Cabin),
 	}
 }
 
-func (f *Flight) AddSeatClass(seatClass SeatClass, layout [][]*Seat, basePrice float64) {
+func (f *Flight) AddSeatClass(seatClass SeatClass, layout [][]*Seat, basePrice money.Money) {
 	if _, exists := f.Seats[seatClass]; !exists {
 		f.Seats[seatClass] = make([]*Seat, 0)
 		f.BasePrices[seatClass] = basePrice
 		f.Mutex[seatClass] = &sync.Mutex{}
 	}
+	if f.feed == nil {
+		f.feed = &seatFeed{}
+	}
 
 	f.Columns[seatClass] = len(layout)
 	f.Rows[seatClass] = len(layout[0])
@@ -35,21 +43,109 @@ func (f *Flight) AddSeatClass(seatClass SeatClass, layout [][]*Seat, basePrice f
 	for column, rows := range layout {
 		for row, seat := range rows {
 			seat := Seat{
-				SeatID:   string(rune(int('A')+column)) + strconv.Itoa(row+1),
-				Row:      row + 1,
-				Column:   column + 1,
-				Special:  seat.Special,
-				IsBooked: false,
+				SeatID:  string(rune(int('A')+column)) + strconv.Itoa(row+1),
+				Row:     row + 1,
+				Column:  column + 1,
+				Special: seat.Special,
+				feed:    f.feed,
 			}
 			f.Seats[seatClass] = append(f.Seats[seatClass], &seat)
 		}
 	}
 }
 
+// SetFareFamilies splits an existing seat class into fare families.
+func (f *Flight) SetFareFamilies(seatClass SeatClass, families []fare.Family) error {
+	if _, exists := f.Seats[seatClass]; !exists {
+		return ErrSeatClassNotFound
+	}
+	cabin, err := fare.NewCabin(families)
+	if err != nil {
+		return err
+	}
+	if f.Fares == nil {
+		f.Fares = make(map[SeatClass]*fare.Cabin)
+	}
+	f.Fares[seatClass] = cabin
+	return nil
+}
+
+// Availability counts a seat class's seats. It reports false for a class the
+// flight does not have.
+func (f *Flight) Availability(seatClass SeatClass) (Availability, bool) {
+	seats, ok := f.Seats[seatClass]
+	if !ok {
+		return Availability{}, false
+	}
+	a := Availability{Total: len(seats)}
+	for _, seat := range seats {
+		if seat.IsBookedSeat() {
+			a.Booked++
+		} else if seat.Special == "" {
+			a.Available++
+		}
+	}
+	return a, true
+}
+
+// SeatSnapshot copies a seat class's seats, so the copy can be read while
+// seats are booked and released.
+func (f *Flight) SeatSnapshot(seatClass SeatClass) []SeatState {
+	seats := f.Seats[seatClass]
+	if seats == nil {
+		return nil
+	}
+	states := make([]SeatState, len(seats))
+	for i, seat := range seats {
+		states[i] = seat.State()
+	}
+	return states
+}
+
+// ReleaseSeat frees a seat still booked at version, the version its booking
+// took it at, and reports whether it did. A seat freed since, and perhaps
+// booked again by someone else, is left alone.
+func (f *Flight) ReleaseSeat(seatClass SeatClass, seatID string, version uint64) bool {
+	for _, seat := range f.Seats[seatClass] {
+		if seat.SeatID == seatID {
+			return seat.CompareAndSetBooked(version, false)
+		}
+	}
+	return false
+}
+
+// WatchSeats returns a channel that receives after seats of the flight are
+// booked or freed, and a function to stop watching. Changes made before the
+// channel is read are reported once, so a watcher should take a fresh
+// SeatSnapshot on every receive.
+func (f *Flight) WatchSeats() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	if f.feed == nil {
+		return ch, func() {}
+	}
+	f.feed.mu.Lock()
+	if f.feed.watchers == nil {
+		f.feed.watchers = make(map[chan struct{}]struct{})
+	}
+	f.feed.watchers[ch] = struct{}{}
+	f.feed.watching.Add(1)
+	f.feed.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			f.feed.mu.Lock()
+			delete(f.feed.watchers, ch)
+			f.feed.watching.Add(-1)
+			f.feed.mu.Unlock()
+		})
+	}
+}
+
+// getAvailableSeats requires the class's mutex to be held.
 func (f *Flight) getAvailableSeats(seatClass SeatClass) []*Seat {
 	availableSeats := make([]*Seat, 0)
 	for _, seat := range f.Seats[seatClass] {
-		if !seat.IsBooked && seat.Special == "" {
+		if !seat.IsBookedSeat() && seat.Special == "" {
 			availableSeats = append(availableSeats, seat)
 		}
 	}
@@ -73,7 +169,7 @@ func (f *Flight) GetRows(seatClass string) int {
 	return f.Rows[SeatClass(seatClass)]
 }
 
-func (f *Flight) GetBasePrice(seatClass string) float64 {
+func (f *Flight) GetBasePrice(seatClass string) money.Money {
 	return f.BasePrices[SeatClass(seatClass)]
 }
 
@@ -106,11 +202,62 @@ func (s *Seat) GetSpecial() string {
 }
 
 func (s *Seat) IsBookedSeat() bool {
-	return s.IsBooked
+	return s.state.Load()&1 == 1
 }
 
+// SetBooked books or frees the seat whatever its version.
 func (s *Seat) SetBooked(b bool) {
-	s.IsBooked = b
+	for {
+		old := s.state.Load()
+		if s.state.CompareAndSwap(old, nextState(old, b)) {
+			s.feed.notify()
+			return
+		}
+	}
+}
+
+// BookedVersion reports whether the seat is booked and its version.
+func (s *Seat) BookedVersion() (bool, uint64) {
+	state := s.state.Load()
+	return state&1 == 1, state >> 1
+}
+
+// State copies the seat with its version.
+func (s *Seat) State() SeatState {
+	booked, version := s.BookedVersion()
+	return SeatState{
+		SeatID:   s.SeatID,
+		Row:      s.Row,
+		Column:   s.Column,
+		Special:  s.Special,
+		IsBooked: booked,
+		Version:  version,
+	}
+}
+
+// CompareAndSetBooked books or frees the seat only if it is still at version
+// and not already so, and reports whether it did.
+func (s *Seat) CompareAndSetBooked(version uint64, b bool) bool {
+	old := s.state.Load()
+	if old>>1 != version || (old&1 == 1) == b {
+		return false
+	}
+	if !s.state.CompareAndSwap(old, nextState(old, b)) {
+		return false
+	}
+	s.feed.notify()
+	return true
+}
+
+// UndoRelease books again a seat its booking freed at version, putting it
+// back at version so the booking still holds it, and reports whether it did.
+// It fails once the seat has been booked or freed by anyone else since.
+func (s *Seat) UndoRelease(version uint64) bool {
+	if !s.state.CompareAndSwap((version+1)<<1, version<<1|1) {
+		return false
+	}
+	s.feed.notify()
+	return true
 }
 
 func (m *MutexAdapter) Lock() {
@@ -3,13 +3,41 @@ package flight
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
 type SeatClass string
 type MutexAdapter sync.Mutex
 
-var ErrNoSeatAvailable = errors.New("no seat available")
+var (
+	ErrNoSeatAvailable   = errors.New("no seat available")
+	ErrSeatClassNotFound = errors.New("seat class not found")
+	ErrInvalidPricing    = errors.New("invalid pricing")
+)
+
+// Pricing is how Pricing.Price adjusts a base price for the days left before
+// departure and for frequent flyers. Percentages are whole numbers.
+type Pricing struct {
+	EarlyDays             int   // booked at least this many days out gets EarlyDiscount
+	EarlyDiscount         int64 // percent off
+	LateDays              int   // booked this many days out or fewer pays LateSurcharge
+	LateSurcharge         int64 // percent on top
+	FrequentFlyerDiscount int64 // percent off
+}
+
+// DefaultPricing gives 10% off 30 days out, adds 20% within 7 days and takes
+// 5% off for frequent flyers.
+var DefaultPricing = Pricing{
+	EarlyDays:             30,
+	EarlyDiscount:         10,
+	LateDays:              7,
+	LateSurcharge:         20,
+	FrequentFlyerDiscount: 5,
+}
 
 type SeatInterface interface {
 	GetSeatID() string
@@ -25,12 +53,33 @@ type MutexInterface interface {
 	Unlock()
 }
 
+// Seat is one seat of a flight. Whether it is booked is kept with a version
+// that goes up on every change, so a seat seen free can be booked with
+// CompareAndSetBooked only if nobody has touched it since.
 type Seat struct {
+	SeatID  string
+	Row     int
+	Column  int
+	Special string
+	state   atomic.Uint64 // version<<1, low bit set while booked
+	feed    *seatFeed     // the flight's, nil for seats not added with AddSeatClass
+}
+
+// SeatState is a copy of a seat at one moment.
+type SeatState struct {
 	SeatID   string
 	Row      int
 	Column   int
 	Special  string
 	IsBooked bool
+	Version  uint64
+}
+
+// Availability is how a seat class was filled, counted seat by seat.
+type Availability struct {
+	Total     int
+	Available int // neither booked nor special
+	Booked    int
 }
 
 type Flight struct {
@@ -42,7 +91,18 @@ type Flight struct {
 	Columns     map[SeatClass]int
 	Rows        map[SeatClass]int
 	Seats       map[SeatClass][]*Seat
-	BasePrices  map[SeatClass]float64
+	BasePrices  map[SeatClass]money.Money
+	Currency    string // selling currency of every price on the flight
 	Aircraft    string
 	Mutex       map[SeatClass]*sync.Mutex
+	Fares       map[SeatClass]*fare.Cabin // optional fare families per class
+	feed        *seatFeed                 // tells WatchSeats callers about seat changes
+}
+
+// seatFeed wakes the watchers of a flight's seats. Each watcher has a channel
+// with room for one wake-up, so changes made while it is busy coalesce.
+type seatFeed struct {
+	watching atomic.Int32 // lets seat changes skip mu while nobody watches
+	mu       sync.Mutex
+	watchers map[chan struct{}]struct{}
 }
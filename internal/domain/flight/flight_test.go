@@ -1,9 +1,13 @@
 package flight
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
 func TestInitializeFlight(t *testing.T) {
@@ -34,13 +38,13 @@ func TestAddSeatClass(t *testing.T) {
 			{&Seat{}, &Seat{}, &Seat{}},
 			{&Seat{}, &Seat{}, &Seat{}},
 		}
-		flight.AddSeatClass("Economy", layout, 1000.0)
+		flight.AddSeatClass("Economy", layout, money.FromFloat(1000, "USD"))
 
 		if len(flight.Seats["Economy"]) != 6 {
 			t.Errorf("expected 6 seats, got %d", len(flight.Seats["Economy"]))
 		}
-		if flight.BasePrices["Economy"] != 1000.0 {
-			t.Errorf("expected base price 1000.0, got %f", flight.BasePrices["Economy"])
+		if flight.BasePrices["Economy"] != money.FromFloat(1000, "USD") {
+			t.Errorf("expected base price 1000.00 USD, got %s", flight.BasePrices["Economy"])
 		}
 		if flight.Columns["Economy"] != 2 || flight.Rows["Economy"] != 3 {
 			t.Errorf("unexpected columns or rows")
@@ -52,10 +56,10 @@ func TestAddSeatClass(t *testing.T) {
 		layout := [][]*Seat{
 			{&Seat{}},
 		}
-		flight.AddSeatClass("Economy", layout, 500.0)
-		flight.AddSeatClass("Economy", layout, 999.0)
-		if flight.BasePrices["Economy"] != 500.0 {
-			t.Errorf("expected base price to remain 500.0, got %f", flight.BasePrices["Economy"])
+		flight.AddSeatClass("Economy", layout, money.FromFloat(500, "USD"))
+		flight.AddSeatClass("Economy", layout, money.FromFloat(999, "USD"))
+		if flight.BasePrices["Economy"] != money.FromFloat(500, "USD") {
+			t.Errorf("expected base price to remain 500.00 USD, got %s", flight.BasePrices["Economy"])
 		}
 	})
 
@@ -66,7 +70,7 @@ func TestAddSeatClass(t *testing.T) {
 				t.Errorf("expected panic on empty layout")
 			}
 		}()
-		flight.AddSeatClass("Economy", [][]*Seat{}, 500.0)
+		flight.AddSeatClass("Economy", [][]*Seat{}, money.FromFloat(500, "USD"))
 	})
 }
 
@@ -77,14 +81,14 @@ func TestGetAvailableSeats(t *testing.T) {
 			{&Seat{}, &Seat{}},
 			{&Seat{}, &Seat{}},
 		}
-		flight.AddSeatClass("Business", layout, 2000.0)
+		flight.AddSeatClass("Business", layout, money.FromFloat(2000, "USD"))
 
 		available := flight.getAvailableSeats("Business")
 		if len(available) != 4 {
 			t.Errorf("expected 4 available seats, got %d", len(available))
 		}
 
-		available[0].IsBooked = true
+		available[0].SetBooked(true)
 		available2 := flight.getAvailableSeats("Business")
 		if len(available2) != 3 {
 			t.Errorf("expected 3 available seats after booking, got %d", len(available2))
@@ -104,7 +108,7 @@ func TestGetAvailableSeats(t *testing.T) {
 		layout := [][]*Seat{
 			{{Special: "Blocked"}, {Special: "Blocked"}},
 		}
-		flight.AddSeatClass("Economy", layout, 1000.0)
+		flight.AddSeatClass("Economy", layout, money.FromFloat(1000, "USD"))
 		available := flight.getAvailableSeats("Economy")
 		if len(available) != 0 {
 			t.Errorf("expected 0 available seats, got %d", len(available))
@@ -112,6 +116,150 @@ func TestGetAvailableSeats(t *testing.T) {
 	})
 }
 
+func TestInventory(t *testing.T) {
+	newFlight := func() *Flight {
+		f := InitializeFlight("FL126", "BKK", "SYD", "Boeing 747", time.Now(), time.Now().Add(9*time.Hour))
+		f.AddSeatClass("Economy", [][]*Seat{{{}, {}, {Special: "Blocked"}}}, money.FromFloat(100, "USD"))
+		return f
+	}
+
+	t.Run("Availability", func(t *testing.T) {
+		f := newFlight()
+		f.Seats["Economy"][0].SetBooked(true)
+		a, ok := f.Availability("Economy")
+		if !ok || a != (Availability{Total: 3, Available: 1, Booked: 1}) {
+			t.Errorf("unexpected availability %+v", a)
+		}
+		if _, ok := f.Availability("First"); ok {
+			t.Errorf("expected no availability for a missing class")
+		}
+	})
+
+	t.Run("SeatSnapshotIsACopy", func(t *testing.T) {
+		f := newFlight()
+		seats := f.SeatSnapshot("Economy")
+		seats[0].IsBooked = true
+		if len(seats) != 3 || f.Seats["Economy"][0].IsBookedSeat() {
+			t.Errorf("expected a copy of 3 seats, got %+v", seats)
+		}
+	})
+
+	t.Run("ReleaseSeat", func(t *testing.T) {
+		f := newFlight()
+		seat := f.Seats["Economy"][1]
+		seat.SetBooked(true)
+		booked := seat.State().Version
+		if !f.ReleaseSeat("Economy", "A2", booked) || seat.IsBookedSeat() {
+			t.Errorf("expected A2 released")
+		}
+		if f.ReleaseSeat("Economy", "A2", booked) || f.ReleaseSeat("Economy", "Z9", 0) || f.ReleaseSeat("First", "A1", 0) {
+			t.Errorf("expected nothing more to release")
+		}
+
+		// Booked again by someone else: the first booking's release is refused
+		seat.SetBooked(true)
+		if f.ReleaseSeat("Economy", "A2", booked) || !seat.IsBookedSeat() {
+			t.Errorf("expected the new booking to keep A2")
+		}
+	})
+
+	t.Run("UndoRelease", func(t *testing.T) {
+		seat := newFlight().Seats["Economy"][0]
+		seat.SetBooked(true)
+		booked := seat.State().Version
+		if seat.UndoRelease(booked) {
+			t.Fatalf("expected a seat still booked refused")
+		}
+		seat.CompareAndSetBooked(booked, false)
+		if !seat.UndoRelease(booked) {
+			t.Fatalf("expected the release undone")
+		}
+		if now := seat.State(); !now.IsBooked || now.Version != booked {
+			t.Errorf("expected the seat booked at version %d, got %+v", booked, now)
+		}
+
+		// Freed and booked again by someone else: the seat is theirs.
+		seat.CompareAndSetBooked(booked, false)
+		seat.SetBooked(true)
+		if seat.UndoRelease(booked) || seat.State().Version != booked+2 {
+			t.Errorf("expected the new booking to keep the seat, got %+v", seat.State())
+		}
+	})
+
+	t.Run("CompareAndSetBooked", func(t *testing.T) {
+		seat := newFlight().Seats["Economy"][0]
+		seen := seat.State()
+		if seen.IsBooked || seen.Version != 0 {
+			t.Fatalf("unexpected state %+v", seen)
+		}
+		if !seat.CompareAndSetBooked(seen.Version, true) {
+			t.Fatalf("expected the seat booked")
+		}
+		if seat.CompareAndSetBooked(seen.Version, true) {
+			t.Errorf("expected a stale version to be refused")
+		}
+		now := seat.State()
+		if !now.IsBooked || now.Version != 1 || seat.CompareAndSetBooked(now.Version, true) {
+			t.Errorf("expected a booked seat at version 1 that cannot be booked again, got %+v", now)
+		}
+
+		// Booked and freed again in between: the seat is free but has moved on.
+		seat.SetBooked(false)
+		if seat.CompareAndSetBooked(seen.Version, true) || seat.State().Version != 2 {
+			t.Errorf("expected version 0 refused at version 2, got %+v", seat.State())
+		}
+	})
+
+	t.Run("WatchSeats", func(t *testing.T) {
+		f := newFlight()
+		changed, stop := f.WatchSeats()
+		f.Seats["Economy"][0].SetBooked(true)
+		f.ReleaseSeat("Economy", "A1", 1)
+		select {
+		case <-changed:
+		default:
+			t.Fatal("expected a change reported")
+		}
+		select {
+		case <-changed:
+			t.Error("expected changes made before the receive coalesced")
+		default:
+		}
+		stop()
+		stop()
+		f.Seats["Economy"][1].SetBooked(true)
+		select {
+		case <-changed:
+			t.Error("expected nothing reported after stop")
+		default:
+		}
+	})
+
+	t.Run("ConcurrentUse", func(t *testing.T) {
+		f := newFlight()
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				seat := f.Seats["Economy"][0]
+				if state := seat.State(); !state.IsBooked && seat.CompareAndSetBooked(state.Version, true) {
+					f.ReleaseSeat("Economy", "A1", state.Version+1)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				_, _ = f.Availability("Economy")
+				_ = f.SeatSnapshot("Economy")
+			}()
+		}
+		wg.Wait()
+		if a, _ := f.Availability("Economy"); a.Booked != 0 {
+			t.Errorf("expected every seat released, got %+v", a)
+		}
+	})
+}
+
 func TestSeatIDFormat(t *testing.T) {
 	t.Run("SeatIDNotEmpty", func(t *testing.T) {
 		flight := InitializeFlight("FL204", "BKK", "SYD", "Boeing 737", time.Now(), time.Now().Add(9*time.Hour))
@@ -119,7 +267,7 @@ func TestSeatIDFormat(t *testing.T) {
 			{&Seat{}, &Seat{}},
 			{&Seat{}, &Seat{}},
 		}
-		flight.AddSeatClass("Economy", layout, 1000.0)
+		flight.AddSeatClass("Economy", layout, money.FromFloat(1000, "USD"))
 		for _, seat := range flight.Seats["Economy"] {
 			if seat.SeatID == "" {
 				t.Errorf("seat ID should not be empty")
@@ -172,60 +320,113 @@ func TestBestSeat(t *testing.T) {
 }
 
 func TestCalculatePrice(t *testing.T) {
-	base := 1000.0
+	base := money.FromFloat(1000, "USD")
 	departure := time.Now().Add(40 * 24 * time.Hour)
 	bookingDate := time.Now()
 
 	t.Run("MoreThan30Days", func(t *testing.T) {
-		price := CalculatePrice(base, departure, bookingDate, 0, false)
-		expected := base * 0.9
+		price := CalculatePrice(base, departure, bookingDate, 0, 10, false)
+		expected := money.FromFloat(1000*0.9, "USD")
 		if price != expected {
-			t.Errorf("expected %.2f, got %.2f", expected, price)
+			t.Errorf("expected %s, got %s", expected, price)
 		}
 	})
 
 	t.Run("LessThanOrEqual7Days", func(t *testing.T) {
 		dep := time.Now().Add(5 * 24 * time.Hour)
-		price := CalculatePrice(base, dep, bookingDate, 0, false)
-		expected := base * 1.2
+		price := CalculatePrice(base, dep, bookingDate, 0, 10, false)
+		expected := money.FromFloat(1000*1.2, "USD")
 		if price != expected {
-			t.Errorf("expected %.2f, got %.2f", expected, price)
+			t.Errorf("expected %s, got %s", expected, price)
 		}
 	})
 
 	t.Run("Between8And30Days", func(t *testing.T) {
 		dep := time.Now().Add(15 * 24 * time.Hour)
-		price := CalculatePrice(base, dep, bookingDate, 0, false)
+		price := CalculatePrice(base, dep, bookingDate, 0, 10, false)
 		expected := base
 		if price != expected {
-			t.Errorf("expected %.2f, got %.2f", expected, price)
+			t.Errorf("expected %s, got %s", expected, price)
 		}
 	})
 
 	t.Run("WithBookedRatio", func(t *testing.T) {
-		price := CalculatePrice(base, departure, bookingDate, 0.5, false)
-		expected := base * 0.9 * 1.5
+		price := CalculatePrice(base, departure, bookingDate, 5, 10, false)
+		expected := money.FromFloat(1000*0.9*1.5, "USD")
 		if price != expected {
-			t.Errorf("expected %.2f, got %.2f", expected, price)
+			t.Errorf("expected %s, got %s", expected, price)
 		}
 	})
 
 	t.Run("FrequentFlyerDiscount", func(t *testing.T) {
-		price := CalculatePrice(base, departure, bookingDate, 0.2, true)
-		expected := base * 0.9 * 1.2 * 0.95
+		price := CalculatePrice(base, departure, bookingDate, 2, 10, true)
+		expected := money.FromFloat(1000*0.9*1.2*0.95, "USD")
 		if price != expected {
-			t.Errorf("expected %.2f, got %.2f", expected, price)
+			t.Errorf("expected %s, got %s", expected, price)
+		}
+	})
+
+	t.Run("RoundsEachStep", func(t *testing.T) {
+		// 5 cents: 4.5 rounds to 5, then 4.75 rounds to 5 again, where a
+		// single rounding of 5*0.9*0.95 = 4.275 would give 4
+		price := CalculatePrice(money.New(5, "USD"), departure, bookingDate, 0, 10, true)
+		if price != money.New(5, "USD") {
+			t.Errorf("expected 0.05 USD, got %s", price)
+		}
+	})
+
+	t.Run("KeepsCurrency", func(t *testing.T) {
+		price := CalculatePrice(money.FromFloat(12345, "JPY"), departure, bookingDate, 0, 10, false)
+		if price != money.New(11111, "JPY") {
+			t.Errorf("expected 11111 JPY, got %s", price)
 		}
 	})
 }
 
+func TestPricing(t *testing.T) {
+	base := money.FromFloat(1000, "USD")
+	bookingDate := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	p := Pricing{EarlyDays: 60, EarlyDiscount: 25, LateDays: 14, LateSurcharge: 50, FrequentFlyerDiscount: 10}
+
+	for _, tc := range []struct {
+		days     int
+		ff       bool
+		expected float64
+	}{
+		{60, false, 750},
+		{40, false, 1000}, // outside both windows
+		{30, false, 1000}, // the default early window no longer applies
+		{14, false, 1500},
+		{14, true, 1350},
+	} {
+		price := p.Price(base, bookingDate.AddDate(0, 0, tc.days), bookingDate, 0, 10, tc.ff)
+		if expected := money.FromFloat(tc.expected, "USD"); price != expected {
+			t.Errorf("%d days, frequent flyer %v: expected %s, got %s", tc.days, tc.ff, expected, price)
+		}
+	}
+
+	if err := DefaultPricing.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []Pricing{
+		{EarlyDays: 7, LateDays: 7},
+		{EarlyDays: 30, LateDays: -1},
+		{EarlyDays: 30, LateDays: 7, EarlyDiscount: 100},
+		{EarlyDays: 30, LateDays: 7, LateSurcharge: -5},
+		{EarlyDays: 30, LateDays: 7, FrequentFlyerDiscount: -1},
+	} {
+		if err := bad.Validate(); !errors.Is(err, ErrInvalidPricing) {
+			t.Errorf("%+v: expected ErrInvalidPricing, got %v", bad, err)
+		}
+	}
+}
+
 func TestSeatInterfaceMethods(t *testing.T) {
 	seat := &Seat{
-		SeatID:   "A1",
-		Row:      1,
-		Column:   1,
-		Special:  "VIP",
-		IsBooked: false,
+		SeatID:  "A1",
+		Row:     1,
+		Column:  1,
+		Special: "VIP",
 	}
 	if seat.GetSeatID() != "A1" {
 		t.Errorf("GetSeatID failed")
@@ -264,7 +465,7 @@ func TestFlightInterfaceMethods(t *testing.T) {
 				{SeatID: "C1"}, {SeatID: "C2"},
 			},
 		},
-		BasePrices: map[SeatClass]float64{"Economy": 1000.0},
+		BasePrices: map[SeatClass]money.Money{"Economy": money.FromFloat(1000, "USD")},
 		Aircraft:   "Boeing 777",
 		Mutex:      map[SeatClass]*sync.Mutex{"Economy": &sync.Mutex{}},
 	}
@@ -283,7 +484,7 @@ func TestFlightInterfaceMethods(t *testing.T) {
 	if flight.GetRows("Economy") != 3 {
 		t.Errorf("GetRows failed")
 	}
-	if flight.GetBasePrice("Economy") != 1000.0 {
+	if flight.GetBasePrice("Economy") != money.FromFloat(1000, "USD") {
 		t.Errorf("GetBasePrice failed")
 	}
 	if !flight.GetDeparture().Equal(flight.Departure) {
@@ -316,3 +517,87 @@ func TestMutexInterfaceType(t *testing.T) {
 	mtx.Lock()
 	mtx.Unlock()
 }
+
+func TestDaysBefore(t *testing.T) {
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+
+	t.Run("CountsOriginCalendarDays", func(t *testing.T) {
+		// 2024-07-10 01:00 in Bangkok is still 2024-07-09 in UTC
+		departure := time.Date(2024, 7, 10, 1, 0, 0, 0, bangkok)
+		booked := time.Date(2024, 7, 2, 20, 0, 0, 0, time.UTC) // 2024-07-03 03:00 in Bangkok
+		if got := DaysBefore(departure, booked); got != 7 {
+			t.Errorf("expected 7 days, got %d", got)
+		}
+	})
+
+	t.Run("SameDay", func(t *testing.T) {
+		departure := time.Date(2024, 7, 10, 23, 0, 0, 0, bangkok)
+		booked := time.Date(2024, 7, 10, 0, 30, 0, 0, bangkok)
+		if got := DaysBefore(departure, booked); got != 0 {
+			t.Errorf("expected 0 days, got %d", got)
+		}
+	})
+
+	t.Run("PriceUsesLocalDays", func(t *testing.T) {
+		// 30 hours before departure but already 29 local days before
+		departure := time.Date(2024, 8, 1, 6, 0, 0, 0, bangkok)
+		booked := time.Date(2024, 7, 3, 0, 0, 0, 0, bangkok)
+		if price := CalculatePrice(money.FromFloat(1000, "USD"), departure, booked, 0, 10, false); price != money.FromFloat(1000, "USD") {
+			t.Errorf("expected standard price 1000.00 USD, got %s", price)
+		}
+	})
+}
+
+func TestCalculateRefund(t *testing.T) {
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+	departure := time.Date(2024, 7, 10, 8, 0, 0, 0, bangkok)
+	rules := fare.Rules{Refundable: true, RefundPercent: 80, LateRefundPercent: 50}
+
+	t.Run("BeforeCutoff", func(t *testing.T) {
+		cancelled := time.Date(2024, 7, 9, 16, 0, 0, 0, time.UTC) // 2024-07-09 23:00 in Bangkok
+		if refund := CalculateRefund(money.FromFloat(1000, "USD"), rules, departure, cancelled); refund != money.FromFloat(800, "USD") {
+			t.Errorf("expected 800.00 USD, got %s", refund)
+		}
+	})
+
+	t.Run("AfterCutoff", func(t *testing.T) {
+		cancelled := time.Date(2024, 7, 9, 17, 30, 0, 0, time.UTC) // 2024-07-10 00:30 in Bangkok
+		if refund := CalculateRefund(money.FromFloat(1000, "USD"), rules, departure, cancelled); refund != money.FromFloat(500, "USD") {
+			t.Errorf("expected 500.00 USD, got %s", refund)
+		}
+	})
+
+	t.Run("DefaultRulesHaveNoCutoff", func(t *testing.T) {
+		cancelled := time.Date(2024, 7, 9, 17, 30, 0, 0, time.UTC)
+		if refund := CalculateRefund(money.FromFloat(1000, "USD"), fare.DefaultRules, departure, cancelled); refund != money.FromFloat(800, "USD") {
+			t.Errorf("expected 800.00 USD, got %s", refund)
+		}
+	})
+}
+
+func TestSetFareFamilies(t *testing.T) {
+	flight := InitializeFlight("FL400", "BKK", "SIN", "Boeing 737", time.Now(), time.Now().Add(2*time.Hour))
+	flight.AddSeatClass("Economy", [][]*Seat{{&Seat{}, &Seat{}}}, money.FromFloat(500, "USD"))
+
+	t.Run("KnownClass", func(t *testing.T) {
+		err := flight.SetFareFamilies("Economy", []fare.Family{{Code: "BASIC", Price: money.FromFloat(300, "USD")}, {Code: "FLEX", Price: money.FromFloat(700, "USD")}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if f, ok := flight.Fares["Economy"].Lowest(); !ok || f.Code != "BASIC" {
+			t.Errorf("expected BASIC to be the lowest family")
+		}
+	})
+
+	t.Run("UnknownClass", func(t *testing.T) {
+		if err := flight.SetFareFamilies("First", []fare.Family{{Code: "F", Price: money.FromFloat(1, "USD")}}); err != ErrSeatClassNotFound {
+			t.Errorf("expected ErrSeatClassNotFound, got %v", err)
+		}
+	})
+
+	t.Run("InvalidFamilies", func(t *testing.T) {
+		if err := flight.SetFareFamilies("Economy", nil); err == nil {
+			t.Error("expected error for empty fare families")
+		}
+	})
+}
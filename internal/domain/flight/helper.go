@@ -1,10 +1,38 @@
 package flight
 
 import (
+	"fmt"
 	"sort"
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
+// nextState is a seat state one version on from old, booked or not.
+func nextState(old uint64, booked bool) uint64 {
+	next := (old>>1 + 1) << 1
+	if booked {
+		next |= 1
+	}
+	return next
+}
+
+// notify wakes every watcher without waiting for any of them.
+func (fd *seatFeed) notify() {
+	if fd == nil || fd.watching.Load() == 0 {
+		return
+	}
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	for ch := range fd.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func BestSeat(seats []*Seat, col, row int) *Seat {
 	if len(seats) == 0 {
 		return nil
@@ -25,18 +53,64 @@ func BestSeat(seats []*Seat, col, row int) *Seat {
 	return seats[0]
 }
 
-func CalculatePrice(base float64, departure, bookingDate time.Time, bookedRatio float64, isFrequentFlyer bool) float64 {
+// CalculatePrice prices a seat with DefaultPricing.
+func CalculatePrice(base money.Money, departure, bookingDate time.Time, booked, total int, isFrequentFlyer bool) money.Money {
+	return DefaultPricing.Price(base, departure, bookingDate, booked, total, isFrequentFlyer)
+}
+
+// Price prices a seat in three steps, each rounded half away from zero to the
+// currency's increment before the next:
+//
+//  1. EarlyDiscount off at least EarlyDays local days out, LateSurcharge on
+//     top within LateDays
+//  2. plus the booked share of the cabin, booked/total, including this seat
+//  3. FrequentFlyerDiscount off for frequent flyers
+func (p Pricing) Price(base money.Money, departure, bookingDate time.Time, booked, total int, isFrequentFlyer bool) money.Money {
 	price := base
-	days := int(departure.Sub(bookingDate).Hours() / 24)
+	days := DaysBefore(departure, bookingDate)
 	switch {
-	case days >= 30:
-		price *= 0.9
-	case days <= 7:
-		price *= 1.2
+	case days >= p.EarlyDays:
+		price = price.MulRatio(100-p.EarlyDiscount, 100)
+	case days <= p.LateDays:
+		price = price.MulRatio(100+p.LateSurcharge, 100)
+	}
+	if total > 0 {
+		price = price.MulRatio(int64(total+booked), int64(total))
 	}
-	price *= (1 + bookedRatio)
 	if isFrequentFlyer {
-		price *= 0.95 // 5% discount
+		price = price.MulRatio(100-p.FrequentFlyerDiscount, 100)
 	}
 	return price
 }
+
+// Validate checks the thresholds leave a window between early and late
+// bookings and that every percentage is sensible.
+func (p Pricing) Validate() error {
+	switch {
+	case p.LateDays < 0 || p.EarlyDays <= p.LateDays:
+		return fmt.Errorf("%w: early days must exceed late days, which must not be negative", ErrInvalidPricing)
+	case p.EarlyDiscount < 0 || p.EarlyDiscount >= 100:
+		return fmt.Errorf("%w: early discount must be between 0 and 99 percent", ErrInvalidPricing)
+	case p.LateSurcharge < 0:
+		return fmt.Errorf("%w: late surcharge must not be negative", ErrInvalidPricing)
+	case p.FrequentFlyerDiscount < 0 || p.FrequentFlyerDiscount >= 100:
+		return fmt.Errorf("%w: frequent flyer discount must be between 0 and 99 percent", ErrInvalidPricing)
+	}
+	return nil
+}
+
+// CalculateRefund applies the fare rules' cancellation fee, with the cutoff
+// counted in the departure's local days.
+func CalculateRefund(price money.Money, rules fare.Rules, departure, cancelDate time.Time) money.Money {
+	return rules.Refund(price, DaysBefore(departure, cancelDate))
+}
+
+// DaysBefore counts calendar days from t until departure, both taken in the
+// departure's time zone, so the result follows the origin airport's dates.
+func DaysBefore(departure, t time.Time) int {
+	y1, m1, d1 := t.In(departure.Location()).Date()
+	y2, m2, d2 := departure.Date()
+	from := time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
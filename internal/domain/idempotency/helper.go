@@ -0,0 +1,36 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Fingerprint identifies a request by its method, path and body. JSON bodies
+// are compacted first so whitespace alone does not make a retry different.
+func Fingerprint(method, path string, body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func copyResponse(r *Response) *Response {
+	c := *r
+	c.Body = append([]byte(nil), r.Body...)
+	return &c
+}
+
+func (s *Store) purge(now time.Time) {
+	for k, e := range s.entries {
+		if e.response != nil && !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+}
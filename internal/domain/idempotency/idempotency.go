@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"fmt"
+	"time"
+)
+
+// NewStore returns a store keeping responses for window, or DefaultWindow
+// when it is not positive.
+func NewStore(window time.Duration) *Store {
+	s := &Store{entries: make(map[string]*entry)}
+	s.SetWindow(window)
+	return s
+}
+
+// SetWindow changes how long responses completed from now on are kept.
+func (s *Store) SetWindow(window time.Duration) {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window = window
+}
+
+// Window returns how long responses are kept.
+func (s *Store) Window() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.window
+}
+
+// Begin claims key for a request identified by fingerprint. It returns the
+// stored response when the same request already completed, and nil when the
+// caller should run the request and then Complete or Abandon the key. A key
+// reused for a different request, or still held by a running request, is an
+// error.
+func (s *Store) Begin(key, fingerprint string, now time.Time) (*Response, error) {
+	if key == "" || len(key) > MaxKeyLength {
+		return nil, fmt.Errorf("%w: must be 1 to %d characters", ErrInvalidKey, MaxKeyLength)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge(now)
+	e, ok := s.entries[key]
+	if !ok {
+		s.entries[key] = &entry{fingerprint: fingerprint}
+		return nil, nil
+	}
+	if e.fingerprint != fingerprint {
+		return nil, fmt.Errorf("%w: %s", ErrKeyReused, key)
+	}
+	if e.response == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInProgress, key)
+	}
+	return copyResponse(e.response), nil
+}
+
+// Complete stores the response to a key claimed by Begin.
+func (s *Store) Complete(key string, resp Response, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+	e.response = copyResponse(&resp)
+	e.expires = now.Add(s.window)
+}
+
+// Purge drops the responses whose window has passed by now.
+func (s *Store) Purge(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge(now)
+}
+
+// Abandon frees a key claimed by Begin without storing a response, so a
+// retry runs the request again.
+func (s *Store) Abandon(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.response == nil {
+		delete(s.entries, key)
+	}
+}
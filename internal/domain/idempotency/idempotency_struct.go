@@ -0,0 +1,40 @@
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultWindow is how long a response is kept for replay.
+const DefaultWindow = 24 * time.Hour
+
+// MaxKeyLength bounds the Idempotency-Key header.
+const MaxKeyLength = 255
+
+var (
+	ErrInvalidKey = errors.New("invalid idempotency key")
+	ErrKeyReused  = errors.New("idempotency key reused with a different request")
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// Response is the first response sent for a key, replayed for its retries.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+type entry struct {
+	fingerprint string
+	response    *Response // nil while the first request is running
+	expires     time.Time
+}
+
+// Store remembers the response to each idempotency key for a window after it
+// was sent.
+type Store struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*entry
+}
@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	book := Fingerprint("POST", "/book", []byte(`{"flight_id":"F1"}`))
+
+	t.Run("ReplaysFirstResponse", func(t *testing.T) {
+		s := NewStore(time.Hour)
+		resp, err := s.Begin("k1", book, now)
+		if err != nil || resp != nil {
+			t.Fatalf("expected to run the request, got %v, %v", resp, err)
+		}
+		s.Complete("k1", Response{Status: 200, ContentType: "application/json", Body: []byte(`{"booking_id":"B1"}`)}, now)
+		resp, err = s.Begin("k1", book, now.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp == nil || resp.Status != 200 || string(resp.Body) != `{"booking_id":"B1"}` {
+			t.Errorf("expected the stored response, got %+v", resp)
+		}
+		resp.Body[0] = 'x'
+		if again, _ := s.Begin("k1", book, now); again.Body[0] != '{' {
+			t.Errorf("expected replays not to share the stored body")
+		}
+	})
+
+	t.Run("DifferentRequest", func(t *testing.T) {
+		s := NewStore(time.Hour)
+		_, _ = s.Begin("k1", book, now)
+		s.Complete("k1", Response{Status: 200}, now)
+		for _, fp := range []string{
+			Fingerprint("POST", "/book", []byte(`{"flight_id":"F2"}`)),
+			Fingerprint("POST", "/cancel", []byte(`{"flight_id":"F1"}`)),
+		} {
+			if _, err := s.Begin("k1", fp, now); !errors.Is(err, ErrKeyReused) {
+				t.Errorf("expected ErrKeyReused, got %v", err)
+			}
+		}
+	})
+
+	t.Run("InProgress", func(t *testing.T) {
+		s := NewStore(time.Hour)
+		_, _ = s.Begin("k1", book, now)
+		if _, err := s.Begin("k1", book, now); !errors.Is(err, ErrInProgress) {
+			t.Errorf("expected ErrInProgress, got %v", err)
+		}
+		s.Abandon("k1")
+		if resp, err := s.Begin("k1", book, now); err != nil || resp != nil {
+			t.Errorf("expected an abandoned key to run again, got %v, %v", resp, err)
+		}
+	})
+
+	t.Run("Expires", func(t *testing.T) {
+		s := NewStore(time.Hour)
+		_, _ = s.Begin("k1", book, now)
+		s.Complete("k1", Response{Status: 200}, now)
+		if resp, _ := s.Begin("k1", book, now.Add(time.Hour-time.Second)); resp == nil {
+			t.Errorf("expected the response within the window")
+		}
+		other := Fingerprint("POST", "/book", []byte(`{}`))
+		if resp, err := s.Begin("k1", other, now.Add(time.Hour)); err != nil || resp != nil {
+			t.Errorf("expected an expired key to be free, got %v, %v", resp, err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		s := NewStore(time.Hour)
+		_, _ = s.Begin("done", book, now)
+		s.Complete("done", Response{Status: 200}, now)
+		_, _ = s.Begin("running", book, now)
+		s.Purge(now.Add(time.Hour))
+		if len(s.entries) != 1 || s.entries["running"] == nil {
+			t.Errorf("expected only the running request to remain, got %d entries", len(s.entries))
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		s := NewStore(0)
+		if s.Window() != DefaultWindow {
+			t.Errorf("expected the default window, got %v", s.Window())
+		}
+		for _, key := range []string{"", strings.Repeat("k", MaxKeyLength+1)} {
+			if _, err := s.Begin(key, book, now); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("expected ErrInvalidKey, got %v", err)
+			}
+		}
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("POST", "/book", []byte(`{"flight_id": "F1"}`))
+	if b := Fingerprint("POST", "/book", []byte("{\n  \"flight_id\":\"F1\"\n}")); a != b {
+		t.Errorf("expected whitespace to be ignored")
+	}
+	if b := Fingerprint("POST", "/book", []byte(`{"flight_id":"F2"}`)); a == b {
+		t.Errorf("expected different bodies to differ")
+	}
+}
@@ -0,0 +1,71 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// currencyOf looks up a currency, treating unknown codes as having two
+// digits so amounts in them still format sensibly.
+func currencyOf(code string) Currency {
+	if c, err := LookupCurrency(code); err == nil {
+		return c
+	}
+	return Currency{Code: NormalizeCode(code), Digits: 2, Increment: 1}
+}
+
+// round rounds an exact minor unit amount half away from zero to the
+// increment.
+func (c Currency) round(minor *big.Rat) int64 {
+	increment := c.Increment
+	if increment < 1 {
+		increment = 1
+	}
+	steps := new(big.Rat).Quo(minor, new(big.Rat).SetInt64(increment))
+	num := new(big.Int).Abs(steps.Num())
+	quo, rem := new(big.Int).QuoRem(num, steps.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(steps.Denom()) >= 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+	if steps.Sign() < 0 {
+		quo.Neg(quo)
+	}
+	return quo.Int64() * increment
+}
+
+// decimalRat returns f as the exact value of its shortest decimal form.
+func decimalRat(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		// NaN and infinities have no decimal form.
+		return new(big.Rat)
+	}
+	return r
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// parseMinor converts a decimal string to minor units without going through
+// a float.
+func parseMinor(amount string, digits int) (int64, error) {
+	amount = strings.TrimSpace(amount)
+	negative := strings.HasPrefix(amount, "-")
+	amount = strings.TrimPrefix(amount, "-")
+	whole, frac, _ := strings.Cut(amount, ".")
+	if whole == "" || len(frac) > digits {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
@@ -0,0 +1,287 @@
+package money
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed rates.csv
+var bundledRates []byte
+
+// currencies lists the supported ISO 4217 currencies. CHF prices round to
+// the 5 rappen cash increment.
+var currencies = map[string]Currency{
+	"AED": {Code: "AED", Digits: 2, Increment: 1},
+	"AUD": {Code: "AUD", Digits: 2, Increment: 1},
+	"BHD": {Code: "BHD", Digits: 3, Increment: 1},
+	"CAD": {Code: "CAD", Digits: 2, Increment: 1},
+	"CHF": {Code: "CHF", Digits: 2, Increment: 5},
+	"CNY": {Code: "CNY", Digits: 2, Increment: 1},
+	"EUR": {Code: "EUR", Digits: 2, Increment: 1},
+	"GBP": {Code: "GBP", Digits: 2, Increment: 1},
+	"HKD": {Code: "HKD", Digits: 2, Increment: 1},
+	"IDR": {Code: "IDR", Digits: 2, Increment: 1},
+	"INR": {Code: "INR", Digits: 2, Increment: 1},
+	"JPY": {Code: "JPY", Digits: 0, Increment: 1},
+	"KRW": {Code: "KRW", Digits: 0, Increment: 1},
+	"KWD": {Code: "KWD", Digits: 3, Increment: 1},
+	"MYR": {Code: "MYR", Digits: 2, Increment: 1},
+	"NZD": {Code: "NZD", Digits: 2, Increment: 1},
+	"PHP": {Code: "PHP", Digits: 2, Increment: 1},
+	"SGD": {Code: "SGD", Digits: 2, Increment: 1},
+	"THB": {Code: "THB", Digits: 2, Increment: 1},
+	"TWD": {Code: "TWD", Digits: 2, Increment: 1},
+	"USD": {Code: "USD", Digits: 2, Increment: 1},
+	"VND": {Code: "VND", Digits: 0, Increment: 1},
+}
+
+// LookupCurrency returns a supported currency by its ISO 4217 code.
+func LookupCurrency(code string) (Currency, error) {
+	c, ok := currencies[NormalizeCode(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// NormalizeCode trims and upper-cases a currency code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// New returns an amount given in minor units.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: NormalizeCode(currency)}
+}
+
+// FromFloat converts a major unit amount such as 12.345 to money, rounding
+// half away from zero to the currency's increment. The float is read as the
+// shortest decimal that prints as it, so 0.285 is 0.285 and not
+// 0.28499999999999998. Configured prices, fees and discounts are read with
+// Parse or as JSON Money instead, so they never pass through a float.
+func FromFloat(amount float64, currency string) Money {
+	c := currencyOf(currency)
+	minor := decimalRat(amount)
+	minor.Mul(minor, new(big.Rat).SetInt(pow10(c.Digits)))
+	return Money{Amount: c.round(minor), Currency: c.Code}
+}
+
+// Parse reads a decimal amount such as "12.34" exactly. More digits than the
+// currency allows are an error.
+func Parse(amount, currency string) (Money, error) {
+	c := currencyOf(currency)
+	minor, err := parseMinor(amount, c.Digits)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: minor, Currency: c.Code}, nil
+}
+
+// Float returns the amount in major units.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / math.Pow10(currencyOf(m.Currency).Digits)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m+o; both must be in the same currency. The zero Money adds
+// as nothing so totals can start from it.
+func (m Money) Add(o Money) (Money, error) {
+	if o == (Money{}) {
+		return m, nil
+	}
+	if m == (Money{}) {
+		return o, nil
+	}
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m-o; both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// MulRatio scales m by num/den, rounding the exact result half away from
+// zero to the currency's increment.
+func (m Money) MulRatio(num, den int64) Money {
+	product := big.NewRat(num, den)
+	product.Mul(product, new(big.Rat).SetInt64(m.Amount))
+	return Money{Amount: currencyOf(m.Currency).round(product), Currency: m.Currency}
+}
+
+// Less orders amounts in the same currency.
+func (m Money) Less(o Money) bool {
+	return m.Amount < o.Amount
+}
+
+// Decimal formats the amount in major units with the currency's digits,
+// e.g. "1234.50".
+func (m Money) Decimal() string {
+	digits := currencyOf(m.Currency).Digits
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if digits == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	scale := int64(math.Pow10(digits))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, digits, amount%scale)
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// MarshalJSON writes {"amount": 1234.50, "currency": "USD"} with the amount
+// as an exact decimal number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	currency, err := json.Marshal(m.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(`{"amount":` + m.Decimal() + `,"currency":` + string(currency) + `}`), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var wire struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&wire); err != nil {
+		return err
+	}
+	parsed, err := Parse(wire.Amount.String(), wire.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func NewRateTable(base string) *RateTable {
+	base = NormalizeCode(base)
+	return &RateTable{base: base, rates: map[string]float64{base: 1}}
+}
+
+// NewDefaultRateTable returns a table loaded with the bundled USD rates.
+func NewDefaultRateTable() *RateTable {
+	t := NewRateTable(DefaultCurrency)
+	if err := t.LoadCSV(bytes.NewReader(bundledRates)); err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// LoadCSV replaces the table's rates with ones read from the header
+// currency,rate. The table's base currency keeps a rate of 1.
+func (t *RateTable) LoadCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRate, err)
+	}
+	rates := make(map[string]float64)
+	for i, rec := range records {
+		if i == 0 {
+			continue
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalidRate, i+1, err)
+		}
+		rates[rec[0]] = rate
+	}
+	return t.Replace(rates)
+}
+
+// Replace validates rates and swaps them in as a whole.
+func (t *RateTable) Replace(rates map[string]float64) error {
+	next := map[string]float64{t.base: 1}
+	for code, rate := range rates {
+		if _, err := LookupCurrency(code); err != nil {
+			return err
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return fmt.Errorf("%w: %s rate %v", ErrInvalidRate, code, rate)
+		}
+		next[NormalizeCode(code)] = rate
+	}
+	if next[t.base] != 1 {
+		return fmt.Errorf("%w: base currency %s must have rate 1", ErrInvalidRate, t.base)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rates = next
+	return nil
+}
+
+func (t *RateTable) Base() string {
+	return t.base
+}
+
+// Rates returns a copy of the rates keyed by currency code.
+func (t *RateTable) Rates() map[string]float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result := make(map[string]float64, len(t.rates))
+	for code, rate := range t.rates {
+		result[code] = rate
+	}
+	return result
+}
+
+// Currencies lists the currencies the table can convert, ordered by code.
+func (t *RateTable) Currencies() []string {
+	rates := t.Rates()
+	result := make([]string, 0, len(rates))
+	for code := range rates {
+		result = append(result, code)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Convert returns m in another currency, rounded to that currency's
+// increment.
+func (t *RateTable) Convert(m Money, to string) (Money, error) {
+	to = NormalizeCode(to)
+	if m.Currency == to {
+		return m, nil
+	}
+	t.mu.RLock()
+	from, okFrom := t.rates[m.Currency]
+	target, okTo := t.rates[to]
+	t.mu.RUnlock()
+	if !okFrom {
+		return Money{}, fmt.Errorf("%w: %s", ErrRateNotFound, m.Currency)
+	}
+	if !okTo {
+		return Money{}, fmt.Errorf("%w: %s", ErrRateNotFound, to)
+	}
+	// minor * target/from * 10^(to digits - from digits), rounded once.
+	source, dest := currencyOf(m.Currency), currencyOf(to)
+	minor := new(big.Rat).SetInt64(m.Amount)
+	minor.Mul(minor, decimalRat(target))
+	minor.Quo(minor, decimalRat(from))
+	minor.Mul(minor, new(big.Rat).SetInt(pow10(dest.Digits)))
+	minor.Quo(minor, new(big.Rat).SetInt(pow10(source.Digits)))
+	return Money{Amount: dest.round(minor), Currency: dest.Code}, nil
+}
@@ -0,0 +1,40 @@
+package money
+
+import (
+	"errors"
+	"sync"
+)
+
+// DefaultCurrency is used for flights that do not name a selling currency.
+const DefaultCurrency = "USD"
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrRateNotFound     = errors.New("exchange rate not found")
+	ErrInvalidRate      = errors.New("invalid exchange rate")
+	ErrInvalidAmount    = errors.New("invalid amount")
+)
+
+// Currency describes how amounts in a currency are stored and rounded.
+// Digits is the number of minor unit digits (2 for cents) and Increment the
+// smallest amount charged, in minor units.
+type Currency struct {
+	Code      string `json:"code"`
+	Digits    int    `json:"digits"`
+	Increment int64  `json:"increment"`
+}
+
+// Money is an amount held in its currency's minor units, e.g. cents.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// RateTable converts between currencies. Rates are units of a currency per
+// one unit of the base currency.
+type RateTable struct {
+	mu    sync.RWMutex
+	base  string
+	rates map[string]float64
+}
@@ -0,0 +1,155 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFromFloat(t *testing.T) {
+	cases := []struct {
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{12.345, "USD", 1235},
+		{-12.345, "USD", -1235},
+		{1234.5, "JPY", 1235},
+		{1.2345, "KWD", 1235},
+		{10.02, "CHF", 1000},
+		{10.03, "CHF", 1005},
+		{323.99999999, "THB", 32400},
+		{0.285, "USD", 29},
+		{1.005, "USD", 101},
+	}
+	for _, tc := range cases {
+		if got := FromFloat(tc.amount, tc.currency); got.Amount != tc.want {
+			t.Errorf("FromFloat(%v, %s) = %d, want %d", tc.amount, tc.currency, got.Amount, tc.want)
+		}
+	}
+}
+
+func TestParseAndDecimal(t *testing.T) {
+	m, err := Parse("1234.5", "usd")
+	if err != nil || m.Amount != 123450 || m.Currency != "USD" {
+		t.Fatalf("unexpected %+v, %v", m, err)
+	}
+	if m.Decimal() != "1234.50" || m.String() != "1234.50 USD" {
+		t.Errorf("unexpected formatting %s", m)
+	}
+	if got := New(-5, "USD").Decimal(); got != "-0.05" {
+		t.Errorf("expected -0.05, got %s", got)
+	}
+	if got := New(1500, "JPY").Decimal(); got != "1500" {
+		t.Errorf("expected 1500, got %s", got)
+	}
+	for _, bad := range []string{"1.234", "abc", "", ".5"} {
+		if _, err := Parse(bad, "USD"); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Parse(%q): expected ErrInvalidAmount, got %v", bad, err)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := New(1000, "USD"), New(250, "USD")
+	if sum, _ := a.Add(b); sum.Amount != 1250 {
+		t.Errorf("expected 1250, got %d", sum.Amount)
+	}
+	if diff, _ := a.Sub(b); diff.Amount != 750 {
+		t.Errorf("expected 750, got %d", diff.Amount)
+	}
+	if _, err := a.Add(New(1, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("expected ErrCurrencyMismatch, got %v", err)
+	}
+	if sum, _ := (Money{}).Add(a); sum != a {
+		t.Errorf("expected the zero value to add as nothing, got %+v", sum)
+	}
+	if got := New(999, "USD").MulRatio(80, 100); got.Amount != 799 {
+		t.Errorf("expected 799, got %d", got.Amount)
+	}
+	if got := New(50, "USD").MulRatio(7, 100); got.Amount != 4 {
+		t.Errorf("expected 4, got %d", got.Amount)
+	}
+	if got := New(-50, "USD").MulRatio(7, 100); got.Amount != -4 {
+		t.Errorf("expected half to round away from zero, got %d", got.Amount)
+	}
+	if got := New(100, "USD").MulRatio(4, 3); got.Amount != 133 {
+		t.Errorf("expected 133, got %d", got.Amount)
+	}
+	if got := New(1000, "CHF").MulRatio(103, 100); got.Amount != 1030 {
+		t.Errorf("expected 1030, got %d", got.Amount)
+	}
+	if got := New(1000, "CHF").MulRatio(1027, 1000); got.Amount != 1025 {
+		t.Errorf("expected the 5 rappen increment, got %d", got.Amount)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(New(32400, "THB"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"amount":324.00,"currency":"THB"}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var m Money
+	if err := json.Unmarshal([]byte(`{"amount": 19.99, "currency": "EUR"}`), &m); err != nil || m != New(1999, "EUR") {
+		t.Errorf("unexpected %+v, %v", m, err)
+	}
+}
+
+func TestRateTable(t *testing.T) {
+	t.Run("Convert", func(t *testing.T) {
+		r := NewRateTable("USD")
+		if err := r.Replace(map[string]float64{"THB": 36.5, "JPY": 157}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := r.Convert(New(10000, "USD"), "thb")
+		if err != nil || got != New(365000, "THB") {
+			t.Errorf("expected 3650.00 THB, got %s, %v", got, err)
+		}
+		// Cross rate through the base currency, rounded to whole yen
+		got, _ = r.Convert(New(100000, "THB"), "JPY")
+		if got != New(4301, "JPY") {
+			t.Errorf("expected 4301 JPY, got %s", got)
+		}
+		if _, err := r.Convert(New(100, "USD"), "EUR"); !errors.Is(err, ErrRateNotFound) {
+			t.Errorf("expected ErrRateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("InvalidRates", func(t *testing.T) {
+		r := NewRateTable("USD")
+		bad := []map[string]float64{
+			{"EUR": 0},
+			{"XXX": 1.2},
+			{"USD": 2},
+		}
+		for _, rates := range bad {
+			if err := r.Replace(rates); err == nil {
+				t.Errorf("expected %v to be rejected", rates)
+			}
+		}
+	})
+
+	t.Run("LoadCSV", func(t *testing.T) {
+		r := NewRateTable("USD")
+		if err := r.LoadCSV(strings.NewReader("currency,rate\nEUR,0.9\n")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := strings.Join(r.Currencies(), ","); got != "EUR,USD" {
+			t.Errorf("expected EUR,USD, got %s", got)
+		}
+		if err := r.LoadCSV(strings.NewReader("currency,rate\nEUR,lots\n")); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("expected ErrInvalidRate, got %v", err)
+		}
+	})
+
+	t.Run("Bundled", func(t *testing.T) {
+		r := NewDefaultRateTable()
+		if r.Base() != "USD" || len(r.Currencies()) < 20 {
+			t.Errorf("expected bundled rates, got %v", r.Currencies())
+		}
+	})
+}
@@ -1,15 +1,81 @@
 package passenger
 
+import (
+	"slices"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+)
+
+// Total is the fare plus every tax, fee and ancillary charged on the booking.
+func (b *BookingInfo) Total() money.Money {
+	currency := b.Price.Currency
+	return money.New(b.Price.Amount+tax.Total(b.Charges, currency).Amount+ancillary.Total(b.Ancillaries, currency).Amount, currency)
+}
+
+// Active reports whether the booking holds its seat.
+func (b *BookingInfo) Active() bool {
+	switch b.Status {
+	case StatusPending, StatusConfirmed, StatusChanging, StatusCancelling:
+		return true
+	}
+	return false
+}
+
+// Clone copies the booking deeply enough that changing the copy, its
+// payments included, leaves b as it was.
+func (b *BookingInfo) Clone() *BookingInfo {
+	c := *b
+	c.Promotions = slices.Clone(b.Promotions)
+	c.Charges = slices.Clone(b.Charges)
+	c.Ancillaries = slices.Clone(b.Ancillaries)
+	c.Payments = slices.Clone(b.Payments)
+	return &c
+}
+
+// SwapStatus stores a copy of a booking with status to if its status is
+// still from, and returns the booking as stored afterwards and whether it
+// was changed. Of concurrent swaps from the same status only one succeeds.
+// Storage that is not a StatusSwapper is only safe against other calls of
+// SwapStatus.
+func SwapStatus(s Storage, bookingID, from, to string) (*BookingInfo, bool, error) {
+	if t, ok := s.(StatusSwapper); ok {
+		return t.SwapStatus(bookingID, from, to)
+	}
+	swapMu.Lock()
+	defer swapMu.Unlock()
+	info, err := s.GetBooking(bookingID)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Status != from {
+		return info, false, nil
+	}
+	next := info.Clone()
+	next.Status = to
+	if err := s.SaveBooking(next); err != nil {
+		return nil, false, err
+	}
+	return next, true, nil
+}
+
 func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{bookings: make(map[string]*BookingInfo)}
+	return &InMemoryStorage{bookings: make(map[string]*BookingInfo), outbox: event.NewInMemoryOutbox()}
 }
 
 func (s *InMemoryStorage) SaveBooking(info *BookingInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.bookings[info.BookingID] = info
 	return nil
 }
 
 func (s *InMemoryStorage) GetBooking(bookingID string) (*BookingInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	info, ok := s.bookings[bookingID]
 	if !ok {
 		return nil, ErrBookingNotFound
@@ -18,6 +84,8 @@ func (s *InMemoryStorage) GetBooking(bookingID string) (*BookingInfo, error) {
 }
 
 func (s *InMemoryStorage) ListBookingsByPassenger(passengerID string) ([]*BookingInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var result []*BookingInfo
 	for _, b := range s.bookings {
 		if b.PassengerID == passengerID {
@@ -28,6 +96,8 @@ func (s *InMemoryStorage) ListBookingsByPassenger(passengerID string) ([]*Bookin
 }
 
 func (s *InMemoryStorage) ListBookingsByFlight(flightID string) ([]*BookingInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	var result []*BookingInfo
 	for _, b := range s.bookings {
 		if b.FlightID == flightID {
@@ -37,6 +107,113 @@ func (s *InMemoryStorage) ListBookingsByFlight(flightID string) ([]*BookingInfo,
 	return result, nil
 }
 
+// SwapStatus changes the booking's status from from to to under the storage
+// lock. The stored booking is replaced by a copy, never changed in place.
+func (s *InMemoryStorage) SwapStatus(bookingID, from, to string) (*BookingInfo, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, ok := s.bookings[bookingID]
+	if !ok {
+		return nil, false, ErrBookingNotFound
+	}
+	if info.Status != from {
+		return info, false, nil
+	}
+	next := info.Clone()
+	next.Status = to
+	s.bookings[bookingID] = next
+	return next, true, nil
+}
+
+// Begin starts a transaction on s. A Transactional s adds events to its own
+// Outbox; other storage gets its writes made one by one on Commit, which
+// stops at the first that fails, and its events added to outbox after them.
+func Begin(s Storage, outbox event.Outbox) (Tx, error) {
+	if t, ok := s.(Transactional); ok {
+		return t.Begin()
+	}
+	return &writeOnCommitTx{s: s, outbox: outbox}, nil
+}
+
+// Begin starts a transaction whose writes become visible together on Commit.
+func (s *InMemoryStorage) Begin() (Tx, error) {
+	return &memoryTx{s: s}, nil
+}
+
+// Outbox holds the events committed with bookings until they are published.
+func (s *InMemoryStorage) Outbox() event.Outbox {
+	return s.outbox
+}
+
+func (tx *memoryTx) SaveBooking(info *BookingInfo) error {
+	tx.pending = append(tx.pending, info)
+	return nil
+}
+
+func (tx *memoryTx) AddEvents(at time.Time, events ...event.Event) error {
+	if len(events) > 0 {
+		tx.events = append(tx.events, eventBatch{at: at, events: events})
+	}
+	return nil
+}
+
+// Commit adds the events to the outbox and stores the bookings under the
+// storage lock, so a relay cannot publish an event before its booking can be
+// read.
+func (tx *memoryTx) Commit() error {
+	tx.s.mu.Lock()
+	defer tx.s.mu.Unlock()
+	for _, b := range tx.events {
+		if err := tx.s.outbox.Add(b.at, b.events...); err != nil {
+			return err
+		}
+	}
+	for _, info := range tx.pending {
+		tx.s.bookings[info.BookingID] = info
+	}
+	tx.pending, tx.events = nil, nil
+	return nil
+}
+
+func (tx *memoryTx) Rollback() error {
+	tx.pending, tx.events = nil, nil
+	return nil
+}
+
+func (tx *writeOnCommitTx) SaveBooking(info *BookingInfo) error {
+	tx.pending = append(tx.pending, info)
+	return nil
+}
+
+func (tx *writeOnCommitTx) AddEvents(at time.Time, events ...event.Event) error {
+	if len(events) > 0 {
+		tx.events = append(tx.events, eventBatch{at: at, events: events})
+	}
+	return nil
+}
+
+func (tx *writeOnCommitTx) Commit() error {
+	for _, info := range tx.pending {
+		if err := tx.s.SaveBooking(info); err != nil {
+			return err
+		}
+	}
+	tx.pending = nil
+	for len(tx.events) > 0 && tx.outbox != nil {
+		if err := tx.outbox.Add(tx.events[0].at, tx.events[0].events...); err != nil {
+			return err
+		}
+		tx.events = tx.events[1:]
+	}
+	tx.events = nil
+	return nil
+}
+
+func (tx *writeOnCommitTx) Rollback() error {
+	tx.pending, tx.events = nil, nil
+	return nil
+}
+
 var ErrBookingNotFound = NewNotFoundError("booking not found")
 
 func NewNotFoundError(msg string) error {
@@ -1,6 +1,25 @@
 package passenger
 
-import "time"
+import (
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+)
+
+const (
+	StatusPending    = "Pending" // held while payment is taken
+	StatusConfirmed  = "Confirmed"
+	StatusChanging   = "Changing"   // a change is being priced and paid for
+	StatusCancelling = "Cancelling" // refunding before the seat is released
+	StatusCancelled  = "Cancelled"
+	StatusFailed     = "Failed" // payment failed and the seat was released
+)
 
 type BookingInfo struct {
 	BookingID   string
@@ -8,8 +27,25 @@ type BookingInfo struct {
 	FlightID    string
 	SeatID      string
 	SeatClass   string
+	SeatVersion uint64 // the seat's version once booked, see flight.ReleaseSeat
 	BookedAt    time.Time
-	Price       float64
+	Price       money.Money
+	Status      string
+	Fare        string      // fare family code, empty when the class has none
+	FareRules   *fare.Rules // rules at booking time, nil for the service's DefaultRules
+	Promotions  []promo.Redemption
+	Charges     []tax.Item // taxes and fees on top of Price
+	Ancillaries []ancillary.Item
+	Payments    []Payment // captured payments, oldest first
+	Changes     int       // changes made so far, numbering their refunds
+}
+
+// Payment is money captured for a booking through the payment gateway and
+// how much of it has been refunded.
+type Payment struct {
+	ID       string      `json:"id"`
+	Amount   money.Money `json:"amount"`
+	Refunded money.Money `json:"refunded"`
 }
 
 type Storage interface {
@@ -19,6 +55,59 @@ type Storage interface {
 	ListBookingsByFlight(flightID string) ([]*BookingInfo, error)
 }
 
+// StatusSwapper is storage that can change a booking's status atomically.
+// See SwapStatus.
+type StatusSwapper interface {
+	SwapStatus(bookingID, from, to string) (*BookingInfo, bool, error)
+}
+
+// Tx is a group of booking writes, and the events they publish, stored
+// together or not at all.
+type Tx interface {
+	SaveBooking(info *BookingInfo) error
+	AddEvents(at time.Time, events ...event.Event) error
+	Commit() error
+	Rollback() error
+}
+
+// Transactional is storage that can group writes into a Tx. Its
+// transactions add events to its own Outbox, which is where they are
+// relayed from.
+type Transactional interface {
+	Storage
+	Begin() (Tx, error)
+	Outbox() event.Outbox
+}
+
+// swapMu makes SwapStatus atomic on storage that is not a StatusSwapper.
+var swapMu sync.Mutex
+
+// InMemoryStorage keeps bookings in a map, and the events written with them
+// in an outbox, and is safe for concurrent use.
 type InMemoryStorage struct {
+	mu       sync.Mutex
 	bookings map[string]*BookingInfo
+	outbox   *event.InMemoryOutbox
+}
+
+// memoryTx holds writes to an InMemoryStorage until Commit.
+type memoryTx struct {
+	s       *InMemoryStorage
+	pending []*BookingInfo
+	events  []eventBatch
+}
+
+// writeOnCommitTx holds writes to storage that has no transactions and makes
+// them one by one on Commit, the events last.
+type writeOnCommitTx struct {
+	s       Storage
+	outbox  event.Outbox
+	pending []*BookingInfo
+	events  []eventBatch
+}
+
+// eventBatch is events added to a Tx at one time.
+type eventBatch struct {
+	at     time.Time
+	events []event.Event
 }
@@ -2,8 +2,12 @@ package passenger
 
 import (
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 )
 
 func TestInMemoryStorage(t *testing.T) {
@@ -16,7 +20,7 @@ func TestInMemoryStorage(t *testing.T) {
 			SeatID:      "1A",
 			SeatClass:   "Economy",
 			BookedAt:    time.Now(),
-			Price:       1234.56,
+			Price:       money.New(123456, "USD"),
 		}
 		err := storage.SaveBooking(booking)
 		if err != nil {
@@ -128,6 +132,108 @@ func TestInMemoryStorage(t *testing.T) {
 	})
 }
 
+// plainStorage hides InMemoryStorage's transactions.
+type plainStorage struct{ Storage }
+
+func TestBegin(t *testing.T) {
+	for name, storage := range map[string]func() (Storage, *InMemoryStorage){
+		"InMemory": func() (Storage, *InMemoryStorage) {
+			s := NewInMemoryStorage()
+			return s, s
+		},
+		"WriteOnCommit": func() (Storage, *InMemoryStorage) {
+			s := NewInMemoryStorage()
+			return plainStorage{s}, s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, inner := storage()
+			outbox := inner.Outbox()
+			tx, err := Begin(s, outbox)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_ = tx.SaveBooking(&BookingInfo{BookingID: "B1"})
+			_ = tx.SaveBooking(&BookingInfo{BookingID: "B2"})
+			_ = tx.AddEvents(time.Now(), event.BookingConfirmed{BookingID: "B1"}, event.BookingConfirmed{BookingID: "B2"})
+			if _, err := inner.GetBooking("B1"); err != ErrBookingNotFound {
+				t.Errorf("expected nothing stored before Commit, got %v", err)
+			}
+			if pending, _ := outbox.Pending(); len(pending) != 0 {
+				t.Errorf("expected no events before Commit, got %+v", pending)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, id := range []string{"B1", "B2"} {
+				if _, err := inner.GetBooking(id); err != nil {
+					t.Errorf("expected %s stored, got %v", id, err)
+				}
+			}
+			if pending, _ := outbox.Pending(); len(pending) != 2 || pending[1].Event.(event.BookingConfirmed).BookingID != "B2" {
+				t.Errorf("expected the events committed with the bookings, got %+v", pending)
+			}
+
+			tx, _ = Begin(s, outbox)
+			_ = tx.SaveBooking(&BookingInfo{BookingID: "B3"})
+			_ = tx.AddEvents(time.Now(), event.BookingConfirmed{BookingID: "B3"})
+			_ = tx.Rollback()
+			if _, err := inner.GetBooking("B3"); err != ErrBookingNotFound {
+				t.Errorf("expected a rolled back write to be dropped, got %v", err)
+			}
+			if pending, _ := outbox.Pending(); len(pending) != 2 {
+				t.Errorf("expected rolled back events dropped, got %+v", pending)
+			}
+		})
+	}
+}
+
+func TestSwapStatus(t *testing.T) {
+	for name, storage := range map[string]func() Storage{
+		"InMemory": func() Storage { return NewInMemoryStorage() },
+		"Swapless": func() Storage { return plainStorage{NewInMemoryStorage()} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := storage()
+			stored := &BookingInfo{BookingID: "B1", Status: StatusConfirmed, Payments: []Payment{{ID: "PAY1"}}}
+			_ = s.SaveBooking(stored)
+
+			var wg sync.WaitGroup
+			var won atomic.Int32
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, ok, err := SwapStatus(s, "B1", StatusConfirmed, StatusCancelling); err != nil {
+						t.Errorf("unexpected error: %v", err)
+					} else if ok {
+						won.Add(1)
+					}
+				}()
+			}
+			wg.Wait()
+			if won.Load() != 1 {
+				t.Errorf("expected exactly one swap to win, got %d", won.Load())
+			}
+			got, _ := s.GetBooking("B1")
+			if got.Status != StatusCancelling || stored.Status != StatusConfirmed {
+				t.Errorf("expected a stored copy to change, got %s and %s", got.Status, stored.Status)
+			}
+			got.Payments[0].ID = "CHANGED"
+			if stored.Payments[0].ID != "PAY1" {
+				t.Error("expected the copy not to share payments")
+			}
+
+			if got, ok, _ := SwapStatus(s, "B1", StatusConfirmed, StatusCancelled); ok || got.Status != StatusCancelling {
+				t.Errorf("expected no swap from the wrong status, got %v, %s", ok, got.Status)
+			}
+			if _, _, err := SwapStatus(s, "B9", StatusConfirmed, StatusCancelled); err != ErrBookingNotFound {
+				t.Errorf("expected ErrBookingNotFound, got %v", err)
+			}
+		})
+	}
+}
+
 func TestNotFoundError_Error(t *testing.T) {
 	t.Run("ErrorMessage", func(t *testing.T) {
 		err := NewNotFoundError("not found")
@@ -0,0 +1,16 @@
+package payment
+
+import "fmt"
+
+// payment returns the payment with id when it is in status. It requires f.mu
+// to be held.
+func (f *Fake) payment(id, status string) (*Payment, error) {
+	p, ok := f.payments[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPaymentNotFound, id)
+	}
+	if p.Status != status {
+		return nil, fmt.Errorf("%w: %s is %s", ErrInvalidState, id, p.Status)
+	}
+	return p, nil
+}
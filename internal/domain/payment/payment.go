@@ -0,0 +1,90 @@
+package payment
+
+import (
+	"fmt"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func NewFake() *Fake {
+	return &Fake{payments: make(map[string]*Payment), refunds: make(map[string]refund)}
+}
+
+func (f *Fake) Authorize(reference string, amount money.Money, method string) (string, error) {
+	if method == FakeDeclined {
+		return "", ErrDeclined
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	p := &Payment{
+		ID:        fmt.Sprintf("pay_%d", f.next),
+		Reference: reference,
+		Method:    method,
+		Amount:    amount,
+		Refunded:  money.New(0, amount.Currency),
+		Status:    StatusAuthorized,
+	}
+	f.payments[p.ID] = p
+	return p.ID, nil
+}
+
+func (f *Fake) Capture(paymentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.payment(paymentID, StatusAuthorized)
+	if err != nil {
+		return err
+	}
+	if p.Method == FakeCaptureFail {
+		return ErrCaptureFailed
+	}
+	p.Status = StatusCaptured
+	return nil
+}
+
+func (f *Fake) Void(paymentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.payment(paymentID, StatusAuthorized)
+	if err != nil {
+		return err
+	}
+	p.Status = StatusVoided
+	return nil
+}
+
+func (f *Fake) Refund(refundID, paymentID string, amount money.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.refunds[refundID]; ok {
+		if r.paymentID != paymentID || r.amount != amount {
+			return fmt.Errorf("%w: %s", ErrRefundMismatch, refundID)
+		}
+		return nil
+	}
+	p, err := f.payment(paymentID, StatusCaptured)
+	if err != nil {
+		return err
+	}
+	if amount.Currency != p.Amount.Currency {
+		return fmt.Errorf("%w: refund in %s, paid in %s", money.ErrCurrencyMismatch, amount.Currency, p.Amount.Currency)
+	}
+	if amount.Amount <= 0 || p.Refunded.Amount+amount.Amount > p.Amount.Amount {
+		return fmt.Errorf("%w: %s of %s left", ErrRefundExceeds, amount, money.New(p.Amount.Amount-p.Refunded.Amount, p.Amount.Currency))
+	}
+	p.Refunded = money.New(p.Refunded.Amount+amount.Amount, p.Amount.Currency)
+	f.refunds[refundID] = refund{paymentID: paymentID, amount: amount}
+	return nil
+}
+
+// Payment returns a copy of a payment's current state.
+func (f *Fake) Payment(paymentID string) (Payment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return Payment{}, ErrPaymentNotFound
+	}
+	return *p, nil
+}
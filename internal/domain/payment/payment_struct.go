@@ -0,0 +1,65 @@
+package payment
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// Payment methods the fake gateway treats specially; any other method is
+// approved.
+const (
+	FakeDeclined    = "tok_declined"     // authorization is declined
+	FakeCaptureFail = "tok_capture_fail" // authorizes, then capture fails
+)
+
+const (
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusVoided     = "voided"
+)
+
+var (
+	ErrDeclined        = errors.New("payment declined")
+	ErrCaptureFailed   = errors.New("payment capture failed")
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrInvalidState    = errors.New("invalid payment state")
+	ErrRefundExceeds   = errors.New("refund exceeds captured amount")
+	ErrRefundMismatch  = errors.New("refund ID reused for a different refund")
+)
+
+// Gateway takes payments in two steps: Authorize holds an amount on the
+// payment method and Capture collects it, or Void drops the hold. Refund
+// returns part or all of a captured payment and is idempotent: repeating a
+// refund ID for the same payment and amount refunds once.
+type Gateway interface {
+	Authorize(reference string, amount money.Money, method string) (string, error)
+	Capture(paymentID string) error
+	Void(paymentID string) error
+	Refund(refundID, paymentID string, amount money.Money) error
+}
+
+// Payment is the state of one authorization.
+type Payment struct {
+	ID        string      `json:"id"`
+	Reference string      `json:"reference"`
+	Method    string      `json:"method,omitempty"`
+	Amount    money.Money `json:"amount"`
+	Refunded  money.Money `json:"refunded"`
+	Status    string      `json:"status"`
+}
+
+// Fake is a deterministic in-process Gateway for tests and local
+// development. Payment IDs are numbered in the order payments are made.
+type Fake struct {
+	mu       sync.Mutex
+	payments map[string]*Payment
+	refunds  map[string]refund
+	next     int
+}
+
+type refund struct {
+	paymentID string
+	amount    money.Money
+}
@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+var _ Gateway = (*Fake)(nil)
+
+func usd(amount float64) money.Money {
+	return money.FromFloat(amount, "USD")
+}
+
+func TestFake(t *testing.T) {
+	t.Run("AuthorizeAndCapture", func(t *testing.T) {
+		f := NewFake()
+		id, err := f.Authorize("B1", usd(100), "tok_visa")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id != "pay_1" {
+			t.Errorf("expected pay_1, got %s", id)
+		}
+		if err := f.Capture(id); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p, _ := f.Payment(id); p.Status != StatusCaptured || p.Reference != "B1" {
+			t.Errorf("unexpected payment %+v", p)
+		}
+		if err := f.Capture(id); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+		if err := f.Void(id); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("Declined", func(t *testing.T) {
+		f := NewFake()
+		if _, err := f.Authorize("B1", usd(100), FakeDeclined); !errors.Is(err, ErrDeclined) {
+			t.Errorf("expected ErrDeclined, got %v", err)
+		}
+	})
+
+	t.Run("CaptureFailsThenVoid", func(t *testing.T) {
+		f := NewFake()
+		id, _ := f.Authorize("B1", usd(100), FakeCaptureFail)
+		if err := f.Capture(id); !errors.Is(err, ErrCaptureFailed) {
+			t.Errorf("expected ErrCaptureFailed, got %v", err)
+		}
+		if err := f.Void(id); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p, _ := f.Payment(id); p.Status != StatusVoided {
+			t.Errorf("expected voided, got %s", p.Status)
+		}
+		if err := f.Refund("R1", id, usd(10)); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("expected an uncaptured payment to refuse refunds, got %v", err)
+		}
+	})
+
+	t.Run("RefundsAreIdempotent", func(t *testing.T) {
+		f := NewFake()
+		id, _ := f.Authorize("B1", usd(100), "")
+		_ = f.Capture(id)
+		for i := 0; i < 3; i++ {
+			if err := f.Refund("R1", id, usd(60)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if p, _ := f.Payment(id); p.Refunded != usd(60) {
+			t.Errorf("expected one refund of 60, got %s", p.Refunded)
+		}
+		if err := f.Refund("R1", id, usd(30)); !errors.Is(err, ErrRefundMismatch) {
+			t.Errorf("expected ErrRefundMismatch, got %v", err)
+		}
+		if err := f.Refund("R2", id, usd(50)); !errors.Is(err, ErrRefundExceeds) {
+			t.Errorf("expected ErrRefundExceeds, got %v", err)
+		}
+		if err := f.Refund("R3", id, money.FromFloat(10, "EUR")); !errors.Is(err, money.ErrCurrencyMismatch) {
+			t.Errorf("expected ErrCurrencyMismatch, got %v", err)
+		}
+		if err := f.Refund("R2", id, usd(40)); err != nil {
+			t.Errorf("expected the rest to be refundable, got %v", err)
+		}
+		if err := f.Refund("R4", "pay_9", usd(1)); !errors.Is(err, ErrPaymentNotFound) {
+			t.Errorf("expected ErrPaymentNotFound, got %v", err)
+		}
+	})
+}
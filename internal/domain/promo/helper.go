@@ -0,0 +1,101 @@
+package promo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// check looks up codes and checks they can all be redeemed together on trip.
+// Callers must hold p.mu.
+func (p *Promotions) check(codes []string, trip Trip, price money.Money) ([]*Promo, error) {
+	promos := make([]*Promo, 0, len(codes))
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		code = NormalizeCode(code)
+		pr, ok := p.promos[code]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPromoNotFound, code)
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrPromoNotStackable, code)
+		}
+		if len(codes) > 1 && !pr.Stackable {
+			return nil, fmt.Errorf("%w: %s", ErrPromoNotStackable, code)
+		}
+		if pr.Kind == KindFixed && pr.Amount.Currency != price.Currency {
+			return nil, fmt.Errorf("%w: %s is in %s, not %s", ErrPromoNotApplicable, code, pr.Amount.Currency, price.Currency)
+		}
+		if err := pr.appliesTo(trip); err != nil {
+			return nil, err
+		}
+		if pr.MaxRedemptions > 0 && p.redeemed[code] >= pr.MaxRedemptions {
+			return nil, fmt.Errorf("%w: %s", ErrPromoExhausted, code)
+		}
+		if pr.MaxPerPassenger > 0 && p.byPassenger[code][trip.PassengerID] >= pr.MaxPerPassenger {
+			return nil, fmt.Errorf("%w: %s already used by %s", ErrPromoExhausted, code, trip.PassengerID)
+		}
+		seen[code] = true
+		promos = append(promos, pr)
+	}
+	return promos, nil
+}
+
+// appliesTo checks a promo's validity window and route, class and travel
+// date restrictions against a trip.
+func (pr *Promo) appliesTo(trip Trip) error {
+	booked, departs := trip.BookingDate.Format(DateLayout), trip.Departure.Format(DateLayout)
+	switch {
+	case !inWindow(booked, pr.ValidFrom, pr.ValidTo):
+		return fmt.Errorf("%w: %s is not valid on %s", ErrPromoNotApplicable, pr.Code, booked)
+	case !inWindow(departs, pr.TravelFrom, pr.TravelTo):
+		return fmt.Errorf("%w: %s does not cover travel on %s", ErrPromoNotApplicable, pr.Code, departs)
+	case !allowed(pr.Origins, trip.Origin), !allowed(pr.Destinations, trip.Destination):
+		return fmt.Errorf("%w: %s does not cover %s-%s", ErrPromoNotApplicable, pr.Code, trip.Origin, trip.Destination)
+	case !allowed(pr.SeatClasses, trip.SeatClass):
+		return fmt.Errorf("%w: %s does not cover %s", ErrPromoNotApplicable, pr.Code, trip.SeatClass)
+	}
+	return nil
+}
+
+// discount applies percent promos to the running price first and fixed
+// promos afterwards, never taking the price below zero. Each percent
+// discount is rounded half away from zero to the currency's increment, so
+// the redemptions and the final price always add up to the original.
+func discount(promos []*Promo, price money.Money) ([]Redemption, money.Money) {
+	ordered := append([]*Promo(nil), promos...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Kind == KindPercent && ordered[j].Kind != KindPercent
+	})
+	redemptions := make([]Redemption, 0, len(ordered))
+	for _, pr := range ordered {
+		off := pr.Amount
+		if pr.Kind == KindPercent {
+			off = price.MulRatio(pr.Percent, 100)
+		}
+		if price.Less(off) {
+			off = price
+		}
+		price = money.New(price.Amount-off.Amount, price.Currency)
+		redemptions = append(redemptions, Redemption{Code: pr.Code, Discount: off})
+	}
+	return redemptions, price
+}
+
+// inWindow compares "YYYY-MM-DD" dates, which order lexically.
+func inWindow(date, from, to string) bool {
+	return (from == "" || date >= from) && (to == "" || date <= to)
+}
+
+func allowed(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package promo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func NewPromotions() *Promotions {
+	return &Promotions{
+		promos:      make(map[string]*Promo),
+		redeemed:    make(map[string]int),
+		byPassenger: make(map[string]map[string]int),
+	}
+}
+
+// NormalizeCode trims and upper-cases a promo code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Add validates a promo and adds or replaces it, keeping the redemptions
+// already recorded against its code.
+func (p *Promotions) Add(pr *Promo) error {
+	pr.Code = NormalizeCode(pr.Code)
+	pr.Amount.Currency = money.NormalizeCode(pr.Amount.Currency)
+	for _, codes := range [][]string{pr.Origins, pr.Destinations} {
+		for i := range codes {
+			codes[i] = NormalizeCode(codes[i])
+		}
+	}
+	if err := pr.Validate(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.promos[pr.Code] = pr
+	return nil
+}
+
+func (p *Promotions) Get(code string) (*Promo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pr, ok := p.promos[NormalizeCode(code)]
+	if !ok {
+		return nil, ErrPromoNotFound
+	}
+	return pr, nil
+}
+
+// List returns all promos ordered by code.
+func (p *Promotions) List() []*Promo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := make([]*Promo, 0, len(p.promos))
+	for _, pr := range p.promos {
+		result = append(result, pr)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// Redemptions returns how many times a code has been redeemed.
+func (p *Promotions) Redemptions(code string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.redeemed[NormalizeCode(code)]
+}
+
+// Redeem applies codes to a trip priced at price and records their use. All
+// codes are checked before any is recorded, so either every code is redeemed
+// or none is. Percent discounts are applied before fixed ones and the price
+// never drops below zero.
+func (p *Promotions) Redeem(codes []string, trip Trip, price money.Money) ([]Redemption, money.Money, error) {
+	if len(codes) == 0 {
+		return nil, price, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	promos, err := p.check(codes, trip, price)
+	if err != nil {
+		return nil, price, err
+	}
+	redemptions, discounted := discount(promos, price)
+	for _, r := range redemptions {
+		p.redeemed[r.Code]++
+		if p.byPassenger[r.Code] == nil {
+			p.byPassenger[r.Code] = make(map[string]int)
+		}
+		p.byPassenger[r.Code][trip.PassengerID]++
+	}
+	return redemptions, discounted, nil
+}
+
+// Preview is Redeem without recording anything, for showing a price.
+func (p *Promotions) Preview(codes []string, trip Trip, price money.Money) ([]Redemption, money.Money, error) {
+	if len(codes) == 0 {
+		return nil, price, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	promos, err := p.check(codes, trip, price)
+	if err != nil {
+		return nil, price, err
+	}
+	redemptions, discounted := discount(promos, price)
+	return redemptions, discounted, nil
+}
+
+// Release reverses the redemptions of a cancelled booking.
+func (p *Promotions) Release(passengerID string, redemptions []Redemption) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range redemptions {
+		if p.redeemed[r.Code] > 0 {
+			p.redeemed[r.Code]--
+		}
+		if p.byPassenger[r.Code][passengerID] > 0 {
+			p.byPassenger[r.Code][passengerID]--
+		}
+	}
+}
+
+func (pr *Promo) Validate() error {
+	if pr.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidPromo)
+	}
+	switch pr.Kind {
+	case KindPercent:
+		if pr.Percent < 1 || pr.Percent > 100 || !pr.Amount.IsZero() {
+			return fmt.Errorf("%w: a percent promo takes a percent from 1 to 100 and no amount", ErrInvalidPromo)
+		}
+	case KindFixed:
+		if pr.Amount.Amount <= 0 || pr.Percent != 0 {
+			return fmt.Errorf("%w: a fixed promo takes a positive amount and no percent", ErrInvalidPromo)
+		}
+		if _, err := money.LookupCurrency(pr.Amount.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromo, err)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be %q or %q", ErrInvalidPromo, KindPercent, KindFixed)
+	}
+	for _, window := range [][2]string{{pr.ValidFrom, pr.ValidTo}, {pr.TravelFrom, pr.TravelTo}} {
+		for _, date := range window {
+			if _, err := time.Parse(DateLayout, date); date != "" && err != nil {
+				return fmt.Errorf("%w: invalid date %q", ErrInvalidPromo, date)
+			}
+		}
+		if window[0] != "" && window[1] != "" && window[1] < window[0] {
+			return fmt.Errorf("%w: window ends before it starts", ErrInvalidPromo)
+		}
+	}
+	if pr.MaxRedemptions < 0 || pr.MaxPerPassenger < 0 {
+		return fmt.Errorf("%w: negative redemption limit", ErrInvalidPromo)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package promo
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+const (
+	KindPercent = "percent"
+	KindFixed   = "fixed"
+
+	DateLayout = "2006-01-02"
+)
+
+var (
+	ErrInvalidPromo       = errors.New("invalid promo code")
+	ErrPromoNotFound      = errors.New("promo code not found")
+	ErrPromoNotApplicable = errors.New("promo code not applicable")
+	ErrPromoExhausted     = errors.New("promo code redemption limit reached")
+	ErrPromoNotStackable  = errors.New("promo code cannot be combined")
+)
+
+// Promo is a discount code. Percent promos take a whole Percent (1 to 100)
+// off the price; fixed promos take off Amount and only apply to prices in
+// its currency. Dates are origin-local calendar days in "YYYY-MM-DD" form
+// and bound inclusively; empty dates and lists leave that dimension
+// unrestricted.
+type Promo struct {
+	Code            string      `json:"code"`
+	Kind            string      `json:"kind"`
+	Percent         int64       `json:"percent,omitempty"`     // percent promos only
+	Amount          money.Money `json:"amount,omitzero"`       // fixed promos only
+	ValidFrom       string      `json:"valid_from,omitempty"`  // first booking date
+	ValidTo         string      `json:"valid_to,omitempty"`    // last booking date
+	TravelFrom      string      `json:"travel_from,omitempty"` // first departure date
+	TravelTo        string      `json:"travel_to,omitempty"`   // last departure date
+	Origins         []string    `json:"origins,omitempty"`
+	Destinations    []string    `json:"destinations,omitempty"`
+	SeatClasses     []string    `json:"seat_classes,omitempty"`
+	MaxRedemptions  int         `json:"max_redemptions,omitempty"`   // 0 is unlimited
+	MaxPerPassenger int         `json:"max_per_passenger,omitempty"` // 0 is unlimited
+	Stackable       bool        `json:"stackable"`                   // may be combined with other stackable codes
+}
+
+// Trip is the booking a promo code is applied to.
+type Trip struct {
+	PassengerID string
+	Origin      string
+	Destination string
+	SeatClass   string
+	BookingDate time.Time // in the origin's time zone
+	Departure   time.Time // in the origin's time zone
+}
+
+// Redemption records the discount a code gave on a booking.
+type Redemption struct {
+	Code     string      `json:"code"`
+	Discount money.Money `json:"discount"`
+}
+
+// Promotions holds promo codes and their redemption counts. Checking limits
+// and recording a redemption happen under one lock so concurrent bookings
+// cannot exceed a limit.
+type Promotions struct {
+	mu          sync.Mutex
+	promos      map[string]*Promo
+	redeemed    map[string]int
+	byPassenger map[string]map[string]int // code -> passenger -> count
+}
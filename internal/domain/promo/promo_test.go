@@ -0,0 +1,183 @@
+package promo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func newTrip(passengerID string) Trip {
+	return Trip{
+		PassengerID: passengerID,
+		Origin:      "BKK",
+		Destination: "NRT",
+		SeatClass:   "Economy",
+		BookingDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Departure:   time.Date(2024, 8, 15, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func usd(amount float64) money.Money {
+	return money.FromFloat(amount, "USD")
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]*Promo{
+		"NoCode":            {Kind: KindFixed, Amount: money.New(1000, "USD")},
+		"UnknownKind":       {Code: "X", Kind: "bogo", Percent: 10},
+		"PercentOverFull":   {Code: "X", Kind: KindPercent, Percent: 101},
+		"PercentWithAmount": {Code: "X", Kind: KindPercent, Percent: 10, Amount: money.New(1000, "USD")},
+		"ZeroFixed":         {Code: "X", Kind: KindFixed, Amount: money.New(0, "USD")},
+		"FixedWithPercent":  {Code: "X", Kind: KindFixed, Amount: money.New(1000, "USD"), Percent: 10},
+		"FixedNoCurrency":   {Code: "X", Kind: KindFixed, Amount: money.New(1000, "")},
+		"BadDate":           {Code: "X", Kind: KindFixed, Amount: money.New(1000, "USD"), ValidFrom: "01/07/2024"},
+		"WindowReversed":    {Code: "X", Kind: KindFixed, Amount: money.New(1000, "USD"), TravelFrom: "2024-08-01", TravelTo: "2024-07-01"},
+		"NegativeLimit":     {Code: "X", Kind: KindFixed, Amount: money.New(1000, "USD"), MaxRedemptions: -1},
+	}
+	for name, pr := range cases {
+		if err := NewPromotions().Add(pr); !errors.Is(err, ErrInvalidPromo) {
+			t.Errorf("%s: expected ErrInvalidPromo, got %v", name, err)
+		}
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	t.Run("PercentThenFixed", func(t *testing.T) {
+		p := NewPromotions()
+		_ = p.Add(&Promo{Code: "less50", Kind: KindFixed, Amount: money.New(5000, "usd"), Stackable: true})
+		_ = p.Add(&Promo{Code: "SUMMER", Kind: KindPercent, Percent: 10, Stackable: true})
+		redemptions, price, err := p.Redeem([]string{"LESS50", "summer"}, newTrip("P1"), usd(1000))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if price != usd(850) {
+			t.Errorf("expected 850.00 USD, got %s", price)
+		}
+		if redemptions[0].Code != "SUMMER" || redemptions[0].Discount != usd(100) || redemptions[1].Discount != usd(50) {
+			t.Errorf("unexpected redemptions %+v", redemptions)
+		}
+	})
+
+	t.Run("NeverBelowZero", func(t *testing.T) {
+		p := NewPromotions()
+		_ = p.Add(&Promo{Code: "FREE", Kind: KindFixed, Amount: money.New(50000, "USD")})
+		redemptions, price, _ := p.Redeem([]string{"FREE"}, newTrip("P1"), usd(300))
+		if !price.IsZero() || redemptions[0].Discount != usd(300) {
+			t.Errorf("expected discount capped at 300, got %+v, %s", redemptions, price)
+		}
+		if _, _, err := p.Redeem([]string{"FREE"}, newTrip("P1"), money.FromFloat(300, "EUR")); !errors.Is(err, ErrPromoNotApplicable) {
+			t.Errorf("expected fixed promo not to apply to another currency, got %v", err)
+		}
+	})
+
+	t.Run("Restrictions", func(t *testing.T) {
+		p := NewPromotions()
+		_ = p.Add(&Promo{
+			Code: "JP", Kind: KindPercent, Percent: 20,
+			ValidFrom: "2024-06-01", ValidTo: "2024-07-31",
+			TravelFrom: "2024-08-01", TravelTo: "2024-08-31",
+			Origins: []string{"bkk"}, Destinations: []string{"NRT", "HND"}, SeatClasses: []string{"Economy"},
+		})
+		if _, _, err := p.Redeem([]string{"JP"}, newTrip("P1"), usd(100)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		cases := map[string]func(tr *Trip){
+			"BookedTooLate":  func(tr *Trip) { tr.BookingDate = time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC) },
+			"TravelOutside":  func(tr *Trip) { tr.Departure = time.Date(2024, 9, 1, 9, 0, 0, 0, time.UTC) },
+			"OtherOrigin":    func(tr *Trip) { tr.Origin = "SIN" },
+			"OtherDest":      func(tr *Trip) { tr.Destination = "KIX" },
+			"OtherSeatClass": func(tr *Trip) { tr.SeatClass = "Business" },
+		}
+		for name, mutate := range cases {
+			trip := newTrip("P1")
+			mutate(&trip)
+			if _, _, err := p.Redeem([]string{"JP"}, trip, usd(100)); !errors.Is(err, ErrPromoNotApplicable) {
+				t.Errorf("%s: expected ErrPromoNotApplicable, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Stacking", func(t *testing.T) {
+		p := NewPromotions()
+		_ = p.Add(&Promo{Code: "A", Kind: KindFixed, Amount: money.New(1000, "USD"), Stackable: true})
+		_ = p.Add(&Promo{Code: "B", Kind: KindFixed, Amount: money.New(1000, "USD")})
+		if _, _, err := p.Redeem([]string{"A", "B"}, newTrip("P1"), usd(100)); !errors.Is(err, ErrPromoNotStackable) {
+			t.Errorf("expected ErrPromoNotStackable, got %v", err)
+		}
+		if _, _, err := p.Redeem([]string{"A", "a"}, newTrip("P1"), usd(100)); !errors.Is(err, ErrPromoNotStackable) {
+			t.Errorf("expected a repeated code to be rejected, got %v", err)
+		}
+		if p.Redemptions("A") != 0 {
+			t.Errorf("failed redemption should not be recorded")
+		}
+		if _, _, err := p.Redeem([]string{"B"}, newTrip("P1"), usd(100)); err != nil {
+			t.Errorf("non-stackable code should apply on its own, got %v", err)
+		}
+	})
+
+	t.Run("UnknownCode", func(t *testing.T) {
+		if _, _, err := NewPromotions().Redeem([]string{"NOPE"}, newTrip("P1"), usd(100)); !errors.Is(err, ErrPromoNotFound) {
+			t.Errorf("expected ErrPromoNotFound, got %v", err)
+		}
+	})
+
+	t.Run("PerPassengerLimitAndRelease", func(t *testing.T) {
+		p := NewPromotions()
+		_ = p.Add(&Promo{Code: "ONCE", Kind: KindFixed, Amount: money.New(1000, "USD"), MaxPerPassenger: 1})
+		redemptions, _, err := p.Redeem([]string{"ONCE"}, newTrip("P1"), usd(100))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, _, err := p.Redeem([]string{"ONCE"}, newTrip("P1"), usd(100)); !errors.Is(err, ErrPromoExhausted) {
+			t.Errorf("expected ErrPromoExhausted, got %v", err)
+		}
+		if _, _, err := p.Redeem([]string{"ONCE"}, newTrip("P2"), usd(100)); err != nil {
+			t.Errorf("another passenger should still redeem, got %v", err)
+		}
+		p.Release("P1", redemptions)
+		if _, _, err := p.Redeem([]string{"ONCE"}, newTrip("P1"), usd(100)); err != nil {
+			t.Errorf("expected code usable again after release, got %v", err)
+		}
+	})
+
+	t.Run("PreviewRecordsNothing", func(t *testing.T) {
+		p := NewPromotions()
+		_ = p.Add(&Promo{Code: "ONCE", Kind: KindPercent, Percent: 20, MaxRedemptions: 1})
+		for i := 0; i < 2; i++ {
+			redemptions, price, err := p.Preview([]string{"ONCE"}, newTrip("P1"), usd(100))
+			if err != nil || price != usd(80) || len(redemptions) != 1 {
+				t.Errorf("unexpected preview %+v %s, %v", redemptions, price, err)
+			}
+		}
+		if p.Redemptions("ONCE") != 0 {
+			t.Errorf("expected no redemptions, got %d", p.Redemptions("ONCE"))
+		}
+	})
+}
+
+func TestRedeem_GlobalLimitUnderConcurrency(t *testing.T) {
+	p := NewPromotions()
+	_ = p.Add(&Promo{Code: "FLASH", Kind: KindPercent, Percent: 50, MaxRedemptions: 10})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, _, err := p.Redeem([]string{"FLASH"}, newTrip(string(rune('A'+i%26))), usd(100)); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if succeeded != 10 || p.Redemptions("FLASH") != 10 {
+		t.Errorf("expected exactly 10 redemptions, got %d (recorded %d)", succeeded, p.Redemptions("FLASH"))
+	}
+}
@@ -0,0 +1,21 @@
+package quote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"time"
+)
+
+func (s *Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func (s *Signer) purge(now time.Time) {
+	for id, expiry := range s.used {
+		if !now.Before(expiry) {
+			delete(s.used, id)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package quote
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NewSigner returns a signer using key, or a random key when it is empty so
+// tokens only verify within this process.
+func NewSigner(key []byte) *Signer {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &Signer{key: key, used: make(map[string]time.Time)}
+}
+
+// Sign returns q as a token of its base64url JSON and signature.
+func (s *Signer) Sign(q *Quote) (string, error) {
+	payload, err := json.Marshal(q)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded)), nil
+}
+
+// Verify checks a token's signature and expiry at now and returns its quote.
+func (s *Signer) Verify(token string, now time.Time) (*Quote, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidQuote
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(encoded)) {
+		return nil, ErrInvalidQuote
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidQuote
+	}
+	var q Quote
+	if err := json.Unmarshal(payload, &q); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuote, err)
+	}
+	if !now.Before(q.ExpiresAt) {
+		return nil, fmt.Errorf("%w at %s", ErrQuoteExpired, q.ExpiresAt.Format(time.RFC3339))
+	}
+	return &q, nil
+}
+
+// Use marks a verified quote as booked. It fails when the quote was already
+// used; expired entries are forgotten since Verify rejects them anyway.
+func (s *Signer) Use(q *Quote, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge(now)
+	if _, ok := s.used[q.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrQuoteUsed, q.ID)
+	}
+	s.used[q.ID] = q.ExpiresAt
+	return nil
+}
+
+// Purge forgets the used quotes that have expired by now.
+func (s *Signer) Purge(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge(now)
+}
+
+// Release makes a quote usable again after the booking it was used for
+// failed.
+func (s *Signer) Release(q *Quote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.used, q.ID)
+}
@@ -0,0 +1,40 @@
+package quote
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// DefaultTTL is how long a quote can be booked at its price.
+const DefaultTTL = 5 * time.Minute
+
+var (
+	ErrInvalidQuote = errors.New("invalid quote token")
+	ErrQuoteExpired = errors.New("quote expired")
+	ErrQuoteUsed    = errors.New("quote already used")
+	ErrQuoteMatch   = errors.New("quote does not match the booking")
+)
+
+// Quote is a fare guaranteed to one passenger for one flight, class and fare
+// family until ExpiresAt. Price is the fare before promo codes, taxes and
+// fees, which are applied again when booking.
+type Quote struct {
+	ID          string      `json:"id"`
+	PassengerID string      `json:"passenger_id"`
+	FlightID    string      `json:"flight_id"`
+	SeatClass   string      `json:"seat_class"`
+	Fare        string      `json:"fare,omitempty"`
+	Price       money.Money `json:"price"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+}
+
+// Signer issues and verifies quote tokens with an HMAC-SHA256 key, and
+// remembers the quotes already booked so each is used once.
+type Signer struct {
+	key  []byte
+	mu   sync.Mutex
+	used map[string]time.Time // quote ID -> expiry
+}
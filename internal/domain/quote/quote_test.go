@@ -0,0 +1,86 @@
+package quote
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func newQuote(now time.Time) *Quote {
+	return &Quote{
+		ID:          "Q1",
+		PassengerID: "P1",
+		FlightID:    "F1",
+		SeatClass:   "Economy",
+		Fare:        "BASIC",
+		Price:       money.FromFloat(123.45, "USD"),
+		ExpiresAt:   now.Add(DefaultTTL),
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSigner([]byte("secret"))
+	token, err := s.Sign(newQuote(now))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		q, err := s.Verify(token, now.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *q != *newQuote(now) {
+			t.Errorf("expected %+v, got %+v", newQuote(now), q)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		if _, err := s.Verify(token, now.Add(DefaultTTL)); !errors.Is(err, ErrQuoteExpired) {
+			t.Errorf("expected ErrQuoteExpired, got %v", err)
+		}
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		other, _ := s.Sign(&Quote{ID: "Q1", Price: money.FromFloat(1, "USD"), ExpiresAt: now.Add(DefaultTTL)})
+		forged := other[:len(other)/2] + token[len(token)/2:]
+		for _, bad := range []string{"", "abc", token + "x", forged} {
+			if _, err := s.Verify(bad, now); !errors.Is(err, ErrInvalidQuote) {
+				t.Errorf("%q: expected ErrInvalidQuote, got %v", bad, err)
+			}
+		}
+	})
+
+	t.Run("OtherKey", func(t *testing.T) {
+		if _, err := NewSigner(nil).Verify(token, now); !errors.Is(err, ErrInvalidQuote) {
+			t.Errorf("expected ErrInvalidQuote, got %v", err)
+		}
+	})
+}
+
+func TestUse(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := NewSigner(nil)
+	q := newQuote(now)
+	if err := s.Use(q, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Use(q, now); !errors.Is(err, ErrQuoteUsed) {
+		t.Errorf("expected ErrQuoteUsed, got %v", err)
+	}
+	s.Release(q)
+	if err := s.Use(q, now); err != nil {
+		t.Errorf("expected a released quote to be usable, got %v", err)
+	}
+	_ = s.Use(&Quote{ID: "Q2", ExpiresAt: now.Add(time.Minute)}, now.Add(DefaultTTL))
+	if len(s.used) != 1 {
+		t.Errorf("expected expired quotes to be forgotten, got %d", len(s.used))
+	}
+	s.Purge(now.Add(DefaultTTL + time.Minute))
+	if len(s.used) != 0 {
+		t.Errorf("expected Purge to forget expired quotes, got %d", len(s.used))
+	}
+}
@@ -0,0 +1,9 @@
+package revenue
+
+// epsilon absorbs floating point error so an excess landing exactly on a
+// step boundary closes that step's family.
+const epsilon = 1e-9
+
+func key(origin, destination, class string) string {
+	return origin + "-" + destination + "/" + class
+}
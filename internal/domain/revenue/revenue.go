@@ -0,0 +1,128 @@
+package revenue
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+func NewControls() *Controls {
+	return &Controls{policies: make(map[string]*Policy)}
+}
+
+// Set validates a policy and adds or replaces the one for its route and
+// seat class.
+func (c *Controls) Set(p *Policy) error {
+	if p.Origin == "" || p.Destination == "" {
+		return fmt.Errorf("%w: origin and destination are required", ErrInvalidPolicy)
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies[key(p.Origin, p.Destination, p.SeatClass)] = p
+	return nil
+}
+
+// Get returns the policy for a route and seat class, falling back to the
+// route's policy for every class.
+func (c *Controls) Get(origin, destination, class string) (*Policy, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if p, ok := c.policies[key(origin, destination, class)]; ok {
+		return p, true
+	}
+	p, ok := c.policies[key(origin, destination, "")]
+	return p, ok
+}
+
+// Delete removes the policy for a route and seat class.
+func (c *Controls) Delete(origin, destination, class string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := key(origin, destination, class)
+	if _, ok := c.policies[k]; !ok {
+		return ErrPolicyNotFound
+	}
+	delete(c.policies, k)
+	return nil
+}
+
+// List returns all policies ordered by route and seat class.
+func (c *Controls) List() []*Policy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]*Policy, 0, len(c.policies))
+	for _, p := range c.policies {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return key(result[i].Origin, result[i].Destination, result[i].SeatClass) <
+			key(result[j].Origin, result[j].Destination, result[j].SeatClass)
+	})
+	return result
+}
+
+// Validate checks the curve and thresholds. The route is not checked so a
+// proposed policy can be simulated against a single flight.
+func (p *Policy) Validate() error {
+	if len(p.Curve) == 0 {
+		return fmt.Errorf("%w: curve is empty", ErrInvalidPolicy)
+	}
+	seen := make(map[int]bool)
+	for _, pt := range p.Curve {
+		if pt.DaysBefore < 0 || seen[pt.DaysBefore] {
+			return fmt.Errorf("%w: days_before %d is negative or repeated", ErrInvalidPolicy, pt.DaysBefore)
+		}
+		if pt.Load < 0 || pt.Load > 1 {
+			return fmt.Errorf("%w: load at %d days must be between 0 and 1", ErrInvalidPolicy, pt.DaysBefore)
+		}
+		seen[pt.DaysBefore] = true
+	}
+	if p.PaceWindow < 0 || p.Tolerance < 0 || p.Step <= 0 {
+		return fmt.Errorf("%w: pace window and tolerance must not be negative and step must be positive", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// Expected returns the forecast load daysBefore departure, interpolating
+// linearly between curve points and holding the end values beyond them.
+func (p *Policy) Expected(daysBefore int) float64 {
+	lower, upper := -1, -1 // nearest points at or after, and at or before, daysBefore
+	for i, pt := range p.Curve {
+		if pt.DaysBefore <= daysBefore && (lower == -1 || pt.DaysBefore > p.Curve[lower].DaysBefore) {
+			lower = i
+		}
+		if pt.DaysBefore >= daysBefore && (upper == -1 || pt.DaysBefore < p.Curve[upper].DaysBefore) {
+			upper = i
+		}
+	}
+	switch {
+	case lower == -1 && upper == -1:
+		return 0
+	case lower == -1:
+		return p.Curve[upper].Load
+	case upper == -1 || lower == upper:
+		return p.Curve[lower].Load
+	}
+	a, b := p.Curve[lower], p.Curve[upper]
+	t := float64(daysBefore-a.DaysBefore) / float64(b.DaysBefore-a.DaysBefore)
+	return a.Load + t*(b.Load-a.Load)
+}
+
+// Evaluate decides how many of the cheapest fare families to close for a
+// cabin in the given state.
+func (p *Policy) Evaluate(s Snapshot) Decision {
+	d := Decision{ExpectedLoad: p.Expected(s.DaysBefore), Load: s.Load}
+	d.Excess = s.Load - d.ExpectedLoad
+	if p.PaceWindow > 0 {
+		d.ExpectedPace = d.ExpectedLoad - p.Expected(s.DaysBefore+p.PaceWindow)
+		d.Pace = s.RecentLoad
+		d.Excess = math.Max(d.Excess, d.Pace-d.ExpectedPace)
+	}
+	if d.Excess > p.Tolerance {
+		d.Closed = 1 + int(math.Floor((d.Excess-p.Tolerance)/p.Step+epsilon))
+	}
+	return d
+}
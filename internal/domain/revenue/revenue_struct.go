@@ -0,0 +1,54 @@
+package revenue
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrInvalidPolicy  = errors.New("invalid revenue policy")
+	ErrPolicyNotFound = errors.New("revenue policy not found")
+)
+
+// Point is the load factor expected a number of days before departure.
+type Point struct {
+	DaysBefore int     `json:"days_before"`
+	Load       float64 `json:"load"` // 0 to 1
+}
+
+// Policy closes the cheapest fare families of a route when sales run ahead
+// of its forecast curve. Once load or booking pace exceeds the curve by more
+// than Tolerance, one family closes, and each further Step of excess closes
+// the next one.
+type Policy struct {
+	Origin      string  `json:"origin"`
+	Destination string  `json:"destination"`
+	SeatClass   string  `json:"seat_class,omitempty"` // empty applies to every class
+	Curve       []Point `json:"curve"`
+	PaceWindow  int     `json:"pace_window_days,omitempty"` // 0 compares load only
+	Tolerance   float64 `json:"tolerance"`
+	Step        float64 `json:"step"`
+}
+
+// Snapshot is the state of a cabin when a control decision is made.
+type Snapshot struct {
+	DaysBefore int
+	Load       float64 // share of the cabin sold
+	RecentLoad float64 // share of the cabin sold within the pace window
+}
+
+// Decision explains how many fare families a policy closes.
+type Decision struct {
+	ExpectedLoad float64 `json:"expected_load"`
+	Load         float64 `json:"load"`
+	ExpectedPace float64 `json:"expected_pace,omitempty"`
+	Pace         float64 `json:"pace,omitempty"`
+	Excess       float64 `json:"excess"`
+	Closed       int     `json:"closed"` // cheapest families closed
+}
+
+// Controls holds the policies in force, keyed by route and seat class.
+type Controls struct {
+	mu       sync.RWMutex
+	policies map[string]*Policy
+}
@@ -0,0 +1,122 @@
+package revenue
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func newTestPolicy() *Policy {
+	return &Policy{
+		Origin:      "BKK",
+		Destination: "NRT",
+		Curve: []Point{
+			{DaysBefore: 0, Load: 0.9},
+			{DaysBefore: 60, Load: 0.2},
+			{DaysBefore: 30, Load: 0.5},
+		},
+		Tolerance: 0.05,
+		Step:      0.1,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := newTestPolicy().Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cases := map[string]func(p *Policy){
+		"EmptyCurve":    func(p *Policy) { p.Curve = nil },
+		"RepeatedDay":   func(p *Policy) { p.Curve = append(p.Curve, Point{DaysBefore: 30, Load: 0.4}) },
+		"NegativeDay":   func(p *Policy) { p.Curve[0].DaysBefore = -1 },
+		"LoadOverFull":  func(p *Policy) { p.Curve[0].Load = 1.2 },
+		"ZeroStep":      func(p *Policy) { p.Step = 0 },
+		"NegativeSlack": func(p *Policy) { p.Tolerance = -0.1 },
+		"NegativePace":  func(p *Policy) { p.PaceWindow = -7 },
+	}
+	for name, mutate := range cases {
+		p := newTestPolicy()
+		mutate(p)
+		if err := p.Validate(); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("%s: expected ErrInvalidPolicy, got %v", name, err)
+		}
+	}
+}
+
+func TestExpected(t *testing.T) {
+	p := newTestPolicy()
+	cases := map[int]float64{
+		0:   0.9,
+		15:  0.7,
+		30:  0.5,
+		45:  0.35,
+		60:  0.2,
+		120: 0.2,
+	}
+	for days, want := range cases {
+		if got := p.Expected(days); math.Abs(got-want) > 1e-9 {
+			t.Errorf("Expected(%d) = %.3f, want %.3f", days, got, want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Run("OnCurveKeepsAllOpen", func(t *testing.T) {
+		d := newTestPolicy().Evaluate(Snapshot{DaysBefore: 30, Load: 0.54})
+		if d.Closed != 0 {
+			t.Errorf("expected no closures within tolerance, got %+v", d)
+		}
+	})
+
+	t.Run("LoadAheadClosesInSteps", func(t *testing.T) {
+		p := newTestPolicy()
+		cases := map[float64]int{0.56: 1, 0.65: 2, 0.8: 3}
+		for load, want := range cases {
+			if d := p.Evaluate(Snapshot{DaysBefore: 30, Load: load}); d.Closed != want {
+				t.Errorf("load %.2f: expected %d closed, got %+v", load, want, d)
+			}
+		}
+	})
+
+	t.Run("PaceAheadCloses", func(t *testing.T) {
+		p := newTestPolicy()
+		p.PaceWindow = 10
+		// Expected pace over 10 days at 30 days out is 0.5 - 0.4 = 0.1
+		d := p.Evaluate(Snapshot{DaysBefore: 30, Load: 0.45, RecentLoad: 0.3})
+		if math.Abs(d.ExpectedPace-0.1) > 1e-9 || d.Closed != 2 {
+			t.Errorf("expected pace to close 2 families, got %+v", d)
+		}
+	})
+}
+
+func TestControls(t *testing.T) {
+	c := NewControls()
+	route := newTestPolicy()
+	economy := newTestPolicy()
+	economy.SeatClass = "Economy"
+	for _, p := range []*Policy{route, economy} {
+		if err := c.Set(p); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if p, _ := c.Get("BKK", "NRT", "Economy"); p != economy {
+		t.Errorf("expected class policy")
+	}
+	if p, _ := c.Get("BKK", "NRT", "Business"); p != route {
+		t.Errorf("expected route policy as fallback")
+	}
+	if _, ok := c.Get("NRT", "BKK", "Economy"); ok {
+		t.Errorf("expected no policy for reverse route")
+	}
+	if len(c.List()) != 2 {
+		t.Errorf("expected 2 policies, got %d", len(c.List()))
+	}
+	if err := c.Set(&Policy{Curve: route.Curve, Step: 0.1}); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("expected ErrInvalidPolicy without a route, got %v", err)
+	}
+	if err := c.Delete("BKK", "NRT", "Economy"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Delete("BKK", "NRT", "Economy"); !errors.Is(err, ErrPolicyNotFound) {
+		t.Errorf("expected ErrPolicyNotFound, got %v", err)
+	}
+}
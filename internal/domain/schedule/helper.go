@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func atClock(day time.Time, clock string, loc *time.Location) (time.Time, error) {
+	c, err := time.Parse(TimeLayout, clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := day.Date()
+	return time.Date(y, m, d, c.Hour(), c.Minute(), 0, 0, loc), nil
+}
+
+// times returns the departure and arrival of the flight on day, with any
+// exception's times applied, and false when the day is cancelled.
+func (s *Schedule) times(day time.Time, origin, destination *time.Location) (time.Time, time.Time, bool) {
+	depClock, arrClock := s.Departure, s.Arrival
+	if e, ok := s.exceptionOn(day); ok {
+		if e.Cancelled {
+			return time.Time{}, time.Time{}, false
+		}
+		if e.Departure != "" {
+			depClock = e.Departure
+		}
+		if e.Arrival != "" {
+			arrClock = e.Arrival
+		}
+	}
+	dep, err := atClock(day, depClock, origin)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	arr, err := atClock(day.AddDate(0, 0, s.ArrivalDayOffset), arrClock, destination)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return dep, arr, true
+}
+
+// currency is the schedule's selling currency, money.DefaultCurrency when it
+// has none.
+func (s *Schedule) currency() string {
+	if s.Currency == "" {
+		return money.DefaultCurrency
+	}
+	return money.NormalizeCode(s.Currency)
+}
+
+// basePrice reads a class's base price exactly, zero when it has none.
+func (s *Schedule) basePrice(class string) (money.Money, error) {
+	amount := s.BasePrices[class]
+	if amount == "" {
+		return money.New(0, s.currency()), nil
+	}
+	price, err := money.Parse(amount.String(), s.currency())
+	if err != nil {
+		return money.Money{}, err
+	}
+	if price.Amount < 0 {
+		return money.Money{}, money.ErrInvalidAmount
+	}
+	return price, nil
+}
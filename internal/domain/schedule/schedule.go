@@ -0,0 +1,284 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func (s *Schedule) Validate() error {
+	if s.FlightNumber == "" || s.Origin == "" || s.Destination == "" {
+		return fmt.Errorf("%w: flight_number, origin and destination are required", ErrInvalidSchedule)
+	}
+	if len(s.DaysOfWeek) == 0 {
+		return fmt.Errorf("%w: days_of_week is empty", ErrInvalidSchedule)
+	}
+	for _, d := range s.DaysOfWeek {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("%w: invalid day of week %d", ErrInvalidSchedule, d)
+		}
+	}
+	from, err := time.Parse(DateLayout, s.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("%w: invalid valid_from", ErrInvalidSchedule)
+	}
+	to, err := time.Parse(DateLayout, s.ValidTo)
+	if err != nil {
+		return fmt.Errorf("%w: invalid valid_to", ErrInvalidSchedule)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("%w: valid_to is before valid_from", ErrInvalidSchedule)
+	}
+	if _, err := time.Parse(TimeLayout, s.Departure); err != nil {
+		return fmt.Errorf("%w: invalid departure", ErrInvalidSchedule)
+	}
+	if _, err := time.Parse(TimeLayout, s.Arrival); err != nil {
+		return fmt.Errorf("%w: invalid arrival", ErrInvalidSchedule)
+	}
+	if s.ArrivalDayOffset < 0 {
+		return fmt.Errorf("%w: negative arrival_day_offset", ErrInvalidSchedule)
+	}
+	if len(s.SeatLayout) == 0 {
+		return fmt.Errorf("%w: seat_layout is empty", ErrInvalidSchedule)
+	}
+	for class, layout := range s.SeatLayout {
+		if len(layout) == 0 || len(layout[0]) == 0 {
+			return fmt.Errorf("%w: empty layout for %s", ErrInvalidSchedule, class)
+		}
+	}
+	if s.Currency != "" {
+		if _, err := money.LookupCurrency(s.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+	}
+	for class := range s.BasePrices {
+		if _, err := s.basePrice(class); err != nil {
+			return fmt.Errorf("%w: %s base price: %v", ErrInvalidSchedule, class, err)
+		}
+	}
+	for class, families := range s.FareFamilies {
+		if _, ok := s.SeatLayout[class]; !ok {
+			return fmt.Errorf("%w: fare families for unknown class %s", ErrInvalidSchedule, class)
+		}
+		cabin, err := fare.NewCabin(families)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidSchedule, class, err)
+		}
+		if currency := cabin.Families()[0].Price.Currency; currency != s.currency() {
+			return fmt.Errorf("%w: %s fares are priced in %s, schedule sells in %s", ErrInvalidSchedule, class, currency, s.currency())
+		}
+	}
+	for _, e := range s.Exceptions {
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateTimes checks that every flight the schedule operates, retimed
+// dates included, arrives after it departs. Departure and arrival clock times
+// are taken in the origin and destination time zones, so a short westbound
+// flight may land at an earlier clock time on the same day.
+func (s *Schedule) ValidateTimes(origin, destination *time.Location) error {
+	from, err := time.Parse(DateLayout, s.ValidFrom)
+	if err != nil {
+		return fmt.Errorf("%w: invalid valid_from", ErrInvalidSchedule)
+	}
+	to, err := time.Parse(DateLayout, s.ValidTo)
+	if err != nil {
+		return fmt.Errorf("%w: invalid valid_to", ErrInvalidSchedule)
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !s.operatesOn(day.Weekday()) {
+			continue
+		}
+		dep, arr, ok := s.times(day, origin, destination)
+		if ok && !arr.After(dep) {
+			return fmt.Errorf("%w: %s arrives before it departs", ErrInvalidSchedule, day.Format(DateLayout))
+		}
+	}
+	return nil
+}
+
+func (e Exception) validate() error {
+	if _, err := time.Parse(DateLayout, e.Date); err != nil {
+		return fmt.Errorf("%w: invalid exception date %q", ErrInvalidSchedule, e.Date)
+	}
+	if e.Departure != "" {
+		if _, err := time.Parse(TimeLayout, e.Departure); err != nil {
+			return fmt.Errorf("%w: invalid exception departure %q", ErrInvalidSchedule, e.Departure)
+		}
+	}
+	if e.Arrival != "" {
+		if _, err := time.Parse(TimeLayout, e.Arrival); err != nil {
+			return fmt.Errorf("%w: invalid exception arrival %q", ErrInvalidSchedule, e.Arrival)
+		}
+	}
+	return nil
+}
+
+// FlightID returns the ID of the dated flight instance operating on date.
+func (s *Schedule) FlightID(date time.Time) string {
+	return s.FlightNumber + "-" + date.Format(flightIDDate)
+}
+
+// FlightDate returns the date of the dated flight instance with flightID,
+// and false when the ID is not one of the schedule's.
+func (s *Schedule) FlightDate(flightID string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(flightID, s.FlightNumber+"-")
+	if !ok {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(flightIDDate, suffix)
+	return date, err == nil
+}
+
+// AddException records or replaces the exception for e.Date.
+func (s *Schedule) AddException(e Exception) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	for i, existing := range s.Exceptions {
+		if existing.Date == e.Date {
+			s.Exceptions[i] = e
+			return nil
+		}
+	}
+	s.Exceptions = append(s.Exceptions, e)
+	return nil
+}
+
+// Generate materializes the dated flights operating between from and to,
+// inclusive of both dates as seen at the origin. Departure and arrival clock
+// times are local to origin and destination respectively. Cancelled dates
+// are skipped and retimed dates use the exception's times.
+func (s *Schedule) Generate(from, to time.Time, origin, destination *time.Location) []*flight.Flight {
+	validFrom, err := time.Parse(DateLayout, s.ValidFrom)
+	if err != nil {
+		return nil
+	}
+	validTo, err := time.Parse(DateLayout, s.ValidTo)
+	if err != nil {
+		return nil
+	}
+	start := truncateDay(from.In(origin))
+	if start.Before(validFrom) {
+		start = validFrom
+	}
+	end := truncateDay(to.In(origin))
+	if end.After(validTo) {
+		end = validTo
+	}
+
+	var result []*flight.Flight
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if !s.operatesOn(day.Weekday()) {
+			continue
+		}
+		if f := s.Instance(day, origin, destination); f != nil {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// Operates reports whether the schedule flies on an origin-local date: a
+// day of the week it operates within its validity period that no exception
+// cancels.
+func (s *Schedule) Operates(date time.Time) bool {
+	day := truncateDay(date)
+	from, err := time.Parse(DateLayout, s.ValidFrom)
+	if err != nil || day.Before(from) {
+		return false
+	}
+	to, err := time.Parse(DateLayout, s.ValidTo)
+	if err != nil || day.After(to) || !s.operatesOn(day.Weekday()) {
+		return false
+	}
+	e, ok := s.exceptionOn(day)
+	return !ok || !e.Cancelled
+}
+
+// Instance builds the flight for a single origin-local date, or returns nil
+// when the date is cancelled by an exception.
+func (s *Schedule) Instance(date time.Time, origin, destination *time.Location) *flight.Flight {
+	day := truncateDay(date)
+	dep, arr, ok := s.times(day, origin, destination)
+	if !ok {
+		return nil
+	}
+
+	f := flight.InitializeFlight(s.FlightID(day), s.Origin, s.Destination, s.Aircraft, dep, arr)
+	f.Currency = s.currency()
+	for class, layout := range s.SeatLayout {
+		seatLayout := make([][]*flight.Seat, 0, len(layout))
+		for _, row := range layout {
+			seatRow := make([]*flight.Seat, 0, len(row))
+			for _, seat := range row {
+				seatRow = append(seatRow, &flight.Seat{Special: seat.Special})
+			}
+			seatLayout = append(seatLayout, seatRow)
+		}
+		base, err := s.basePrice(class)
+		if err != nil {
+			return nil
+		}
+		f.AddSeatClass(flight.SeatClass(class), seatLayout, base)
+	}
+	for class, families := range s.FareFamilies {
+		if err := f.SetFareFamilies(flight.SeatClass(class), families); err != nil {
+			return nil
+		}
+	}
+	return f
+}
+
+func (s *Schedule) operatesOn(d time.Weekday) bool {
+	for _, day := range s.DaysOfWeek {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) exceptionOn(day time.Time) (Exception, bool) {
+	date := day.Format(DateLayout)
+	for _, e := range s.Exceptions {
+		if e.Date == date {
+			return e, true
+		}
+	}
+	return Exception{}, false
+}
+
+// Import reads a JSON array of schedules and validates each of them.
+func Import(r io.Reader) ([]*Schedule, error) {
+	var schedules []*Schedule
+	if err := json.NewDecoder(r).Decode(&schedules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	for _, s := range schedules {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.FlightNumber, err)
+		}
+	}
+	return schedules, nil
+}
+
+// Export writes schedules as a JSON array readable by Import.
+func Export(w io.Writer, schedules []*Schedule) error {
+	if schedules == nil {
+		schedules = []*Schedule{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schedules)
+}
@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+)
+
+const (
+	DateLayout = "2006-01-02"
+	TimeLayout = "15:04"
+
+	// DefaultHorizon is how far ahead dated flights are materialized.
+	DefaultHorizon = 90 * 24 * time.Hour
+
+	flightIDDate = "20060102" // date part of a dated flight's ID
+)
+
+var (
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+	ErrScheduleNotFound = errors.New("schedule not found")
+)
+
+type SeatTemplate struct {
+	Special string `json:"special"`
+}
+
+// Exception overrides a single operating date of a schedule, either
+// cancelling it or retiming its local departure/arrival.
+type Exception struct {
+	Date      string `json:"date"` // "YYYY-MM-DD"
+	Cancelled bool   `json:"cancelled,omitempty"`
+	Departure string `json:"departure,omitempty"` // "HH:MM"
+	Arrival   string `json:"arrival,omitempty"`   // "HH:MM"
+}
+
+// Schedule is a recurring flight number operating on a days-of-week pattern
+// within a validity period. Dates, times and base prices are kept in their
+// wire format so a schedule can be imported and exported as-is; base prices
+// are decimal amounts in the schedule's currency.
+type Schedule struct {
+	FlightNumber     string                      `json:"flight_number"`
+	Origin           string                      `json:"origin"`
+	Destination      string                      `json:"destination"`
+	DaysOfWeek       []time.Weekday              `json:"days_of_week"` // 0 = Sunday
+	ValidFrom        string                      `json:"valid_from"`   // "YYYY-MM-DD"
+	ValidTo          string                      `json:"valid_to"`     // "YYYY-MM-DD"
+	Departure        string                      `json:"departure"`    // "HH:MM"
+	Arrival          string                      `json:"arrival"`      // "HH:MM"
+	ArrivalDayOffset int                         `json:"arrival_day_offset,omitempty"`
+	Aircraft         string                      `json:"aircraft"`
+	SeatLayout       map[string][][]SeatTemplate `json:"seat_layout"`
+	BasePrices       map[string]json.Number      `json:"base_prices"`
+	Currency         string                      `json:"currency,omitempty"` // defaults to money.DefaultCurrency
+	FareFamilies     map[string][]fare.Family    `json:"fare_families,omitempty"`
+	Exceptions       []Exception                 `json:"exceptions,omitempty"`
+}
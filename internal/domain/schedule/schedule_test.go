@@ -0,0 +1,278 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func usd(amount float64) money.Money {
+	return money.FromFloat(amount, money.DefaultCurrency)
+}
+
+func newTestSchedule() *Schedule {
+	return &Schedule{
+		FlightNumber:     "TG100",
+		Origin:           "BKK",
+		Destination:      "NRT",
+		DaysOfWeek:       []time.Weekday{time.Monday, time.Wednesday, time.Friday},
+		ValidFrom:        "2024-07-01",
+		ValidTo:          "2024-07-14",
+		Departure:        "23:30",
+		Arrival:          "07:45",
+		ArrivalDayOffset: 1,
+		Aircraft:         "Boeing 787",
+		SeatLayout: map[string][][]SeatTemplate{
+			"Economy": {{{}, {}}, {{}, {Special: "Wheelchair"}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "500"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		if err := newTestSchedule().Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cases := map[string]func(s *Schedule){
+			"NoFlightNumber": func(s *Schedule) { s.FlightNumber = "" },
+			"NoDays":         func(s *Schedule) { s.DaysOfWeek = nil },
+			"BadDay":         func(s *Schedule) { s.DaysOfWeek = []time.Weekday{7} },
+			"BadValidFrom":   func(s *Schedule) { s.ValidFrom = "07/01/2024" },
+			"ToBeforeFrom":   func(s *Schedule) { s.ValidTo = "2024-06-01" },
+			"BadDeparture":   func(s *Schedule) { s.Departure = "25:00" },
+			"EmptyLayout":    func(s *Schedule) { s.SeatLayout = nil },
+			"FareForUnknownClass": func(s *Schedule) {
+				s.FareFamilies = map[string][]fare.Family{"First": {{Code: "F", Price: usd(900)}}}
+			},
+			"BadFareFamily": func(s *Schedule) {
+				s.FareFamilies = map[string][]fare.Family{"Economy": {{Code: "", Price: usd(100)}}}
+			},
+			"UnknownCurrency": func(s *Schedule) { s.Currency = "XYZ" },
+			"BadBasePrice":    func(s *Schedule) { s.BasePrices["Economy"] = "1.234" },
+			"NegativeBasePrice": func(s *Schedule) {
+				s.BasePrices["Economy"] = "-1"
+			},
+			"FareCurrencyMismatch": func(s *Schedule) {
+				s.FareFamilies = map[string][]fare.Family{"Economy": {{Code: "E", Price: money.FromFloat(100, "EUR")}}}
+			},
+			"BadException": func(s *Schedule) {
+				s.Exceptions = []Exception{{Date: "tomorrow"}}
+			},
+		}
+		for name, mutate := range cases {
+			s := newTestSchedule()
+			mutate(s)
+			if err := s.Validate(); !errors.Is(err, ErrInvalidSchedule) {
+				t.Errorf("%s: expected ErrInvalidSchedule, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestValidateTimes(t *testing.T) {
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	t.Run("OvernightAndWestbound", func(t *testing.T) {
+		s := newTestSchedule()
+		if err := s.ValidateTimes(bangkok, tokyo); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		// 10:00 in Tokyo is 08:00 in Bangkok, so a 09:30 arrival is later
+		s.Origin, s.Destination = "NRT", "BKK"
+		s.Departure, s.Arrival, s.ArrivalDayOffset = "10:00", "09:30", 0
+		if err := s.ValidateTimes(tokyo, bangkok); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("ArrivesBeforeDeparture", func(t *testing.T) {
+		s := newTestSchedule()
+		s.ArrivalDayOffset = 0
+		if err := s.ValidateTimes(time.UTC, time.UTC); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("expected ErrInvalidSchedule, got %v", err)
+		}
+	})
+
+	t.Run("RetimedDate", func(t *testing.T) {
+		s := newTestSchedule()
+		s.Exceptions = []Exception{{Date: "2024-07-03", Arrival: "23:00", Departure: "23:30"}}
+		s.ArrivalDayOffset = 0
+		s.Departure, s.Arrival = "08:00", "16:00"
+		if err := s.ValidateTimes(time.UTC, time.UTC); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("expected ErrInvalidSchedule, got %v", err)
+		}
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("DaysOfWeekWithinValidity", func(t *testing.T) {
+		s := newTestSchedule()
+		from := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 7, 31, 0, 0, 0, 0, time.UTC)
+		flights := s.Generate(from, to, time.UTC, time.UTC)
+		// Mon/Wed/Fri between 2024-07-01 and 2024-07-14
+		want := []string{"TG100-20240701", "TG100-20240703", "TG100-20240705", "TG100-20240708", "TG100-20240710", "TG100-20240712"}
+		if len(flights) != len(want) {
+			t.Fatalf("expected %d flights, got %d", len(want), len(flights))
+		}
+		for i, f := range flights {
+			if f.FlightID != want[i] {
+				t.Errorf("expected %s, got %s", want[i], f.FlightID)
+			}
+		}
+		first := flights[0]
+		if !first.Departure.Equal(time.Date(2024, 7, 1, 23, 30, 0, 0, time.UTC)) {
+			t.Errorf("unexpected departure %v", first.Departure)
+		}
+		if !first.Arrival.Equal(time.Date(2024, 7, 2, 7, 45, 0, 0, time.UTC)) {
+			t.Errorf("unexpected arrival %v", first.Arrival)
+		}
+		if len(first.Seats["Economy"]) != 4 || first.BasePrices["Economy"] != money.FromFloat(500, money.DefaultCurrency) {
+			t.Errorf("seat layout not copied from template")
+		}
+		if first.Seats["Economy"][3].Special != "Wheelchair" {
+			t.Errorf("expected special seat to be kept")
+		}
+	})
+
+	t.Run("FareFamilies", func(t *testing.T) {
+		s := newTestSchedule()
+		s.FareFamilies = map[string][]fare.Family{"Economy": {
+			{Code: "EB", Price: usd(300), Allotment: 2},
+			{Code: "EF", Price: usd(600)},
+		}}
+		from := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		flights := s.Generate(from, from.AddDate(0, 0, 1), time.UTC, time.UTC)
+		if len(flights) != 1 {
+			t.Fatalf("expected 1 flight, got %d", len(flights))
+		}
+		cabin := flights[0].Fares["Economy"]
+		if cabin == nil || len(cabin.Families()) != 2 || cabin.Families()[0].Code != "EB" {
+			t.Errorf("expected fare families to be applied")
+		}
+	})
+
+	t.Run("HorizonLimitsRange", func(t *testing.T) {
+		s := newTestSchedule()
+		from := time.Date(2024, 7, 3, 12, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC)
+		flights := s.Generate(from, to, time.UTC, time.UTC)
+		if len(flights) != 2 {
+			t.Errorf("expected 2 flights, got %d", len(flights))
+		}
+	})
+
+	t.Run("Exceptions", func(t *testing.T) {
+		s := newTestSchedule()
+		_ = s.AddException(Exception{Date: "2024-07-03", Cancelled: true})
+		_ = s.AddException(Exception{Date: "2024-07-05", Departure: "21:00", Arrival: "05:15"})
+		flights := s.Generate(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC), time.UTC, time.UTC)
+		if len(flights) != 2 {
+			t.Fatalf("expected 2 flights, got %d", len(flights))
+		}
+		retimed := flights[1]
+		if retimed.FlightID != "TG100-20240705" {
+			t.Fatalf("unexpected flight %s", retimed.FlightID)
+		}
+		if !retimed.Departure.Equal(time.Date(2024, 7, 5, 21, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected retimed departure %v", retimed.Departure)
+		}
+		if !retimed.Arrival.Equal(time.Date(2024, 7, 6, 5, 15, 0, 0, time.UTC)) {
+			t.Errorf("unexpected retimed arrival %v", retimed.Arrival)
+		}
+	})
+
+	t.Run("LocalTimes", func(t *testing.T) {
+		bangkok, _ := time.LoadLocation("Asia/Bangkok")
+		tokyo, _ := time.LoadLocation("Asia/Tokyo")
+		s := newTestSchedule()
+		// 2024-07-01 17:30 UTC is already Tuesday 2024-07-02 in Bangkok
+		from := time.Date(2024, 7, 1, 17, 30, 0, 0, time.UTC)
+		flights := s.Generate(from, from.AddDate(0, 0, 1), bangkok, tokyo)
+		if len(flights) != 1 || flights[0].FlightID != "TG100-20240703" {
+			t.Fatalf("expected only the Wednesday flight, got %d", len(flights))
+		}
+		f := flights[0]
+		if !f.Departure.Equal(time.Date(2024, 7, 3, 23, 30, 0, 0, bangkok)) {
+			t.Errorf("unexpected departure %v", f.Departure)
+		}
+		if !f.Arrival.Equal(time.Date(2024, 7, 4, 7, 45, 0, 0, tokyo)) {
+			t.Errorf("unexpected arrival %v", f.Arrival)
+		}
+	})
+
+	t.Run("Operates", func(t *testing.T) {
+		s := newTestSchedule()
+		s.Exceptions = []Exception{{Date: "2024-07-05", Cancelled: true}}
+		for date, want := range map[string]bool{
+			"2024-07-01": true,  // Monday
+			"2024-07-02": false, // Tuesday
+			"2024-07-05": false, // cancelled
+			"2024-07-15": false, // after valid_to
+		} {
+			day, _ := time.Parse(DateLayout, date)
+			if got := s.Operates(day); got != want {
+				t.Errorf("%s: expected %v, got %v", date, want, got)
+			}
+		}
+		if date, ok := s.FlightDate("TG100-20240703"); !ok || date.Format(DateLayout) != "2024-07-03" {
+			t.Errorf("unexpected flight date %v, %v", date, ok)
+		}
+		if _, ok := s.FlightDate("TG101-20240703"); ok {
+			t.Error("expected another flight number not to match")
+		}
+	})
+
+	t.Run("AddExceptionReplacesSameDate", func(t *testing.T) {
+		s := newTestSchedule()
+		_ = s.AddException(Exception{Date: "2024-07-03", Cancelled: true})
+		_ = s.AddException(Exception{Date: "2024-07-03", Departure: "22:00"})
+		if len(s.Exceptions) != 1 || s.Exceptions[0].Cancelled {
+			t.Errorf("expected exception to be replaced, got %+v", s.Exceptions)
+		}
+	})
+}
+
+func TestImportExport(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		s := newTestSchedule()
+		s.Exceptions = []Exception{{Date: "2024-07-03", Cancelled: true}}
+		var buf bytes.Buffer
+		if err := Export(&buf, []*Schedule{s}); err != nil {
+			t.Fatalf("unexpected export error: %v", err)
+		}
+		imported, err := Import(&buf)
+		if err != nil {
+			t.Fatalf("unexpected import error: %v", err)
+		}
+		if len(imported) != 1 {
+			t.Fatalf("expected 1 schedule, got %d", len(imported))
+		}
+		got := imported[0]
+		if got.FlightNumber != "TG100" || len(got.DaysOfWeek) != 3 || got.ArrivalDayOffset != 1 {
+			t.Errorf("unexpected imported schedule: %+v", got)
+		}
+		if len(got.Exceptions) != 1 || !got.Exceptions[0].Cancelled {
+			t.Errorf("exceptions not round-tripped")
+		}
+	})
+
+	t.Run("ImportInvalid", func(t *testing.T) {
+		if _, err := Import(bytes.NewBufferString("{bad json")); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("expected ErrInvalidSchedule, got %v", err)
+		}
+		if _, err := Import(bytes.NewBufferString(`[{"flight_number":"X1"}]`)); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("expected ErrInvalidSchedule, got %v", err)
+		}
+	})
+}
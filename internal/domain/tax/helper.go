@@ -0,0 +1,42 @@
+package tax
+
+import "github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+
+func (r *Rule) appliesTo(trip Trip) bool {
+	if r.Kind == KindSeatSelection && !trip.SeatSelected {
+		return false
+	}
+	return allowed(r.Airports, NormalizeCode(trip.Origin)) && allowed(r.SeatClasses, trip.SeatClass)
+}
+
+func allowed(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Total adds up items in currency.
+func Total(items []Item, currency string) money.Money {
+	total := money.New(0, currency)
+	for _, item := range items {
+		total = money.New(total.Amount+item.Amount.Amount, currency)
+	}
+	return total
+}
+
+// RefundableTotal adds up the refundable items in currency.
+func RefundableTotal(items []Item, currency string) money.Money {
+	refundable := make([]Item, 0, len(items))
+	for _, item := range items {
+		if item.Refundable {
+			refundable = append(refundable, item)
+		}
+	}
+	return Total(refundable, currency)
+}
@@ -0,0 +1,102 @@
+package tax
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func NewTable() *Table {
+	return &Table{rules: make(map[string]*Rule)}
+}
+
+// NormalizeCode trims and upper-cases a rule or airport code.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Set validates a rule and adds or replaces it.
+func (t *Table) Set(r *Rule) error {
+	r.Code = NormalizeCode(r.Code)
+	r.Amount.Currency = money.NormalizeCode(r.Amount.Currency)
+	for i := range r.Airports {
+		r.Airports[i] = NormalizeCode(r.Airports[i])
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules[r.Code] = r
+	return nil
+}
+
+func (t *Table) Delete(code string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	code = NormalizeCode(code)
+	if _, ok := t.rules[code]; !ok {
+		return ErrRuleNotFound
+	}
+	delete(t.rules, code)
+	return nil
+}
+
+// List returns all rules ordered by code.
+func (t *Table) List() []*Rule {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result := make([]*Rule, 0, len(t.rules))
+	for _, r := range t.rules {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// Compute returns the charges that apply to trip, ordered by code. Percent
+// rules are taken of fare, rounded half away from zero to its increment,
+// and fixed amounts converted into the fare's currency.
+func (t *Table) Compute(trip Trip, fare money.Money, convert Converter) ([]Item, error) {
+	items := make([]Item, 0)
+	for _, r := range t.List() {
+		if !r.appliesTo(trip) {
+			continue
+		}
+		amount := fare.MulRatio(r.Percent, 100)
+		if r.Percent == 0 {
+			converted, err := convert(r.Amount, fare.Currency)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.Code, err)
+			}
+			amount = converted
+		}
+		items = append(items, Item{Code: r.Code, Name: r.Name, Kind: r.Kind, Amount: amount, Refundable: r.Refundable})
+	}
+	return items, nil
+}
+
+func (r *Rule) Validate() error {
+	if r.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidRule)
+	}
+	switch r.Kind {
+	case KindAirportTax, KindSecurityFee, KindFuelSurcharge, KindBookingFee, KindSeatSelection:
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidRule, r.Kind)
+	}
+	switch {
+	case r.Amount.Amount < 0 || r.Percent < 0 || r.Percent > 100:
+		return fmt.Errorf("%w: amount must be positive and percent between 0 and 100", ErrInvalidRule)
+	case (r.Amount.Amount > 0) == (r.Percent > 0):
+		return fmt.Errorf("%w: exactly one of amount or percent is required", ErrInvalidRule)
+	}
+	if r.Amount.Amount > 0 {
+		if _, err := money.LookupCurrency(r.Amount.Currency); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+	return nil
+}
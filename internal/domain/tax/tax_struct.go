@@ -0,0 +1,62 @@
+package tax
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+const (
+	KindAirportTax    = "airport_tax"
+	KindSecurityFee   = "security_fee"
+	KindFuelSurcharge = "fuel_surcharge"
+	KindBookingFee    = "booking_fee"
+	KindSeatSelection = "seat_selection_fee" // only charged when a seat is chosen
+)
+
+var (
+	ErrInvalidRule  = errors.New("invalid tax or fee")
+	ErrRuleNotFound = errors.New("tax or fee not found")
+)
+
+// Rule configures a tax, fee or surcharge. It is either a fixed Amount or a
+// whole Percent (1 to 100) of the fare after promo discounts. Empty Airports
+// and SeatClasses lists apply the rule everywhere; Airports are matched
+// against the departure airport.
+type Rule struct {
+	Code        string      `json:"code"`
+	Name        string      `json:"name,omitempty"`
+	Kind        string      `json:"kind"`
+	Amount      money.Money `json:"amount,omitzero"`
+	Percent     int64       `json:"percent,omitempty"`
+	Airports    []string    `json:"airports,omitempty"`
+	SeatClasses []string    `json:"seat_classes,omitempty"`
+	Refundable  bool        `json:"refundable"` // refunded in full on cancellation
+}
+
+// Trip is the booking taxes and fees are computed for.
+type Trip struct {
+	Origin       string
+	Destination  string
+	SeatClass    string
+	SeatSelected bool
+}
+
+// Item is one charge of a booking's price breakdown, in the fare's currency.
+type Item struct {
+	Code       string      `json:"code"`
+	Name       string      `json:"name,omitempty"`
+	Kind       string      `json:"kind"`
+	Amount     money.Money `json:"amount"`
+	Refundable bool        `json:"refundable"`
+}
+
+// Converter converts money into another currency.
+type Converter func(m money.Money, currency string) (money.Money, error)
+
+// Table holds the configured rules by code.
+type Table struct {
+	mu    sync.RWMutex
+	rules map[string]*Rule
+}
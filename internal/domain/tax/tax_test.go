@@ -0,0 +1,107 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+func usd(amount float64) money.Money {
+	return money.FromFloat(amount, "USD")
+}
+
+// toTHB converts at 40 THB per USD.
+func toTHB(m money.Money, currency string) (money.Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+	if currency != "THB" {
+		return money.Money{}, money.ErrRateNotFound
+	}
+	return money.New(m.MulRatio(40, 1).Amount, "THB"), nil
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]*Rule{
+		"NoCode":          {Kind: KindBookingFee, Amount: usd(5)},
+		"UnknownKind":     {Code: "X", Kind: "vat", Amount: usd(5)},
+		"AmountAndRate":   {Code: "X", Kind: KindBookingFee, Amount: usd(5), Percent: 10},
+		"Neither":         {Code: "X", Kind: KindBookingFee},
+		"PercentOverFull": {Code: "X", Kind: KindFuelSurcharge, Percent: 101},
+		"NoCurrency":      {Code: "X", Kind: KindBookingFee, Amount: money.New(500, "")},
+		"NegativeAmount":  {Code: "X", Kind: KindBookingFee, Amount: usd(-5)},
+		"NegativePercent": {Code: "X", Kind: KindFuelSurcharge, Percent: -10},
+	}
+	for name, r := range cases {
+		if err := NewTable().Set(r); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s: expected ErrInvalidRule, got %v", name, err)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	table := NewTable()
+	for _, r := range []*Rule{
+		{Code: "bkk-tax", Kind: KindAirportTax, Amount: money.New(70000, "thb"), Airports: []string{"bkk"}, Refundable: true},
+		{Code: "SEC", Kind: KindSecurityFee, Amount: usd(5), Refundable: true},
+		{Code: "FUEL", Kind: KindFuelSurcharge, Percent: 12},
+		{Code: "SEAT", Kind: KindSeatSelection, Amount: usd(15), SeatClasses: []string{"Economy"}},
+	} {
+		if err := table.Set(r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	t.Run("AppliesFilters", func(t *testing.T) {
+		items, err := table.Compute(Trip{Origin: "BKK", SeatClass: "Economy"}, money.FromFloat(1000, "THB"), toTHB)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []Item{
+			{Code: "BKK-TAX", Kind: KindAirportTax, Amount: money.FromFloat(700, "THB"), Refundable: true},
+			{Code: "FUEL", Kind: KindFuelSurcharge, Amount: money.FromFloat(120, "THB")},
+			{Code: "SEC", Kind: KindSecurityFee, Amount: money.FromFloat(200, "THB"), Refundable: true},
+		}
+		if len(items) != len(want) {
+			t.Fatalf("expected %d items, got %+v", len(want), items)
+		}
+		for i := range want {
+			if items[i] != want[i] {
+				t.Errorf("item %d: expected %+v, got %+v", i, want[i], items[i])
+			}
+		}
+		if total := Total(items, "THB"); total != money.FromFloat(1020, "THB") {
+			t.Errorf("expected 1020 THB, got %s", total)
+		}
+		if refundable := RefundableTotal(items, "THB"); refundable != money.FromFloat(900, "THB") {
+			t.Errorf("expected 900 THB refundable, got %s", refundable)
+		}
+	})
+
+	t.Run("SeatSelection", func(t *testing.T) {
+		items, _ := table.Compute(Trip{Origin: "SIN", SeatClass: "Economy", SeatSelected: true}, usd(100), toTHB)
+		if len(items) != 3 || items[1].Code != "SEAT" || items[1].Amount != usd(15) {
+			t.Errorf("expected the seat selection fee, got %+v", items)
+		}
+		items, _ = table.Compute(Trip{Origin: "SIN", SeatClass: "Business", SeatSelected: true}, usd(100), toTHB)
+		if len(items) != 2 {
+			t.Errorf("expected no seat fee outside Economy, got %+v", items)
+		}
+	})
+
+	t.Run("MissingRate", func(t *testing.T) {
+		if _, err := table.Compute(Trip{Origin: "BKK"}, money.FromFloat(100, "EUR"), toTHB); !errors.Is(err, money.ErrRateNotFound) {
+			t.Errorf("expected ErrRateNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := table.Delete("sec"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := table.Delete("SEC"); !errors.Is(err, ErrRuleNotFound) {
+			t.Errorf("expected ErrRuleNotFound, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts + "."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Subscription) public() Subscription {
+	c := *s
+	c.Secret = ""
+	return c
+}
+
+// checkHost refuses u unless it is https and every address its host
+// resolves to is public, or AllowPrivate is set.
+func (d *Dispatcher) checkHost(u *url.URL) error {
+	if d.AllowPrivate {
+		return nil
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%w: url must use https", ErrInvalidSubscription)
+	}
+	host := u.Hostname()
+	addrs, err := d.lookup(host)
+	if err != nil {
+		return fmt.Errorf("%w: resolving %s: %w", ErrInvalidSubscription, host, err)
+	}
+	for _, addr := range addrs {
+		if !public(addr) {
+			return fmt.Errorf("%w: %s resolves to %s: %w", ErrInvalidSubscription, host, addr, ErrPrivateAddress)
+		}
+	}
+	return nil
+}
+
+// lookup returns the addresses of host, which may be an IP address.
+func (d *Dispatcher) lookup(host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	lookupHost := d.LookupHost
+	if lookupHost == nil {
+		lookupHost = func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	addrs, err := lookupHost(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses")
+	}
+	return addrs, err
+}
+
+// checkDial is the dialer's Control: it keeps deliveries, their redirects
+// and hosts that resolve differently since Add off private addresses.
+func (d *Dispatcher) checkDial(_, address string, _ syscall.RawConn) error {
+	if d.AllowPrivate {
+		return nil
+	}
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !public(addr.Addr()) {
+		return fmt.Errorf("dialing %s: %w", address, ErrPrivateAddress)
+	}
+	return nil
+}
+
+// public reports whether addr is reachable from the internet rather than
+// the loopback, link-local, private or unspecified networks.
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// find returns the subscription with id. d.mu must be held.
+func (d *Dispatcher) find(id string) *Subscription {
+	for _, sub := range d.subs {
+		if sub.ID == id {
+			return sub
+		}
+	}
+	return nil
+}
+
+// initStop makes the channel Close closes, for a zero Dispatcher. d.mu must
+// be held.
+func (d *Dispatcher) initStop() {
+	if d.stop == nil {
+		d.stop = make(chan struct{})
+	}
+}
+
+// stopped reports whether Close was called. d.mu must be held.
+func (d *Dispatcher) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver posts dl until it is accepted, the attempts run out, its
+// subscription is deleted or the dispatcher is closed.
+func (d *Dispatcher) deliver(dl delivery) {
+	defer d.wg.Done()
+	for n := 1; ; n++ {
+		status, err := d.post(dl)
+		d.mu.Lock()
+		d.addAttempt(dl, n, status, err)
+		if err == nil {
+			d.mu.Unlock()
+			return
+		}
+		if n >= d.Retry.MaxAttempts {
+			d.addDeadLetter(dl, n, err.Error())
+			d.mu.Unlock()
+			return
+		}
+		if d.find(dl.sub.ID) == nil {
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+
+		timer := time.NewTimer(d.Retry.Backoff(n))
+		select {
+		case <-timer.C:
+		case <-d.stop:
+			timer.Stop()
+			d.mu.Lock()
+			d.addDeadLetter(dl, n, err.Error()+"; retries stopped by shutdown")
+			d.mu.Unlock()
+			return
+		}
+	}
+}
+
+// post makes one attempt at dl, returning the response status and an error
+// unless the subscriber answered with a 2xx status.
+func (d *Dispatcher) post(dl delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, dl.sub.URL, bytes.NewReader(dl.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(dl.sub.Secret, d.Clock(), dl.payload))
+	req.Header.Set(EventHeader, string(dl.record.Event.Kind()))
+	req.Header.Set(DeliveryHeader, dl.id)
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// addAttempt logs an attempt. d.mu must be held.
+func (d *Dispatcher) addAttempt(dl delivery, n, status int, err error) {
+	a := Attempt{
+		DeliveryID:     dl.id,
+		SubscriptionID: dl.sub.ID,
+		EventID:        dl.record.ID,
+		Type:           dl.record.Event.Kind(),
+		Attempt:        n,
+		At:             d.Clock(),
+		StatusCode:     status,
+		Delivered:      err == nil,
+	}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	d.log = appendBounded(d.log, a)
+}
+
+// addDeadLetter gives up on dl after n attempts. d.mu must be held.
+func (d *Dispatcher) addDeadLetter(dl delivery, n int, lastErr string) {
+	d.dead = appendBounded(d.dead, DeadLetter{
+		DeliveryID:     dl.id,
+		SubscriptionID: dl.sub.ID,
+		URL:            dl.sub.URL,
+		Payload:        dl.payload,
+		Attempts:       n,
+		LastError:      lastErr,
+		At:             d.Clock(),
+	})
+}
+
+// appendBounded appends v, dropping the oldest entries beyond LogSize.
+func appendBounded[T any](list []T, v T) []T {
+	list = append(list, v)
+	if len(list) > LogSize {
+		list = append(list[:0], list[len(list)-LogSize:]...)
+	}
+	return list
+}
@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/google/uuid"
+)
+
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		Retry: DefaultRetry,
+		Clock: time.Now,
+		stop:  make(chan struct{}),
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, Control: d.checkDial}).DialContext
+	d.Client = &http.Client{Timeout: DefaultTimeout, Transport: transport}
+	return d
+}
+
+// Add checks and registers a subscription, giving it an ID and, when it has
+// none, a generated secret. The returned copy is the only one showing the
+// secret. Unless AllowPrivate is set, the URL must be https and its host
+// must resolve to public addresses only.
+func (d *Dispatcher) Add(sub Subscription) (Subscription, error) {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	if err := d.checkHost(u); err != nil {
+		return Subscription{}, err
+	}
+	var kinds []event.Kind
+	for _, k := range sub.Events {
+		if !slices.Contains(event.Kinds, k) {
+			return Subscription{}, fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, k)
+		}
+		if !slices.Contains(kinds, k) {
+			kinds = append(kinds, k)
+		}
+	}
+	if sub.Secret == "" {
+		if sub.Secret, err = newSecret(); err != nil {
+			return Subscription{}, err
+		}
+	}
+	sub.ID = uuid.New().String()
+	sub.Events = kinds
+	sub.CreatedAt = d.Clock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stored := sub
+	d.subs = append(d.subs, &stored)
+	return sub, nil
+}
+
+// List returns the subscriptions, oldest first, without their secrets.
+func (d *Dispatcher) List() []Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	subs := make([]Subscription, 0, len(d.subs))
+	for _, sub := range d.subs {
+		subs = append(subs, sub.public())
+	}
+	return subs
+}
+
+// Delete removes a subscription. Its deliveries still under way make the
+// attempt in progress but are not retried.
+func (d *Dispatcher) Delete(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, sub := range d.subs {
+		if sub.ID == id {
+			d.subs = append(d.subs[:i], d.subs[i+1:]...)
+			return nil
+		}
+	}
+	return ErrSubscriptionNotFound
+}
+
+// Deliveries returns the logged attempts at delivering events to a
+// subscription, oldest first.
+func (d *Dispatcher) Deliveries(id string) ([]Attempt, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.find(id) == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+	attempts := []Attempt{}
+	for _, a := range d.log {
+		if a.SubscriptionID == id {
+			attempts = append(attempts, a)
+		}
+	}
+	return attempts, nil
+}
+
+// DeadLetters returns the events that could not be delivered, oldest first.
+func (d *Dispatcher) DeadLetters() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DeadLetter{}, d.dead...)
+}
+
+// Handle starts delivering r to every subscription that wants its kind. It
+// is meant to subscribe to an event bus. After Close, r goes straight to the
+// dead-letter list.
+func (d *Dispatcher) Handle(r event.Record) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.initStop()
+	var payload []byte
+	for _, sub := range d.subs {
+		if len(sub.Events) > 0 && !slices.Contains(sub.Events, r.Event.Kind()) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(Payload{ID: r.ID, Type: r.Event.Kind(), CreatedAt: r.At, Data: r.Event}); err != nil {
+				return
+			}
+		}
+		dl := delivery{id: sub.ID + "-" + strconv.FormatUint(r.ID, 10), sub: *sub, record: r, payload: payload}
+		if d.stopped() {
+			d.addDeadLetter(dl, 0, "dispatcher closed")
+			continue
+		}
+		d.wg.Add(1)
+		go d.deliver(dl)
+	}
+}
+
+// Close stops retrying and waits for the attempts in progress. Deliveries
+// that were waiting to retry are moved to the dead-letter list.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	d.initStop()
+	if !d.stopped() {
+		close(d.stop)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// Sign returns the SignatureHeader value for body sent at t: the Unix time
+// and the hex HMAC-SHA256 of "<unix time>.<body>" keyed with secret.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify checks a SignatureHeader value against body, rejecting signatures
+// made more than SignatureTolerance away from now. Receivers use it to tell
+// deliveries from forgeries and replays.
+func Verify(secret, header string, body []byte, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Backoff is how long to wait after failed attempt n, counting from 1.
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < n && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+)
+
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	DefaultTimeout        = 10 * time.Second
+
+	// LogSize bounds the delivery log and the dead-letter list; the oldest
+	// entries are dropped first.
+	LogSize = 1000
+
+	// SignatureTolerance is how old a signature Verify accepts.
+	SignatureTolerance = 5 * time.Minute
+
+	// lookupTimeout bounds resolving a subscription's host.
+	lookupTimeout = 5 * time.Second
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature" // "t=<unix seconds>,v1=<hex HMAC-SHA256>"
+	EventHeader     = "X-Webhook-Event"     // the event kind
+	DeliveryHeader  = "X-Webhook-Delivery"  // the same for every attempt at a delivery
+)
+
+var (
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrInvalidSignature     = errors.New("invalid webhook signature")
+	ErrPrivateAddress       = errors.New("webhook address is not public")
+)
+
+// Subscription asks for events of the given kinds, or of every kind when
+// Events is empty, to be posted to URL and signed with Secret. The secret is
+// only shown when the subscription is created.
+type Subscription struct {
+	ID        string       `json:"id"`
+	URL       string       `json:"url"`
+	Events    []event.Kind `json:"events,omitempty"`
+	Secret    string       `json:"secret,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Payload is the JSON body posted for an event. ID is the event's outbox
+// record ID, so a receiver can tell a retried delivery it already handled.
+type Payload struct {
+	ID        uint64      `json:"id"`
+	Type      event.Kind  `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      event.Event `json:"data"`
+}
+
+// Attempt is one try at posting an event to a subscription. StatusCode is
+// zero when no response came back.
+type Attempt struct {
+	DeliveryID     string     `json:"delivery_id"`
+	SubscriptionID string     `json:"subscription_id"`
+	EventID        uint64     `json:"event_id"`
+	Type           event.Kind `json:"type"`
+	Attempt        int        `json:"attempt"`
+	At             time.Time  `json:"at"`
+	StatusCode     int        `json:"status_code,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	Delivered      bool       `json:"delivered"`
+}
+
+// DeadLetter is an event a subscription did not accept within the allowed
+// attempts, or whose retries were cut short by Close.
+type DeadLetter struct {
+	DeliveryID     string          `json:"delivery_id"`
+	SubscriptionID string          `json:"subscription_id"`
+	URL            string          `json:"url"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastError      string          `json:"last_error"`
+	At             time.Time       `json:"at"`
+}
+
+// RetryPolicy spaces out attempts at a delivery: the wait doubles after each
+// failed attempt, from InitialBackoff up to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetry is the retry policy of NewDispatcher.
+var DefaultRetry = RetryPolicy{
+	MaxAttempts:    DefaultMaxAttempts,
+	InitialBackoff: DefaultInitialBackoff,
+	MaxBackoff:     DefaultMaxBackoff,
+}
+
+// Dispatcher keeps webhook subscriptions and posts events to them, each
+// delivery in a goroutine of its own. It is safe for concurrent use. Client,
+// Retry, LookupHost and AllowPrivate may be changed before the first
+// subscription.
+type Dispatcher struct {
+	Client *http.Client
+	Retry  RetryPolicy
+	Clock  func() time.Time
+	// LookupHost resolves subscription hosts, net.DefaultResolver's when nil.
+	LookupHost func(ctx context.Context, host string) ([]netip.Addr, error)
+	// AllowPrivate accepts plain http and loopback, link-local and private
+	// addresses, for local development. Otherwise subscriptions must use
+	// https and resolve to public addresses only, and NewDispatcher's client
+	// refuses to connect anywhere else.
+	AllowPrivate bool
+
+	mu   sync.Mutex
+	subs []*Subscription
+	log  []Attempt
+	dead []DeadLetter
+	stop chan struct{} // closed by Close to cut retries short
+	wg   sync.WaitGroup
+}
+
+// delivery is an event on its way to a subscription.
+type delivery struct {
+	id      string
+	sub     Subscription
+	record  event.Record
+	payload []byte
+}
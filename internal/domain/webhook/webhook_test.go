@@ -0,0 +1,284 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// receiver is a local webhook endpoint answering with the statuses in
+// replies, then 200.
+type receiver struct {
+	*httptest.Server
+	mu      sync.Mutex
+	replies []int
+	bodies  [][]byte
+	headers []http.Header
+}
+
+func newReceiver(t *testing.T, replies ...int) *receiver {
+	r := &receiver{replies: replies}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header.Clone())
+		status := http.StatusOK
+		if len(r.replies) > 0 {
+			status, r.replies = r.replies[0], r.replies[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+// waitFor polls done until it holds or a second has passed.
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !done(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+// newTestDispatcher delivers to local receivers, retrying quickly.
+func newTestDispatcher() *Dispatcher {
+	d := NewDispatcher()
+	d.Retry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+	d.AllowPrivate = true
+	return d
+}
+
+var confirmed = event.Record{ID: 7, At: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+	Event: event.BookingConfirmed{BookingID: "B1", FlightID: "F1", Total: money.New(12500, "USD")}}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("DeliversSignedPayload", func(t *testing.T) {
+		r := newReceiver(t)
+		d := newTestDispatcher()
+		sub, err := d.Add(Subscription{URL: r.URL, Secret: "s3cret"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		d.Handle(confirmed)
+		d.Close()
+
+		if r.calls() != 1 {
+			t.Fatalf("expected 1 delivery, got %d", r.calls())
+		}
+		body, h := r.bodies[0], r.headers[0]
+		if err := Verify("s3cret", h.Get(SignatureHeader), body, time.Now()); err != nil {
+			t.Errorf("expected a valid signature, got %v", err)
+		}
+		if h.Get(EventHeader) != "BookingConfirmed" || h.Get(DeliveryHeader) != sub.ID+"-7" {
+			t.Errorf("unexpected headers %v", h)
+		}
+		var p struct {
+			ID   uint64         `json:"id"`
+			Type string         `json:"type"`
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil || p.ID != 7 || p.Type != "BookingConfirmed" || p.Data["booking_id"] != "B1" {
+			t.Errorf("unexpected payload %s", body)
+		}
+		log, _ := d.Deliveries(sub.ID)
+		if len(log) != 1 || !log[0].Delivered || log[0].StatusCode != 200 {
+			t.Errorf("expected one delivered attempt logged, got %+v", log)
+		}
+	})
+
+	t.Run("RetriesWithBackoff", func(t *testing.T) {
+		r := newReceiver(t, 500, 503)
+		d := newTestDispatcher()
+		sub, _ := d.Add(Subscription{URL: r.URL})
+		d.Handle(confirmed)
+		waitFor(t, func() bool { return r.calls() == 3 })
+		d.Close()
+
+		log, _ := d.Deliveries(sub.ID)
+		if len(log) != 3 || log[0].StatusCode != 500 || log[1].StatusCode != 503 || !log[2].Delivered {
+			t.Fatalf("expected two failures then a delivery, got %+v", log)
+		}
+		if log[2].Attempt != 3 || log[0].DeliveryID != log[2].DeliveryID {
+			t.Errorf("expected attempts of one delivery, got %+v", log)
+		}
+		if len(d.DeadLetters()) != 0 {
+			t.Errorf("expected no dead letters")
+		}
+	})
+
+	t.Run("DeadLetters", func(t *testing.T) {
+		r := newReceiver(t, 500, 500, 500)
+		d := newTestDispatcher()
+		sub, _ := d.Add(Subscription{URL: r.URL})
+		d.Handle(confirmed)
+		waitFor(t, func() bool { return len(d.DeadLetters()) == 1 })
+		d.Close()
+
+		dead := d.DeadLetters()
+		if len(dead) != 1 || dead[0].SubscriptionID != sub.ID || dead[0].Attempts != 3 || dead[0].LastError != "unexpected status 500" {
+			t.Fatalf("expected a dead letter after 3 attempts, got %+v", dead)
+		}
+		var p struct {
+			ID uint64 `json:"id"`
+		}
+		if err := json.Unmarshal(dead[0].Payload, &p); err != nil || p.ID != 7 {
+			t.Errorf("expected the payload kept, got %s", dead[0].Payload)
+		}
+	})
+
+	t.Run("CloseStopsRetries", func(t *testing.T) {
+		r := newReceiver(t, 500)
+		d := newTestDispatcher()
+		d.Retry.InitialBackoff, d.Retry.MaxBackoff = time.Hour, time.Hour
+		_, _ = d.Add(Subscription{URL: r.URL})
+		d.Handle(confirmed)
+		waitFor(t, func() bool { return r.calls() == 1 })
+		d.Close()
+		if dead := d.DeadLetters(); len(dead) != 1 || dead[0].Attempts != 1 {
+			t.Fatalf("expected the waiting retry dead-lettered, got %+v", dead)
+		}
+		d.Handle(confirmed)
+		if r.calls() != 1 || len(d.DeadLetters()) != 2 {
+			t.Errorf("expected events after Close dead-lettered without a delivery")
+		}
+	})
+
+	t.Run("FiltersEvents", func(t *testing.T) {
+		r := newReceiver(t)
+		d := newTestDispatcher()
+		_, _ = d.Add(Subscription{URL: r.URL, Events: []event.Kind{event.KindBookingCancelled}})
+		d.Handle(confirmed)
+		d.Handle(event.Record{ID: 8, Event: event.BookingCancelled{BookingID: "B1"}})
+		d.Close()
+		if r.calls() != 1 || r.headers[0].Get(EventHeader) != "BookingCancelled" {
+			t.Errorf("expected only BookingCancelled delivered, got %d calls", r.calls())
+		}
+	})
+
+	t.Run("Subscriptions", func(t *testing.T) {
+		d := newTestDispatcher()
+		for _, sub := range []Subscription{
+			{URL: "ftp://example.com/hook"},
+			{URL: "/hook"},
+			{URL: "https://example.com/hook", Events: []event.Kind{"BookingExploded"}},
+		} {
+			if _, err := d.Add(sub); !errors.Is(err, ErrInvalidSubscription) {
+				t.Errorf("expected ErrInvalidSubscription for %+v, got %v", sub, err)
+			}
+		}
+		sub, err := d.Add(Subscription{URL: "https://example.com/hook", Events: []event.Kind{event.KindSeatReleased, event.KindSeatReleased}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if sub.ID == "" || len(sub.Secret) < 32 || len(sub.Events) != 1 {
+			t.Errorf("expected an ID, a generated secret and one event type, got %+v", sub)
+		}
+		if list := d.List(); len(list) != 1 || list[0].Secret != "" {
+			t.Errorf("expected the secret hidden, got %+v", list)
+		}
+		if err := d.Delete(sub.ID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := d.Delete(sub.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+			t.Errorf("expected ErrSubscriptionNotFound, got %v", err)
+		}
+		if _, err := d.Deliveries(sub.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+			t.Errorf("expected ErrSubscriptionNotFound, got %v", err)
+		}
+	})
+
+	t.Run("PublicAddressesOnly", func(t *testing.T) {
+		d := NewDispatcher()
+		d.LookupHost = func(_ context.Context, host string) ([]netip.Addr, error) {
+			switch host {
+			case "partner.example":
+				return []netip.Addr{netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("2606:2800:21f:cb07:6820:80da:af6b:8b2c")}, nil
+			case "internal.example":
+				return []netip.Addr{netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("10.0.0.8")}, nil
+			}
+			return nil, errors.New("no such host")
+		}
+		for _, url := range []string{
+			"http://partner.example/hook",
+			"https://internal.example/hook",
+			"https://unknown.example/hook",
+			"https://127.0.0.1/hook",
+			"https://[::1]:8443/hook",
+			"https://169.254.169.254/latest/meta-data",
+			"https://192.168.1.10/hook",
+			"https://100.64.0.1/hook",
+			"https://0.0.0.0/hook",
+			"https://[::ffff:10.1.2.3]/hook",
+			"https://[fe80::1]/hook",
+		} {
+			if _, err := d.Add(Subscription{URL: url}); !errors.Is(err, ErrInvalidSubscription) {
+				t.Errorf("expected ErrInvalidSubscription for %s, got %v", url, err)
+			}
+		}
+		if _, err := d.Add(Subscription{URL: "https://partner.example/hook"}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		// Redirects and hosts that resolve differently by the time of a
+		// delivery are stopped when dialling.
+		r := newReceiver(t)
+		_, err := d.post(delivery{sub: Subscription{URL: r.URL}, record: confirmed})
+		if !errors.Is(err, ErrPrivateAddress) || r.calls() != 0 {
+			t.Errorf("expected the private address refused, got %v and %d calls", err, r.calls())
+		}
+	})
+
+	t.Run("ZeroValueClose", func(t *testing.T) {
+		var d Dispatcher
+		d.Close()
+		d.Close()
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 5: 10 * time.Second, 40: 10 * time.Second} {
+		if got := p.Backoff(n); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"id":1}`)
+	header := Sign("s3cret", now, body)
+	if err := Verify("s3cret", header, body, now.Add(time.Minute)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for name, check := range map[string]func() error{
+		"WrongSecret": func() error { return Verify("other", header, body, now) },
+		"Tampered":    func() error { return Verify("s3cret", header, []byte(`{"id":2}`), now) },
+		"Replayed":    func() error { return Verify("s3cret", header, body, now.Add(time.Hour)) },
+		"Malformed":   func() error { return Verify("s3cret", "v1=abc", body, now) },
+	} {
+		if err := check(); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
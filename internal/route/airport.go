@@ -0,0 +1,57 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/airport"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) AddAirportHandler(c *gin.Context) {
+	var req AddAirportInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid airport data"})
+		return
+	}
+	err := h.service.Airports.Add(&airport.Airport{
+		Code:      req.Code,
+		Name:      req.Name,
+		City:      req.City,
+		Country:   req.Country,
+		TimeZone:  req.TimeZone,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Metro:     req.Metro,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Airport added"})
+}
+
+// ListAirportsHandler lists every airport, or the airports an airport or
+// metropolitan area code resolves to when ?code= is given.
+func (h *Handler) ListAirportsHandler(c *gin.Context) {
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusOK, h.service.Airports.List())
+		return
+	}
+	result := make([]*airport.Airport, 0)
+	for _, resolved := range h.service.Airports.Resolve(code) {
+		if a, err := h.service.Airports.Get(resolved); err == nil {
+			result = append(result, a)
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) GetAirportHandler(c *gin.Context) {
+	a, err := h.service.Airports.Get(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Airport not found"})
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
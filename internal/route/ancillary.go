@@ -0,0 +1,88 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SetAncillaryHandler(c *gin.Context) {
+	var p ancillary.Product
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ancillary data"})
+		return
+	}
+	if err := h.service.Ancillaries.Set(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *Handler) ListAncillariesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Ancillaries.List())
+}
+
+func (h *Handler) DeleteAncillaryHandler(c *gin.Context) {
+	if err := h.service.Ancillaries.Delete(c.Param("code")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ancillary not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Ancillary deleted"})
+}
+
+// FlightAncillariesHandler lists the ancillaries that can still be bought for
+// a seat class on a flight.
+func (h *Handler) FlightAncillariesHandler(c *gin.Context) {
+	offers, err := h.service.AncillaryOffers(c.Param("flight_id"), c.DefaultQuery("seat_class", "Economy"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flight not found"})
+		return
+	}
+	c.JSON(http.StatusOK, offers)
+}
+
+func (h *Handler) PurchaseAncillariesHandler(c *gin.Context) {
+	var req AncillaryPurchaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Ancillaries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ancillary purchase"})
+		return
+	}
+	if _, err := h.displayPrice(money.New(0, h.service.Rates.Base()), req.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	bk, err := h.service.PurchaseAncillaries(c.Param("booking_id"), req.Ancillaries, req.PaymentMethod, h.now())
+	switch {
+	case errors.Is(err, passenger.ErrBookingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	case paymentFailed(err):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return
+	case badAncillaryRequest(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	display, _ := h.displayPrice(bk.Total(), req.Currency)
+	c.JSON(http.StatusOK, AncillaryPurchaseResponse{
+		BookingID:    bk.BookingID,
+		Ancillaries:  bk.Ancillaries,
+		Total:        bk.Total(),
+		DisplayPrice: display,
+	})
+}
+
+// badAncillaryRequest reports whether err rejects the ancillaries asked for,
+// as opposed to ones that have sold out.
+func badAncillaryRequest(err error) bool {
+	return errors.Is(err, ancillary.ErrProductNotFound) || errors.Is(err, ancillary.ErrNotApplicable) ||
+		errors.Is(err, ancillary.ErrLimitExceeded) || errors.Is(err, ancillary.ErrInvalidProduct)
+}
@@ -0,0 +1,104 @@
+package route
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive is how often an idle availability stream sends a comment,
+// so proxies do not close it.
+const streamKeepAlive = 15 * time.Second
+
+// AvailabilityStreamHandler streams a flight's seats as server-sent events: a
+// "snapshot" of every seat class and seat, then a "seats" event with the
+// classes and seats that changed whenever seats are booked or freed. A seat
+// held by a booking awaiting payment is booked, and special seats are never
+// available. The stream ends when the client goes away or the server shuts
+// down.
+func (h *Handler) AvailabilityStreamHandler(c *gin.Context) {
+	fl := h.service.FindFlightByID(c.Param("flight_id"))
+	if fl == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flight not found"})
+		return
+	}
+	changed, stop := fl.WatchSeats()
+	defer stop()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	last := seatSnapshots(fl)
+	c.SSEvent("snapshot", availabilityUpdate(fl, nil, last))
+	c.Writer.Flush()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-h.shutdown:
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-changed:
+			now := seatSnapshots(fl)
+			if update := availabilityUpdate(fl, last, now); len(update.Seats) > 0 {
+				c.SSEvent("seats", update)
+			}
+			last = now
+			return true
+		}
+	})
+}
+
+func seatSnapshots(fl *flight.Flight) map[flight.SeatClass][]flight.SeatState {
+	seats := make(map[flight.SeatClass][]flight.SeatState, len(fl.Seats))
+	for class := range fl.Seats {
+		seats[class] = fl.SeatSnapshot(class)
+	}
+	return seats
+}
+
+// availabilityUpdate lists the seats booked or freed between two snapshots,
+// with the counts of their classes. With no earlier snapshot it lists every
+// seat and class.
+func availabilityUpdate(fl *flight.Flight, before, after map[flight.SeatClass][]flight.SeatState) AvailabilityUpdate {
+	update := AvailabilityUpdate{
+		FlightID: fl.FlightID,
+		Classes:  map[string]ClassAvailability{},
+		Seats:    map[string][]SeatUpdate{},
+	}
+	for class, seats := range after {
+		var changed []SeatUpdate
+		for i, seat := range seats {
+			if before != nil && i < len(before[class]) && before[class][i].IsBooked == seat.IsBooked {
+				continue
+			}
+			changed = append(changed, SeatUpdate{
+				SeatID:  seat.SeatID,
+				Row:     seat.Row,
+				Column:  seat.Column,
+				Special: seat.Special,
+				Booked:  seat.IsBooked,
+			})
+		}
+		if before != nil && len(changed) == 0 {
+			continue
+		}
+		counts := ClassAvailability{Total: len(seats)}
+		for _, seat := range seats {
+			if seat.IsBooked {
+				counts.Booked++
+			} else if seat.Special == "" {
+				counts.Available++
+			}
+		}
+		update.Classes[string(class)] = counts
+		update.Seats[string(class)] = changed
+	}
+	return update
+}
@@ -0,0 +1,71 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ChangeBookingHandler(c *gin.Context) {
+	var req ChangeBookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid change request"})
+		return
+	}
+	if _, err := h.displayPrice(money.New(0, h.service.Rates.Base()), req.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.service.ChangeBooking(usecase.ChangeRequest{
+		BookingID:     c.Param("booking_id"),
+		FlightID:      req.FlightID,
+		SeatClass:     req.SeatClass,
+		SeatID:        req.SeatID,
+		Fare:          req.Fare,
+		PaymentMethod: req.PaymentMethod,
+		Now:           h.now(),
+	})
+	switch {
+	case errors.Is(err, passenger.ErrBookingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
+		return
+	case errors.Is(err, usecase.ErrFlightNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flight not found"})
+		return
+	case paymentFailed(err):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, usecase.ErrInvalidChange) || errors.Is(err, flight.ErrSeatClassNotFound) ||
+		errors.Is(err, fare.ErrFareNotFound) || errors.Is(err, ancillary.ErrNotApplicable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	bk := res.Booking
+	display, _ := h.displayPrice(res.Due, req.Currency)
+	c.JSON(http.StatusOK, ChangeBookingResponse{
+		BookingID:         bk.BookingID,
+		FlightID:          bk.FlightID,
+		SeatClass:         bk.SeatClass,
+		Seat:              bk.SeatID,
+		Fare:              bk.Fare,
+		Price:             bk.Price,
+		Charges:           bk.Charges,
+		Ancillaries:       bk.Ancillaries,
+		Total:             bk.Total(),
+		FareDifference:    res.FareDifference,
+		ChargesDifference: res.ChargesDifference,
+		ChangeFee:         res.ChangeFee,
+		AmountDue:         res.Due,
+		DisplayAmountDue:  display,
+	})
+}
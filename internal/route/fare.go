@@ -0,0 +1,41 @@
+package route
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) FareCalendarHandler(c *gin.Context) {
+	origin, destination, class := c.Query("origin"), c.Query("destination"), c.Query("class")
+	if origin == "" || destination == "" || class == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "origin, destination and class are required"})
+		return
+	}
+	loc := h.service.Location(origin)
+	from, err := time.ParseInLocation("2006-01-02", c.Query("from"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", c.Query("to"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+		return
+	}
+	currency := money.NormalizeCode(c.Query("currency"))
+	days, err := h.service.FareCalendar(origin, destination, from, to, class, currency, h.now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, FareCalendarResponse{
+		Origin:      origin,
+		Destination: destination,
+		SeatClass:   class,
+		Currency:    currency,
+		Days:        days,
+	})
+}
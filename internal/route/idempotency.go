@@ -0,0 +1,75 @@
+package route
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyMiddleware replays the first response to a request carrying an
+// Idempotency-Key header for retries with the same key, method, path and
+// body. Requests without the header run as usual. Server errors are not
+// stored, so they can be retried.
+func (h *Handler) IdempotencyMiddleware(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		c.Next()
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	fingerprint := idempotency.Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+	stored, err := h.idempotency.Begin(key, fingerprint, h.now())
+	switch {
+	case errors.Is(err, idempotency.ErrKeyReused):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, idempotency.ErrInProgress):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case stored != nil:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+		return
+	}
+	defer h.idempotency.Abandon(key)
+
+	w := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.Next()
+	if status := w.Status(); status < http.StatusInternalServerError {
+		h.idempotency.Complete(key, idempotency.Response{
+			Status:      status,
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		}, h.now())
+	}
+}
+
+// recordingWriter keeps a copy of the response body as it is written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,34 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) AddPromoHandler(c *gin.Context) {
+	var pr promo.Promo
+	if err := c.ShouldBindJSON(&pr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promo data"})
+		return
+	}
+	if err := h.service.Promotions.Add(&pr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pr)
+}
+
+func (h *Handler) ListPromosHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Promotions.List())
+}
+
+func (h *Handler) GetPromoHandler(c *gin.Context) {
+	pr, err := h.service.Promotions.Get(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promo code not found"})
+		return
+	}
+	c.JSON(http.StatusOK, PromoResponse{Promo: pr, Redemptions: h.service.Promotions.Redemptions(pr.Code)})
+}
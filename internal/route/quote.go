@@ -0,0 +1,52 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) QuoteHandler(c *gin.Context) {
+	var req BookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quote request"})
+		return
+	}
+	quoteReq, err := h.parseBookingRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BookingError{Error: err.Error()})
+		return
+	}
+	res, err := h.service.Quote(quoteReq)
+	switch {
+	case errors.Is(err, usecase.ErrFlightNotFound):
+		c.JSON(http.StatusNotFound, BookingError{Error: err.Error()})
+		return
+	case errors.Is(err, fare.ErrFareNotFound) || errors.Is(err, promo.ErrPromoNotFound) ||
+		errors.Is(err, promo.ErrPromoNotApplicable) || errors.Is(err, promo.ErrPromoNotStackable) ||
+		badAncillaryRequest(err):
+		c.JSON(http.StatusBadRequest, BookingError{Error: err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, BookingError{Error: err.Error()})
+		return
+	}
+	display, _ := h.displayPrice(res.Total, req.Currency)
+	c.JSON(http.StatusOK, QuoteResponse{
+		QuoteToken:   res.Token,
+		ExpiresAt:    res.Quote.ExpiresAt,
+		FlightID:     res.Quote.FlightID,
+		SeatClass:    res.Quote.SeatClass,
+		Fare:         res.Quote.Fare,
+		Price:        res.Price,
+		Charges:      res.Charges,
+		Ancillaries:  res.Ancillaries,
+		Total:        res.Total,
+		DisplayPrice: display,
+		Promotions:   res.Promotions,
+	})
+}
@@ -0,0 +1,40 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetRatesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, RatesResponse{Base: h.service.Rates.Base(), Rates: h.service.Rates.Rates()})
+}
+
+// SetRatesHandler swaps in a whole new rate table; currencies left out can
+// no longer be converted to.
+func (h *Handler) SetRatesHandler(c *gin.Context) {
+	var req RatesInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rates"})
+		return
+	}
+	if err := h.service.SetRates(req.Rates); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, RatesResponse{Base: h.service.Rates.Base(), Rates: h.service.Rates.Rates()})
+}
+
+// displayPrice converts m for display, or returns nil when no display
+// currency was asked for.
+func (h *Handler) displayPrice(m money.Money, currency string) (*money.Money, error) {
+	if currency == "" {
+		return nil, nil
+	}
+	converted, err := h.service.Convert(m, currency)
+	if err != nil {
+		return nil, err
+	}
+	return &converted, nil
+}
@@ -0,0 +1,58 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/revenue"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SetRevenuePolicyHandler(c *gin.Context) {
+	var p revenue.Policy
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy data"})
+		return
+	}
+	if err := h.service.SetRevenuePolicy(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *Handler) ListRevenuePoliciesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Revenue.List())
+}
+
+// DeleteRevenuePolicyHandler removes the policy for ?origin=&destination=
+// and an optional &class=.
+func (h *Handler) DeleteRevenuePolicyHandler(c *gin.Context) {
+	err := h.service.DeleteRevenuePolicy(c.Query("origin"), c.Query("destination"), c.Query("class"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Policy deleted"})
+}
+
+// SimulateRevenueHandler shows what each booking on a flight would have cost
+// under a proposed policy without putting it in force.
+func (h *Handler) SimulateRevenueHandler(c *gin.Context) {
+	var req SimulateRevenueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid simulation request"})
+		return
+	}
+	sim, err := h.service.SimulateRevenue(req.FlightID, &req.Policy)
+	if errors.Is(err, usecase.ErrFlightNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flight not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sim)
+}
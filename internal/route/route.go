@@ -1,32 +1,55 @@
 package route
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"sort"
 	"time"
 
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/payment"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/quote"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
 	"github.com/gin-gonic/gin"
 )
 
-func AddFlightHandler(c *gin.Context) {
+func (h *Handler) AddFlightHandler(c *gin.Context) {
 	var req AddFlightInput
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flight data"})
 		return
 	}
-	dep, err := time.Parse("2006-01-02 15:04", req.Departure)
+	dep, err := time.ParseInLocation("2006-01-02 15:04", req.Departure, h.service.Location(req.Origin))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid departure format"})
 		return
 	}
-	arr, err := time.Parse("2006-01-02 15:04", req.Arrival)
+	arr, err := time.ParseInLocation("2006-01-02 15:04", req.Arrival, h.service.Location(req.Destination))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid arrival format"})
 		return
 	}
 	fl := flight.InitializeFlight(req.FlightID, req.Origin, req.Destination, req.Aircraft, dep, arr)
+	if req.Currency != "" {
+		fl.Currency = money.NormalizeCode(req.Currency)
+	}
 	classPriority := make([]string, 0)
+	basePrices := make(map[string]money.Money, len(req.SeatLayout))
 	for class, layout := range req.SeatLayout {
+		base := money.New(0, fl.Currency)
+		if amount := req.BasePrices[class]; amount != "" {
+			parsed, err := money.Parse(amount.String(), fl.Currency)
+			if err != nil || parsed.Amount < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base price for " + class})
+				return
+			}
+			base = parsed
+		}
+		basePrices[class] = base
 		seatLayout := [][]*flight.Seat{}
 		classPriority = append(classPriority, class)
 		for r, row := range layout {
@@ -40,79 +63,139 @@ func AddFlightHandler(c *gin.Context) {
 			}
 			seatLayout = append(seatLayout, seatRow)
 		}
-		fl.AddSeatClass(flight.SeatClass(class), seatLayout, req.BasePrices[class])
+		fl.AddSeatClass(flight.SeatClass(class), seatLayout, base)
+	}
+	for class, families := range req.FareFamilies {
+		if err := fl.SetFareFamilies(flight.SeatClass(class), families); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": class + ": " + err.Error()})
+			return
+		}
 	}
-	service.AddFlight(fl)
-	service.SetSeatClassPriority(classPriority)
+	if err := h.service.AddFlight(fl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Highest to lowest, as SeatClassPriority expects
+	sort.Slice(classPriority, func(i, j int) bool {
+		pi, pj := basePrices[classPriority[i]], basePrices[classPriority[j]]
+		if pi != pj {
+			return pj.Less(pi)
+		}
+		return classPriority[i] < classPriority[j]
+	})
+	h.service.SetSeatClassPriority(classPriority)
 	c.JSON(http.StatusOK, gin.H{"status": "Flight added"})
 }
 
-func GetFlightHandler(c *gin.Context) {
+// GetFlightHandler returns a flight's availability, with base prices also
+// shown in the optional ?currency= display currency.
+func (h *Handler) GetFlightHandler(c *gin.Context) {
 	flightID := c.Param("flight_id")
-	fl := service.FindFlightByID(flightID)
+	fl := h.service.FindFlightByID(flightID)
 	if fl == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Flight not found"})
 		return
 	}
-	resp := GetFlightResponse{
-		FlightID:    fl.FlightID,
-		Origin:      fl.Origin,
-		Destination: fl.Destination,
-		Departure:   fl.Departure.Format("2006-01-02 15:04"),
-		Seats: map[string]struct {
-			Total     int     `json:"total"`
-			Available int     `json:"available"`
-			BasePrice float64 `json:"base_price"`
-		}{},
-	}
-	for class, seats := range fl.Seats {
-		total := len(seats)
-		available := 0
-		for _, s := range seats {
-			if !s.IsBooked && s.Special == "" {
-				available++
-			}
+	resp, err := h.newGetFlightResponse(fl, c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SearchFlightsHandler finds flights by origin, destination and origin-local
+// date; origin and destination may be metropolitan area codes such as NYC.
+func (h *Handler) SearchFlightsHandler(c *gin.Context) {
+	origin, destination := c.Query("origin"), c.Query("destination")
+	if origin == "" || destination == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "origin and destination are required"})
+		return
+	}
+	date, err := time.ParseInLocation("2006-01-02", c.Query("date"), h.service.Location(origin))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date"})
+		return
+	}
+	resp := make([]GetFlightResponse, 0)
+	for _, fl := range h.service.SearchFlights(origin, destination, date) {
+		summary, err := h.newGetFlightResponse(fl, c.Query("currency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
-		resp.Seats[string(class)] = struct {
-			Total     int     `json:"total"`
-			Available int     `json:"available"`
-			BasePrice float64 `json:"base_price"`
-		}{
-			Total:     total,
-			Available: available,
+		resp = append(resp, summary)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) newGetFlightResponse(fl *flight.Flight, currency string) (GetFlightResponse, error) {
+	originLoc, destinationLoc := h.service.Location(fl.Origin), h.service.Location(fl.Destination)
+	resp := GetFlightResponse{
+		FlightID:            fl.FlightID,
+		Origin:              fl.Origin,
+		Destination:         fl.Destination,
+		Departure:           fl.Departure.In(originLoc).Format("2006-01-02 15:04"),
+		Arrival:             fl.Arrival.In(destinationLoc).Format("2006-01-02 15:04"),
+		OriginTimeZone:      originLoc.String(),
+		DestinationTimeZone: destinationLoc.String(),
+		Currency:            fl.Currency,
+		Seats:               map[string]SeatAvailability{},
+	}
+	for class := range fl.Seats {
+		availability, _ := fl.Availability(class)
+		summary := SeatAvailability{
+			Total:     availability.Total,
+			Available: availability.Available,
 			BasePrice: fl.BasePrices[class],
 		}
+		display, err := h.displayPrice(fl.BasePrices[class], currency)
+		if err != nil {
+			return GetFlightResponse{}, err
+		}
+		summary.DisplayBasePrice = display
+		summary.Fares = h.service.FareAvailability(fl, string(class), h.now())
+		resp.Seats[string(class)] = summary
 	}
-	c.JSON(http.StatusOK, resp)
+	if distance, err := h.service.FlightDistance(fl); err == nil {
+		resp.DistanceKm = math.Round(distance)
+	}
+	return resp, nil
 }
 
-func BookFlightHandler(c *gin.Context) {
+func (h *Handler) BookFlightHandler(c *gin.Context) {
 	var req BookingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking request"})
 		return
 	}
-	bookDate, err := time.Parse("2006-01-02", req.BookingDate)
+	bookReq, err := h.parseBookingRequest(req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking_date"})
+		c.JSON(http.StatusBadRequest, BookingError{Error: err.Error()})
+		return
+	}
+	bk, err := h.service.Book(bookReq)
+	if errors.Is(err, quote.ErrInvalidQuote) || errors.Is(err, quote.ErrQuoteMatch) || badAncillaryRequest(err) {
+		c.JSON(http.StatusBadRequest, BookingError{Error: err.Error()})
+		return
+	}
+	if paymentFailed(err) {
+		c.JSON(http.StatusPaymentRequired, BookingError{Error: err.Error()})
+		return
+	}
+	if errors.Is(err, fare.ErrFareNotFound) || errors.Is(err, promo.ErrPromoNotFound) ||
+		errors.Is(err, promo.ErrPromoNotApplicable) || errors.Is(err, promo.ErrPromoNotStackable) {
+		c.JSON(http.StatusBadRequest, BookingError{Error: err.Error()})
 		return
 	}
-	bk, err := service.BookSeat(req.PassengerID, req.FlightID, req.SeatClass, bookDate)
 	if err != nil {
 		// Try to detect upgrade suggestion
 		if err.Error() == "no seat available" {
-			flightObj := service.FindFlightByID(req.FlightID)
+			flightObj := h.service.FindFlightByID(req.FlightID)
 			upgrade := ""
 			for _, c := range []string{"Business", "First"} {
-				if c != req.SeatClass {
-					for _, s := range flightObj.Seats[flight.SeatClass(c)] {
-						if !s.IsBooked {
-							upgrade = c
-							break
-						}
-					}
-				}
-				if upgrade != "" {
+				if a, _ := flightObj.Availability(flight.SeatClass(c)); c != req.SeatClass && a.Available > 0 {
+					upgrade = c
 					break
 				}
 			}
@@ -126,30 +209,38 @@ func BookFlightHandler(c *gin.Context) {
 		c.JSON(http.StatusConflict, BookingError{Error: err.Error()})
 		return
 	}
+	display, _ := h.displayPrice(bk.Total(), req.Currency)
 	c.JSON(http.StatusOK, BookingResponse{
-		BookingID:   bk.BookingID,
-		PassengerID: bk.PassengerID,
-		FlightID:    bk.FlightID,
-		Seat:        bk.SeatID,
-		Price:       bk.Price,
-		Status:      "Confirmed",
+		BookingID:    bk.BookingID,
+		PassengerID:  bk.PassengerID,
+		FlightID:     bk.FlightID,
+		Seat:         bk.SeatID,
+		Price:        bk.Price,
+		Charges:      bk.Charges,
+		Ancillaries:  bk.Ancillaries,
+		Total:        bk.Total(),
+		DisplayPrice: display,
+		Fare:         bk.Fare,
+		Promotions:   bk.Promotions,
+		Payments:     bk.Payments,
+		Status:       bk.Status,
 	})
 }
 
-func CancelBookingHandler(c *gin.Context) {
+func (h *Handler) CancelBookingHandler(c *gin.Context) {
 	var req CancelRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cancellation request"})
 		return
 	}
-	bk, err := passengerStore.GetBooking(req.BookingID)
+	bk, err := h.bookings.GetBooking(req.BookingID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found"})
 		return
 	}
 	// Save refund before cancellation (since CancelBooking may update price)
-	refund := bk.Price * 0.8
-	err = service.CancelBooking(req.BookingID, time.Now())
+	refund := h.service.CalculateRefund(bk, h.now())
+	err = h.service.CancelBooking(req.BookingID, h.now())
 	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
@@ -160,3 +251,37 @@ func CancelBookingHandler(c *gin.Context) {
 		RefundAmount: refund,
 	})
 }
+
+// parseBookingRequest reads the booking date as a calendar day at the origin
+// airport and rejects an unknown display currency before any seat is taken.
+func (h *Handler) parseBookingRequest(req BookingRequest) (usecase.BookingRequest, error) {
+	loc := time.UTC
+	if fl := h.service.FindFlightByID(req.FlightID); fl != nil {
+		loc = h.service.Location(fl.Origin)
+	}
+	bookDate, err := time.ParseInLocation("2006-01-02", req.BookingDate, loc)
+	if err != nil {
+		return usecase.BookingRequest{}, errors.New("Invalid booking_date")
+	}
+	if _, err := h.displayPrice(money.New(0, h.service.Rates.Base()), req.Currency); err != nil {
+		return usecase.BookingRequest{}, err
+	}
+	return usecase.BookingRequest{
+		PassengerID:   req.PassengerID,
+		FlightID:      req.FlightID,
+		SeatClass:     req.SeatClass,
+		Fare:          req.Fare,
+		PromoCodes:    req.PromoCodes,
+		SeatID:        req.SeatID,
+		BookingDate:   bookDate,
+		QuoteToken:    req.QuoteToken,
+		Ancillaries:   req.Ancillaries,
+		PaymentMethod: req.PaymentMethod,
+	}, nil
+}
+
+// paymentFailed reports whether err is the payment gateway turning a payment
+// down.
+func paymentFailed(err error) bool {
+	return errors.Is(err, payment.ErrDeclined) || errors.Is(err, payment.ErrCaptureFailed)
+}
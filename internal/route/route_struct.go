@@ -1,46 +1,201 @@
 package route
 
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/idempotency"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/revenue"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+)
+
+// Deps is what a router is built from. Bookings defaults to the service's
+// passenger storage, Clock to the service's clock and Idempotency to a new
+// store keeping responses for Config.IdempotencyWindow. Closing Shutdown ends
+// the availability streams, which otherwise run until their clients leave.
+type Deps struct {
+	Service     *usecase.Service
+	Bookings    passenger.Storage
+	Clock       func() time.Time
+	Idempotency *idempotency.Store
+	Shutdown    <-chan struct{}
+	Logger      *slog.Logger // requests and recovered panics, slog.Default() when nil
+	Config      Config
+}
+
+// Config tunes the HTTP layer.
+type Config struct {
+	IdempotencyWindow time.Duration // how long Idempotency-Key responses are replayed; 24h when zero
+}
+
+// Handler serves the API from its own service and stores, so several
+// configured instances can run side by side.
+type Handler struct {
+	service     *usecase.Service
+	bookings    passenger.Storage
+	now         func() time.Time
+	idempotency *idempotency.Store
+	shutdown    <-chan struct{}
+}
+
 // AddFlight endpoint expects a full seat layout with specials
 type AddFlightInput struct {
 	FlightID    string `json:"flight_id"`
 	Origin      string `json:"origin"`
 	Destination string `json:"destination"`
-	Departure   string `json:"departure"` // "YYYY-MM-DD HH:MM"
-	Arrival     string `json:"arrival"`   // "YYYY-MM-DD HH:MM"
+	Departure   string `json:"departure"` // "YYYY-MM-DD HH:MM", origin local time
+	Arrival     string `json:"arrival"`   // "YYYY-MM-DD HH:MM", destination local time
 	Aircraft    string `json:"aircraft"`
 	SeatLayout  map[string][][]struct {
 		Special string `json:"special"`
 	} `json:"seat_layout"` // class -> 2D layout, each seat can have a special
-	BasePrices map[string]float64 `json:"base_prices"`
+	Currency     string                   `json:"currency,omitempty"`      // selling currency, USD when empty
+	BasePrices   map[string]json.Number   `json:"base_prices"`             // exact decimals in the selling currency
+	FareFamilies map[string][]fare.Family `json:"fare_families,omitempty"` // class -> fare buckets
 }
 
 // GetFlight returns AddFlightRequest-style response
 type GetFlightResponse struct {
-	FlightID    string `json:"flight_id"`
-	Origin      string `json:"origin"`
-	Destination string `json:"destination"`
-	Departure   string `json:"departure"`
-	Seats       map[string]struct {
-		Total     int     `json:"total"`
-		Available int     `json:"available"`
-		BasePrice float64 `json:"base_price"`
-	} `json:"seats"`
+	FlightID            string                      `json:"flight_id"`
+	Origin              string                      `json:"origin"`
+	Destination         string                      `json:"destination"`
+	Departure           string                      `json:"departure"` // origin local time
+	Arrival             string                      `json:"arrival"`   // destination local time
+	OriginTimeZone      string                      `json:"origin_time_zone"`
+	DestinationTimeZone string                      `json:"destination_time_zone"`
+	Currency            string                      `json:"currency"`
+	DistanceKm          float64                     `json:"distance_km,omitempty"`
+	Seats               map[string]SeatAvailability `json:"seats"`
+}
+
+type SeatAvailability struct {
+	Total            int                 `json:"total"`
+	Available        int                 `json:"available"`
+	BasePrice        money.Money         `json:"base_price"`
+	DisplayBasePrice *money.Money        `json:"display_base_price,omitempty"`
+	Fares            []fare.Availability `json:"fares,omitempty"`
+}
+
+// AvailabilityUpdate is the data of an availability stream event. A snapshot
+// holds every class and seat; later updates hold only the seats booked or
+// freed since the last event, with the counts of their classes.
+type AvailabilityUpdate struct {
+	FlightID string                       `json:"flight_id"`
+	Classes  map[string]ClassAvailability `json:"classes"`
+	Seats    map[string][]SeatUpdate      `json:"seats"`
+}
+
+// ClassAvailability counts a seat class's seats. Special seats are never
+// sold, so they count towards Total only.
+type ClassAvailability struct {
+	Total     int `json:"total"`
+	Available int `json:"available"` // free seats on sale
+	Booked    int `json:"booked"`    // including seats held by bookings awaiting payment
+}
+
+type SeatUpdate struct {
+	SeatID  string `json:"seat_id"`
+	Row     int    `json:"row"`
+	Column  int    `json:"column"`
+	Special string `json:"special,omitempty"`
+	Booked  bool   `json:"booked"`
 }
 
 type BookingRequest struct {
-	PassengerID string `json:"passenger_id"`
-	FlightID    string `json:"flight_id"`
-	SeatClass   string `json:"seat_class"`
-	BookingDate string `json:"booking_date"` // "YYYY-MM-DD"
+	PassengerID   string              `json:"passenger_id"`
+	FlightID      string              `json:"flight_id"`
+	SeatClass     string              `json:"seat_class"`
+	Fare          string              `json:"fare,omitempty"` // fare family code, cheapest when empty
+	PromoCodes    []string            `json:"promo_codes,omitempty"`
+	SeatID        string              `json:"seat_id,omitempty"`  // chosen seat, best available when empty
+	BookingDate   string              `json:"booking_date"`       // "YYYY-MM-DD", origin local date
+	Currency      string              `json:"currency,omitempty"` // display currency for display_price
+	QuoteToken    string              `json:"quote_token,omitempty"`
+	Ancillaries   []ancillary.Request `json:"ancillaries,omitempty"`
+	PaymentMethod string              `json:"payment_method,omitempty"` // payment gateway token
 }
 
 type BookingResponse struct {
-	BookingID   string  `json:"booking_id"`
-	PassengerID string  `json:"passenger_id"`
-	FlightID    string  `json:"flight_id"`
-	Seat        string  `json:"seat"`
-	Price       float64 `json:"price"`
-	Status      string  `json:"status"`
+	BookingID    string              `json:"booking_id"`
+	PassengerID  string              `json:"passenger_id"`
+	FlightID     string              `json:"flight_id"`
+	Seat         string              `json:"seat"`
+	Price        money.Money         `json:"price"` // fare after promotions
+	Charges      []tax.Item          `json:"charges,omitempty"`
+	Ancillaries  []ancillary.Item    `json:"ancillaries,omitempty"`
+	Total        money.Money         `json:"total"`
+	DisplayPrice *money.Money        `json:"display_price,omitempty"` // total in the display currency
+	Fare         string              `json:"fare,omitempty"`
+	Promotions   []promo.Redemption  `json:"promotions,omitempty"`
+	Payments     []passenger.Payment `json:"payments,omitempty"`
+	Status       string              `json:"status"`
+}
+
+// QuoteResponse prices a booking request. Fare and Price are guaranteed until
+// ExpiresAt when booked with QuoteToken; promotions and charges are applied
+// again at booking.
+type QuoteResponse struct {
+	QuoteToken   string             `json:"quote_token"`
+	ExpiresAt    time.Time          `json:"expires_at"`
+	FlightID     string             `json:"flight_id"`
+	SeatClass    string             `json:"seat_class"`
+	Fare         string             `json:"fare,omitempty"`
+	Price        money.Money        `json:"price"` // fare after promotions
+	Charges      []tax.Item         `json:"charges,omitempty"`
+	Ancillaries  []ancillary.Item   `json:"ancillaries,omitempty"`
+	Total        money.Money        `json:"total"`
+	DisplayPrice *money.Money       `json:"display_price,omitempty"`
+	Promotions   []promo.Redemption `json:"promotions,omitempty"`
+}
+
+type AncillaryPurchaseRequest struct {
+	Ancillaries   []ancillary.Request `json:"ancillaries"`
+	Currency      string              `json:"currency,omitempty"` // display currency for display_price
+	PaymentMethod string              `json:"payment_method,omitempty"`
+}
+
+// AncillaryPurchaseResponse lists every ancillary on the booking and its new
+// total.
+type AncillaryPurchaseResponse struct {
+	BookingID    string           `json:"booking_id"`
+	Ancillaries  []ancillary.Item `json:"ancillaries"`
+	Total        money.Money      `json:"total"`
+	DisplayPrice *money.Money     `json:"display_price,omitempty"`
+}
+
+// ChangeBookingRequest moves a booking; empty fields keep their current
+// value.
+type ChangeBookingRequest struct {
+	FlightID      string `json:"flight_id,omitempty"` // another flight on the same route
+	SeatClass     string `json:"seat_class,omitempty"`
+	SeatID        string `json:"seat_id,omitempty"`
+	Fare          string `json:"fare,omitempty"`
+	Currency      string `json:"currency,omitempty"`       // display currency for display_amount_due
+	PaymentMethod string `json:"payment_method,omitempty"` // pays the amount due
+}
+
+type ChangeBookingResponse struct {
+	BookingID         string           `json:"booking_id"`
+	FlightID          string           `json:"flight_id"`
+	SeatClass         string           `json:"seat_class"`
+	Seat              string           `json:"seat"`
+	Fare              string           `json:"fare,omitempty"`
+	Price             money.Money      `json:"price"`
+	Charges           []tax.Item       `json:"charges,omitempty"`
+	Ancillaries       []ancillary.Item `json:"ancillaries,omitempty"`
+	Total             money.Money      `json:"total"`
+	FareDifference    money.Money      `json:"fare_difference"`
+	ChargesDifference money.Money      `json:"charges_difference"`
+	ChangeFee         money.Money      `json:"change_fee"`
+	AmountDue         money.Money      `json:"amount_due"` // negative when money is owed back
+	DisplayAmountDue  *money.Money     `json:"display_amount_due,omitempty"`
 }
 
 type BookingError struct {
@@ -51,7 +206,49 @@ type CancelRequest struct {
 	BookingID string `json:"booking_id"`
 }
 type CancelResponse struct {
-	BookingID    string  `json:"booking_id"`
-	Status       string  `json:"status"`
-	RefundAmount float64 `json:"refund_amount"`
+	BookingID    string      `json:"booking_id"`
+	Status       string      `json:"status"`
+	RefundAmount money.Money `json:"refund_amount"`
+}
+
+type AddAirportInput struct {
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	City      string  `json:"city"`
+	Country   string  `json:"country"`
+	TimeZone  string  `json:"time_zone"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Metro     string  `json:"metro"`
+}
+
+type FareCalendarResponse struct {
+	Origin      string                `json:"origin"`
+	Destination string                `json:"destination"`
+	SeatClass   string                `json:"seat_class"`
+	Currency    string                `json:"currency,omitempty"`
+	Days        []usecase.CalendarDay `json:"days"`
+}
+
+// SimulateRevenueRequest replays a flight's bookings under a proposed policy.
+// The policy's route defaults to the flight's.
+type SimulateRevenueRequest struct {
+	FlightID string         `json:"flight_id" binding:"required"`
+	Policy   revenue.Policy `json:"policy"`
+}
+
+// RatesInput replaces the exchange rates, in units per one base currency
+// unit.
+type RatesInput struct {
+	Rates map[string]float64 `json:"rates" binding:"required"`
+}
+
+type RatesResponse struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+type PromoResponse struct {
+	*promo.Promo
+	Redemptions int `json:"redemptions"`
 }
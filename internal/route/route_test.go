@@ -1,13 +1,28 @@
 package route
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/payment"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,14 +31,40 @@ type SeatLayoutCell struct {
 	Special string `json:"special"`
 }
 
-func setupTestRouter() *gin.Engine {
+// testRouter is a router over its own service and bookings, so tests do
+// not share state.
+type testRouter struct {
+	http.Handler
+	service  *usecase.Service
+	bookings *passenger.InMemoryStorage
+}
+
+func setupTestRouter() *testRouter {
 	gin.SetMode(gin.TestMode)
-	r := gin.Default()
-	r.POST("/flights", AddFlightHandler)
-	r.GET("/flights/:flight_id", GetFlightHandler)
-	r.POST("/book", BookFlightHandler)
-	r.POST("/cancel", CancelBookingHandler)
-	return r
+	bookings := passenger.NewInMemoryStorage()
+	service := usecase.NewService([]*flight.Flight{}, bookings)
+	return &testRouter{
+		Handler:  NewRouter(Deps{Service: service}),
+		service:  service,
+		bookings: bookings,
+	}
+}
+
+func TestNewRouter_Independent(t *testing.T) {
+	a, b := setupTestRouter(), setupTestRouter()
+	body := `{"flight_id": "IND001", "origin": "BKK", "destination": "CNX", "departure": "2030-01-01 10:00", "arrival": "2030-01-01 11:10", "aircraft": "A320", "seat_layout": {"Economy": [[{"special": ""}]]}, "base_prices": {"Economy": 100}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	a.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	for router, code := range map[*testRouter]int{a: 200, b: 404} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/flights/IND001", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code)
+	}
 }
 
 func TestAddAndGetFlight(t *testing.T) {
@@ -47,9 +88,9 @@ func TestAddAndGetFlight(t *testing.T) {
 				{{Special: ""}},
 			},
 		},
-		BasePrices: map[string]float64{
-			"Economy":  300,
-			"Business": 1000,
+		BasePrices: map[string]json.Number{
+			"Economy":  "300",
+			"Business": "1000",
 		},
 	}
 	body, _ := json.Marshal(flightReq)
@@ -95,7 +136,7 @@ func TestAddFlight_InvalidInput(t *testing.T) {
 		SeatLayout: map[string][][]struct {
 			Special string "json:\"special\""
 		}{},
-		BasePrices: map[string]float64{},
+		BasePrices: map[string]json.Number{},
 	}
 	body, _ := json.Marshal(flightReq)
 	w = httptest.NewRecorder()
@@ -131,8 +172,8 @@ func TestBookAndCancelFlight(t *testing.T) {
 				{{Special: ""}, {Special: ""}},
 			},
 		},
-		BasePrices: map[string]float64{
-			"Economy": 500,
+		BasePrices: map[string]json.Number{
+			"Economy": "500",
 		},
 	}
 	body, _ := json.Marshal(flightReq)
@@ -178,7 +219,7 @@ func TestBookAndCancelFlight(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, bookResp.BookingID, cancelResp.BookingID)
 	assert.Equal(t, "Cancelled", cancelResp.Status)
-	assert.True(t, cancelResp.RefundAmount > 0)
+	assert.Positive(t, cancelResp.RefundAmount.Amount)
 }
 
 func TestBook_InvalidInput(t *testing.T) {
@@ -263,8 +304,8 @@ func TestBookNoSeatAvailable(t *testing.T) {
 				{{Special: ""}},
 			},
 		},
-		BasePrices: map[string]float64{
-			"Economy": 200,
+		BasePrices: map[string]json.Number{
+			"Economy": "200",
 		},
 	}
 	body, _ := json.Marshal(flightReq)
@@ -322,9 +363,9 @@ func TestBook_UpgradeSuggestion(t *testing.T) {
 				{{Special: ""}},
 			},
 		},
-		BasePrices: map[string]float64{
-			"Economy":  400,
-			"Business": 1200,
+		BasePrices: map[string]json.Number{
+			"Economy":  "400",
+			"Business": "1200",
 		},
 	}
 	body, _ := json.Marshal(flightReq)
@@ -360,3 +401,1442 @@ func TestBook_UpgradeSuggestion(t *testing.T) {
 	_ = json.Unmarshal(w.Body.Bytes(), &errResp)
 	assert.Equal(t, errResp.Error, "No seats available in Economy. Upgrade to Business?")
 }
+
+func TestSchedules(t *testing.T) {
+	router := setupTestRouter()
+	today := time.Now().UTC()
+
+	sched := map[string]interface{}{
+		"flight_number":      "SC100",
+		"origin":             "BKK",
+		"destination":        "CNX",
+		"days_of_week":       []int{0, 1, 2, 3, 4, 5, 6},
+		"valid_from":         today.Format("2006-01-02"),
+		"valid_to":           today.AddDate(0, 0, 6).Format("2006-01-02"),
+		"departure":          "23:00",
+		"arrival":            "00:10",
+		"arrival_day_offset": 1,
+		"aircraft":           "Airbus A320",
+		"seat_layout": map[string][][]SeatLayoutCell{
+			"Economy": {{{Special: ""}}},
+		},
+		"base_prices": map[string]float64{"Economy": 100},
+	}
+	body, _ := json.Marshal(sched)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/schedules", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var addResp struct {
+		FlightsCreated int `json:"flights_created"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &addResp)
+	assert.Equal(t, 7, addResp.FlightsCreated)
+
+	// Materialized flights are bookable through the regular endpoints
+	flightID := "SC100-" + today.AddDate(0, 0, 2).Format("20060102")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/"+flightID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	// Cancel a date
+	exc := map[string]interface{}{"date": today.AddDate(0, 0, 2).Format("2006-01-02"), "cancelled": true}
+	body, _ = json.Marshal(exc)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/schedules/SC100/exceptions", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/"+flightID, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	// Unknown schedule
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/schedules/NOPE/exceptions", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	// Export and re-import
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/schedules", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"SC100"`)
+	assert.Contains(t, w.Body.String(), `"cancelled": true`)
+
+	w2 := httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/schedules/import", bytes.NewBuffer(w.Body.Bytes()))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w2, req)
+	assert.Equal(t, 200, w2.Code)
+
+	// Invalid schedule
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/schedules", bytes.NewBuffer([]byte(`{"flight_number":"BAD"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestFlightLocalTimes(t *testing.T) {
+	router := setupTestRouter()
+
+	flightReq := AddFlightInput{
+		FlightID:    "TZ001",
+		Origin:      "JFK",
+		Destination: "LHR",
+		Departure:   "2024-07-10 22:00",
+		Arrival:     "2024-07-11 10:00",
+		Aircraft:    "Boeing 777",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "300"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	fl := router.service.FindFlightByID("TZ001")
+	assert.True(t, fl.Departure.Equal(time.Date(2024, 7, 11, 2, 0, 0, 0, time.UTC)))
+	assert.True(t, fl.Arrival.Equal(time.Date(2024, 7, 11, 9, 0, 0, 0, time.UTC)))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/TZ001", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var resp GetFlightResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "2024-07-10 22:00", resp.Departure)
+	assert.Equal(t, "2024-07-11 10:00", resp.Arrival)
+	assert.Equal(t, "America/New_York", resp.OriginTimeZone)
+	assert.Equal(t, "Europe/London", resp.DestinationTimeZone)
+}
+
+func TestAirports(t *testing.T) {
+	router := setupTestRouter()
+
+	body, _ := json.Marshal(AddAirportInput{Code: "KBV", TimeZone: "Asia/Bangkok"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/airports", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/airports/KBV", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"time_zone":"Asia/Bangkok"`)
+
+	body, _ = json.Marshal(AddAirportInput{Code: "BAD", TimeZone: "Nowhere/Special"})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/airports", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/airports/NOPE", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestAddFlight_UnknownAirport(t *testing.T) {
+	router := setupTestRouter()
+
+	flightReq := AddFlightInput{
+		FlightID:    "BADAP",
+		Origin:      "XYZ",
+		Destination: "LAX",
+		Departure:   "2024-07-10 08:00",
+		Arrival:     "2024-07-10 11:00",
+		Aircraft:    "Boeing 777",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "300"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Nil(t, router.service.FindFlightByID("BADAP"))
+}
+
+func TestSearchFlights(t *testing.T) {
+	router := setupTestRouter()
+
+	flightReq := AddFlightInput{
+		FlightID:    "SR001",
+		Origin:      "ewr ",
+		Destination: "sfo",
+		Departure:   "2025-03-02 23:30",
+		Arrival:     "2025-03-03 02:45",
+		Aircraft:    "Boeing 737",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "250"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights?origin=NYC&destination=SFO&date=2025-03-02", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var found []GetFlightResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &found)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "SR001", found[0].FlightID)
+		assert.Equal(t, "EWR", found[0].Origin)
+		assert.InDelta(t, 4100, found[0].DistanceKm, 50)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights?origin=NYC&destination=SFO&date=2025-03-03", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights?origin=NYC&date=2025-03-02", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	// Metro lookup
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/airports?code=NYC", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var airports []map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &airports)
+	assert.Len(t, airports, 3)
+}
+
+func TestFareCalendar(t *testing.T) {
+	router := setupTestRouter()
+	departure := time.Now().AddDate(0, 0, 45)
+
+	flightReq := AddFlightInput{
+		FlightID:    "CAL001",
+		Origin:      "HKG",
+		Destination: "ICN",
+		Departure:   departure.Format("2006-01-02") + " 10:00",
+		Arrival:     departure.Format("2006-01-02") + " 14:30",
+		Aircraft:    "Airbus A330",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "200"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	url := "/fares/calendar?origin=HKG&destination=SEL&class=Economy&from=" +
+		departure.AddDate(0, 0, -1).Format("2006-01-02") + "&to=" + departure.AddDate(0, 0, 1).Format("2006-01-02")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", url, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var resp FareCalendarResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if assert.Len(t, resp.Days, 3) {
+		assert.False(t, resp.Days[0].Available)
+		assert.True(t, resp.Days[1].Available)
+		assert.Equal(t, "CAL001", resp.Days[1].FlightID)
+		if assert.NotNil(t, resp.Days[1].Price) {
+			assert.InDelta(t, 200*0.9*1.5, resp.Days[1].Price.Float(), 0.001)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fares/calendar?origin=HKG&destination=ICN&class=Economy&from=bad&to=2024-01-01", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/fares/calendar?origin=HKG&destination=ICN&class=Economy&from=2024-03-01&to=2024-01-01", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestFareFamilies(t *testing.T) {
+	router := setupTestRouter()
+
+	flightReq := AddFlightInput{
+		FlightID:    "FF001",
+		Origin:      "BKK",
+		Destination: "SIN",
+		Departure:   time.Now().AddDate(0, 0, 30).Format("2006-01-02") + " 08:00",
+		Arrival:     time.Now().AddDate(0, 0, 30).Format("2006-01-02") + " 11:30",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "200"},
+		FareFamilies: map[string][]fare.Family{"Economy": {
+			{Code: "EB", Name: "Basic", Price: money.FromFloat(100, "USD"), Allotment: 1, Rules: fare.Rules{CheckedBags: 0}},
+			{Code: "EF", Name: "Flex", Price: money.FromFloat(300, "USD"), Rules: fare.Rules{Refundable: true, RefundPercent: 100, LateRefundPercent: 100}},
+		}},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	book := func(fareCode string) (*httptest.ResponseRecorder, BookingResponse) {
+		body, _ := json.Marshal(BookingRequest{
+			PassengerID: "P100",
+			FlightID:    "FF001",
+			SeatClass:   "Economy",
+			Fare:        fareCode,
+			BookingDate: time.Now().Format("2006-01-02"),
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/book", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var resp BookingResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := book("")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "EB", resp.Fare)
+
+	w, resp = book("")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "EF", resp.Fare)
+
+	w, _ = book("XX")
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/FF001", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var flightResp GetFlightResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &flightResp)
+	if fares := flightResp.Seats["Economy"].Fares; assert.Len(t, fares, 2) {
+		assert.Equal(t, 0, fares[0].Available)
+		assert.Equal(t, "EF", fares[1].Code)
+	}
+
+	// Flex is fully refundable
+	body, _ = json.Marshal(CancelRequest{BookingID: resp.BookingID})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/cancel", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var cancelResp CancelResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &cancelResp)
+	assert.Equal(t, resp.Price, cancelResp.RefundAmount)
+}
+
+func TestRevenuePolicies(t *testing.T) {
+	router := setupTestRouter()
+	departure := time.Now().AddDate(0, 0, 20).Format("2006-01-02")
+
+	flightReq := AddFlightInput{
+		FlightID:    "RM001",
+		Origin:      "SIN",
+		Destination: "HKG",
+		Departure:   departure + " 09:00",
+		Arrival:     departure + " 13:00",
+		Aircraft:    "Airbus A321",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}, {Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "200"},
+		FareFamilies: map[string][]fare.Family{"Economy": {
+			{Code: "EB", Price: money.FromFloat(100, "USD")},
+			{Code: "EF", Price: money.FromFloat(300, "USD")},
+		}},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	for _, p := range []string{"P1", "P2"} {
+		body, _ = json.Marshal(BookingRequest{PassengerID: p, FlightID: "RM001", SeatClass: "Economy", BookingDate: time.Now().Format("2006-01-02")})
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/book", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+	}
+
+	// Simulating does not put the policy in force
+	simReq := `{"flight_id": "RM001", "policy": {"curve": [{"days_before": 0, "load": 0}], "step": 0.5}}`
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/revenue/simulate", bytes.NewBufferString(simReq))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var sim struct {
+		Bookings []struct {
+			SimulatedFare string `json:"simulated_fare"`
+		} `json:"bookings"`
+		Revenue          money.Money `json:"revenue"`
+		SimulatedRevenue money.Money `json:"simulated_revenue"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &sim)
+	if assert.Len(t, sim.Bookings, 2) {
+		assert.Equal(t, "EB", sim.Bookings[0].SimulatedFare)
+		assert.Equal(t, "EF", sim.Bookings[1].SimulatedFare)
+	}
+	assert.True(t, sim.Revenue.Less(sim.SimulatedRevenue))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/revenue/simulate", bytes.NewBufferString(`{"flight_id": "NOPE", "policy": {"curve": [{"days_before": 0, "load": 0}], "step": 0.5}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+
+	policyReq := `{"origin": "SIN", "destination": "HKG", "curve": [{"days_before": 0, "load": 0}], "step": 0.5}`
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/revenue/policies", bytes.NewBufferString(policyReq))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/revenue/policies", bytes.NewBufferString(`{"origin": "SIN", "destination": "HKG", "curve": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/RM001", nil)
+	router.ServeHTTP(w, req)
+	var flightResp GetFlightResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &flightResp)
+	if fares := flightResp.Seats["Economy"].Fares; assert.Len(t, fares, 2) {
+		assert.True(t, fares[0].Closed)
+		assert.False(t, fares[1].Closed)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/revenue/policies", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"origin":"SIN"`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/revenue/policies?origin=SIN&destination=HKG", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/revenue/policies?origin=SIN&destination=HKG", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestPromoCodes(t *testing.T) {
+	router := setupTestRouter()
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+
+	flightReq := AddFlightInput{
+		FlightID:    "PR001",
+		Origin:      "BKK",
+		Destination: "CNX",
+		Departure:   departure + " 07:00",
+		Arrival:     departure + " 08:15",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/promotions", bytes.NewBufferString(`{"code": "north20", "kind": "fixed", "amount": {"amount": 20, "currency": "USD"}, "destinations": ["CNX"], "max_redemptions": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/promotions", bytes.NewBufferString(`{"code": "BAD", "kind": "percent", "percent": 0.5}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	book := func(passengerID string, codes ...string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(BookingRequest{
+			PassengerID: passengerID,
+			FlightID:    "PR001",
+			SeatClass:   "Economy",
+			PromoCodes:  codes,
+			BookingDate: time.Now().Format("2006-01-02"),
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/book", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = book("P1", "NORTH20")
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	if assert.Len(t, bookResp.Promotions, 1) {
+		assert.Equal(t, "NORTH20", bookResp.Promotions[0].Code)
+		assert.InDelta(t, 20, bookResp.Promotions[0].Discount.Float(), 0.001)
+	}
+
+	assert.Equal(t, 409, book("P2", "NORTH20").Code)
+	assert.Equal(t, 400, book("P2", "UNKNOWN").Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/promotions/north20", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var promoResp struct {
+		Code        string `json:"code"`
+		Redemptions int    `json:"redemptions"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &promoResp)
+	assert.Equal(t, "NORTH20", promoResp.Code)
+	assert.Equal(t, 1, promoResp.Redemptions)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/promotions/NOPE", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestCurrencies(t *testing.T) {
+	router := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/rates", bytes.NewBufferString(`{"rates": {"THB": 40, "EUR": 0.5}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/rates", bytes.NewBufferString(`{"rates": {"THB": -1}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/rates", nil)
+	router.ServeHTTP(w, req)
+	var rates RatesResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &rates)
+	assert.Equal(t, "USD", rates.Base)
+	assert.Equal(t, map[string]float64{"USD": 1, "THB": 40, "EUR": 0.5}, rates.Rates)
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	flightReq := AddFlightInput{
+		FlightID:    "CU001",
+		Origin:      "BKK",
+		Destination: "HKT",
+		Departure:   departure + " 09:00",
+		Arrival:     departure + " 10:25",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}}},
+		},
+		Currency:   "thb",
+		BasePrices: map[string]json.Number{"Economy": "4000"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	// Base prices are exact, so a fraction of a satang is rejected
+	flightReq.FlightID = "CU002"
+	flightReq.BasePrices = map[string]json.Number{"Economy": "4000.001"}
+	body, _ = json.Marshal(flightReq)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/CU001?currency=USD", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var flightResp GetFlightResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &flightResp)
+	assert.Equal(t, "THB", flightResp.Currency)
+	economy := flightResp.Seats["Economy"]
+	assert.Equal(t, money.FromFloat(4000, "THB"), economy.BasePrice)
+	if assert.NotNil(t, economy.DisplayBasePrice) {
+		assert.Equal(t, money.FromFloat(100, "USD"), *economy.DisplayBasePrice)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/flights/CU001?currency=GBP", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+
+	book := func(currency string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(BookingRequest{
+			PassengerID: "P1",
+			FlightID:    "CU001",
+			SeatClass:   "Economy",
+			BookingDate: time.Now().Format("2006-01-02"),
+			Currency:    currency,
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/book", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, 400, book("GBP").Code)
+	w = book("EUR")
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	// 4000 THB with one of two seats sold, shown at 0.5 EUR per 40 THB
+	assert.Equal(t, money.FromFloat(6000, "THB"), bookResp.Price)
+	if assert.NotNil(t, bookResp.DisplayPrice) {
+		assert.Equal(t, money.FromFloat(75, "EUR"), *bookResp.DisplayPrice)
+	}
+
+	body, _ = json.Marshal(CancelRequest{BookingID: bookResp.BookingID})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/cancel", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var cancelResp CancelResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &cancelResp)
+	assert.Equal(t, money.FromFloat(4800, "THB"), cancelResp.RefundAmount) // default 20% cancellation fee
+}
+
+func TestTaxesAndFees(t *testing.T) {
+	router := setupTestRouter()
+
+	for _, body := range []string{
+		`{"code": "dmk-apt", "kind": "airport_tax", "amount": {"amount": 3, "currency": "USD"}, "airports": ["dmk"], "refundable": true}`,
+		`{"code": "DMK-BOOK", "kind": "booking_fee", "amount": {"amount": 2.5, "currency": "USD"}, "airports": ["DMK"]}`,
+		`{"code": "DMK-SEAT", "kind": "seat_selection_fee", "amount": {"amount": 7, "currency": "USD"}, "airports": ["DMK"]}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/taxes", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+	}
+
+	for _, body := range []string{
+		`{"code": "BAD", "kind": "airport_tax", "percent": 101}`,
+		`{"code": "BAD", "kind": "airport_tax", "percent": 0.5}`,
+		`{"code": "BAD", "kind": "airport_tax", "amount": {"amount": 2.505, "currency": "USD"}}`,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/taxes", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, 400, w.Code, body)
+	}
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	flightReq := AddFlightInput{
+		FlightID:    "TX001",
+		Origin:      "DMK",
+		Destination: "CNX",
+		Departure:   departure + " 07:00",
+		Arrival:     departure + " 08:15",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	book := func(seatID string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(BookingRequest{
+			PassengerID: "P1",
+			FlightID:    "TX001",
+			SeatClass:   "Economy",
+			SeatID:      seatID,
+			BookingDate: time.Now().Format("2006-01-02"),
+		})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/book", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = book("A2")
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	assert.Equal(t, "A2", bookResp.Seat)
+	// 100 with one of two seats sold, plus 3 + 2.50 + 7
+	assert.Equal(t, money.FromFloat(150, "USD"), bookResp.Price)
+	assert.Len(t, bookResp.Charges, 3)
+	assert.Equal(t, money.FromFloat(162.5, "USD"), bookResp.Total)
+
+	assert.Equal(t, 409, book("A2").Code)
+
+	body, _ = json.Marshal(CancelRequest{BookingID: bookResp.BookingID})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/cancel", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var cancelResp CancelResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &cancelResp)
+	// 80% of the fare plus the refundable airport tax
+	assert.Equal(t, money.FromFloat(123, "USD"), cancelResp.RefundAmount)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/taxes", nil)
+	router.ServeHTTP(w, req)
+	var rules []map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &rules)
+	assert.Len(t, rules, 3)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/taxes/nope", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestQuotes(t *testing.T) {
+	router := setupTestRouter()
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	flightReq := AddFlightInput{
+		FlightID:    "QT001",
+		Origin:      "BKK",
+		Destination: "HKT",
+		Departure:   departure + " 09:00",
+		Arrival:     departure + " 10:20",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}, {Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	}
+	body, _ := json.Marshal(flightReq)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	post := func(path string, r BookingRequest) *httptest.ResponseRecorder {
+		if r.FlightID == "" {
+			r.FlightID = "QT001"
+		}
+		r.SeatClass = "Economy"
+		r.BookingDate = time.Now().Format("2006-01-02")
+		body, _ := json.Marshal(r)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = post("/quote", BookingRequest{PassengerID: "QP1"})
+	assert.Equal(t, 200, w.Code)
+	var quoteResp QuoteResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &quoteResp)
+	assert.NotEmpty(t, quoteResp.QuoteToken)
+	assert.True(t, quoteResp.ExpiresAt.After(time.Now()))
+	// 100 with one of four seats sold
+	assert.Equal(t, money.FromFloat(125, "USD"), quoteResp.Price)
+
+	// Another passenger's booking moves the load factor on.
+	assert.Equal(t, 200, post("/book", BookingRequest{PassengerID: "QP2"}).Code)
+
+	// The token belongs to QP1.
+	w = post("/book", BookingRequest{PassengerID: "QP2", QuoteToken: quoteResp.QuoteToken})
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, 400, post("/book", BookingRequest{PassengerID: "QP1", QuoteToken: "garbage"}).Code)
+
+	w = post("/book", BookingRequest{PassengerID: "QP1", QuoteToken: quoteResp.QuoteToken})
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	assert.Equal(t, money.FromFloat(125, "USD"), bookResp.Price)
+
+	// A quote books once.
+	assert.Equal(t, 409, post("/book", BookingRequest{PassengerID: "QP1", QuoteToken: quoteResp.QuoteToken}).Code)
+
+	assert.Equal(t, 404, post("/quote", BookingRequest{PassengerID: "QP1", FlightID: "nope"}).Code)
+}
+
+func TestAncillaries(t *testing.T) {
+	router := setupTestRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"code": "an-bag", "kind": "checked_bag", "price": {"amount": 20, "currency": "USD"}, "flight_ids": ["AN001"], "max_per_booking": 2, "refundable": true}`,
+		`{"code": "AN-LOUNGE", "kind": "lounge", "price": {"amount": 30, "currency": "USD"}, "flight_ids": ["AN001"], "inventory": 1}`,
+	} {
+		assert.Equal(t, 200, do("POST", "/ancillaries", body).Code)
+	}
+	assert.Equal(t, 400, do("POST", "/ancillaries", `{"code": "BAD", "kind": "wifi", "price": {"amount": 5, "currency": "USD"}}`).Code)
+	assert.Equal(t, 400, do("POST", "/ancillaries", `{"code": "BAD", "kind": "meal", "price": {"amount": 5.001, "currency": "USD"}}`).Code)
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	flightReq := AddFlightInput{
+		FlightID:    "AN001",
+		Origin:      "BKK",
+		Destination: "CNX",
+		Departure:   departure + " 07:00",
+		Arrival:     departure + " 08:15",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}, {Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	}
+	body, _ := json.Marshal(flightReq)
+	assert.Equal(t, 200, do("POST", "/flights", string(body)).Code)
+
+	body, _ = json.Marshal(BookingRequest{
+		PassengerID: "ANP1",
+		FlightID:    "AN001",
+		SeatClass:   "Economy",
+		BookingDate: time.Now().Format("2006-01-02"),
+		Ancillaries: []ancillary.Request{{Code: "AN-BAG"}},
+	})
+	w := do("POST", "/book", string(body))
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	assert.Len(t, bookResp.Ancillaries, 1)
+	// 125 for the first of four seats plus a 20 bag
+	assert.Equal(t, money.FromFloat(145, "USD"), bookResp.Total)
+
+	w = do("GET", "/flights/AN001/ancillaries", "")
+	assert.Equal(t, 200, w.Code)
+	var offers []map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &offers)
+	assert.Len(t, offers, 2)
+
+	path := "/bookings/" + bookResp.BookingID + "/ancillaries"
+	w = do("POST", path, `{"ancillaries": [{"code": "an-bag"}, {"code": "AN-LOUNGE"}]}`)
+	assert.Equal(t, 200, w.Code)
+	var purchase AncillaryPurchaseResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &purchase)
+	assert.Len(t, purchase.Ancillaries, 3)
+	assert.Equal(t, money.FromFloat(195, "USD"), purchase.Total)
+
+	assert.Equal(t, 400, do("POST", path, `{"ancillaries": [{"code": "AN-BAG"}]}`).Code) // two bags at most
+	assert.Equal(t, 400, do("POST", path, `{"ancillaries": []}`).Code)
+	assert.Equal(t, 404, do("POST", "/bookings/nope/ancillaries", `{"ancillaries": [{"code": "AN-BAG"}]}`).Code)
+	assert.Equal(t, 404, do("GET", "/flights/nope/ancillaries", "").Code)
+
+	// The only lounge pass is sold.
+	body, _ = json.Marshal(BookingRequest{
+		PassengerID: "ANP2",
+		FlightID:    "AN001",
+		SeatClass:   "Economy",
+		BookingDate: time.Now().Format("2006-01-02"),
+		Ancillaries: []ancillary.Request{{Code: "AN-LOUNGE"}},
+	})
+	assert.Equal(t, 409, do("POST", "/book", string(body)).Code)
+
+	body, _ = json.Marshal(CancelRequest{BookingID: bookResp.BookingID})
+	w = do("POST", "/cancel", string(body))
+	assert.Equal(t, 200, w.Code)
+	var cancelResp CancelResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &cancelResp)
+	// 80% of the fare plus both refundable bags
+	assert.Equal(t, money.FromFloat(140, "USD"), cancelResp.RefundAmount)
+	assert.Equal(t, 409, do("POST", path, `{"ancillaries": [{"code": "AN-LOUNGE"}]}`).Code)
+}
+
+func TestChangeBooking(t *testing.T) {
+	router := setupTestRouter()
+
+	do := func(method, path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	for _, id := range []string{"CB001", "CB002"} {
+		w := do("POST", "/flights", AddFlightInput{
+			FlightID:    id,
+			Origin:      "BKK",
+			Destination: "CNX",
+			Departure:   departure + " 07:00",
+			Arrival:     departure + " 08:10",
+			Aircraft:    "Airbus A320",
+			SeatLayout: map[string][][]struct {
+				Special string `json:"special"`
+			}{
+				"Economy":  {{{Special: ""}, {Special: ""}, {Special: ""}, {Special: ""}}},
+				"Business": {{{Special: ""}, {Special: ""}}},
+			},
+			BasePrices: map[string]json.Number{"Economy": "100", "Business": "300"},
+		})
+		assert.Equal(t, 200, w.Code)
+	}
+
+	w := do("POST", "/book", BookingRequest{
+		PassengerID: "CBP1",
+		FlightID:    "CB001",
+		SeatClass:   "Economy",
+		BookingDate: time.Now().Format("2006-01-02"),
+	})
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	path := "/bookings/" + bookResp.BookingID + "/change"
+
+	w = do("POST", path, ChangeBookingRequest{SeatID: "A3"})
+	assert.Equal(t, 200, w.Code)
+	var changeResp ChangeBookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &changeResp)
+	assert.Equal(t, "A3", changeResp.Seat)
+	assert.True(t, changeResp.AmountDue.IsZero())
+
+	w = do("POST", path, ChangeBookingRequest{FlightID: "CB002", SeatClass: "Business"})
+	assert.Equal(t, 200, w.Code)
+	_ = json.Unmarshal(w.Body.Bytes(), &changeResp)
+	assert.Equal(t, "CB002", changeResp.FlightID)
+	assert.Equal(t, "Business", changeResp.SeatClass)
+	// 300 with one of two Business seats sold is 450, up from 125
+	assert.Equal(t, money.FromFloat(450, "USD"), changeResp.Price)
+	assert.Equal(t, money.FromFloat(325, "USD"), changeResp.AmountDue)
+
+	assert.Equal(t, 400, do("POST", path, ChangeBookingRequest{SeatClass: "First"}).Code)
+	assert.Equal(t, 400, do("POST", path, ChangeBookingRequest{}).Code)
+	assert.Equal(t, 404, do("POST", path, ChangeBookingRequest{FlightID: "nope"}).Code)
+	assert.Equal(t, 404, do("POST", "/bookings/nope/change", ChangeBookingRequest{SeatID: "A1"}).Code)
+
+	w = do("POST", "/book", BookingRequest{
+		PassengerID: "CBP2",
+		FlightID:    "CB002",
+		SeatClass:   "Business",
+		BookingDate: time.Now().Format("2006-01-02"),
+	})
+	assert.Equal(t, 200, w.Code)
+	var other BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &other)
+	assert.Equal(t, 409, do("POST", path, ChangeBookingRequest{SeatID: other.Seat}).Code)
+}
+
+func TestRequestLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	service := usecase.NewService([]*flight.Flight{}, passenger.NewInMemoryStorage())
+	router := NewRouter(Deps{Service: service, Logger: logger})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/flights/NOPE", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+	assert.Contains(t, logs.String(), "level=INFO msg=request method=GET path=/flights/NOPE status=404")
+
+	t.Run("Recovery", func(t *testing.T) {
+		logs.Reset()
+		r := gin.New()
+		r.Use(RequestLogger(logger), Recovery(logger))
+		r.GET("/panic", func(*gin.Context) { panic("boom") })
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/panic", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 500, w.Code)
+		assert.JSONEq(t, `{"error": "Internal server error"}`, w.Body.String())
+		assert.Contains(t, logs.String(), `msg="handler panicked" method=GET path=/panic panic=boom`)
+		assert.Contains(t, logs.String(), "level=ERROR msg=request method=GET path=/panic status=500")
+	})
+}
+
+func TestPayments(t *testing.T) {
+	router := setupTestRouter()
+
+	do := func(method, path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	w := do("POST", "/flights", AddFlightInput{
+		FlightID:    "PY001",
+		Origin:      "BKK",
+		Destination: "CNX",
+		Departure:   departure + " 12:00",
+		Arrival:     departure + " 13:10",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy":  {{{Special: ""}}},
+			"Business": {{{Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "100", "Business": "300"},
+	})
+	assert.Equal(t, 200, w.Code)
+
+	book := func(method string) *httptest.ResponseRecorder {
+		return do("POST", "/book", BookingRequest{
+			PassengerID:   "PYP1",
+			FlightID:      "PY001",
+			SeatClass:     "Economy",
+			BookingDate:   time.Now().Format("2006-01-02"),
+			PaymentMethod: method,
+		})
+	}
+	assert.Equal(t, 402, book(payment.FakeDeclined).Code)
+	assert.Equal(t, 402, book(payment.FakeCaptureFail).Code)
+
+	// The only seat was released each time.
+	w = book("tok_visa")
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	assert.Equal(t, "Confirmed", bookResp.Status)
+	assert.Len(t, bookResp.Payments, 1)
+	assert.Equal(t, bookResp.Total, bookResp.Payments[0].Amount)
+
+	// An upgrade whose difference is declined leaves the booking as it was.
+	w = do("POST", "/bookings/"+bookResp.BookingID+"/change", ChangeBookingRequest{
+		SeatClass:     "Business",
+		PaymentMethod: payment.FakeDeclined,
+	})
+	assert.Equal(t, 402, w.Code)
+
+	w = do("POST", "/cancel", CancelRequest{BookingID: bookResp.BookingID})
+	assert.Equal(t, 200, w.Code)
+	p, err := router.service.Payments.(*payment.Fake).Payment(bookResp.Payments[0].ID)
+	assert.NoError(t, err)
+	// 80% of 200, the price of the only seat
+	assert.Equal(t, money.FromFloat(160, "USD"), p.Refunded)
+}
+
+func TestAvailabilityStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := usecase.NewService([]*flight.Flight{}, passenger.NewInMemoryStorage())
+	shutdown := make(chan struct{})
+	router := NewRouter(Deps{Service: service, Shutdown: shutdown})
+
+	do := func(method, path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, 404, do("GET", "/flights/NOPE/availability/stream", nil).Code)
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	assert.Equal(t, 200, do("POST", "/flights", AddFlightInput{
+		FlightID:    "SSE001",
+		Origin:      "BKK",
+		Destination: "CNX",
+		Departure:   departure + " 12:00",
+		Arrival:     departure + " 13:10",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{"Economy": {{{Special: ""}, {Special: ""}, {Special: "Blocked"}}}},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	}).Code)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/flights/SSE001/availability/stream")
+	if err != nil {
+		t.Fatalf("opening stream: %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	events := bufio.NewReader(resp.Body)
+	next := func() (string, AvailabilityUpdate) {
+		t.Helper()
+		var name string
+		var update AvailabilityUpdate
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "data:"):
+				assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &update))
+			case line == "" && name != "":
+				return name, update
+			}
+		}
+	}
+
+	name, snapshot := next()
+	assert.Equal(t, "snapshot", name)
+	assert.Equal(t, ClassAvailability{Total: 3, Available: 2}, snapshot.Classes["Economy"])
+	assert.Len(t, snapshot.Seats["Economy"], 3)
+	assert.Equal(t, "Blocked", snapshot.Seats["Economy"][2].Special)
+
+	w := do("POST", "/book", BookingRequest{PassengerID: "SSEP1", FlightID: "SSE001", SeatClass: "Economy", BookingDate: time.Now().Format("2006-01-02")})
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	name, update := next()
+	assert.Equal(t, "seats", name)
+	assert.Equal(t, ClassAvailability{Total: 3, Available: 1, Booked: 1}, update.Classes["Economy"])
+	if assert.Len(t, update.Seats["Economy"], 1) {
+		assert.Equal(t, bookResp.Seat, update.Seats["Economy"][0].SeatID)
+		assert.True(t, update.Seats["Economy"][0].Booked)
+	}
+
+	assert.Equal(t, 200, do("POST", "/cancel", CancelRequest{BookingID: bookResp.BookingID}).Code)
+	_, update = next()
+	assert.Equal(t, ClassAvailability{Total: 3, Available: 2}, update.Classes["Economy"])
+	if assert.Len(t, update.Seats["Economy"], 1) {
+		assert.False(t, update.Seats["Economy"][0].Booked)
+	}
+
+	close(shutdown)
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("expected the stream ended on shutdown, got %v", err)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	router := setupTestRouter()
+	router.service.Webhooks.Retry = webhook.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	t.Cleanup(router.service.Webhooks.Close)
+
+	do := func(method, path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var mu sync.Mutex
+	var received []event.Kind
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if webhook.Verify("partner-secret", r.Header.Get(webhook.SignatureHeader), body, time.Now()) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var p struct {
+			Type event.Kind `json:"type"`
+		}
+		_ = json.Unmarshal(body, &p)
+		mu.Lock()
+		received = append(received, p.Type)
+		mu.Unlock()
+	}))
+	defer partner.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	assert.Equal(t, 400, do("POST", "/webhooks", webhook.Subscription{URL: "not a url"}).Code)
+	assert.Equal(t, 400, do("POST", "/webhooks", webhook.Subscription{URL: partner.URL}).Code)
+	assert.Equal(t, 400, do("POST", "/webhooks", webhook.Subscription{URL: "https://169.254.169.254/latest"}).Code)
+	router.service.Webhooks.AllowPrivate = true
+	assert.Equal(t, 400, do("POST", "/webhooks", webhook.Subscription{URL: partner.URL, Events: []event.Kind{"Nope"}}).Code)
+	w := do("POST", "/webhooks", webhook.Subscription{URL: partner.URL, Secret: "partner-secret",
+		Events: []event.Kind{event.KindBookingConfirmed, event.KindBookingCancelled}})
+	assert.Equal(t, 200, w.Code)
+	var sub webhook.Subscription
+	_ = json.Unmarshal(w.Body.Bytes(), &sub)
+	assert.Equal(t, "partner-secret", sub.Secret)
+	w = do("POST", "/webhooks", webhook.Subscription{URL: broken.URL, Events: []event.Kind{event.KindBookingConfirmed}})
+	assert.Equal(t, 200, w.Code)
+	var brokenSub webhook.Subscription
+	_ = json.Unmarshal(w.Body.Bytes(), &brokenSub)
+	assert.NotEmpty(t, brokenSub.Secret)
+
+	w = do("GET", "/webhooks", nil)
+	var subs []webhook.Subscription
+	_ = json.Unmarshal(w.Body.Bytes(), &subs)
+	assert.Len(t, subs, 2)
+	assert.Empty(t, subs[0].Secret)
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	assert.Equal(t, 200, do("POST", "/flights", AddFlightInput{
+		FlightID:    "WH001",
+		Origin:      "BKK",
+		Destination: "CNX",
+		Departure:   departure + " 12:00",
+		Arrival:     departure + " 13:10",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{"Economy": {{{Special: ""}, {Special: ""}}}},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	}).Code)
+	w = do("POST", "/book", BookingRequest{PassengerID: "WHP1", FlightID: "WH001", SeatClass: "Economy", BookingDate: time.Now().Format("2006-01-02")})
+	assert.Equal(t, 200, w.Code)
+	var bookResp BookingResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &bookResp)
+	assert.Equal(t, 200, do("POST", "/cancel", CancelRequest{BookingID: bookResp.BookingID}).Code)
+
+	// Deliveries run in the background.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if (n == 2 && len(router.service.Webhooks.DeadLetters()) == 1) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	assert.ElementsMatch(t, []event.Kind{event.KindBookingConfirmed, event.KindBookingCancelled}, received)
+	mu.Unlock()
+
+	w = do("GET", "/webhooks/"+sub.ID+"/deliveries", nil)
+	assert.Equal(t, 200, w.Code)
+	var attempts []webhook.Attempt
+	_ = json.Unmarshal(w.Body.Bytes(), &attempts)
+	if assert.Len(t, attempts, 2) {
+		assert.True(t, attempts[0].Delivered && attempts[1].Delivered)
+	}
+
+	w = do("GET", "/webhooks/dead-letters", nil)
+	var dead []webhook.DeadLetter
+	_ = json.Unmarshal(w.Body.Bytes(), &dead)
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, brokenSub.ID, dead[0].SubscriptionID)
+		assert.Equal(t, 2, dead[0].Attempts)
+	}
+	w = do("GET", "/webhooks/"+brokenSub.ID+"/deliveries", nil)
+	_ = json.Unmarshal(w.Body.Bytes(), &attempts)
+	if assert.Len(t, attempts, 2) {
+		assert.Equal(t, 503, attempts[1].StatusCode)
+	}
+
+	assert.Equal(t, 200, do("DELETE", "/webhooks/"+brokenSub.ID, nil).Code)
+	assert.Equal(t, 404, do("DELETE", "/webhooks/"+brokenSub.ID, nil).Code)
+	assert.Equal(t, 404, do("GET", "/webhooks/"+brokenSub.ID+"/deliveries", nil).Code)
+}
+
+func TestIdempotency(t *testing.T) {
+	router := setupTestRouter()
+
+	do := func(path, key string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	w := do("/flights", "", AddFlightInput{
+		FlightID:    "IK001",
+		Origin:      "BKK",
+		Destination: "CNX",
+		Departure:   departure + " 12:00",
+		Arrival:     departure + " 13:10",
+		Aircraft:    "Airbus A320",
+		SeatLayout: map[string][][]struct {
+			Special string `json:"special"`
+		}{
+			"Economy": {{{Special: ""}, {Special: ""}}},
+		},
+		BasePrices: map[string]json.Number{"Economy": "100"},
+	})
+	assert.Equal(t, 200, w.Code)
+
+	booking := BookingRequest{
+		PassengerID: "IKP1",
+		FlightID:    "IK001",
+		SeatClass:   "Economy",
+		BookingDate: time.Now().Format("2006-01-02"),
+	}
+	first := do("/book", "ik-book-1", booking)
+	assert.Equal(t, 200, first.Code)
+	retry := do("/book", "ik-book-1", booking)
+	assert.Equal(t, 200, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	bookings, _ := router.bookings.ListBookingsByPassenger("IKP1")
+	assert.Len(t, bookings, 1)
+
+	other := booking
+	other.SeatClass = "Business"
+	assert.Equal(t, 422, do("/book", "ik-book-1", other).Code)
+
+	// A declined payment is replayed too; a new key is a new attempt.
+	declined := booking
+	declined.PaymentMethod = payment.FakeDeclined
+	assert.Equal(t, 402, do("/book", "ik-book-2", declined).Code)
+	declined.PaymentMethod = ""
+	assert.Equal(t, 422, do("/book", "ik-book-2", declined).Code)
+	assert.Equal(t, 200, do("/book", "ik-book-3", declined).Code)
+
+	var bookResp BookingResponse
+	_ = json.Unmarshal(first.Body.Bytes(), &bookResp)
+	cancel := CancelRequest{BookingID: bookResp.BookingID}
+	first = do("/cancel", "ik-cancel-1", cancel)
+	assert.Equal(t, 200, first.Code)
+	retry = do("/cancel", "ik-cancel-1", cancel)
+	assert.Equal(t, 200, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 409, do("/cancel", "", cancel).Code)
+	assert.Equal(t, 400, do("/cancel", strings.Repeat("k", 256), cancel).Code)
+}
+
+// TestConcurrentBookCancelAndRead is meant to be run with -race: GET
+// /flights reads seat state while other requests book and cancel seats.
+func TestConcurrentBookCancelAndRead(t *testing.T) {
+	router := setupTestRouter()
+	body := `{"flight_id": "RACE01", "origin": "BKK", "destination": "CNX", "departure": "2030-01-01 10:00", "arrival": "2030-01-01 11:10", "aircraft": "A320",
+		"seat_layout": {"Economy": [[{"special": ""}, {"special": ""}, {"special": ""}], [{"special": ""}, {"special": ""}, {"special": "Blocked"}]], "Business": [[{"special": ""}]]},
+		"base_prices": {"Economy": 100, "Business": 300}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/flights", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	post := func(path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	economy := func() SeatAvailability {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/flights/RACE01", nil)
+		router.ServeHTTP(w, req)
+		var resp GetFlightResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Seats["Economy"]
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(passenger string) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				w := post("/book", BookingRequest{PassengerID: passenger, FlightID: "RACE01", SeatClass: "Economy", BookingDate: "2029-12-01"})
+				if w.Code != http.StatusOK {
+					assert.Equal(t, http.StatusConflict, w.Code)
+					continue
+				}
+				var bk BookingResponse
+				_ = json.Unmarshal(w.Body.Bytes(), &bk)
+				assert.Equal(t, http.StatusOK, post("/cancel", CancelRequest{BookingID: bk.BookingID}).Code)
+			}
+		}(fmt.Sprintf("P%d", i))
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				seats := economy()
+				assert.Equal(t, 6, seats.Total)
+				assert.True(t, seats.Available >= 0 && seats.Available <= 5, "available %d", seats.Available)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, economy().Available)
+}
@@ -0,0 +1,81 @@
+package route
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+// NewHandler returns a handler serving deps.Service.
+func NewHandler(deps Deps) *Handler {
+	h := &Handler{
+		service:     deps.Service,
+		bookings:    deps.Bookings,
+		now:         deps.Clock,
+		idempotency: deps.Idempotency,
+		shutdown:    deps.Shutdown,
+	}
+	if h.idempotency == nil {
+		h.idempotency = idempotency.NewStore(deps.Config.IdempotencyWindow)
+	}
+	if h.bookings == nil {
+		h.bookings = deps.Service.Passengers
+	}
+	if h.now == nil {
+		h.now = deps.Service.Clock
+	}
+	return h
+}
+
+// NewRouter returns the API with every endpoint registered, logging requests
+// and recovered panics to deps.Logger.
+func NewRouter(deps Deps) http.Handler {
+	h := NewHandler(deps)
+	logger := deps.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := gin.New()
+	r.Use(RequestLogger(logger), Recovery(logger))
+	r.POST("/flights", h.AddFlightHandler)
+	r.GET("/flights", h.SearchFlightsHandler)
+	r.GET("/flights/:flight_id", h.GetFlightHandler)
+	r.GET("/flights/:flight_id/availability/stream", h.AvailabilityStreamHandler)
+	r.GET("/fares/calendar", h.FareCalendarHandler)
+	r.POST("/quote", h.QuoteHandler)
+	r.POST("/book", h.IdempotencyMiddleware, h.BookFlightHandler)
+	r.POST("/cancel", h.IdempotencyMiddleware, h.CancelBookingHandler)
+	r.POST("/airports", h.AddAirportHandler)
+	r.GET("/airports", h.ListAirportsHandler)
+	r.GET("/airports/:code", h.GetAirportHandler)
+	r.POST("/schedules", h.AddScheduleHandler)
+	r.GET("/schedules", h.ListSchedulesHandler)
+	r.POST("/schedules/import", h.ImportSchedulesHandler)
+	r.POST("/schedules/:flight_number/exceptions", h.AddScheduleExceptionHandler)
+	r.POST("/revenue/policies", h.SetRevenuePolicyHandler)
+	r.GET("/revenue/policies", h.ListRevenuePoliciesHandler)
+	r.DELETE("/revenue/policies", h.DeleteRevenuePolicyHandler)
+	r.POST("/revenue/simulate", h.SimulateRevenueHandler)
+	r.POST("/promotions", h.AddPromoHandler)
+	r.GET("/promotions", h.ListPromosHandler)
+	r.GET("/promotions/:code", h.GetPromoHandler)
+	r.GET("/rates", h.GetRatesHandler)
+	r.PUT("/rates", h.SetRatesHandler)
+	r.POST("/taxes", h.SetTaxHandler)
+	r.GET("/taxes", h.ListTaxesHandler)
+	r.DELETE("/taxes/:code", h.DeleteTaxHandler)
+	r.POST("/ancillaries", h.SetAncillaryHandler)
+	r.GET("/ancillaries", h.ListAncillariesHandler)
+	r.DELETE("/ancillaries/:code", h.DeleteAncillaryHandler)
+	r.GET("/flights/:flight_id/ancillaries", h.FlightAncillariesHandler)
+	r.POST("/bookings/:booking_id/ancillaries", h.IdempotencyMiddleware, h.PurchaseAncillariesHandler)
+	r.POST("/bookings/:booking_id/change", h.IdempotencyMiddleware, h.ChangeBookingHandler)
+	r.POST("/webhooks", h.AddWebhookHandler)
+	r.GET("/webhooks", h.ListWebhooksHandler)
+	r.GET("/webhooks/dead-letters", h.WebhookDeadLettersHandler)
+	r.DELETE("/webhooks/:id", h.DeleteWebhookHandler)
+	r.GET("/webhooks/:id/deliveries", h.WebhookDeliveriesHandler)
+	return r
+}
@@ -0,0 +1,78 @@
+package route
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/schedule"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) AddScheduleHandler(c *gin.Context) {
+	var req schedule.Schedule
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule data"})
+		return
+	}
+	created, err := h.service.AddSchedule(&req, h.now())
+	if errors.Is(err, usecase.ErrFlightHasBookings) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Schedule added", "flights_created": created})
+}
+
+func (h *Handler) ListSchedulesHandler(c *gin.Context) {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := schedule.Export(c.Writer, h.service.ListSchedules()); err != nil {
+		c.Error(err)
+	}
+}
+
+func (h *Handler) ImportSchedulesHandler(c *gin.Context) {
+	schedules, err := schedule.Import(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := h.now()
+	created := 0
+	for _, sc := range schedules {
+		n, err := h.service.AddSchedule(sc, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		created += n
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":          "Schedules imported",
+		"schedules":       len(schedules),
+		"flights_created": created,
+	})
+}
+
+func (h *Handler) AddScheduleExceptionHandler(c *gin.Context) {
+	var req schedule.Exception
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule exception"})
+		return
+	}
+	err := h.service.AddScheduleException(c.Param("flight_number"), req)
+	switch {
+	case errors.Is(err, schedule.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+	case errors.Is(err, usecase.ErrFlightHasBookings):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"status": "Exception added"})
+	}
+}
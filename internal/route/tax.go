@@ -0,0 +1,33 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SetTaxHandler(c *gin.Context) {
+	var r tax.Rule
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax data"})
+		return
+	}
+	if err := h.service.Taxes.Set(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) ListTaxesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Taxes.List())
+}
+
+func (h *Handler) DeleteTaxHandler(c *gin.Context) {
+	if err := h.service.Taxes.Delete(c.Param("code")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Tax deleted"})
+}
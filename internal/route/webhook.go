@@ -0,0 +1,51 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// AddWebhookHandler registers a subscription and answers with its secret,
+// which is not shown again.
+func (h *Handler) AddWebhookHandler(c *gin.Context) {
+	var sub webhook.Subscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+		return
+	}
+	created, err := h.service.AddWebhook(sub)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, created)
+}
+
+func (h *Handler) ListWebhooksHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Webhooks.List())
+}
+
+func (h *Handler) DeleteWebhookHandler(c *gin.Context) {
+	if err := h.service.Webhooks.Delete(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Webhook deleted"})
+}
+
+// WebhookDeliveriesHandler lists the logged attempts at delivering events to
+// a subscription, oldest first.
+func (h *Handler) WebhookDeliveriesHandler(c *gin.Context) {
+	attempts, err := h.service.Webhooks.Deliveries(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	c.JSON(http.StatusOK, attempts)
+}
+
+func (h *Handler) WebhookDeadLettersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Webhooks.DeadLetters())
+}
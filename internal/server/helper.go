@@ -0,0 +1,27 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// every calls job each interval, with the service's time, until ctx is
+// cancelled. A job already running when ctx is cancelled finishes first.
+func (s *Server) every(ctx context.Context, workers *sync.WaitGroup, name string, interval time.Duration, job func(now time.Time)) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.Logger.Debug("worker stopped", "worker", name)
+				return
+			case <-ticker.C:
+				job(s.Service.Clock())
+			}
+		}
+	}()
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/config"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/idempotency"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/route"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+)
+
+// New opens the configured storage and builds the service and router.
+func New(cfg config.Config, logger *slog.Logger) (*Server, error) {
+	bookings, err := openStorage(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	service := usecase.NewService([]*flight.Flight{}, bookings)
+	cfg.Apply(service)
+	service.Logger = logger
+	if cfg.RatesFile != "" {
+		if err := loadRates(service, cfg.RatesFile); err != nil {
+			return nil, fmt.Errorf("loading rates from %s: %w", cfg.RatesFile, err)
+		}
+	}
+	s := &Server{
+		Config:      cfg,
+		Service:     service,
+		Bookings:    bookings,
+		Logger:      logger,
+		idempotency: idempotency.NewStore(time.Duration(cfg.Holds.IdempotencyWindow)),
+	}
+	shutdown := make(chan struct{})
+	s.http = &http.Server{
+		Handler: route.NewRouter(route.Deps{
+			Service:     service,
+			Bookings:    bookings,
+			Idempotency: s.idempotency,
+			Shutdown:    shutdown,
+			Logger:      logger,
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.http.RegisterOnShutdown(func() { close(shutdown) })
+	return s, nil
+}
+
+// Listen opens the configured address, so Addr is known before Serve.
+func (s *Server) Listen() error {
+	l, err := net.Listen("tcp", s.Config.Server.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	return nil
+}
+
+// Addr returns the address being listened on, or nil before Listen.
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Run listens and serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	return s.Serve(ctx)
+}
+
+// Serve handles requests and runs the background workers until ctx is
+// cancelled, then shuts down in order: the listener closes, availability
+// streams end, requests in flight drain within the shutdown timeout, the
+// workers and flight actors stop, asynchronous event subscribers finish what
+// they have queued, webhook deliveries waiting to retry are dead-lettered and
+// the storage is closed. It returns ErrDrainTimedOut when requests had to be
+// cut off.
+func (s *Server) Serve(ctx context.Context) error {
+	if s.listener == nil {
+		return ErrNotListening
+	}
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	s.startWorkers(workerCtx, &workers)
+
+	served := make(chan error, 1)
+	go func() { served <- s.http.Serve(s.listener) }()
+	s.Logger.Info("listening", "addr", s.Addr().String())
+
+	var result error
+	select {
+	case err := <-served:
+		result = err
+	case <-ctx.Done():
+		s.Logger.Info("shutting down", "timeout", time.Duration(s.Config.Server.ShutdownTimeout).String())
+		drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.Config.Server.ShutdownTimeout))
+		if err := s.http.Shutdown(drainCtx); err != nil {
+			s.Logger.Warn("cutting off requests still in flight", "err", err)
+			_ = s.http.Close()
+			result = ErrDrainTimedOut
+		}
+		cancel()
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) && result == nil {
+			result = err
+		}
+	}
+
+	stopWorkers()
+	workers.Wait()
+	s.Service.StopActors()
+	if s.Service.Events != nil {
+		s.Service.Events.Close()
+	}
+	if s.Service.Webhooks != nil {
+		s.Service.Webhooks.Close()
+	}
+	if closer, ok := s.Bookings.(io.Closer); ok {
+		if err := closer.Close(); err != nil && result == nil {
+			result = fmt.Errorf("closing storage: %w", err)
+		}
+	}
+	s.Logger.Info("stopped")
+	return result
+}
+
+// startWorkers runs schedule materialization, the hold reaper and the event
+// relay until ctx is cancelled.
+func (s *Server) startWorkers(ctx context.Context, workers *sync.WaitGroup) {
+	s.every(ctx, workers, "schedules", time.Duration(s.Config.Workers.ScheduleInterval), func(now time.Time) {
+		if created := s.Service.MaterializeSchedules(now); created > 0 {
+			s.Logger.Info("materialized scheduled flights", "created", created)
+		}
+	})
+	s.every(ctx, workers, "reaper", time.Duration(s.Config.Workers.ReapInterval), func(now time.Time) {
+		s.Service.Quotes.Purge(now)
+		s.idempotency.Purge(now)
+		if err := s.Service.RelayEvents(); err != nil {
+			s.Logger.Warn("relaying events failed", "err", err)
+		}
+	})
+}
+
+// openStorage returns the configured booking storage.
+func openStorage(cfg config.Storage) (passenger.Storage, error) {
+	switch cfg.Backend {
+	case config.StorageMemory:
+		return passenger.NewInMemoryStorage(), nil
+	}
+	return nil, fmt.Errorf("unsupported storage backend %q", cfg.Backend)
+}
+
+// loadRates replaces the bundled exchange rates with a "currency,rate" CSV
+// file.
+func loadRates(service *usecase.Service, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return service.LoadRates(f)
+}
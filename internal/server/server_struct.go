@@ -0,0 +1,33 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/config"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/idempotency"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+)
+
+var (
+	ErrNotListening  = errors.New("server is not listening")
+	ErrDrainTimedOut = errors.New("in-flight requests did not finish before the shutdown timeout")
+)
+
+// Server is the API over HTTP with its background workers. Run serves until
+// its context is cancelled, then stops accepting connections, lets requests
+// in flight finish within the shutdown timeout, stops the workers, flight
+// actors, event subscribers and webhook retries and closes the storage.
+type Server struct {
+	Config   config.Config
+	Service  *usecase.Service
+	Bookings passenger.Storage
+	Logger   *slog.Logger
+
+	idempotency *idempotency.Store
+	http        *http.Server
+	listener    net.Listener
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/config"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/payment"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/schedule"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/usecase"
+)
+
+// slowGateway holds every authorization until release is closed.
+type slowGateway struct {
+	payment.Gateway
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *slowGateway) Authorize(reference string, amount money.Money, method string) (string, error) {
+	g.started <- struct{}{}
+	<-g.release
+	return g.Gateway.Authorize(reference, amount, method)
+}
+
+// closingStorage records whether the server closed it.
+type closingStorage struct {
+	passenger.Storage
+	closed bool
+}
+
+func (c *closingStorage) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newTestServer(t *testing.T, shutdownTimeout time.Duration) *Server {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Server.Addr = "127.0.0.1:0"
+	cfg.Server.ShutdownTimeout = config.Duration(shutdownTimeout)
+	cfg.Workers.ScheduleInterval = config.Duration(10 * time.Millisecond)
+	cfg.Webhooks.AllowPrivate = true
+	s, err := New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Listen(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+// client opens a connection per request. A spare keep-alive connection
+// that never sends a request holds up Shutdown for five seconds.
+var client = &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+func post(t *testing.T, s *Server, path, body string) (*http.Response, error) {
+	t.Helper()
+	return client.Post("http://"+s.Addr().String()+path, "application/json", bytes.NewBufferString(body))
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	departure := time.Now().AddDate(0, 0, 10).Format("2006-01-02")
+	flightBody := `{"flight_id": "SD001", "origin": "BKK", "destination": "CNX", "departure": "` + departure + ` 12:00", "arrival": "` + departure + ` 13:10", "aircraft": "A320", "seat_layout": {"Economy": [[{"special": ""}]]}, "base_prices": {"Economy": 100}}`
+	bookBody := `{"passenger_id": "P1", "flight_id": "SD001", "seat_class": "Economy", "booking_date": "` + time.Now().Format("2006-01-02") + `"}`
+
+	t.Run("DrainsBookingInFlight", func(t *testing.T) {
+		s := newTestServer(t, 5*time.Second)
+		gateway := &slowGateway{Gateway: s.Service.Payments, started: make(chan struct{}, 1), release: make(chan struct{})}
+		s.Service.Payments = gateway
+		storage := &closingStorage{Storage: s.Bookings}
+		s.Bookings = storage
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx) }()
+
+		resp, err := post(t, s, "/flights", flightBody)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("adding flight: %v, %v", resp, err)
+		}
+		resp.Body.Close()
+		booked := make(chan int, 1)
+		go func() {
+			resp, err := post(t, s, "/book", bookBody)
+			if err != nil {
+				booked <- 0
+				return
+			}
+			resp.Body.Close()
+			booked <- resp.StatusCode
+		}()
+		<-gateway.started
+		cancel()
+
+		select {
+		case err := <-served:
+			t.Fatalf("expected the server to wait for the booking, returned %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if _, err := post(t, s, "/book", bookBody); err == nil {
+			t.Errorf("expected new connections to be refused while draining")
+		}
+		close(gateway.release)
+		if code := <-booked; code != http.StatusOK {
+			t.Errorf("expected the booking to complete, got %d", code)
+		}
+		if err := <-served; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		bookings, _ := s.Service.Passengers.ListBookingsByPassenger("P1")
+		if len(bookings) != 1 || bookings[0].Status != passenger.StatusConfirmed {
+			t.Errorf("expected one confirmed booking, got %+v", bookings)
+		}
+		if !storage.closed {
+			t.Errorf("expected the storage to be closed")
+		}
+	})
+
+	t.Run("StopsActors", func(t *testing.T) {
+		s := newTestServer(t, 5*time.Second)
+		s.Service.Inventory = usecase.InventoryActor
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx) }()
+
+		for _, req := range [][2]string{{"/flights", flightBody}, {"/book", bookBody}} {
+			resp, err := post(t, s, req[0], req[1])
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("posting %s: %v, %v", req[0], resp, err)
+			}
+			resp.Body.Close()
+		}
+		cancel()
+		select {
+		case err := <-served:
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the server to stop")
+		}
+	})
+
+	t.Run("DrainsEventSubscribers", func(t *testing.T) {
+		s := newTestServer(t, 5*time.Second)
+		var handled atomic.Int32
+		s.Service.Events.SubscribeAsync(func(event.Record) {
+			time.Sleep(20 * time.Millisecond)
+			handled.Add(1)
+		}, 0, event.KindBookingConfirmed)
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx) }()
+
+		for _, req := range [][2]string{{"/flights", flightBody}, {"/book", bookBody}} {
+			resp, err := post(t, s, req[0], req[1])
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("posting %s: %v, %v", req[0], resp, err)
+			}
+			resp.Body.Close()
+		}
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if handled.Load() != 1 {
+			t.Errorf("expected the queued BookingConfirmed to be handled before Serve returned")
+		}
+	})
+
+	t.Run("StopsWebhookRetries", func(t *testing.T) {
+		var calls atomic.Int32
+		partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer partner.Close()
+		s := newTestServer(t, 5*time.Second)
+		s.Service.Webhooks.Retry.InitialBackoff, s.Service.Webhooks.Retry.MaxBackoff = time.Hour, time.Hour
+		if _, err := s.Service.AddWebhook(webhook.Subscription{URL: partner.URL}); err != nil {
+			t.Fatalf("adding webhook: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx) }()
+
+		for _, req := range [][2]string{{"/flights", flightBody}, {"/book", bookBody}} {
+			resp, err := post(t, s, req[0], req[1])
+			if err != nil || resp.StatusCode != http.StatusOK {
+				t.Fatalf("posting %s: %v, %v", req[0], resp, err)
+			}
+			resp.Body.Close()
+		}
+		for deadline := time.Now().Add(time.Second); calls.Load() < 2; time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out waiting for the webhook deliveries")
+			}
+		}
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if dead := s.Service.Webhooks.DeadLetters(); len(dead) != 2 {
+			t.Errorf("expected both waiting deliveries dead-lettered on shutdown, got %+v", dead)
+		}
+	})
+
+	t.Run("EndsAvailabilityStreams", func(t *testing.T) {
+		s := newTestServer(t, 5*time.Second)
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx) }()
+
+		resp, err := post(t, s, "/flights", flightBody)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("adding flight: %v, %v", resp, err)
+		}
+		resp.Body.Close()
+		stream, err := client.Get("http://" + s.Addr().String() + "/flights/SD001/availability/stream")
+		if err != nil || stream.StatusCode != http.StatusOK {
+			t.Fatalf("opening stream: %v, %v", stream, err)
+		}
+		defer stream.Body.Close()
+		if _, err := stream.Body.Read(make([]byte, 1)); err != nil {
+			t.Fatalf("reading snapshot: %v", err)
+		}
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("expected the stream to end without a drain timeout, got %v", err)
+		}
+	})
+
+	t.Run("DrainTimeout", func(t *testing.T) {
+		s := newTestServer(t, 50*time.Millisecond)
+		gateway := &slowGateway{Gateway: s.Service.Payments, started: make(chan struct{}, 1), release: make(chan struct{})}
+		s.Service.Payments = gateway
+		defer close(gateway.release)
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(ctx) }()
+
+		resp, err := post(t, s, "/flights", flightBody)
+		if err != nil {
+			t.Fatalf("adding flight: %v", err)
+		}
+		resp.Body.Close()
+		go func() {
+			if resp, err := post(t, s, "/book", bookBody); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-gateway.started
+		cancel()
+		select {
+		case err := <-served:
+			if !errors.Is(err, ErrDrainTimedOut) {
+				t.Errorf("expected ErrDrainTimedOut, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the server to give up draining")
+		}
+	})
+}
+
+func TestServer_Workers(t *testing.T) {
+	s := newTestServer(t, time.Second)
+	today := time.Now()
+	sc := &schedule.Schedule{
+		FlightNumber: "TG100",
+		Origin:       "BKK",
+		Destination:  "NRT",
+		DaysOfWeek: []time.Weekday{
+			time.Sunday, time.Monday, time.Tuesday, time.Wednesday,
+			time.Thursday, time.Friday, time.Saturday,
+		},
+		ValidFrom:  today.Format(schedule.DateLayout),
+		ValidTo:    today.AddDate(0, 0, 3).Format(schedule.DateLayout),
+		Departure:  "08:00",
+		Arrival:    "16:00",
+		Aircraft:   "Boeing 787",
+		SeatLayout: map[string][][]schedule.SeatTemplate{"Economy": {{{}}}},
+		BasePrices: map[string]json.Number{"Economy": "500"},
+	}
+	// Registered without materializing, as if the horizon had moved on.
+	s.Service.Schedules = append(s.Service.Schedules, sc)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx) }()
+
+	tomorrow := sc.FlightID(today.AddDate(0, 0, 1))
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Service.FindFlightByID(tomorrow) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s.Service.FindFlightByID(tomorrow) == nil {
+		t.Errorf("expected the schedule worker to create %s", tomorrow)
+	}
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+)
+
+// onInventory runs fn, which books, frees or moves seats of f, as a command
+// on f's actor in InventoryActor mode and straight away otherwise. fn must
+// not send commands of its own.
+func (s *Service) onInventory(f *flight.Flight, fn func()) {
+	if s.Inventory != InventoryActor {
+		fn()
+		return
+	}
+	done := make(chan struct{})
+	command := func() {
+		defer close(done)
+		fn()
+	}
+	for {
+		a := s.actor(f.FlightID)
+		select {
+		case a.commands <- command:
+			<-done
+			return
+		case <-a.stopped:
+			// Stopped before taking the command: the next actor runs it.
+		}
+	}
+}
+
+// claimSeat books the available seat of f's class with seatID, or the best
+// one when seatID is empty, and returns it with the version it is booked at.
+// In InventoryActor mode the seat is picked and taken by one command on f's
+// actor; otherwise the class's mutex must be held.
+func (s *Service) claimSeat(f *flight.Flight, class, seatID string) (seat *flight.Seat, version uint64, err error) {
+	s.onInventory(f, func() {
+		if seat, err = pickSeat(f, class, seatID); err != nil {
+			return
+		}
+		_, seen := seat.BookedVersion()
+		if !seat.CompareAndSetBooked(seen, true) {
+			err = booking.ErrSeatUnavailable
+			return
+		}
+		version = seen + 1
+	})
+	return seat, version, err
+}
+
+// actor returns the actor of flightID, starting it on first use.
+func (s *Service) actor(flightID string) *flightActor {
+	s.actorsMu.Lock()
+	defer s.actorsMu.Unlock()
+	if a, ok := s.actors[flightID]; ok {
+		return a
+	}
+	if s.actors == nil {
+		s.actors = make(map[string]*flightActor)
+	}
+	a := &flightActor{
+		commands: make(chan func()),
+		quit:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go a.run()
+	s.actors[flightID] = a
+	return a
+}
+
+func (a *flightActor) run() {
+	defer close(a.stopped)
+	for {
+		select {
+		case command := <-a.commands:
+			command()
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// StopActors stops the flight actors of InventoryActor mode, letting the
+// commands they are running finish. A later command starts its flight's
+// actor again.
+func (s *Service) StopActors() {
+	s.actorsMu.Lock()
+	actors := s.actors
+	s.actors = nil
+	s.actorsMu.Unlock()
+	for _, a := range actors {
+		close(a.quit)
+	}
+	for _, a := range actors {
+		<-a.stopped
+	}
+}
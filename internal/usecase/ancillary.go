@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+)
+
+// AncillaryOffers lists the ancillaries on sale for a class on a flight
+// after booking, so seat selection products are left out.
+func (s *Service) AncillaryOffers(flightID, class string) ([]ancillary.Offer, error) {
+	f := s.findFlightByID(flightID)
+	if f == nil {
+		return nil, ErrFlightNotFound
+	}
+	return s.Ancillaries.Offers(ancillary.Trip{
+		FlightID:    flightID,
+		Origin:      f.Origin,
+		Destination: f.Destination,
+		SeatClass:   class,
+	}), nil
+}
+
+// PurchaseAncillaries adds ancillaries to a confirmed booking before its
+// flight departs, priced in the booking's currency and paid with method.
+// Seat selection is only sold with the seat, at booking time. A purchase
+// that cannot be stored is refunded and its items released. Like a change,
+// the purchase holds the booking as Changing and is made to a copy.
+func (s *Service) PurchaseAncillaries(bookingID string, reqs []ancillary.Request, method string, now time.Time) (_ *passenger.BookingInfo, err error) {
+	stored, swapped, err := passenger.SwapStatus(s.Passengers, bookingID, passenger.StatusConfirmed, passenger.StatusChanging)
+	if err != nil {
+		return nil, err
+	}
+	if !swapped {
+		return nil, confirmed(stored)
+	}
+	defer func() {
+		if err != nil {
+			_, _, _ = passenger.SwapStatus(s.Passengers, bookingID, passenger.StatusChanging, passenger.StatusConfirmed)
+		}
+	}()
+	bk := stored.Clone()
+	bk.Status = passenger.StatusConfirmed
+	f := s.findFlightByID(bk.FlightID)
+	if f == nil {
+		return nil, ErrFlightNotFound
+	}
+	if !now.Before(f.Departure) {
+		return nil, ErrFlightDeparted
+	}
+	uow, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer uow.rollback()
+	items, err := s.Ancillaries.Reserve(ancillary.Trip{
+		FlightID:    bk.FlightID,
+		Origin:      f.Origin,
+		Destination: f.Destination,
+		SeatClass:   bk.SeatClass,
+		SeatID:      bk.SeatID,
+	}, bk.Ancillaries, reqs, bk.Price.Currency, s.Rates.Convert, now)
+	if err != nil {
+		return nil, err
+	}
+	uow.onRollback(func() { s.Ancillaries.Release(bk.FlightID, items) })
+	amount := ancillary.Total(items, bk.Price.Currency)
+	if err := s.charge(bk, amount, method); err != nil {
+		return nil, err
+	}
+	uow.onRollback(func() { _ = s.refund(bk, amount, "unsaved") })
+	bk.Ancillaries = append(bk.Ancillaries, items...)
+	if err := uow.save(bk); err != nil {
+		return nil, err
+	}
+	if err := uow.commit(); err != nil {
+		return nil, err
+	}
+	return bk, nil
+}
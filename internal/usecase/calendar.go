@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"strings"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+)
+
+// FareCalendar returns the lowest price per origin-local day between from and
+// to (inclusive) for a seat class, priced as if booked at now with each
+// flight's current load factor. Prices are shown in currency, or in each
+// flight's selling currency when it is empty, and compared in the rate
+// table's base currency. Results are cached until a booking, cancellation,
+// flight or rate change invalidates them.
+func (s *Service) FareCalendar(origin, destination string, from, to time.Time, class, currency string, now time.Time) ([]CalendarDay, error) {
+	loc := s.Location(origin)
+	start, end := truncateDay(from.In(loc)), truncateDay(to.In(loc))
+	if end.Before(start) || end.Sub(start) >= MaxCalendarDays*24*time.Hour {
+		return nil, ErrInvalidDateRange
+	}
+	currency = money.NormalizeCode(currency)
+	if currency != "" {
+		if _, err := s.Rates.Convert(money.New(0, s.Rates.Base()), currency); err != nil {
+			return nil, err
+		}
+	}
+
+	key := strings.Join([]string{
+		origin, destination, start.Format("2006-01-02"), end.Format("2006-01-02"),
+		class, currency, now.In(loc).Format("2006-01-02"),
+	}, "|")
+	if days, ok := s.fareCache.get(key); ok {
+		return days, nil
+	}
+
+	days := make([]CalendarDay, 0)
+	lowest := make([]money.Money, 0)
+	index := make(map[string]int)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		index[date] = len(days)
+		days = append(days, CalendarDay{Date: date})
+		lowest = append(lowest, money.Money{})
+	}
+
+	origins, destinations := s.resolve(origin), s.resolve(destination)
+	for _, f := range s.flights() {
+		if !contains(origins, f.Origin) || !contains(destinations, f.Destination) {
+			continue
+		}
+		departure := f.Departure.In(s.Location(f.Origin))
+		i, ok := index[departure.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		price, ok := s.lowestFare(f, class, now)
+		if !ok {
+			continue
+		}
+		// Flights without a rate to the base currency can't be compared.
+		comparable, err := s.Rates.Convert(price, s.Rates.Base())
+		if err != nil {
+			continue
+		}
+		if currency != "" {
+			if price, err = s.Rates.Convert(price, currency); err != nil {
+				continue
+			}
+		}
+		if !days[i].Available || comparable.Less(lowest[i]) {
+			days[i] = CalendarDay{Date: days[i].Date, Available: true, Price: &price, FlightID: f.FlightID}
+			lowest[i] = comparable
+		}
+	}
+
+	s.fareCache.put(key, append([]CalendarDay(nil), days...))
+	return days, nil
+}
+
+// lowestFare prices the next seat sold in class at its cheapest open fare.
+func (s *Service) lowestFare(f *flight.Flight, class string, now time.Time) (money.Money, bool) {
+	price, _, err := s.priceInClass(f, class, "", "", now, false)
+	return price, err == nil
+}
+
+func (c *fareCache) get(key string) ([]CalendarDay, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	days, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]CalendarDay(nil), days...), true
+}
+
+func (c *fareCache) put(key string, days []CalendarDay) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]CalendarDay)
+	}
+	c.entries[key] = days
+}
+
+func (c *fareCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
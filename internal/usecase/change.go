@@ -0,0 +1,249 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+)
+
+// ChangeBooking moves a confirmed booking before departure.
+//
+// A seat move within the same class keeps the fare and needs no change
+// permission. A new class, flight or fare family is a ticket change: it
+// needs a changeable fare, is priced like a new booking at req.Now with the
+// booking's promo discounts carried over, and costs the fare's change fee.
+// Taxes and fees are worked out again for the new trip and ancillaries move
+// with the booking. Non-refundable fares never get money back, so their Due
+// is at least zero. A positive Due is charged with req.PaymentMethod and a
+// negative one refunded to the booking's payments. A change that cannot be
+// stored refunds its charge; its refund is numbered by the booking's
+// changes, so a retry repeats it and the gateway pays it out once.
+//
+// The booking is first swapped from Confirmed to Changing in storage, so a
+// concurrent change or cancellation gets ErrBookingChanged, and the change
+// is made to a copy that replaces the stored booking, back as Confirmed,
+// once it is paid for. A change that fails leaves the booking as it was.
+//
+// The change runs as one unit of work under both cabins' locks, or in
+// InventoryActor mode with the new seat taken and the old one freed by
+// commands on the flights' actors. The new seat
+// is taken before payment and the old seat and fare are given back with the
+// booking's write, so the booking never holds no seat and the new seat
+// cannot be sold in the meantime. A change that cannot be committed frees
+// the new seat and takes the old one back. SeatReleased for the old seat, if
+// it moved, and PriceChanged for either cabin whose next fare moved are
+// committed with the booking and relayed once it is stored.
+func (s *Service) ChangeBooking(req ChangeRequest) (res *ChangeResult, err error) {
+	stored, swapped, err := passenger.SwapStatus(s.Passengers, req.BookingID, passenger.StatusConfirmed, passenger.StatusChanging)
+	if err != nil {
+		return nil, err
+	}
+	if !swapped {
+		return nil, confirmed(stored)
+	}
+	defer func() {
+		if err != nil {
+			_, _, _ = passenger.SwapStatus(s.Passengers, req.BookingID, passenger.StatusChanging, passenger.StatusConfirmed)
+		}
+	}()
+	prev, bk := *stored, stored.Clone()
+	prev.Status, bk.Status = passenger.StatusConfirmed, passenger.StatusConfirmed
+	from := s.findFlightByID(prev.FlightID)
+	if from == nil {
+		return nil, ErrFlightNotFound
+	}
+	to := from
+	if req.FlightID != "" && req.FlightID != prev.FlightID {
+		if to = s.findFlightByID(req.FlightID); to == nil {
+			return nil, ErrFlightNotFound
+		}
+		if to.Origin != from.Origin || to.Destination != from.Destination {
+			return nil, fmt.Errorf("%w: %s flies %s-%s", ErrInvalidChange, to.FlightID, to.Origin, to.Destination)
+		}
+	}
+	if !req.Now.Before(from.Departure) || !req.Now.Before(to.Departure) {
+		return nil, ErrFlightDeparted
+	}
+	closeSales, err := s.openSales(to)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSales()
+	class := req.SeatClass
+	if class == "" {
+		class = prev.SeatClass
+	}
+	if _, ok := to.Mutex[flight.SeatClass(class)]; !ok {
+		return nil, fmt.Errorf("%w: %s", flight.ErrSeatClassNotFound, class)
+	}
+	sameCabin := to == from && class == prev.SeatClass
+	reticket := !sameCabin || (req.Fare != "" && req.Fare != prev.Fare)
+	keepSeat := sameCabin && (req.SeatID == "" || req.SeatID == prev.SeatID)
+	if !reticket && keepSeat {
+		return nil, fmt.Errorf("%w: nothing to change", ErrInvalidChange)
+	}
+	rules := s.bookingRules(bk)
+	if reticket && !rules.Changeable {
+		return nil, ErrChangeNotAllowed
+	}
+	closed := 0
+	if reticket {
+		closed = s.closedFamilies(to, class, req.Now)
+	}
+	isFrequentFlyer := s.isFrequentFlyer(prev.PassengerID)
+	uow, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer uow.rollback()
+
+	prices := s.watchPrices(req.Now)
+	prices.watch(from, prev.SeatClass)
+	prices.watch(to, class)
+
+	// The flight actors take and free seats one at a time in InventoryActor
+	// mode, so the cabins are locked only in the other modes.
+	locked := s.Inventory != InventoryActor
+	if locked {
+		unlock := lockCabins(from, prev.SeatClass, to, class)
+		defer unlock()
+	}
+	old := findSeat(from, prev.SeatClass, prev.SeatID)
+	if old == nil {
+		return nil, ErrBookingChanged
+	}
+	if booked, version := old.BookedVersion(); !booked || version != prev.SeatVersion {
+		return nil, ErrBookingChanged
+	}
+	seat, seatVersion, booked := old, prev.SeatVersion, 0
+	if !keepSeat {
+		// Optimistic bookings do not wait for the cabin lock, so the new seat
+		// is taken before anything is paid for.
+		if seat, seatVersion, err = s.claimSeat(to, class, req.SeatID); err != nil {
+			return nil, err
+		}
+		uow.onRollback(func() { s.onInventory(to, func() { seat.CompareAndSetBooked(seatVersion, false) }) })
+	}
+	for _, st := range to.Seats[flight.SeatClass(class)] {
+		if (st.IsBookedSeat() && st != old) || st == seat {
+			booked++
+		}
+	}
+
+	currency := prev.Price.Currency
+	price := prev.Price
+	var family *fare.Family
+	cabin := to.Fares[flight.SeatClass(class)]
+	if reticket {
+		base := to.BasePrices[flight.SeatClass(class)]
+		if cabin == nil && req.Fare != "" {
+			return nil, fare.ErrFareNotFound
+		}
+		if cabin != nil {
+			reserved, err := cabin.ReserveOpen(req.Fare, closed)
+			if err != nil {
+				return nil, err
+			}
+			family = &reserved
+			base = reserved.Price
+			uow.onRollback(func() { cabin.Release(reserved.Code) })
+		}
+		fareAt := s.Pricing.Price(base, to.Departure.In(s.Location(to.Origin)), req.Now, booked, len(to.Seats[flight.SeatClass(class)]), isFrequentFlyer)
+		if fareAt, err = s.Rates.Convert(fareAt, currency); err != nil {
+			return nil, err
+		}
+		price = money.New(max(fareAt.Amount-discounts(&prev), 0), currency)
+	}
+
+	seatSelected := req.SeatID != "" || (keepSeat && seatChosen(&prev))
+	charges, err := s.Taxes.Compute(tax.Trip{
+		Origin:       to.Origin,
+		Destination:  to.Destination,
+		SeatClass:    class,
+		SeatSelected: seatSelected,
+	}, price, s.Rates.Convert)
+	if err != nil {
+		return nil, err
+	}
+	fee := money.New(0, currency)
+	if reticket && !rules.ChangeFee.IsZero() {
+		if fee, err = s.Rates.Convert(rules.ChangeFee, currency); err != nil {
+			return nil, err
+		}
+	}
+	res = &ChangeResult{
+		FareDifference:    money.New(price.Amount-prev.Price.Amount, currency),
+		ChargesDifference: money.New(tax.Total(charges, currency).Amount-tax.Total(prev.Charges, currency).Amount, currency),
+		ChangeFee:         fee,
+	}
+	res.Due = money.New(res.FareDifference.Amount+res.ChargesDifference.Amount+fee.Amount, currency)
+	if res.Due.Amount < 0 && !rules.Refundable {
+		res.Due = money.New(0, currency)
+	}
+
+	fromTrip := ancillary.Trip{FlightID: from.FlightID, Origin: from.Origin, Destination: from.Destination,
+		SeatClass: prev.SeatClass, SeatID: prev.SeatID, SeatSelected: seatChosen(&prev)}
+	toTrip := ancillary.Trip{FlightID: to.FlightID, Origin: to.Origin, Destination: to.Destination,
+		SeatClass: class, SeatID: seat.SeatID, SeatSelected: seatSelected}
+	if err = s.Ancillaries.Move(fromTrip, toTrip, prev.Ancillaries); err != nil {
+		return nil, err
+	}
+	uow.onRollback(func() { _ = s.Ancillaries.Move(toTrip, fromTrip, prev.Ancillaries) })
+	bk.Changes++
+	if res.Due.Amount > 0 {
+		if err = s.charge(bk, res.Due, req.PaymentMethod); err != nil {
+			return nil, err
+		}
+		due := res.Due
+		uow.onRollback(func() { _ = s.refund(bk, due, "unsaved") })
+	} else if res.Due.Amount < 0 {
+		if err = s.refund(bk, money.New(-res.Due.Amount, currency), fmt.Sprintf("change-%d", bk.Changes)); err != nil {
+			return nil, err
+		}
+	}
+
+	bk.FlightID, bk.SeatClass, bk.SeatID, bk.SeatVersion = to.FlightID, class, seat.SeatID, seatVersion
+	bk.Price, bk.Charges = price, charges
+	if reticket {
+		bk.Fare, bk.FareRules = "", nil
+		if family != nil {
+			bk.Fare = family.Code
+			rules := family.Rules
+			bk.FareRules = &rules
+		}
+	}
+	if err = uow.save(bk); err != nil {
+		return nil, err
+	}
+	if seat != old {
+		s.onInventory(from, func() { old.CompareAndSetBooked(prev.SeatVersion, false) })
+		uow.onRollback(func() { s.retakeSeat(from, old, prev.SeatVersion, bk.BookingID) })
+		uow.emit(event.SeatReleased{
+			FlightID:  from.FlightID,
+			SeatClass: prev.SeatClass,
+			SeatID:    prev.SeatID,
+			BookingID: bk.BookingID,
+		})
+	}
+	if oldCabin := from.Fares[flight.SeatClass(prev.SeatClass)]; reticket && oldCabin != nil && prev.Fare != "" {
+		oldCabin.Release(prev.Fare)
+		uow.onRollback(func() { _, _ = oldCabin.Reserve(prev.Fare) })
+	}
+	if locked {
+		uow.emit(prices.changesLocked()...)
+	} else {
+		uow.emit(prices.changes()...)
+	}
+	if err = uow.commit(); err != nil {
+		return nil, err
+	}
+	s.fareCache.invalidate()
+	res.Booking = bk
+	return res, nil
+}
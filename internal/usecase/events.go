@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+)
+
+// publish adds events that are not stored with a booking to the outbox and
+// relays it. Callers publish what has already happened; events about a
+// booking write go in its unit of work instead.
+func (s *Service) publish(events ...event.Event) {
+	if s.Events == nil || s.Outbox == nil || len(events) == 0 {
+		return
+	}
+	if err := s.Outbox.Add(s.Clock(), events...); err != nil {
+		s.logger().Error("events lost: adding them to the outbox failed", "err", err, "events", len(events))
+		return
+	}
+	s.relay()
+}
+
+// relay runs RelayEvents, logging a failure. The events it leaves in the
+// outbox go out with the next relay.
+func (s *Service) relay() {
+	if err := s.RelayEvents(); err != nil {
+		s.logger().Warn("relaying events failed", "err", err)
+	}
+}
+
+// RelayEvents publishes the outbox's pending events to the event bus in the
+// order they were added, marking each published once handed over.
+func (s *Service) RelayEvents() error {
+	if s.Events == nil || s.Outbox == nil {
+		return nil
+	}
+	return s.Events.Relay(s.Outbox)
+}
+
+func (s *Service) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
+	}
+	return s.Logger
+}
+
+// watchPrices starts a priceWatch at now, or returns nil, which watches
+// nothing, when no one subscribes to PriceChanged.
+func (s *Service) watchPrices(now time.Time) *priceWatch {
+	if s.Events == nil || !s.Events.Subscribed(event.KindPriceChanged) {
+		return nil
+	}
+	return &priceWatch{s: s, now: now}
+}
+
+// watch notes the fare of the next seat sold in f's class. It takes the
+// class's mutex, so it must not be called under it.
+func (w *priceWatch) watch(f *flight.Flight, class string) {
+	if w == nil {
+		return
+	}
+	for _, c := range w.cabins {
+		if c.f == f && c.class == class {
+			return
+		}
+	}
+	w.cabins = append(w.cabins, watchedCabin{f: f, class: class, price: w.s.nextFare(f, class, w.now)})
+}
+
+// watchRoute watches every flight on a route, in class or in every class
+// when class is empty.
+func (w *priceWatch) watchRoute(origin, destination, class string) {
+	if w == nil {
+		return
+	}
+	for _, f := range w.s.flights() {
+		if f.Origin != origin || f.Destination != destination {
+			continue
+		}
+		for _, c := range w.s.classList(f) {
+			if class == "" || c == class {
+				w.watch(f, c)
+			}
+		}
+	}
+}
+
+// changes returns a PriceChanged event for each watched cabin whose next
+// fare has moved. Like watch, it must not be called under a class's mutex.
+func (w *priceWatch) changes() []event.Event {
+	if w == nil {
+		return nil
+	}
+	return w.diff(w.s.nextFare)
+}
+
+// changesLocked is changes for a caller holding the mutex of every watched
+// cabin.
+func (w *priceWatch) changesLocked() []event.Event {
+	if w == nil {
+		return nil
+	}
+	return w.diff(w.s.nextLockedFare)
+}
+
+func (w *priceWatch) diff(nextFare func(*flight.Flight, string, time.Time) *money.Money) []event.Event {
+	var events []event.Event
+	for _, c := range w.cabins {
+		price := nextFare(c.f, c.class, w.now)
+		if price == nil && c.price == nil || price != nil && c.price != nil && *price == *c.price {
+			continue
+		}
+		events = append(events, event.PriceChanged{FlightID: c.f.FlightID, SeatClass: c.class, Price: price, Previous: c.price})
+	}
+	return events
+}
+
+// nextFare is lowestFare, nil when the class has nothing left to sell.
+func (s *Service) nextFare(f *flight.Flight, class string, now time.Time) *money.Money {
+	price, ok := s.lowestFare(f, class, now)
+	if !ok {
+		return nil
+	}
+	return &price
+}
+
+// nextLockedFare is nextFare for a caller holding the class's mutex.
+func (s *Service) nextLockedFare(f *flight.Flight, class string, now time.Time) *money.Money {
+	price, _, err := s.priceInLockedClass(f, class, "", "", now, false, s.closedFamilies(f, class, now))
+	if err != nil {
+		return nil
+	}
+	return &price
+}
+
+func bookingConfirmed(bk *passenger.BookingInfo) event.BookingConfirmed {
+	return event.BookingConfirmed{
+		BookingID:   bk.BookingID,
+		PassengerID: bk.PassengerID,
+		FlightID:    bk.FlightID,
+		SeatClass:   bk.SeatClass,
+		SeatID:      bk.SeatID,
+		Fare:        bk.Fare,
+		Total:       bk.Total(),
+	}
+}
+
+func bookingCancelled(bk *passenger.BookingInfo, refund money.Money) event.BookingCancelled {
+	return event.BookingCancelled{
+		BookingID:   bk.BookingID,
+		PassengerID: bk.PassengerID,
+		FlightID:    bk.FlightID,
+		SeatClass:   bk.SeatClass,
+		SeatID:      bk.SeatID,
+		Refund:      refund,
+	}
+}
@@ -2,14 +2,29 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/quote"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/schedule"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
 )
 
 func (s *Service) findFlightByID(flightID string) *flight.Flight {
+	s.catalog.RLock()
+	defer s.catalog.RUnlock()
 	for _, f := range s.Flights {
 		if f.FlightID == flightID {
 			return f
@@ -23,8 +38,23 @@ func (s *Service) isFrequentFlyer(passengerID string) bool {
 	if err != nil {
 		return false
 	}
-	// Example: 5 or more bookings = frequent flyer
-	return len(bookings) >= 5
+	// Frequent flyers have made enough bookings, not counting unpaid ones
+	minBookings := s.FrequentFlyerBookings
+	if minBookings <= 0 {
+		minBookings = DefaultFrequentFlyerBookings
+	}
+	count := 0
+	for _, bk := range bookings {
+		if bk.Status != passenger.StatusFailed {
+			count++
+		}
+	}
+	return count >= minBookings
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
 }
 
 func sameDay(a, b time.Time) bool {
@@ -44,22 +74,662 @@ func (s *Service) tryUpgradeClass(f *flight.Flight, currentClass string) (string
 }
 
 func (s *Service) classList(f *flight.Flight) []string {
-	if len(s.SeatClassPriority) > 0 {
+	s.catalog.RLock()
+	priority := s.SeatClassPriority
+	s.catalog.RUnlock()
+	if len(priority) > 0 {
 		var present []string
-		for _, c := range s.SeatClassPriority {
+		for _, c := range priority {
 			if _, ok := f.Seats[flight.SeatClass(c)]; ok {
 				present = append(present, c)
 			}
 		}
 		return present
 	}
+	// Without a configured priority, cheaper classes rank lower so upgrades
+	// move to the next more expensive class.
 	var classes []string
 	for class := range f.Seats {
 		classes = append(classes, string(class))
 	}
+	sort.Slice(classes, func(i, j int) bool {
+		pi, pj := f.BasePrices[flight.SeatClass(classes[i])], f.BasePrices[flight.SeatClass(classes[j])]
+		if pi != pj {
+			return pi.Less(pj)
+		}
+		return classes[i] < classes[j]
+	})
 	return classes
 }
 
 func generateBookingID() string {
 	return uuid.New().String()
 }
+
+// flights returns a copy of the flight list to range over without the lock.
+func (s *Service) flights() []*flight.Flight {
+	s.catalog.RLock()
+	defer s.catalog.RUnlock()
+	return append([]*flight.Flight(nil), s.Flights...)
+}
+
+func (s *Service) findSchedule(flightNumber string) *schedule.Schedule {
+	s.catalog.RLock()
+	defer s.catalog.RUnlock()
+	for _, sc := range s.Schedules {
+		if sc.FlightNumber == flightNumber {
+			return sc
+		}
+	}
+	return nil
+}
+
+// materialize adds a schedule's missing flights. Runs are serialized so two
+// of them cannot both add the same dated flight.
+func (s *Service) materialize(sc *schedule.Schedule, now time.Time) int {
+	s.materializeMu.Lock()
+	defer s.materializeMu.Unlock()
+	horizon := s.ScheduleHorizon
+	if horizon <= 0 {
+		horizon = schedule.DefaultHorizon
+	}
+	created := 0
+	for _, f := range sc.Generate(now, now.Add(horizon), s.Location(sc.Origin), s.Location(sc.Destination)) {
+		if s.findFlightByID(f.FlightID) != nil {
+			continue
+		}
+		if err := s.AddFlight(f); err != nil {
+			continue
+		}
+		created++
+	}
+	return created
+}
+
+// removeFlight requires the catalog write lock.
+func (s *Service) removeFlight(flightID string) {
+	for i, f := range s.Flights {
+		if f.FlightID == flightID {
+			s.Flights = append(s.Flights[:i], s.Flights[i+1:]...)
+			s.fareCache.invalidate()
+			return
+		}
+	}
+}
+
+// catalogFlight is findFlightByID for callers holding the catalog lock.
+func (s *Service) catalogFlight(flightID string) *flight.Flight {
+	for _, f := range s.Flights {
+		if f.FlightID == flightID {
+			return f
+		}
+	}
+	return nil
+}
+
+// hasActiveBookings reports whether any booking still holds a seat on the
+// flight.
+func (s *Service) hasActiveBookings(flightID string) (bool, error) {
+	bookings, err := s.Passengers.ListBookingsByFlight(flightID)
+	if err != nil {
+		return false, err
+	}
+	for _, bk := range bookings {
+		if bk.Active() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// salesGate returns the lock that keeps bookings on a flight apart from
+// schedule changes to it.
+func (s *Service) salesGate(flightID string) *sync.RWMutex {
+	s.salesMu.Lock()
+	defer s.salesMu.Unlock()
+	if s.sales == nil {
+		s.sales = make(map[string]*sync.RWMutex)
+	}
+	gate, ok := s.sales[flightID]
+	if !ok {
+		gate = &sync.RWMutex{}
+		s.sales[flightID] = gate
+	}
+	return gate
+}
+
+// openSales keeps f open for booking until the returned function is called,
+// so that a schedule change cannot cancel or retime it in the meantime. It
+// fails with ErrFlightNotFound once f has been removed.
+func (s *Service) openSales(f *flight.Flight) (func(), error) {
+	gate := s.salesGate(f.FlightID)
+	gate.RLock()
+	if s.findFlightByID(f.FlightID) != f {
+		gate.RUnlock()
+		return nil, ErrFlightNotFound
+	}
+	return gate.RUnlock, nil
+}
+
+// closeSales stops new bookings on the flights, waiting for those under way,
+// until the returned function is called. Gates are taken in flight ID order
+// so that two callers cannot deadlock.
+func (s *Service) closeSales(flightIDs ...string) func() {
+	ids := append([]string(nil), flightIDs...)
+	sort.Strings(ids)
+	gates := make([]*sync.RWMutex, 0, len(ids))
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		gate := s.salesGate(id)
+		gate.Lock()
+		gates = append(gates, gate)
+	}
+	return func() {
+		for i := len(gates) - 1; i >= 0; i-- {
+			gates[i].Unlock()
+		}
+	}
+}
+
+// replaceSchedule registers sc in place of the schedule with its flight
+// number, if there is one. The old schedule's flights yet to depart are
+// removed so that materialize builds them again from sc, except those with
+// active bookings, which are kept as sold. A replacement that would cancel
+// or retime one of those is rejected with ErrFlightHasBookings.
+func (s *Service) replaceSchedule(sc *schedule.Schedule, now time.Time) error {
+	s.materializeMu.Lock()
+	defer s.materializeMu.Unlock()
+	var dated []string
+	if s.findSchedule(sc.FlightNumber) != nil {
+		prefix := sc.FlightNumber + "-"
+		for _, f := range s.flights() {
+			if strings.HasPrefix(f.FlightID, prefix) && f.Departure.After(now) {
+				dated = append(dated, f.FlightID)
+			}
+		}
+	}
+	reopen := s.closeSales(dated...)
+	defer reopen()
+	s.catalog.Lock()
+	defer s.catalog.Unlock()
+
+	var stale []string
+	for _, id := range dated {
+		f := s.catalogFlight(id)
+		if f == nil {
+			continue
+		}
+		active, err := s.hasActiveBookings(id)
+		if err != nil {
+			return err
+		}
+		if !active {
+			stale = append(stale, id)
+			continue
+		}
+		date, ok := sc.FlightDate(id)
+		if !ok || !sc.Operates(date) {
+			return fmt.Errorf("%w: %s would be cancelled", ErrFlightHasBookings, id)
+		}
+		inst := sc.Instance(date, s.Location(sc.Origin), s.Location(sc.Destination))
+		if inst == nil || !inst.Departure.Equal(f.Departure) || !inst.Arrival.Equal(f.Arrival) {
+			return fmt.Errorf("%w: %s would be retimed", ErrFlightHasBookings, id)
+		}
+	}
+
+	replaced := false
+	for i, existing := range s.Schedules {
+		if existing.FlightNumber == sc.FlightNumber {
+			s.Schedules[i] = sc
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.Schedules = append(s.Schedules, sc)
+	}
+	for _, id := range stale {
+		s.removeFlight(id)
+	}
+	return nil
+}
+
+// applyException adds e to sc and applies it to the dated flight on date,
+// with bookings on the flight held off, and returns the FlightRescheduled
+// event to publish when the flight was retimed.
+func (s *Service) applyException(sc *schedule.Schedule, date time.Time, e schedule.Exception) (*event.FlightRescheduled, error) {
+	s.materializeMu.Lock()
+	defer s.materializeMu.Unlock()
+	reopen := s.closeSales(sc.FlightID(date))
+	defer reopen()
+	s.catalog.Lock()
+	defer s.catalog.Unlock()
+
+	origin, destination := s.Location(sc.Origin), s.Location(sc.Destination)
+	trial := *sc
+	trial.Exceptions = append([]schedule.Exception(nil), sc.Exceptions...)
+	if err := trial.AddException(e); err != nil {
+		return nil, err
+	}
+	inst := trial.Instance(date, origin, destination)
+	if inst != nil && !inst.Arrival.After(inst.Departure) {
+		return nil, fmt.Errorf("%w: %s arrives before it departs", schedule.ErrInvalidSchedule, e.Date)
+	}
+	existing := s.catalogFlight(sc.FlightID(date))
+	if existing != nil && e.Cancelled {
+		active, err := s.hasActiveBookings(existing.FlightID)
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			return nil, ErrFlightHasBookings
+		}
+	}
+	sc.Exceptions = trial.Exceptions
+	if existing == nil {
+		return nil, nil
+	}
+	if e.Cancelled {
+		s.removeFlight(existing.FlightID)
+		return nil, nil
+	}
+	if inst == nil {
+		return nil, nil
+	}
+	rescheduled := event.FlightRescheduled{
+		FlightID:          existing.FlightID,
+		Departure:         inst.Departure,
+		Arrival:           inst.Arrival,
+		PreviousDeparture: existing.Departure,
+		PreviousArrival:   existing.Arrival,
+	}
+	existing.Departure = inst.Departure
+	existing.Arrival = inst.Arrival
+	s.fareCache.invalidate()
+	if rescheduled.Departure.Equal(rescheduled.PreviousDeparture) && rescheduled.Arrival.Equal(rescheduled.PreviousArrival) {
+		return nil, nil
+	}
+	return &rescheduled, nil
+}
+
+func (s *Service) validateRoute(origin, destination string) error {
+	if origin == destination {
+		return fmt.Errorf("%w: origin and destination are both %s", ErrInvalidRoute, origin)
+	}
+	if s.Airports == nil {
+		return nil
+	}
+	if _, err := s.Airports.Get(origin); err != nil {
+		return fmt.Errorf("%w: unknown origin %q", ErrInvalidRoute, origin)
+	}
+	if _, err := s.Airports.Get(destination); err != nil {
+		return fmt.Errorf("%w: unknown destination %q", ErrInvalidRoute, destination)
+	}
+	return nil
+}
+
+func (s *Service) resolve(code string) []string {
+	if s.Airports == nil {
+		return []string{code}
+	}
+	return s.Airports.Resolve(code)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// bookInClass reserves a fare family (when the class has any) and then the
+// seat with seatID, or the best seat when it is empty, giving the fare back
+// if no seat can be assigned. A class whose families are all sold out is
+// treated as full so the upgrade path applies. A quote's price is charged
+// instead of the current one, and its fare sold even if revenue controls
+// have closed it since.
+func (s *Service) bookInClass(f *flight.Flight, class, fareCode, seatID string, now time.Time, isFrequentFlyer bool, q *quote.Quote) (booking.Seat, money.Money, *fare.Family, error) {
+	adapter := &bookingFlightAdapter{Flight: f, loc: s.Location(f.Origin)}
+	cabin := f.Fares[flight.SeatClass(class)]
+	if cabin == nil && fareCode != "" {
+		return nil, money.Money{}, nil, fare.ErrFareNotFound
+	}
+	if cabin != nil {
+		closed := s.closedFamilies(f, class, now)
+		if q != nil {
+			closed = 0
+		}
+		family, err := cabin.ReserveOpen(fareCode, closed)
+		if errors.Is(err, fare.ErrFareSoldOut) && fareCode == "" {
+			return nil, money.Money{}, nil, booking.ErrNoSeatAvailable
+		}
+		if err != nil {
+			return nil, money.Money{}, nil, err
+		}
+		adapter.fare = &family
+	}
+
+	choose := bestSeat
+	if seatID != "" {
+		choose = requestedSeat(seatID)
+	}
+	seat, price, err := s.takeSeat(adapter, class, now, choose, isFrequentFlyer)
+	if err != nil {
+		if adapter.fare != nil {
+			cabin.Release(adapter.fare.Code)
+		}
+		return nil, money.Money{}, nil, err
+	}
+	if q != nil {
+		price = q.Price
+	}
+	return seat, price, adapter.fare, nil
+}
+
+// takeSeat books the seat choose picks the way s.Inventory says. On a
+// flight's actor no other booking of the service runs alongside, so the
+// optimistic booking there rarely has to look twice.
+func (s *Service) takeSeat(f *bookingFlightAdapter, class string, now time.Time, choose func([]booking.Seat, int, int) booking.Seat, isFrequentFlyer bool) (seat booking.Seat, price money.Money, err error) {
+	switch s.Inventory {
+	case InventoryOptimistic:
+		return booking.BookOptimistic(f, class, now, choose, s.Pricing.Price, isFrequentFlyer)
+	case InventoryActor:
+		s.onInventory(f.Flight, func() {
+			seat, price, err = booking.BookOptimistic(f, class, now, choose, s.Pricing.Price, isFrequentFlyer)
+		})
+		return seat, price, err
+	}
+	return booking.BookBestSeat(f, class, now, choose, s.Pricing.Price, isFrequentFlyer)
+}
+
+func bestSeat(seats []booking.Seat, col, row int) booking.Seat {
+	var flightSeats []*flight.Seat
+	for _, s := range seats {
+		if fs, ok := s.(*flight.Seat); ok {
+			flightSeats = append(flightSeats, fs)
+		}
+	}
+	return flight.BestSeat(flightSeats, col, row)
+}
+
+// useQuote verifies req's quote token, checks it was issued for the same
+// booking and marks it used.
+func (s *Service) useQuote(req BookingRequest) (*quote.Quote, error) {
+	now := s.Clock()
+	q, err := s.Quotes.Verify(req.QuoteToken, now)
+	if err != nil {
+		return nil, err
+	}
+	if q.PassengerID != req.PassengerID || q.FlightID != req.FlightID || q.SeatClass != req.SeatClass ||
+		(req.Fare != "" && req.Fare != q.Fare) {
+		return nil, quote.ErrQuoteMatch
+	}
+	if err := s.Quotes.Use(q, now); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// priceInClass prices the seat bookInClass would sell without taking it,
+// counting it towards the load factor the same way BookBestSeat does.
+func (s *Service) priceInClass(f *flight.Flight, class, fareCode, seatID string, now time.Time, isFrequentFlyer bool) (money.Money, *fare.Family, error) {
+	mutex, ok := f.Mutex[flight.SeatClass(class)]
+	if !ok {
+		return money.Money{}, nil, booking.ErrNoSeatAvailable
+	}
+	cabin := f.Fares[flight.SeatClass(class)]
+	if cabin == nil && fareCode != "" {
+		return money.Money{}, nil, fare.ErrFareNotFound
+	}
+	closed := s.closedFamilies(f, class, now)
+	mutex.Lock()
+	defer mutex.Unlock()
+	return s.priceInLockedClass(f, class, fareCode, seatID, now, isFrequentFlyer, closed)
+}
+
+// priceInLockedClass is priceInClass with the closed fare families already
+// counted. It requires the class's mutex to be held.
+func (s *Service) priceInLockedClass(f *flight.Flight, class, fareCode, seatID string, now time.Time, isFrequentFlyer bool, closed int) (money.Money, *fare.Family, error) {
+	cabin := f.Fares[flight.SeatClass(class)]
+	seats := f.Seats[flight.SeatClass(class)]
+	booked, available, requested := 0, 0, false
+	for _, seat := range seats {
+		if seat.IsBookedSeat() {
+			booked++
+		} else if seat.Special == "" {
+			available++
+			requested = requested || seat.SeatID == seatID
+		}
+	}
+	if available == 0 {
+		return money.Money{}, nil, booking.ErrNoSeatAvailable
+	}
+	if seatID != "" && !requested {
+		return money.Money{}, nil, booking.ErrSeatUnavailable
+	}
+	base := f.BasePrices[flight.SeatClass(class)]
+	var family *fare.Family
+	if cabin != nil {
+		found, err := cabin.FindOpen(fareCode, closed)
+		if errors.Is(err, fare.ErrFareSoldOut) && fareCode == "" {
+			return money.Money{}, nil, booking.ErrNoSeatAvailable
+		}
+		if err != nil {
+			return money.Money{}, nil, err
+		}
+		family = &found
+		base = found.Price
+	}
+	departure := f.Departure.In(s.Location(f.Origin))
+	return s.Pricing.Price(base, departure, now, booked+1, len(seats), isFrequentFlyer), family, nil
+}
+
+// requestedSeat picks the seat with seatID if it is still available.
+func requestedSeat(seatID string) func([]booking.Seat, int, int) booking.Seat {
+	return func(seats []booking.Seat, _, _ int) booking.Seat {
+		for _, s := range seats {
+			if fs, ok := s.(*flight.Seat); ok && fs.SeatID == seatID {
+				return fs
+			}
+		}
+		return nil
+	}
+}
+
+// bookedWithin counts the bookings made in the window days up to and
+// including the day of t.
+func bookedWithin(bookings []*passenger.BookingInfo, t time.Time, window int) int {
+	count := 0
+	for _, bk := range bookings {
+		if days := flight.DaysBefore(t, bk.BookedAt); days >= 0 && days < window {
+			count++
+		}
+	}
+	return count
+}
+
+// repriceFare moves a booking out of a closed family into the cheapest open
+// one, scaling its price by the change in family price and rounding half
+// away from zero to the currency's increment.
+func repriceFare(families []fare.Family, code string, price money.Money, closed int) (string, money.Money) {
+	if closed > len(families)-1 {
+		closed = len(families) - 1
+	}
+	for i, f := range families {
+		if f.Code != code {
+			continue
+		}
+		if i >= closed {
+			return code, price
+		}
+		open := families[closed]
+		from, to := f.Price, open.Price
+		if from.IsZero() {
+			return open.Code, money.New(price.Amount+to.Amount, price.Currency)
+		}
+		return open.Code, price.MulRatio(to.Amount, from.Amount)
+	}
+	return code, price
+}
+
+// releaseSeat frees a seat booked at version and the fare family it was sold
+// in. A seat that no longer holds that booking keeps its fare too.
+func (s *Service) releaseSeat(f *flight.Flight, class, seatID string, version uint64, fareCode string) {
+	seatClass := flight.SeatClass(class)
+	released := false
+	s.onInventory(f, func() { released = f.ReleaseSeat(seatClass, seatID, version) })
+	if cabin := f.Fares[seatClass]; released && cabin != nil && fareCode != "" {
+		cabin.Release(fareCode)
+	}
+}
+
+// failBooking releases an unpaid booking and stores it as failed.
+func (s *Service) failBooking(f *flight.Flight, bk *passenger.BookingInfo) {
+	s.releaseBooking(f, bk)
+	bk.Status = passenger.StatusFailed
+	_ = s.Passengers.SaveBooking(bk)
+}
+
+// releaseBooking gives back everything a booking holds: its seat and fare,
+// promo code redemptions and ancillary inventory.
+func (s *Service) releaseBooking(f *flight.Flight, bk *passenger.BookingInfo) {
+	s.releaseSeat(f, bk.SeatClass, bk.SeatID, bk.SeatVersion, bk.Fare)
+	s.Promotions.Release(bk.PassengerID, bk.Promotions)
+	s.Ancillaries.Release(bk.FlightID, bk.Ancillaries)
+}
+
+// validateCurrency normalizes a flight's selling currency and checks its
+// base prices are all in it.
+func validateCurrency(f *flight.Flight) error {
+	if f.Currency == "" {
+		f.Currency = money.DefaultCurrency
+	}
+	f.Currency = money.NormalizeCode(f.Currency)
+	if _, err := money.LookupCurrency(f.Currency); err != nil {
+		return err
+	}
+	for class, price := range f.BasePrices {
+		if price.Currency != f.Currency {
+			return fmt.Errorf("%w: %s is priced in %s, flight sells in %s", money.ErrCurrencyMismatch, class, price.Currency, f.Currency)
+		}
+	}
+	for class, cabin := range f.Fares {
+		for _, family := range cabin.Families() {
+			if family.Price.Currency != f.Currency {
+				return fmt.Errorf("%w: %s %s is priced in %s, flight sells in %s", money.ErrCurrencyMismatch, class, family.Code, family.Price.Currency, f.Currency)
+			}
+		}
+	}
+	return nil
+}
+
+// lockCabins locks the seat mutexes of two cabins, ordered by flight ID and
+// then class so that changes moving seats in opposite directions cannot
+// deadlock, and returns a function that unlocks them. A cabin passed twice is
+// locked once.
+func lockCabins(f1 *flight.Flight, class1 string, f2 *flight.Flight, class2 string) func() {
+	m1, m2 := f1.Mutex[flight.SeatClass(class1)], f2.Mutex[flight.SeatClass(class2)]
+	if m1 == m2 {
+		m1.Lock()
+		return m1.Unlock
+	}
+	if f2.FlightID < f1.FlightID || (f2.FlightID == f1.FlightID && class2 < class1) {
+		m1, m2 = m2, m1
+	}
+	m1.Lock()
+	m2.Lock()
+	return func() {
+		m2.Unlock()
+		m1.Unlock()
+	}
+}
+
+// retakeSeat gives seat back to bookingID at version after the change that
+// freed it failed to be stored. Only a booking that does not wait for the
+// cabin's lock can have taken it in between, which is logged.
+func (s *Service) retakeSeat(f *flight.Flight, seat *flight.Seat, version uint64, bookingID string) {
+	taken := false
+	s.onInventory(f, func() { taken = seat.UndoRelease(version) })
+	if !taken {
+		s.logger().Error("seat lost by a failed change", "flight", f.FlightID, "seat", seat.SeatID, "booking", bookingID)
+	}
+}
+
+// findSeat requires the class's mutex to be held.
+func findSeat(f *flight.Flight, class, seatID string) *flight.Seat {
+	for _, seat := range f.Seats[flight.SeatClass(class)] {
+		if seat.SeatID == seatID {
+			return seat
+		}
+	}
+	return nil
+}
+
+// pickSeat returns the available seat with seatID, or the best available one
+// when it is empty, without booking it. It requires the class's mutex to be
+// held, or to run on the flight's actor in InventoryActor mode.
+func pickSeat(f *flight.Flight, class, seatID string) (*flight.Seat, error) {
+	var available []*flight.Seat
+	for _, seat := range f.Seats[flight.SeatClass(class)] {
+		if !seat.IsBookedSeat() && seat.Special == "" {
+			available = append(available, seat)
+		}
+	}
+	if len(available) == 0 {
+		return nil, booking.ErrNoSeatAvailable
+	}
+	if seatID == "" {
+		return flight.BestSeat(available, f.GetColumns(class), f.GetRows(class)), nil
+	}
+	for _, seat := range available {
+		if seat.SeatID == seatID {
+			return seat, nil
+		}
+	}
+	return nil, booking.ErrSeatUnavailable
+}
+
+func (s *Service) quoteTTL() time.Duration {
+	if s.QuoteTTL <= 0 {
+		return quote.DefaultTTL
+	}
+	return s.QuoteTTL
+}
+
+// bookingRules returns the fare rules a booking was sold under.
+func (s *Service) bookingRules(bk *passenger.BookingInfo) fare.Rules {
+	if bk.FareRules != nil {
+		return *bk.FareRules
+	}
+	return s.DefaultRules
+}
+
+// discounts adds up the promo discounts taken off a booking's fare, in minor
+// units of its currency.
+func discounts(bk *passenger.BookingInfo) int64 {
+	total := int64(0)
+	for _, r := range bk.Promotions {
+		total += r.Discount.Amount
+	}
+	return total
+}
+
+// seatChosen reports whether the passenger picked the booking's seat, which
+// shows in a seat selection fee or ancillary.
+func seatChosen(bk *passenger.BookingInfo) bool {
+	for _, item := range bk.Charges {
+		if item.Kind == tax.KindSeatSelection {
+			return true
+		}
+	}
+	for _, item := range bk.Ancillaries {
+		if item.Kind == ancillary.KindSeatSelection {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+)
+
+// charge authorizes and captures amount for bk through the payment gateway
+// and records the payment on bk. A failed capture voids the authorization.
+// Nothing is charged for a zero amount.
+func (s *Service) charge(bk *passenger.BookingInfo, amount money.Money, method string) error {
+	if amount.Amount <= 0 {
+		return nil
+	}
+	id, err := s.Payments.Authorize(bk.BookingID, amount, method)
+	if err != nil {
+		return err
+	}
+	if err := s.Payments.Capture(id); err != nil {
+		_ = s.Payments.Void(id)
+		return err
+	}
+	bk.Payments = append(bk.Payments, passenger.Payment{ID: id, Amount: amount, Refunded: money.New(0, amount.Currency)})
+	return nil
+}
+
+// refund returns amount to bk's payments, newest first, never more than was
+// collected. Each refund ID is made of the booking, reason and payment, so
+// retrying the same refund cannot pay out twice.
+func (s *Service) refund(bk *passenger.BookingInfo, amount money.Money, reason string) error {
+	left := amount.Amount
+	for i := len(bk.Payments) - 1; i >= 0 && left > 0; i-- {
+		p := &bk.Payments[i]
+		part := min(left, p.Amount.Amount-p.Refunded.Amount)
+		if part <= 0 {
+			continue
+		}
+		refundID := fmt.Sprintf("%s/%s/%s", bk.BookingID, reason, p.ID)
+		if err := s.Payments.Refund(refundID, p.ID, money.New(part, p.Amount.Currency)); err != nil {
+			return err
+		}
+		p.Refunded = money.New(p.Refunded.Amount+part, p.Amount.Currency)
+		left -= part
+	}
+	return nil
+}
+
+// confirmed checks bk can still be changed, cancelled or added to.
+func confirmed(bk *passenger.BookingInfo) error {
+	switch bk.Status {
+	case passenger.StatusConfirmed:
+		return nil
+	case passenger.StatusChanging:
+		return ErrBookingChanged
+	case passenger.StatusCancelling, passenger.StatusCancelled:
+		return ErrBookingCancelled
+	default:
+		return fmt.Errorf("%w: %s", ErrBookingNotConfirmed, bk.Status)
+	}
+}
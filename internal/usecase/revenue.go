@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"sort"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/airport"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/revenue"
+)
+
+// SetRevenuePolicy normalizes a policy's route and puts it in force for
+// future bookings, publishing PriceChanged for the cabins whose next fare it
+// moves.
+func (s *Service) SetRevenuePolicy(p *revenue.Policy) error {
+	p.Origin, p.Destination = airport.NormalizeCode(p.Origin), airport.NormalizeCode(p.Destination)
+	if err := s.validateRoute(p.Origin, p.Destination); err != nil {
+		return err
+	}
+	prices := s.watchPrices(s.Clock())
+	prices.watchRoute(p.Origin, p.Destination, p.SeatClass)
+	if err := s.Revenue.Set(p); err != nil {
+		return err
+	}
+	s.fareCache.invalidate()
+	s.publish(prices.changes()...)
+	return nil
+}
+
+func (s *Service) DeleteRevenuePolicy(origin, destination, class string) error {
+	origin, destination = airport.NormalizeCode(origin), airport.NormalizeCode(destination)
+	prices := s.watchPrices(s.Clock())
+	prices.watchRoute(origin, destination, class)
+	if err := s.Revenue.Delete(origin, destination, class); err != nil {
+		return err
+	}
+	s.fareCache.invalidate()
+	s.publish(prices.changes()...)
+	return nil
+}
+
+// FareAvailability lists a class's fare families with the closures in force
+// at now, or nil when the class has no fare families.
+func (s *Service) FareAvailability(f *flight.Flight, class string, now time.Time) []fare.Availability {
+	cabin := f.Fares[flight.SeatClass(class)]
+	if cabin == nil {
+		return nil
+	}
+	return cabin.AvailabilityOpen(s.closedFamilies(f, class, now))
+}
+
+// SimulateRevenue replays a flight's bookings in the order they were made
+// and prices each one under a proposed policy. A booking whose fare family
+// the policy would have closed moves up to the cheapest open family, keeping
+// its dynamic pricing multipliers. Cancelled bookings are replayed as sold
+// since they held a seat when later bookings were made; bookings whose
+// payment failed are left out.
+func (s *Service) SimulateRevenue(flightID string, p *revenue.Policy) (*Simulation, error) {
+	f := s.findFlightByID(flightID)
+	if f == nil {
+		return nil, ErrFlightNotFound
+	}
+	if p.Origin == "" && p.Destination == "" {
+		p.Origin, p.Destination = f.Origin, f.Destination
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	bookings, err := s.Passengers.ListBookingsByFlight(flightID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(bookings, func(i, j int) bool { return bookings[i].BookedAt.Before(bookings[j].BookedAt) })
+
+	departure := f.Departure.In(s.Location(f.Origin))
+	result := &Simulation{
+		FlightID:         flightID,
+		Bookings:         make([]SimulatedBooking, 0, len(bookings)),
+		Revenue:          money.New(0, f.Currency),
+		SimulatedRevenue: money.New(0, f.Currency),
+	}
+	sold := make(map[string][]*passenger.BookingInfo)
+	for _, bk := range bookings {
+		if bk.Status == passenger.StatusFailed {
+			continue
+		}
+		sim := SimulatedBooking{
+			BookingID:      bk.BookingID,
+			SeatClass:      bk.SeatClass,
+			BookedAt:       bk.BookedAt,
+			DaysBefore:     flight.DaysBefore(departure, bk.BookedAt),
+			Fare:           bk.Fare,
+			Price:          bk.Price,
+			SimulatedFare:  bk.Fare,
+			SimulatedPrice: bk.Price,
+		}
+		capacity := len(f.Seats[flight.SeatClass(bk.SeatClass)])
+		if (p.SeatClass == "" || p.SeatClass == bk.SeatClass) && capacity > 0 {
+			earlier := sold[bk.SeatClass]
+			decision := p.Evaluate(revenue.Snapshot{
+				DaysBefore: sim.DaysBefore,
+				Load:       float64(len(earlier)) / float64(capacity),
+				RecentLoad: float64(bookedWithin(earlier, bk.BookedAt, p.PaceWindow)) / float64(capacity),
+			})
+			sim.Decision = &decision
+			if cabin := f.Fares[flight.SeatClass(bk.SeatClass)]; cabin != nil && bk.Fare != "" {
+				sim.SimulatedFare, sim.SimulatedPrice = repriceFare(cabin.Families(), bk.Fare, bk.Price, decision.Closed)
+			}
+		}
+		sold[bk.SeatClass] = append(sold[bk.SeatClass], bk)
+		if result.Revenue, err = result.Revenue.Add(sim.Price); err != nil {
+			return nil, err
+		}
+		if result.SimulatedRevenue, err = result.SimulatedRevenue.Add(sim.SimulatedPrice); err != nil {
+			return nil, err
+		}
+		result.Bookings = append(result.Bookings, sim)
+	}
+	return result, nil
+}
+
+// closedFamilies returns how many of the cheapest fare families the policy
+// for the flight's route and class closes at now.
+func (s *Service) closedFamilies(f *flight.Flight, class string, now time.Time) int {
+	if s.Revenue == nil || f.Fares[flight.SeatClass(class)] == nil {
+		return 0
+	}
+	p, ok := s.Revenue.Get(f.Origin, f.Destination, class)
+	if !ok {
+		return 0
+	}
+	snapshot, ok := s.cabinSnapshot(f, class, now, p.PaceWindow)
+	if !ok {
+		return 0
+	}
+	return p.Evaluate(snapshot).Closed
+}
+
+func (s *Service) cabinSnapshot(f *flight.Flight, class string, now time.Time, paceWindow int) (revenue.Snapshot, bool) {
+	availability, ok := f.Availability(flight.SeatClass(class))
+	if !ok || availability.Total == 0 {
+		return revenue.Snapshot{}, false
+	}
+
+	snapshot := revenue.Snapshot{
+		DaysBefore: flight.DaysBefore(f.Departure.In(s.Location(f.Origin)), now),
+		Load:       float64(availability.Booked) / float64(availability.Total),
+	}
+	if paceWindow > 0 {
+		bookings, err := s.Passengers.ListBookingsByFlight(f.FlightID)
+		if err == nil {
+			var confirmed []*passenger.BookingInfo
+			for _, bk := range bookings {
+				if bk.SeatClass == class && bk.Active() {
+					confirmed = append(confirmed, bk)
+				}
+			}
+			snapshot.RecentLoad = float64(bookedWithin(confirmed, now, paceWindow)) / float64(availability.Total)
+		}
+	}
+	return snapshot, true
+}
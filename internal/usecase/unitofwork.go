@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+)
+
+// begin starts a unit of work on the passenger storage.
+func (s *Service) begin() (*unitOfWork, error) {
+	tx, err := passenger.Begin(s.Passengers, s.Outbox)
+	if err != nil {
+		return nil, err
+	}
+	return &unitOfWork{tx: tx, s: s}, nil
+}
+
+// onRollback registers how to undo a change already made.
+func (u *unitOfWork) onRollback(undo func()) {
+	u.undo = append(u.undo, undo)
+}
+
+// emit records events to publish once the unit of work is committed. They
+// are dropped when the service publishes no events.
+func (u *unitOfWork) emit(events ...event.Event) {
+	if u.s.Events == nil || u.s.Outbox == nil {
+		return
+	}
+	u.events = append(u.events, events...)
+}
+
+func (u *unitOfWork) save(bk *passenger.BookingInfo) error {
+	return u.tx.SaveBooking(bk)
+}
+
+// commit stores the saved bookings with the emitted events in the outbox and
+// relays them, or rolls everything back when it cannot store them.
+func (u *unitOfWork) commit() error {
+	if err := u.tx.AddEvents(u.s.Clock(), u.events...); err != nil {
+		u.rollback()
+		return err
+	}
+	if err := u.tx.Commit(); err != nil {
+		u.rollback()
+		return err
+	}
+	u.done = true
+	if len(u.events) > 0 {
+		u.s.relay()
+	}
+	return nil
+}
+
+// rollback drops the saved bookings and undoes the registered changes, newest
+// first. It does nothing after commit, so it can be deferred.
+func (u *unitOfWork) rollback() {
+	if u.done {
+		return
+	}
+	u.done = true
+	_ = u.tx.Rollback()
+	for i := len(u.undo) - 1; i >= 0; i-- {
+		u.undo[i]()
+	}
+}
+
+// commitBooking stores bk on its own, with events to publish.
+func (s *Service) commitBooking(bk *passenger.BookingInfo, events ...event.Event) error {
+	uow, err := s.begin()
+	if err != nil {
+		return err
+	}
+	if err := uow.save(bk); err != nil {
+		uow.rollback()
+		return err
+	}
+	uow.emit(events...)
+	return uow.commit()
+}
@@ -2,11 +2,25 @@ package usecase
 
 import (
 	"errors"
+	"io"
+	"sync"
 	"time"
 
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/airport"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/payment"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/quote"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/revenue"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/schedule"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
 )
 
 func (a *bookingMutexAdapter) Lock()   { a.m.Lock() }
@@ -25,6 +39,15 @@ func (f *bookingFlightAdapter) GetSeats(class string) []booking.Seat {
 	return result
 }
 
+func (f *bookingFlightAdapter) GetVersionedSeats(class string) []booking.VersionedSeat {
+	seats := f.Flight.Seats[flight.SeatClass(class)]
+	result := make([]booking.VersionedSeat, len(seats))
+	for i, s := range seats {
+		result[i] = s
+	}
+	return result
+}
+
 func (f *bookingFlightAdapter) GetColumns(class string) int {
 	return f.Flight.GetColumns(class)
 }
@@ -33,27 +56,73 @@ func (f *bookingFlightAdapter) GetRows(class string) int {
 	return f.Flight.GetRows(class)
 }
 
-func (f *bookingFlightAdapter) GetBasePrice(class string) float64 {
+func (f *bookingFlightAdapter) GetBasePrice(class string) money.Money {
+	if f.fare != nil {
+		return f.fare.Price
+	}
 	return f.Flight.GetBasePrice(class)
 }
 
 func (f *bookingFlightAdapter) GetDeparture() time.Time {
-	return f.Flight.GetDeparture()
+	if f.loc == nil {
+		return f.Flight.GetDeparture()
+	}
+	return f.Flight.GetDeparture().In(f.loc)
 }
 
 func NewService(flights []*flight.Flight, passengers passenger.Storage) *Service {
-	return &Service{Flights: flights, Passengers: passengers}
+	var outbox event.Outbox = event.NewInMemoryOutbox()
+	if t, ok := passengers.(passenger.Transactional); ok {
+		outbox = t.Outbox()
+	}
+	return &Service{
+		Flights:      flights,
+		Passengers:   passengers,
+		Airports:     airport.NewDefaultRegistry(),
+		Revenue:      revenue.NewControls(),
+		Promotions:   promo.NewPromotions(),
+		Rates:        money.NewDefaultRateTable(),
+		Taxes:        tax.NewTable(),
+		Quotes:       quote.NewSigner(nil),
+		Ancillaries:  ancillary.NewCatalogue(),
+		Payments:     payment.NewFake(),
+		Events:       event.NewBus(),
+		Outbox:       outbox,
+		Webhooks:     webhook.NewDispatcher(),
+		Clock:        time.Now,
+		Pricing:      flight.DefaultPricing,
+		DefaultRules: fare.DefaultRules,
+	}
 }
 
-func (s *Service) AddFlight(f *flight.Flight) {
+// AddFlight normalizes the flight's airport codes and checks them against
+// the airport registry, and checks every base price is in the flight's
+// selling currency, before adding it.
+func (s *Service) AddFlight(f *flight.Flight) error {
+	f.Origin, f.Destination = airport.NormalizeCode(f.Origin), airport.NormalizeCode(f.Destination)
+	if err := s.validateRoute(f.Origin, f.Destination); err != nil {
+		return err
+	}
+	if err := validateCurrency(f); err != nil {
+		return err
+	}
+	s.catalog.Lock()
 	s.Flights = append(s.Flights, f)
+	s.catalog.Unlock()
+	s.fareCache.invalidate()
+	return nil
 }
 
+// SearchFlights returns flights departing on date's calendar day in the
+// origin airport's local time. Origin and destination may be airport or
+// metropolitan area codes.
 func (s *Service) SearchFlights(origin, destination string, date time.Time) []*flight.Flight {
+	origins, destinations := s.resolve(origin), s.resolve(destination)
 	var result []*flight.Flight
-	for _, f := range s.Flights {
-		if f.Origin == origin && f.Destination == destination &&
-			sameDay(f.Departure, date) {
+	for _, f := range s.flights() {
+		loc := s.Location(f.Origin)
+		if contains(origins, f.Origin) && contains(destinations, f.Destination) &&
+			sameDay(f.Departure.In(loc), date.In(loc)) {
 			result = append(result, f)
 		}
 	}
@@ -61,30 +130,70 @@ func (s *Service) SearchFlights(origin, destination string, date time.Time) []*f
 }
 
 func (s *Service) BookSeat(passengerID, flightID, class string, now time.Time) (*passenger.BookingInfo, error) {
-	flightObj := s.findFlightByID(flightID)
+	return s.Book(BookingRequest{PassengerID: passengerID, FlightID: flightID, SeatClass: class, BookingDate: now})
+}
+
+// Book assigns the requested seat, or the best one, in the requested class
+// and fare family, upgrading to the best seat of the next class when the
+// requested class is full. It then applies any promo codes, adds taxes and
+// fees on the discounted fare and sells the requested ancillaries. A
+// rejected code or ancillary gives the seat back.
+//
+// Everything taken is committed in one unit of work with the pending
+// booking record, so a booking that cannot be stored gives it all back. The
+// total is then authorized and captured through the payment gateway and the
+// booking confirmed once it is paid. If payment fails, or the confirmed
+// booking cannot be stored and the payment is refunded, the booking is
+// marked failed and its seat, fare, promo codes and ancillaries are released.
+// With a quote token the quoted fare is charged and no upgrade is made.
+//
+// A confirmed booking publishes BookingConfirmed, and PriceChanged for the
+// class when the fare of its next seat has moved.
+func (s *Service) Book(req BookingRequest) (bk *passenger.BookingInfo, err error) {
+	flightObj := s.findFlightByID(req.FlightID)
 	if flightObj == nil {
-		return nil, errors.New("flight not found")
+		return nil, ErrFlightNotFound
 	}
+	// Schedule changes to the flight wait until the pending booking is stored
+	unlock, err := s.openSales(flightObj)
+	if err != nil {
+		return nil, err
+	}
+	closeSales := sync.OnceFunc(unlock)
+	defer closeSales()
 
-	isFrequentFlyer := s.isFrequentFlyer(passengerID)
-
-	bestSeatFunc := func(seats []booking.Seat, col, row int) booking.Seat {
-		var flightSeats []*flight.Seat
-		for _, s := range seats {
-			if fs, ok := s.(*flight.Seat); ok {
-				flightSeats = append(flightSeats, fs)
-			}
+	var q *quote.Quote
+	if req.QuoteToken != "" {
+		if q, err = s.useQuote(req); err != nil {
+			return nil, err
 		}
-		seat := flight.BestSeat(flightSeats, col, row)
-		return seat
+		req.Fare = q.Fare
+		defer func() {
+			if err != nil {
+				s.Quotes.Release(q)
+			}
+		}()
+	}
+
+	uow, err := s.begin()
+	if err != nil {
+		return nil, err
 	}
+	defer uow.rollback()
 
-	adapter := &bookingFlightAdapter{Flight: flightObj}
-	seat, price, err := booking.BookBestSeat(adapter, class, bestSeatFunc, flight.CalculatePrice, isFrequentFlyer)
-	if err != nil && errors.Is(err, booking.ErrNoSeatAvailable) {
+	isFrequentFlyer := s.isFrequentFlyer(req.PassengerID)
+	prices := s.watchPrices(req.BookingDate)
+	prices.watch(flightObj, req.SeatClass)
+
+	class := req.SeatClass
+	seatID := req.SeatID
+	seat, price, family, err := s.bookInClass(flightObj, class, req.Fare, seatID, req.BookingDate, isFrequentFlyer, q)
+	if err != nil && errors.Is(err, booking.ErrNoSeatAvailable) && q == nil {
 		upgradeClass, upErr := s.tryUpgradeClass(flightObj, class)
 		if upErr == nil {
-			seat, price, err = booking.BookBestSeat(adapter, upgradeClass, bestSeatFunc, flight.CalculatePrice, isFrequentFlyer)
+			seatID = ""
+			prices.watch(flightObj, upgradeClass)
+			seat, price, family, err = s.bookInClass(flightObj, upgradeClass, "", "", req.BookingDate, isFrequentFlyer, nil)
 			if err != nil {
 				return nil, err
 			}
@@ -95,39 +204,315 @@ func (s *Service) BookSeat(passengerID, flightID, class string, now time.Time) (
 	} else if err != nil {
 		return nil, err
 	}
+	fareCode := ""
+	if family != nil {
+		fareCode = family.Code
+	}
+	// Only the booking that took a seat frees it, so its version stays put
+	seatVersion := seat.(*flight.Seat).State().Version
+	uow.onRollback(func() { s.releaseSeat(flightObj, class, seat.(*flight.Seat).SeatID, seatVersion, fareCode) })
+
+	loc := s.Location(flightObj.Origin)
+	redemptions, price, err := s.Promotions.Redeem(req.PromoCodes, promo.Trip{
+		PassengerID: req.PassengerID,
+		Origin:      flightObj.Origin,
+		Destination: flightObj.Destination,
+		SeatClass:   class,
+		BookingDate: req.BookingDate.In(loc),
+		Departure:   flightObj.Departure.In(loc),
+	}, price)
+	if err != nil {
+		return nil, err
+	}
+	uow.onRollback(func() { s.Promotions.Release(req.PassengerID, redemptions) })
+	charges, err := s.Taxes.Compute(tax.Trip{
+		Origin:       flightObj.Origin,
+		Destination:  flightObj.Destination,
+		SeatClass:    class,
+		SeatSelected: seatID != "",
+	}, price, s.Rates.Convert)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.Ancillaries.Reserve(ancillary.Trip{
+		FlightID:     req.FlightID,
+		Origin:       flightObj.Origin,
+		Destination:  flightObj.Destination,
+		SeatClass:    class,
+		SeatID:       seat.(*flight.Seat).SeatID,
+		SeatSelected: seatID != "",
+	}, nil, req.Ancillaries, price.Currency, s.Rates.Convert, req.BookingDate)
+	if err != nil {
+		return nil, err
+	}
+	uow.onRollback(func() { s.Ancillaries.Release(req.FlightID, items) })
 
 	bookingInfo := &passenger.BookingInfo{
 		BookingID:   generateBookingID(),
-		PassengerID: passengerID,
-		FlightID:    flightID,
+		PassengerID: req.PassengerID,
+		FlightID:    req.FlightID,
 		SeatID:      seat.(*flight.Seat).SeatID,
 		SeatClass:   class,
-		BookedAt:    now,
+		SeatVersion: seatVersion,
+		BookedAt:    req.BookingDate,
 		Price:       price,
+		Status:      passenger.StatusPending,
+		Promotions:  redemptions,
+		Charges:     charges,
+		Ancillaries: items,
+	}
+	if family != nil {
+		bookingInfo.Fare = family.Code
+		rules := family.Rules
+		bookingInfo.FareRules = &rules
 	}
-	if err := s.Passengers.SaveBooking(bookingInfo); err != nil {
+	// Stored bookings are replaced, never changed in place, so the pending
+	// booking is a copy and bookingInfo stays this call's to confirm.
+	if err := uow.save(bookingInfo.Clone()); err != nil {
+		return nil, err
+	}
+	if err := uow.commit(); err != nil {
+		return nil, err
+	}
+	closeSales()
+	s.fareCache.invalidate()
+	if err := s.charge(bookingInfo, bookingInfo.Total(), req.PaymentMethod); err != nil {
+		s.failBooking(flightObj, bookingInfo)
+		return nil, err
+	}
+	bookingInfo.Status = passenger.StatusConfirmed
+	events := append([]event.Event{bookingConfirmed(bookingInfo)}, prices.changes()...)
+	if err := s.commitBooking(bookingInfo, events...); err != nil {
+		_ = s.refund(bookingInfo, bookingInfo.Total(), "unconfirmed")
+		s.failBooking(flightObj, bookingInfo)
 		return nil, err
 	}
 	return bookingInfo, nil
 }
 
-func (s *Service) CancelBooking(bookingID string, now time.Time) error {
-	bookingInfo, err := s.Passengers.GetBooking(bookingID)
+// Quote prices req the way Book would, without taking a seat or redeeming
+// promo codes, and signs the fare into a token that Book honours until it
+// expires. Quotes are not upgraded to another class.
+func (s *Service) Quote(req BookingRequest) (*QuoteResult, error) {
+	flightObj := s.findFlightByID(req.FlightID)
+	if flightObj == nil {
+		return nil, ErrFlightNotFound
+	}
+	fareAt, family, err := s.priceInClass(flightObj, req.SeatClass, req.Fare, req.SeatID, req.BookingDate, s.isFrequentFlyer(req.PassengerID))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	q := quote.Quote{
+		ID:          generateBookingID(),
+		PassengerID: req.PassengerID,
+		FlightID:    req.FlightID,
+		SeatClass:   req.SeatClass,
+		Price:       fareAt,
+		ExpiresAt:   s.Clock().Add(s.quoteTTL()),
+	}
+	if family != nil {
+		q.Fare = family.Code
+	}
+	token, err := s.Quotes.Sign(&q)
+	if err != nil {
+		return nil, err
 	}
-	flightObj := s.findFlightByID(bookingInfo.FlightID)
+
+	loc := s.Location(flightObj.Origin)
+	redemptions, price, err := s.Promotions.Preview(req.PromoCodes, promo.Trip{
+		PassengerID: req.PassengerID,
+		Origin:      flightObj.Origin,
+		Destination: flightObj.Destination,
+		SeatClass:   req.SeatClass,
+		BookingDate: req.BookingDate.In(loc),
+		Departure:   flightObj.Departure.In(loc),
+	}, fareAt)
+	if err != nil {
+		return nil, err
+	}
+	charges, err := s.Taxes.Compute(tax.Trip{
+		Origin:       flightObj.Origin,
+		Destination:  flightObj.Destination,
+		SeatClass:    req.SeatClass,
+		SeatSelected: req.SeatID != "",
+	}, price, s.Rates.Convert)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.Ancillaries.Preview(ancillary.Trip{
+		FlightID:     req.FlightID,
+		Origin:       flightObj.Origin,
+		Destination:  flightObj.Destination,
+		SeatClass:    req.SeatClass,
+		SeatID:       req.SeatID,
+		SeatSelected: req.SeatID != "",
+	}, nil, req.Ancillaries, price.Currency, s.Rates.Convert, req.BookingDate)
+	if err != nil {
+		return nil, err
+	}
+	return &QuoteResult{
+		Quote:       q,
+		Token:       token,
+		Price:       price,
+		Promotions:  redemptions,
+		Charges:     charges,
+		Ancillaries: items,
+		Total: money.New(price.Amount+tax.Total(charges, price.Currency).Amount+
+			ancillary.Total(items, price.Currency).Amount, price.Currency),
+	}, nil
+}
+
+// CalculateRefund returns what cancelling bk at now would refund: the fare
+// under its fare rules, with the cutoff evaluated in the origin airport's
+// local time, plus every refundable tax, fee and ancillary in full.
+func (s *Service) CalculateRefund(bk *passenger.BookingInfo, now time.Time) money.Money {
+	flightObj := s.findFlightByID(bk.FlightID)
 	if flightObj == nil {
-		return errors.New("flight not found")
+		return money.New(0, bk.Price.Currency)
 	}
+	refund := flight.CalculateRefund(bk.Price, s.bookingRules(bk), flightObj.Departure.In(s.Location(flightObj.Origin)), now)
+	return money.New(refund.Amount+tax.RefundableTotal(bk.Charges, refund.Currency).Amount+
+		ancillary.RefundableTotal(bk.Ancillaries, refund.Currency).Amount, refund.Currency)
+}
 
-	seatClass := flight.SeatClass(bookingInfo.SeatClass)
-	for _, seat := range flightObj.Seats[seatClass] {
-		if seat.SeatID == bookingInfo.SeatID {
-			seat.IsBooked = false
-			break
+// CancelBooking refunds CalculateRefund's amount through the payment gateway
+// and then releases the booking's seat, fare, promo codes and ancillaries.
+// The refund IDs are derived from the booking, so retrying a cancellation
+// whose refund went through does not refund again. It publishes
+// BookingCancelled once the booking is stored, then SeatReleased and any
+// PriceChanged once the seat is back on sale.
+//
+// The booking is first swapped from Confirmed to Cancelling in storage, so
+// of concurrent cancellations only one refunds and releases; the others get
+// ErrBookingCancelled. A cancellation that fails puts it back to Confirmed.
+func (s *Service) CancelBooking(bookingID string, now time.Time) (err error) {
+	stored, swapped, err := passenger.SwapStatus(s.Passengers, bookingID, passenger.StatusConfirmed, passenger.StatusCancelling)
+	if err != nil {
+		return err
+	}
+	if !swapped {
+		return confirmed(stored)
+	}
+	defer func() {
+		if err != nil {
+			_, _, _ = passenger.SwapStatus(s.Passengers, bookingID, passenger.StatusCancelling, passenger.StatusConfirmed)
 		}
+	}()
+	flightObj := s.findFlightByID(stored.FlightID)
+	if flightObj == nil {
+		return ErrFlightNotFound
+	}
+	prices := s.watchPrices(now)
+	prices.watch(flightObj, stored.SeatClass)
+	bookingInfo := stored.Clone()
+	refund := s.CalculateRefund(bookingInfo, now)
+	if err := s.refund(bookingInfo, refund, "cancel"); err != nil {
+		return err
+	}
+
+	// The seat is only given back once the booking is stored as cancelled.
+	// After a failed save the stored payments are still as they were, so a
+	// retry repeats the same refunds and the gateway pays them out once.
+	bookingInfo.Status = passenger.StatusCancelled
+	if err := s.commitBooking(bookingInfo, bookingCancelled(bookingInfo, refund)); err != nil {
+		return err
+	}
+	s.releaseBooking(flightObj, bookingInfo)
+	s.fareCache.invalidate()
+	s.publish(append([]event.Event{event.SeatReleased{
+		FlightID:  bookingInfo.FlightID,
+		SeatClass: bookingInfo.SeatClass,
+		SeatID:    bookingInfo.SeatID,
+		BookingID: bookingInfo.BookingID,
+	}}, prices.changes()...)...)
+
+	return nil
+}
+
+// AddSchedule registers a recurring schedule, replacing any schedule with the
+// same flight number, and materializes its flights within the horizon. See
+// replaceSchedule for what happens to the old schedule's flights.
+func (s *Service) AddSchedule(sc *schedule.Schedule, now time.Time) (int, error) {
+	if err := sc.Validate(); err != nil {
+		return 0, err
 	}
+	sc.Origin, sc.Destination = airport.NormalizeCode(sc.Origin), airport.NormalizeCode(sc.Destination)
+	if err := s.validateRoute(sc.Origin, sc.Destination); err != nil {
+		return 0, err
+	}
+	if err := sc.ValidateTimes(s.Location(sc.Origin), s.Location(sc.Destination)); err != nil {
+		return 0, err
+	}
+	if err := s.replaceSchedule(sc, now); err != nil {
+		return 0, err
+	}
+	return s.materialize(sc, now), nil
+}
 
+// ListSchedules returns the registered schedules.
+func (s *Service) ListSchedules() []*schedule.Schedule {
+	s.catalog.RLock()
+	defer s.catalog.RUnlock()
+	return append([]*schedule.Schedule(nil), s.Schedules...)
+}
+
+// MaterializeSchedules adds every scheduled flight departing between now and
+// the rolling horizon that has not been created yet.
+func (s *Service) MaterializeSchedules(now time.Time) int {
+	created := 0
+	for _, sc := range s.ListSchedules() {
+		created += s.materialize(sc, now)
+	}
+	return created
+}
+
+// AddScheduleException records a cancelled or retimed date on a schedule and
+// applies it to the dated flight if it has already been materialized,
+// publishing FlightRescheduled for a retimed one. A flight with active
+// bookings cannot be cancelled. Bookings on the flight wait until the
+// exception is applied.
+func (s *Service) AddScheduleException(flightNumber string, e schedule.Exception) error {
+	sc := s.findSchedule(flightNumber)
+	if sc == nil {
+		return schedule.ErrScheduleNotFound
+	}
+	date, err := time.Parse(schedule.DateLayout, e.Date)
+	if err != nil {
+		return schedule.ErrInvalidSchedule
+	}
+	rescheduled, err := s.applyException(sc, date, e)
+	if err != nil {
+		return err
+	}
+	if rescheduled != nil {
+		s.publish(*rescheduled)
+	}
+	return nil
+}
+
+// SetRates replaces the exchange rates, given in units per one base
+// currency unit.
+func (s *Service) SetRates(rates map[string]float64) error {
+	if err := s.Rates.Replace(rates); err != nil {
+		return err
+	}
+	s.fareCache.invalidate()
+	return nil
+}
+
+// LoadRates replaces the exchange rates from a "currency,rate" CSV.
+func (s *Service) LoadRates(r io.Reader) error {
+	if err := s.Rates.LoadCSV(r); err != nil {
+		return err
+	}
+	s.fareCache.invalidate()
 	return nil
 }
+
+// FlightDistance returns the great-circle distance of a flight in kilometres.
+func (s *Service) FlightDistance(f *flight.Flight) (float64, error) {
+	if s.Airports == nil {
+		return 0, airport.ErrAirportNotFound
+	}
+	return s.Airports.Distance(f.Origin, f.Destination)
+}
@@ -1,10 +1,47 @@
 package usecase
 
 import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/airport"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/payment"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/quote"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/revenue"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/schedule"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+)
+
+var (
+	ErrFlightHasBookings   = errors.New("flight has bookings")
+	ErrInvalidRoute        = errors.New("invalid route")
+	ErrInvalidDateRange    = errors.New("invalid date range")
+	ErrBookingCancelled    = errors.New("booking already cancelled")
+	ErrFlightNotFound      = errors.New("flight not found")
+	ErrFlightDeparted      = errors.New("flight has departed")
+	ErrInvalidChange       = errors.New("invalid booking change")
+	ErrChangeNotAllowed    = errors.New("fare does not allow changes")
+	ErrBookingChanged      = errors.New("booking changed concurrently")
+	ErrBookingNotConfirmed = errors.New("booking not confirmed")
 )
 
+// MaxCalendarDays bounds the number of days a fare calendar may span.
+const MaxCalendarDays = 62
+
+// DefaultFrequentFlyerBookings is how many paid bookings make a passenger a
+// frequent flyer.
+const DefaultFrequentFlyerBookings = 5
+
 // --- Mutex Adapter ---
 type bookingMutexAdapter struct {
 	m flight.MutexInterface
@@ -13,17 +50,210 @@ type bookingMutexAdapter struct {
 // --- Flight Adapter ---
 type bookingFlightAdapter struct {
 	*flight.Flight
+	loc  *time.Location // origin time zone
+	fare *fare.Family   // selected fare family, overrides the class base price
 }
 
+// InventoryMode is how bookings take seats from a seat class. It is chosen
+// before the service takes bookings.
+type InventoryMode string
+
+const (
+	// InventoryLocked books one seat at a time per seat class, under the
+	// class's mutex.
+	InventoryLocked InventoryMode = "locked"
+	// InventoryOptimistic books seats side by side without the mutex. Each
+	// booking takes its seat with compare-and-swap on the seat's version and
+	// looks again when another booking got there first.
+	InventoryOptimistic InventoryMode = "optimistic"
+	// InventoryActor gives each flight a goroutine that runs the service's
+	// seat bookings, releases and moves for it one at a time, in the order
+	// they arrive. Seats still live on the flight, so reads and changes made
+	// outside the service do not wait for it. See Service.StopActors.
+	InventoryActor InventoryMode = "actor"
+)
+
 type Service struct {
 	Flights           []*flight.Flight
 	Passengers        passenger.Storage
 	SeatClassPriority []string // Highest to lowest, e.g. ["First", "Business", "Economy"]
+	Airports          *airport.Registry
+	Schedules         []*schedule.Schedule
+	ScheduleHorizon   time.Duration // Defaults to schedule.DefaultHorizon
+	Revenue           *revenue.Controls
+	Promotions        *promo.Promotions
+	Rates             *money.RateTable
+	Taxes             *tax.Table
+	Quotes            *quote.Signer
+	Ancillaries       *ancillary.Catalogue
+	Payments          payment.Gateway
+	Events            *event.Bus   // nil publishes no events
+	Outbox            event.Outbox // holds events until published, a Transactional storage's from NewService
+	Logger            *slog.Logger // reports events that could not be relayed, slog.Default() when nil
+	Webhooks          *webhook.Dispatcher
+	Clock             func() time.Time // wall clock for quote expiry
+
+	Pricing               flight.Pricing // seat pricing, flight.DefaultPricing from NewService
+	DefaultRules          fare.Rules     // refund and change rules outside fare families, fare.DefaultRules from NewService
+	FrequentFlyerBookings int            // Defaults to DefaultFrequentFlyerBookings
+	QuoteTTL              time.Duration  // Defaults to quote.DefaultTTL
+	Inventory             InventoryMode  // how bookings take seats, InventoryLocked when empty
+
+	catalog       sync.RWMutex             // guards Flights, Schedules and SeatClassPriority
+	materializeMu sync.Mutex               // one schedule materialization or change at a time
+	salesMu       sync.Mutex               // guards sales
+	sales         map[string]*sync.RWMutex // by flight ID, see openSales
+	fareCache     fareCache
+	actorsMu      sync.Mutex              // guards actors
+	actors        map[string]*flightActor // by flight ID, InventoryActor only
+	webhooksOnce  sync.Once               // subscribes Webhooks to Events
+}
+
+// flightActor serializes the service's seat changes on a flight in
+// InventoryActor mode. Its goroutine runs the commands sent to it one at a
+// time until quit is closed.
+type flightActor struct {
+	commands chan func()
+	quit     chan struct{}
+	stopped  chan struct{}
+}
+
+// BookingRequest describes a seat to book. Fare selects a fare family within
+// the seat class; when empty the cheapest available family is used.
+type BookingRequest struct {
+	PassengerID   string
+	FlightID      string
+	SeatClass     string
+	Fare          string
+	PromoCodes    []string
+	SeatID        string // a chosen seat, charged the seat selection fee
+	BookingDate   time.Time
+	QuoteToken    string // books at a quote's price, see Service.Quote
+	Ancillaries   []ancillary.Request
+	PaymentMethod string // passed to the payment gateway
+}
+
+// QuoteResult is a priced booking request. Quote holds the guaranteed fare;
+// Price, Charges, Ancillaries and Total show what booking with promo codes
+// applied would charge now.
+type QuoteResult struct {
+	Quote       quote.Quote
+	Token       string
+	Price       money.Money
+	Promotions  []promo.Redemption
+	Charges     []tax.Item
+	Ancillaries []ancillary.Item
+	Total       money.Money
+}
+
+// ChangeRequest moves a booking to another seat, seat class or flight on the
+// same route. Empty fields keep the booking's current value; with a new
+// class or flight Fare picks the fare family, the cheapest open one when
+// empty, and SeatID the seat, the best available one when empty.
+type ChangeRequest struct {
+	BookingID     string
+	FlightID      string
+	SeatClass     string
+	SeatID        string
+	Fare          string
+	PaymentMethod string // pays any amount due
+	Now           time.Time
+}
+
+// ChangeResult is a changed booking and what the change costs in the
+// booking's currency. Due adds up the fare difference, the difference in
+// taxes and fees and the change fee; it is negative when money is owed back.
+type ChangeResult struct {
+	Booking           *passenger.BookingInfo
+	FareDifference    money.Money
+	ChargesDifference money.Money
+	ChangeFee         money.Money
+	Due               money.Money
+}
+
+// CalendarDay is the lowest fare found for one origin-local date.
+type CalendarDay struct {
+	Date      string       `json:"date"` // "YYYY-MM-DD"
+	Available bool         `json:"available"`
+	Price     *money.Money `json:"price,omitempty"`
+	FlightID  string       `json:"flight_id,omitempty"`
+}
+
+// SimulatedBooking compares what a booking cost with what it would have cost
+// under a proposed revenue policy. Decision is nil when the policy does not
+// apply to the booking's class.
+type SimulatedBooking struct {
+	BookingID      string            `json:"booking_id"`
+	SeatClass      string            `json:"seat_class"`
+	BookedAt       time.Time         `json:"booked_at"`
+	DaysBefore     int               `json:"days_before"`
+	Decision       *revenue.Decision `json:"decision,omitempty"`
+	Fare           string            `json:"fare,omitempty"`
+	Price          money.Money       `json:"price"`
+	SimulatedFare  string            `json:"simulated_fare,omitempty"`
+	SimulatedPrice money.Money       `json:"simulated_price"`
 }
 
-// SetSeatClassPriority sets the seat class upgrade/search order.
+// Simulation is the replay of a flight's bookings under a proposed policy.
+type Simulation struct {
+	FlightID         string             `json:"flight_id"`
+	Bookings         []SimulatedBooking `json:"bookings"`
+	Revenue          money.Money        `json:"revenue"`
+	SimulatedRevenue money.Money        `json:"simulated_revenue"`
+}
+
+// unitOfWork ties changes to in-memory inventory, such as a seat taken or a
+// promo code redeemed, to the booking records written with them. Each change
+// registers how to undo it; commit stores the records together, and rollback
+// or a failed commit undoes the changes in reverse. Events emitted in the
+// unit of work are published only once it is committed.
+type unitOfWork struct {
+	tx     passenger.Tx
+	undo   []func()
+	events []event.Event
+	s      *Service // clock for the events, and relays them once committed
+	done   bool
+}
+
+// priceWatch notes the fares the next seats of some cabins sell at, so the
+// ones that move can be published as PriceChanged events.
+type priceWatch struct {
+	s      *Service
+	now    time.Time
+	cabins []watchedCabin
+}
+
+type watchedCabin struct {
+	f     *flight.Flight
+	class string
+	price *money.Money // nil when the class had nothing left to sell
+}
+
+// fareCache holds computed fare calendars until the next inventory change.
+type fareCache struct {
+	mu      sync.Mutex
+	entries map[string][]CalendarDay
+}
+
+// SetSeatClassPriority sets the seat class upgrade/search order. It is safe
+// to call while the service takes bookings.
 func (s *Service) SetSeatClassPriority(priority []string) {
-	s.SeatClassPriority = priority
+	s.catalog.Lock()
+	defer s.catalog.Unlock()
+	s.SeatClassPriority = append([]string(nil), priority...)
+}
+
+// Location returns the time zone of an airport, UTC when it is unknown.
+func (s *Service) Location(code string) *time.Location {
+	if s.Airports == nil {
+		return time.UTC
+	}
+	return s.Airports.Location(code)
+}
+
+// Convert returns m in another currency using the service's rate table.
+func (s *Service) Convert(m money.Money, currency string) (money.Money, error) {
+	return s.Rates.Convert(m, currency)
 }
 
 // FindFlightByID is an exported wrapper for findFlightByID.
@@ -1,15 +1,36 @@
 package usecase
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/ancillary"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/booking"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/event"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/fare"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/flight"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/money"
 	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/passenger"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/payment"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/promo"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/quote"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/revenue"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/schedule"
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/tax"
 )
 
+func usd(amount float64) money.Money {
+	return money.FromFloat(amount, "USD")
+}
+
 // --- Mock Passenger Storage ---
 type mockPassengerStorage struct {
 	bookings map[string]*passenger.BookingInfo
@@ -55,11 +76,11 @@ func TestService_BookAndCancelSeat(t *testing.T) {
 			Destination: "JFK",
 			Departure:   time.Now().Add(24 * time.Hour),
 			Seats: map[flight.SeatClass][]*flight.Seat{"Economy": {
-				{SeatID: "1A", Row: 1, Column: 1, IsBooked: false},
+				{SeatID: "1A", Row: 1, Column: 1},
 			}},
 			Columns:    map[flight.SeatClass]int{"Economy": 1},
 			Rows:       map[flight.SeatClass]int{"Economy": 1},
-			BasePrices: map[flight.SeatClass]float64{"Economy": 1000},
+			BasePrices: map[flight.SeatClass]money.Money{"Economy": usd(1000)},
 			Mutex:      map[flight.SeatClass]*sync.Mutex{"Economy": new(sync.Mutex)},
 		}
 		passengerStore := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
@@ -78,7 +99,7 @@ func TestService_BookAndCancelSeat(t *testing.T) {
 		if err != nil {
 			t.Errorf("unexpected cancel error: %v", err)
 		}
-		if f.Seats["Economy"][0].IsBooked {
+		if f.Seats["Economy"][0].IsBookedSeat() {
 			t.Errorf("seat should be unbooked after cancel")
 		}
 	})
@@ -117,11 +138,11 @@ func TestService_BookAndCancelSeat(t *testing.T) {
 			Destination: "JFK",
 			Departure:   time.Now().Add(24 * time.Hour),
 			Seats: map[flight.SeatClass][]*flight.Seat{"Economy": {
-				{SeatID: "1A", Row: 1, Column: 1, IsBooked: true},
+				bookedSeat("1A"),
 			}},
 			Columns:    map[flight.SeatClass]int{"Economy": 1},
 			Rows:       map[flight.SeatClass]int{"Economy": 1},
-			BasePrices: map[flight.SeatClass]float64{"Economy": 1000},
+			BasePrices: map[flight.SeatClass]money.Money{"Economy": usd(1000)},
 			Mutex:      map[flight.SeatClass]*sync.Mutex{"Economy": new(sync.Mutex)},
 		}
 		passengerStore := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
@@ -146,7 +167,7 @@ func TestService_AddAndSearchFlights(t *testing.T) {
 			Seats:       map[flight.SeatClass][]*flight.Seat{},
 			Columns:     map[flight.SeatClass]int{},
 			Rows:        map[flight.SeatClass]int{},
-			BasePrices:  map[flight.SeatClass]float64{},
+			BasePrices:  map[flight.SeatClass]money.Money{},
 			Mutex:       map[flight.SeatClass]*sync.Mutex{"Economy": new(sync.Mutex)},
 		}
 		svc.AddFlight(fl)
@@ -248,6 +269,27 @@ func TestService_HelperFunctions(t *testing.T) {
 			t.Error("expected 2 classes")
 		}
 	})
+	t.Run("SetSeatClassPriority while booking", func(t *testing.T) {
+		f := newChangeFlight("PRIO1", "CNX")
+		svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				svc.SetSeatClassPriority([]string{"Business", "Economy"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if classes := svc.classList(f); len(classes) != 2 {
+					t.Errorf("expected 2 classes, got %v", classes)
+				}
+			}
+		}()
+		wg.Wait()
+	})
 	t.Run("generateBookingID", func(t *testing.T) {
 		id := generateBookingID()
 		if len(id) == 0 {
@@ -264,13 +306,13 @@ func TestService_BookSeat_UpgradePath(t *testing.T) {
 			Destination: "JFK",
 			Departure:   time.Now().Add(24 * time.Hour),
 			Seats: map[flight.SeatClass][]*flight.Seat{
-				"Economy":  {{SeatID: "1A", Row: 1, Column: 1, IsBooked: true}},
-				"Business": {{SeatID: "2A", Row: 1, Column: 1, IsBooked: false}},
-				"First":    {{SeatID: "3A", Row: 1, Column: 1, IsBooked: false}},
+				"Economy":  {bookedSeat("1A")},
+				"Business": {{SeatID: "2A", Row: 1, Column: 1}},
+				"First":    {{SeatID: "3A", Row: 1, Column: 1}},
 			},
 			Columns:    map[flight.SeatClass]int{"Economy": 1, "Business": 1, "First": 1},
 			Rows:       map[flight.SeatClass]int{"Economy": 1, "Business": 1, "First": 1},
-			BasePrices: map[flight.SeatClass]float64{"Economy": 1000, "Business": 2000, "First": 3000},
+			BasePrices: map[flight.SeatClass]money.Money{"Economy": usd(1000), "Business": usd(2000), "First": usd(3000)},
 			Mutex: map[flight.SeatClass]*sync.Mutex{
 				"Economy":  new(sync.Mutex),
 				"Business": new(sync.Mutex),
@@ -297,12 +339,12 @@ func TestService_BookSeat_UpgradePath(t *testing.T) {
 			Destination: "JFK",
 			Departure:   time.Now().Add(24 * time.Hour),
 			Seats: map[flight.SeatClass][]*flight.Seat{
-				"Economy":  {{SeatID: "1A", Row: 1, Column: 1, IsBooked: true}},
-				"Business": {{SeatID: "2A", Row: 1, Column: 1, IsBooked: false}},
+				"Economy":  {bookedSeat("1A")},
+				"Business": {{SeatID: "2A", Row: 1, Column: 1}},
 			},
 			Columns:    map[flight.SeatClass]int{"Economy": 1, "Business": 1},
 			Rows:       map[flight.SeatClass]int{"Economy": 1, "Business": 1},
-			BasePrices: map[flight.SeatClass]float64{"Economy": 1000, "Business": 2000},
+			BasePrices: map[flight.SeatClass]money.Money{"Economy": usd(1000), "Business": usd(2000)},
 			Mutex: map[flight.SeatClass]*sync.Mutex{
 				"Economy":  new(sync.Mutex),
 				"Business": new(sync.Mutex),
@@ -327,11 +369,11 @@ func TestService_BookSeat_UpgradePath(t *testing.T) {
 			Destination: "JFK",
 			Departure:   time.Now().Add(24 * time.Hour),
 			Seats: map[flight.SeatClass][]*flight.Seat{
-				"Economy": {{SeatID: "1A", Row: 1, Column: 1, IsBooked: true}},
+				"Economy": {bookedSeat("1A")},
 			},
 			Columns:    map[flight.SeatClass]int{"Economy": 1},
 			Rows:       map[flight.SeatClass]int{"Economy": 1},
-			BasePrices: map[flight.SeatClass]float64{"Economy": 1000},
+			BasePrices: map[flight.SeatClass]money.Money{"Economy": usd(1000)},
 			Mutex:      map[flight.SeatClass]*sync.Mutex{"Economy": new(sync.Mutex)},
 		}
 		passengerStore := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
@@ -343,3 +385,2348 @@ func TestService_BookSeat_UpgradePath(t *testing.T) {
 		}
 	})
 }
+
+func newTestSchedule(validFrom, validTo time.Time) *schedule.Schedule {
+	return &schedule.Schedule{
+		FlightNumber: "TG100",
+		Origin:       "BKK",
+		Destination:  "NRT",
+		DaysOfWeek: []time.Weekday{
+			time.Sunday, time.Monday, time.Tuesday, time.Wednesday,
+			time.Thursday, time.Friday, time.Saturday,
+		},
+		ValidFrom:  validFrom.Format(schedule.DateLayout),
+		ValidTo:    validTo.Format(schedule.DateLayout),
+		Departure:  "08:00",
+		Arrival:    "16:00",
+		Aircraft:   "Boeing 787",
+		SeatLayout: map[string][][]schedule.SeatTemplate{"Economy": {{{}}}},
+		BasePrices: map[string]json.Number{"Economy": "500"},
+	}
+}
+
+func TestService_Schedules(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("MaterializeWithinHorizon", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		svc.ScheduleHorizon = 3 * 24 * time.Hour
+		created, err := svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if created != 4 || len(svc.Flights) != 4 {
+			t.Errorf("expected 4 flights, created %d, have %d", created, len(svc.Flights))
+		}
+
+		// Rolling forward only creates the new days
+		created = svc.MaterializeSchedules(now.AddDate(0, 0, 2))
+		if created != 2 || len(svc.Flights) != 6 {
+			t.Errorf("expected 2 new flights, created %d, have %d", created, len(svc.Flights))
+		}
+		if svc.FindFlightByID("TG100-20240706") == nil {
+			t.Error("expected flight on 2024-07-06")
+		}
+	})
+
+	t.Run("InvalidSchedule", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		sc := newTestSchedule(now, now)
+		sc.DaysOfWeek = nil
+		if _, err := svc.AddSchedule(sc, now); !errors.Is(err, schedule.ErrInvalidSchedule) {
+			t.Errorf("expected ErrInvalidSchedule, got %v", err)
+		}
+	})
+
+	t.Run("ExceptionsApplyToMaterializedFlights", func(t *testing.T) {
+		store := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
+		svc := NewService([]*flight.Flight{}, store)
+		svc.ScheduleHorizon = 3 * 24 * time.Hour
+		_, _ = svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+
+		if err := svc.AddScheduleException("TG100", schedule.Exception{Date: "2024-07-02", Cancelled: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if svc.FindFlightByID("TG100-20240702") != nil {
+			t.Error("cancelled flight should be removed")
+		}
+
+		if err := svc.AddScheduleException("TG100", schedule.Exception{Date: "2024-07-03", Departure: "10:30"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		retimed := svc.FindFlightByID("TG100-20240703")
+		if retimed == nil || retimed.Departure.Hour() != 10 || retimed.Departure.Minute() != 30 {
+			t.Errorf("expected retimed departure, got %+v", retimed)
+		}
+
+		// Cancelled dates are not recreated when rolling forward
+		svc.MaterializeSchedules(now)
+		if svc.FindFlightByID("TG100-20240702") != nil {
+			t.Error("cancelled flight should not be materialized again")
+		}
+	})
+
+	t.Run("CancelFlightWithBookings", func(t *testing.T) {
+		store := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
+		svc := NewService([]*flight.Flight{}, store)
+		svc.ScheduleHorizon = 24 * time.Hour
+		_, _ = svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+		store.bookings["B1"] = &passenger.BookingInfo{BookingID: "B1", FlightID: "TG100-20240701", Status: passenger.StatusConfirmed}
+
+		err := svc.AddScheduleException("TG100", schedule.Exception{Date: "2024-07-01", Cancelled: true})
+		if !errors.Is(err, ErrFlightHasBookings) {
+			t.Errorf("expected ErrFlightHasBookings, got %v", err)
+		}
+		if svc.FindFlightByID("TG100-20240701") == nil {
+			t.Error("flight with bookings should be kept")
+		}
+
+		// Cancelled and failed bookings no longer hold the flight
+		store.bookings["B1"].Status = passenger.StatusCancelled
+		store.bookings["B2"] = &passenger.BookingInfo{BookingID: "B2", FlightID: "TG100-20240701", Status: passenger.StatusFailed}
+		if err := svc.AddScheduleException("TG100", schedule.Exception{Date: "2024-07-01", Cancelled: true}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if svc.FindFlightByID("TG100-20240701") != nil {
+			t.Error("flight without active bookings should be removed")
+		}
+	})
+
+	t.Run("ExceptionArrivingBeforeDeparture", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		svc.ScheduleHorizon = 3 * 24 * time.Hour
+		_, _ = svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+
+		// 18:30 in Bangkok is 20:30 in Tokyo, after the 16:00 arrival
+		err := svc.AddScheduleException("TG100", schedule.Exception{Date: "2024-07-02", Departure: "18:30"})
+		if !errors.Is(err, schedule.ErrInvalidSchedule) {
+			t.Errorf("expected ErrInvalidSchedule, got %v", err)
+		}
+		if f := svc.FindFlightByID("TG100-20240702"); f.Departure.Hour() != 8 {
+			t.Errorf("expected the flight to keep its times, got %v", f.Departure)
+		}
+	})
+
+	t.Run("ReplaceRebuildsUnbookedFlights", func(t *testing.T) {
+		store := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
+		svc := NewService([]*flight.Flight{}, store)
+		svc.ScheduleHorizon = 2 * 24 * time.Hour
+		_, _ = svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+		store.bookings["B1"] = &passenger.BookingInfo{BookingID: "B1", FlightID: "TG100-20240702", Status: passenger.StatusCancelled}
+
+		sc := newTestSchedule(now, now.AddDate(0, 1, 0))
+		sc.Departure = "09:00"
+		if _, err := svc.AddSchedule(sc, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, id := range []string{"TG100-20240702", "TG100-20240703"} {
+			if f := svc.FindFlightByID(id); f == nil || f.Departure.Hour() != 9 {
+				t.Errorf("expected %s rebuilt at 09:00, got %+v", id, f)
+			}
+		}
+		// Departed flights are left alone
+		if f := svc.FindFlightByID("TG100-20240701"); f == nil || f.Departure.Hour() != 8 {
+			t.Errorf("expected the departed flight kept, got %+v", f)
+		}
+		if len(svc.Flights) != 3 {
+			t.Errorf("expected 3 flights, have %d", len(svc.Flights))
+		}
+	})
+
+	t.Run("ReplaceKeepsBookedFlights", func(t *testing.T) {
+		store := &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}
+		svc := NewService([]*flight.Flight{}, store)
+		svc.ScheduleHorizon = 2 * 24 * time.Hour
+		_, _ = svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+		store.bookings["B1"] = &passenger.BookingInfo{BookingID: "B1", FlightID: "TG100-20240702", Status: passenger.StatusConfirmed}
+		booked := svc.FindFlightByID("TG100-20240702")
+
+		retimed := newTestSchedule(now, now.AddDate(0, 1, 0))
+		retimed.Departure = "09:00"
+		if _, err := svc.AddSchedule(retimed, now); !errors.Is(err, ErrFlightHasBookings) {
+			t.Errorf("expected ErrFlightHasBookings for a retimed booked flight, got %v", err)
+		}
+		dropped := newTestSchedule(now, now.AddDate(0, 1, 0))
+		dropped.DaysOfWeek = []time.Weekday{time.Monday}
+		if _, err := svc.AddSchedule(dropped, now); !errors.Is(err, ErrFlightHasBookings) {
+			t.Errorf("expected ErrFlightHasBookings for a cancelled booked flight, got %v", err)
+		}
+		if svc.ListSchedules()[0].Departure != "08:00" {
+			t.Error("rejected replacements should keep the old schedule")
+		}
+
+		// Same times: the booked flight is kept as sold
+		repriced := newTestSchedule(now, now.AddDate(0, 1, 0))
+		repriced.BasePrices = map[string]json.Number{"Economy": "600"}
+		if _, err := svc.AddSchedule(repriced, now); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if svc.FindFlightByID("TG100-20240702") != booked {
+			t.Error("expected the booked flight to be kept")
+		}
+		if f := svc.FindFlightByID("TG100-20240703"); f == nil || f.BasePrices["Economy"] != usd(600) {
+			t.Errorf("expected the unbooked flight rebuilt at the new price, got %+v", f)
+		}
+	})
+
+	t.Run("UnknownSchedule", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		err := svc.AddScheduleException("XX1", schedule.Exception{Date: "2024-07-01", Cancelled: true})
+		if !errors.Is(err, schedule.ErrScheduleNotFound) {
+			t.Errorf("expected ErrScheduleNotFound, got %v", err)
+		}
+	})
+}
+
+func TestService_LocalTime(t *testing.T) {
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+
+	t.Run("SearchUsesOriginDate", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		// 2024-07-10 00:30 in Bangkok, 2024-07-09 in UTC
+		svc.AddFlight(&flight.Flight{FlightID: "F7", Origin: "BKK", Destination: "NRT", Departure: time.Date(2024, 7, 9, 17, 30, 0, 0, time.UTC)})
+
+		found := svc.SearchFlights("BKK", "NRT", time.Date(2024, 7, 10, 0, 0, 0, 0, bangkok))
+		if len(found) != 1 {
+			t.Errorf("expected flight on Bangkok date 2024-07-10, got %d", len(found))
+		}
+		found = svc.SearchFlights("BKK", "NRT", time.Date(2024, 7, 9, 0, 0, 0, 0, bangkok))
+		if len(found) != 0 {
+			t.Errorf("expected no flight on Bangkok date 2024-07-09, got %d", len(found))
+		}
+	})
+
+	t.Run("BookingWindowUsesOriginDate", func(t *testing.T) {
+		f := &flight.Flight{
+			FlightID:    "F8",
+			Origin:      "BKK",
+			Destination: "NRT",
+			// 2024-07-10 00:30 in Bangkok
+			Departure:  time.Date(2024, 7, 9, 17, 30, 0, 0, time.UTC),
+			Seats:      map[flight.SeatClass][]*flight.Seat{"Economy": {{SeatID: "1A", Row: 1, Column: 1}, {SeatID: "1B", Row: 1, Column: 2}}},
+			Columns:    map[flight.SeatClass]int{"Economy": 2},
+			Rows:       map[flight.SeatClass]int{"Economy": 1},
+			BasePrices: map[flight.SeatClass]money.Money{"Economy": usd(1000)},
+			Mutex:      map[flight.SeatClass]*sync.Mutex{"Economy": new(sync.Mutex)},
+		}
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		// Booked on 2024-07-02 in Bangkok: 8 local days out, no surcharge
+		bk, err := svc.BookSeat("P1", "F8", "Economy", time.Date(2024, 7, 2, 0, 0, 0, 0, bangkok))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.Price != usd(1500) {
+			t.Errorf("expected 1500, got %s", bk.Price)
+		}
+	})
+
+	t.Run("RefundCutoffUsesOriginDate", func(t *testing.T) {
+		f := &flight.Flight{FlightID: "F9", Origin: "BKK", Departure: time.Date(2024, 7, 9, 17, 30, 0, 0, time.UTC)}
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		rules := fare.Rules{Refundable: true, RefundPercent: 80, LateRefundPercent: 50}
+		bk := &passenger.BookingInfo{FlightID: "F9", Price: usd(1000), FareRules: &rules}
+		// 2024-07-09 12:00 UTC is 19:00 on the day before departure in Bangkok
+		if refund := svc.CalculateRefund(bk, time.Date(2024, 7, 9, 12, 0, 0, 0, time.UTC)); refund != usd(800) {
+			t.Errorf("expected 800, got %s", refund)
+		}
+		// 2024-07-09 17:10 UTC is already departure day in Bangkok
+		if refund := svc.CalculateRefund(bk, time.Date(2024, 7, 9, 17, 10, 0, 0, time.UTC)); refund != usd(500) {
+			t.Errorf("expected 500, got %s", refund)
+		}
+		// Without fare rules there is no same-day cutoff
+		if refund := svc.CalculateRefund(&passenger.BookingInfo{FlightID: "F9", Price: usd(1000)}, time.Date(2024, 7, 9, 17, 10, 0, 0, time.UTC)); refund != usd(800) {
+			t.Errorf("expected 800, got %s", refund)
+		}
+		if refund := svc.CalculateRefund(&passenger.BookingInfo{FlightID: "NOPE", Price: usd(1000)}, time.Now()); refund != usd(0) {
+			t.Errorf("expected 0 for unknown flight, got %s", refund)
+		}
+	})
+}
+
+func TestService_AirportValidation(t *testing.T) {
+	t.Run("NormalizesCodes", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		f := &flight.Flight{FlightID: "N1", Origin: "jfk", Destination: "LAX "}
+		if err := svc.AddFlight(f); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if f.Origin != "JFK" || f.Destination != "LAX" {
+			t.Errorf("expected normalized codes, got %q -> %q", f.Origin, f.Destination)
+		}
+	})
+
+	t.Run("RejectsUnknownOrSameAirport", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		for _, f := range []*flight.Flight{
+			{FlightID: "N2", Origin: "XYZ", Destination: "LAX"},
+			{FlightID: "N3", Origin: "JFK", Destination: "XYZ"},
+			{FlightID: "N4", Origin: "JFK", Destination: "jfk"},
+		} {
+			if err := svc.AddFlight(f); !errors.Is(err, ErrInvalidRoute) {
+				t.Errorf("%s: expected ErrInvalidRoute, got %v", f.FlightID, err)
+			}
+		}
+		if len(svc.Flights) != 0 {
+			t.Errorf("invalid flights should not be added")
+		}
+
+		sc := newTestSchedule(time.Now(), time.Now())
+		sc.Destination = "XYZ"
+		if _, err := svc.AddSchedule(sc, time.Now()); !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("expected ErrInvalidRoute for schedule, got %v", err)
+		}
+	})
+
+	t.Run("MetroSearch", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		newYork, _ := time.LoadLocation("America/New_York")
+		dep := time.Date(2024, 7, 10, 9, 0, 0, 0, newYork)
+		for _, f := range []*flight.Flight{
+			{FlightID: "M1", Origin: "JFK", Destination: "LHR", Departure: dep},
+			{FlightID: "M2", Origin: "EWR", Destination: "LGW", Departure: dep},
+			{FlightID: "M3", Origin: "LGA", Destination: "ORD", Departure: dep},
+		} {
+			_ = svc.AddFlight(f)
+		}
+		found := svc.SearchFlights("NYC", "LON", dep)
+		if len(found) != 2 {
+			t.Errorf("expected 2 flights from NYC to LON, got %d", len(found))
+		}
+		found = svc.SearchFlights("JFK", "LON", dep)
+		if len(found) != 1 || found[0].FlightID != "M1" {
+			t.Errorf("expected only M1 from JFK, got %d", len(found))
+		}
+	})
+
+	t.Run("FlightDistance", func(t *testing.T) {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		d, err := svc.FlightDistance(&flight.Flight{Origin: "BKK", Destination: "SIN"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if d < 1400 || d > 1450 {
+			t.Errorf("expected about 1430 km, got %.1f", d)
+		}
+		if _, err := (&Service{}).FlightDistance(&flight.Flight{Origin: "BKK", Destination: "SIN"}); err == nil {
+			t.Error("expected error without airport registry")
+		}
+	})
+}
+
+func newCalendarFlight(id string, departure time.Time, base float64, seats int) *flight.Flight {
+	f := flight.InitializeFlight(id, "BKK", "NRT", "Airbus A350", departure, departure.Add(6*time.Hour))
+	row := make([]*flight.Seat, seats)
+	for i := range row {
+		row[i] = &flight.Seat{}
+	}
+	f.AddSeatClass("Economy", [][]*flight.Seat{row}, usd(base))
+	return f
+}
+
+func TestService_FareCalendar(t *testing.T) {
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+	now := time.Date(2024, 6, 1, 9, 0, 0, 0, bangkok)
+	day1 := time.Date(2024, 7, 10, 0, 0, 0, 0, bangkok)
+
+	newService := func() *Service {
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		_ = svc.AddFlight(newCalendarFlight("C1", day1.Add(8*time.Hour), 1000, 4))
+		_ = svc.AddFlight(newCalendarFlight("C2", day1.Add(20*time.Hour), 800, 4))
+		_ = svc.AddFlight(newCalendarFlight("C3", day1.AddDate(0, 0, 2).Add(8*time.Hour), 900, 1))
+		return svc
+	}
+
+	t.Run("LowestPerDay", func(t *testing.T) {
+		svc := newService()
+		days, err := svc.FareCalendar("BKK", "NRT", day1, day1.AddDate(0, 0, 2), "Economy", "", now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(days) != 3 {
+			t.Fatalf("expected 3 days, got %d", len(days))
+		}
+		// Early booking discount and one seat sold out of four
+		if !days[0].Available || days[0].FlightID != "C2" || *days[0].Price != usd(800*0.9*1.25) {
+			t.Errorf("unexpected first day %+v", days[0])
+		}
+		if days[1].Available || days[1].Date != "2024-07-11" {
+			t.Errorf("expected no flight on second day, got %+v", days[1])
+		}
+		if !days[2].Available || *days[2].Price != usd(900*0.9*2) {
+			t.Errorf("unexpected third day %+v", days[2])
+		}
+	})
+
+	t.Run("InvalidatedByBookingAndCancellation", func(t *testing.T) {
+		svc := newService()
+		before, _ := svc.FareCalendar("BKK", "NRT", day1, day1.AddDate(0, 0, 2), "Economy", "", now)
+
+		bk, err := svc.BookSeat("P1", "C3", "Economy", now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		after, _ := svc.FareCalendar("BKK", "NRT", day1, day1.AddDate(0, 0, 2), "Economy", "", now)
+		if !before[2].Available || after[2].Available {
+			t.Errorf("expected sold-out day to become unavailable, got %+v", after[2])
+		}
+
+		_ = svc.CancelBooking(bk.BookingID, now)
+		restored, _ := svc.FareCalendar("BKK", "NRT", day1, day1.AddDate(0, 0, 2), "Economy", "", now)
+		if !restored[2].Available {
+			t.Errorf("expected day to be available again after cancellation")
+		}
+	})
+
+	t.Run("CachedResultsAreCopies", func(t *testing.T) {
+		svc := newService()
+		days, _ := svc.FareCalendar("BKK", "NRT", day1, day1, "Economy", "", now)
+		days[0].Price = nil
+		again, _ := svc.FareCalendar("BKK", "NRT", day1, day1, "Economy", "", now)
+		if again[0].Price == nil {
+			t.Error("cached calendar should not be shared with callers")
+		}
+	})
+
+	t.Run("InvalidRange", func(t *testing.T) {
+		svc := newService()
+		if _, err := svc.FareCalendar("BKK", "NRT", day1, day1.AddDate(0, 0, -1), "Economy", "", now); !errors.Is(err, ErrInvalidDateRange) {
+			t.Errorf("expected ErrInvalidDateRange, got %v", err)
+		}
+		if _, err := svc.FareCalendar("BKK", "NRT", day1, day1.AddDate(0, 0, MaxCalendarDays), "Economy", "", now); !errors.Is(err, ErrInvalidDateRange) {
+			t.Errorf("expected ErrInvalidDateRange, got %v", err)
+		}
+	})
+
+	t.Run("UnknownClass", func(t *testing.T) {
+		svc := newService()
+		days, _ := svc.FareCalendar("BKK", "NRT", day1, day1, "First", "", now)
+		if days[0].Available {
+			t.Error("expected no availability for unknown class")
+		}
+	})
+}
+
+func newFareFlight(t *testing.T, id string, seats int) *flight.Flight {
+	f := flight.InitializeFlight(id, "BKK", "SIN", "Airbus A320", time.Now().AddDate(0, 0, 14), time.Now().AddDate(0, 0, 14).Add(2*time.Hour))
+	row := make([]*flight.Seat, seats)
+	for i := range row {
+		row[i] = &flight.Seat{}
+	}
+	f.AddSeatClass("Economy", [][]*flight.Seat{row}, usd(300))
+	f.AddSeatClass("Business", [][]*flight.Seat{{{}}}, usd(1200))
+	err := f.SetFareFamilies("Economy", []fare.Family{
+		{Code: "BASIC", Price: money.FromFloat(200, "USD"), Allotment: 1},
+		{Code: "STANDARD", Price: money.FromFloat(300, "USD"), Allotment: 2, Rules: fare.Rules{Refundable: true, RefundPercent: 50}},
+		{Code: "FLEX", Price: money.FromFloat(500, "USD"), Rules: fare.Rules{Refundable: true, RefundPercent: 100, LateRefundPercent: 100, Changeable: true}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return f
+}
+
+func TestService_FareFamilies(t *testing.T) {
+	newService := func(f *flight.Flight) *Service {
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		svc.SetSeatClassPriority([]string{"Economy", "Business"})
+		return svc
+	}
+
+	t.Run("CheapestFamilyByDefault", func(t *testing.T) {
+		f := newFareFlight(t, "FF1", 4)
+		svc := newService(f)
+		bk, err := svc.BookSeat("P1", "FF1", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 14 days out: no window adjustment, one of four seats booked
+		if bk.Fare != "BASIC" || bk.Price != usd(200*1.25) {
+			t.Errorf("expected BASIC at 250, got %s at %s", bk.Fare, bk.Price)
+		}
+		if bk.FareRules == nil || bk.FareRules.Refundable {
+			t.Errorf("expected BASIC rules to be stored on the booking")
+		}
+		if refund := svc.CalculateRefund(bk, time.Now()); !refund.IsZero() {
+			t.Errorf("expected BASIC to be non-refundable, got %s", refund)
+		}
+
+		// BASIC is now closed, so the next booking falls into STANDARD
+		bk2, _ := svc.BookSeat("P2", "FF1", "Economy", time.Now())
+		if bk2.Fare != "STANDARD" {
+			t.Errorf("expected STANDARD, got %s", bk2.Fare)
+		}
+		if refund := svc.CalculateRefund(bk2, time.Now()); refund != bk2.Price.MulRatio(1, 2) {
+			t.Errorf("expected 50%% refund, got %s", refund)
+		}
+	})
+
+	t.Run("RequestedFamily", func(t *testing.T) {
+		f := newFareFlight(t, "FF2", 4)
+		svc := newService(f)
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "FF2", SeatClass: "Economy", Fare: "FLEX", BookingDate: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.Fare != "FLEX" || bk.Price != usd(500*1.25) {
+			t.Errorf("expected FLEX at 625, got %s at %s", bk.Fare, bk.Price)
+		}
+		if refund := svc.CalculateRefund(bk, time.Now()); refund != bk.Price {
+			t.Errorf("expected full refund, got %s", refund)
+		}
+
+		_, err = svc.Book(BookingRequest{PassengerID: "P1", FlightID: "FF2", SeatClass: "Economy", Fare: "PREMIUM", BookingDate: time.Now()})
+		if !errors.Is(err, fare.ErrFareNotFound) {
+			t.Errorf("expected ErrFareNotFound, got %v", err)
+		}
+		_, err = svc.Book(BookingRequest{PassengerID: "P1", FlightID: "FF2", SeatClass: "Business", Fare: "FLEX", BookingDate: time.Now()})
+		if !errors.Is(err, fare.ErrFareNotFound) {
+			t.Errorf("expected ErrFareNotFound for class without families, got %v", err)
+		}
+	})
+
+	t.Run("RequestedFamilySoldOut", func(t *testing.T) {
+		f := newFareFlight(t, "FF3", 4)
+		svc := newService(f)
+		_, _ = svc.BookSeat("P1", "FF3", "Economy", time.Now())
+		_, err := svc.Book(BookingRequest{PassengerID: "P2", FlightID: "FF3", SeatClass: "Economy", Fare: "BASIC", BookingDate: time.Now()})
+		if !errors.Is(err, fare.ErrFareSoldOut) {
+			t.Errorf("expected ErrFareSoldOut, got %v", err)
+		}
+	})
+
+	t.Run("FareReleasedWhenSeatUnavailable", func(t *testing.T) {
+		f := newFareFlight(t, "FF4", 1)
+		f.Seats["Economy"][0].SetBooked(true)
+		f.Seats["Business"][0].SetBooked(true)
+		svc := newService(f)
+		if _, err := svc.BookSeat("P1", "FF4", "Economy", time.Now()); err == nil {
+			t.Fatal("expected error when no seats are left")
+		}
+		if family, _ := f.Fares["Economy"].Lowest(); family.Code != "BASIC" {
+			t.Errorf("expected BASIC to be released, got %s", family.Code)
+		}
+	})
+
+	t.Run("CancelReleasesFare", func(t *testing.T) {
+		f := newFareFlight(t, "FF5", 4)
+		svc := newService(f)
+		bk, _ := svc.BookSeat("P1", "FF5", "Economy", time.Now())
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stored, _ := svc.Passengers.GetBooking(bk.BookingID); stored.Status != passenger.StatusCancelled {
+			t.Errorf("expected booking to be cancelled, got %s", stored.Status)
+		}
+		if family, _ := f.Fares["Economy"].Lowest(); family.Code != "BASIC" {
+			t.Errorf("expected BASIC to reopen, got %s", family.Code)
+		}
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); !errors.Is(err, ErrBookingCancelled) {
+			t.Errorf("expected ErrBookingCancelled, got %v", err)
+		}
+		if family, _ := f.Fares["Economy"].Lowest(); family.Code != "BASIC" {
+			t.Errorf("second cancel should not release again")
+		}
+	})
+}
+
+func TestService_RevenueControls(t *testing.T) {
+	newService := func(f *flight.Flight) *Service {
+		return NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+	}
+	// Half the cabin is expected to be sold on departure day, none 60 days out
+	policy := func() *revenue.Policy {
+		return &revenue.Policy{
+			Origin:      "bkk",
+			Destination: "sin",
+			Curve:       []revenue.Point{{DaysBefore: 0, Load: 0.5}, {DaysBefore: 60, Load: 0}},
+			Step:        0.1,
+		}
+	}
+
+	t.Run("LoadAheadOfCurveClosesCheapFamilies", func(t *testing.T) {
+		f := newFareFlight(t, "RM1", 4)
+		svc := newService(f)
+		if err := svc.SetRevenuePolicy(policy()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Seats sold outside fare inventory put the cabin at 50% load, well
+		// ahead of the roughly 38% expected two weeks out.
+		f.Seats["Economy"][0].SetBooked(true)
+		f.Seats["Economy"][1].SetBooked(true)
+
+		avail := svc.FareAvailability(f, "Economy", time.Now())
+		if !avail[0].Closed || !avail[1].Closed || avail[2].Closed {
+			t.Errorf("expected BASIC and STANDARD closed, got %+v", avail)
+		}
+		_, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "RM1", SeatClass: "Economy", Fare: "BASIC", BookingDate: time.Now()})
+		if !errors.Is(err, fare.ErrFareClosed) {
+			t.Errorf("expected ErrFareClosed, got %v", err)
+		}
+		bk, err := svc.BookSeat("P1", "RM1", "Economy", time.Now())
+		if err != nil || bk.Fare != "FLEX" {
+			t.Errorf("expected FLEX, got %v, %v", bk, err)
+		}
+
+		if err := svc.DeleteRevenuePolicy("BKK", "SIN", ""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk, _ := svc.BookSeat("P2", "RM1", "Economy", time.Now()); bk == nil || bk.Fare != "BASIC" {
+			t.Errorf("expected BASIC once the policy is removed, got %v", bk)
+		}
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		svc := newService(newFareFlight(t, "RM2", 4))
+		p := policy()
+		p.Destination = "XXX"
+		if err := svc.SetRevenuePolicy(p); !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("expected ErrInvalidRoute, got %v", err)
+		}
+		p = policy()
+		p.Step = 0
+		if err := svc.SetRevenuePolicy(p); !errors.Is(err, revenue.ErrInvalidPolicy) {
+			t.Errorf("expected ErrInvalidPolicy, got %v", err)
+		}
+	})
+
+	t.Run("Simulate", func(t *testing.T) {
+		f := newFareFlight(t, "RM3", 4)
+		svc := newService(f)
+		var prices []money.Money
+		for i := 3; i >= 1; i-- {
+			bk, err := svc.BookSeat("P1", "RM3", "Economy", time.Now().AddDate(0, 0, -i))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			prices = append(prices, bk.Price)
+		}
+
+		proposed := &revenue.Policy{Curve: []revenue.Point{{DaysBefore: 0, Load: 0}}, Step: 0.1}
+		sim, err := svc.SimulateRevenue("RM3", proposed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(sim.Bookings) != 3 {
+			t.Fatalf("expected 3 bookings, got %d", len(sim.Bookings))
+		}
+		first, second := sim.Bookings[0], sim.Bookings[1]
+		if first.Decision.Closed != 0 || first.SimulatedFare != "BASIC" || first.SimulatedPrice != prices[0] {
+			t.Errorf("expected first booking unchanged, got %+v", first)
+		}
+		if second.Fare != "STANDARD" || second.SimulatedFare != "FLEX" || second.SimulatedPrice != prices[1].MulRatio(500, 300) {
+			t.Errorf("expected second booking repriced into FLEX, got %+v", second)
+		}
+		if !sim.Revenue.Less(sim.SimulatedRevenue) {
+			t.Errorf("expected simulated revenue above %s, got %s", sim.Revenue, sim.SimulatedRevenue)
+		}
+		if proposed.Origin != "BKK" || proposed.Destination != "SIN" {
+			t.Errorf("expected route to default to the flight's, got %s-%s", proposed.Origin, proposed.Destination)
+		}
+
+		if _, err := svc.SimulateRevenue("NOPE", policy()); !errors.Is(err, ErrFlightNotFound) {
+			t.Errorf("expected ErrFlightNotFound, got %v", err)
+		}
+		if _, err := svc.SimulateRevenue("RM3", &revenue.Policy{Step: 0.1}); !errors.Is(err, revenue.ErrInvalidPolicy) {
+			t.Errorf("expected ErrInvalidPolicy, got %v", err)
+		}
+	})
+}
+
+func TestService_PromoCodes(t *testing.T) {
+	newService := func(f *flight.Flight) *Service {
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		_ = svc.Promotions.Add(&promo.Promo{Code: "TENOFF", Kind: promo.KindPercent, Percent: 10, MaxPerPassenger: 1})
+		_ = svc.Promotions.Add(&promo.Promo{Code: "BIZONLY", Kind: promo.KindFixed, Amount: usd(50), SeatClasses: []string{"Business"}})
+		return svc
+	}
+
+	t.Run("DiscountRecordedOnBooking", func(t *testing.T) {
+		f := newFareFlight(t, "PR1", 4)
+		svc := newService(f)
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "PR1", SeatClass: "Economy", PromoCodes: []string{"tenoff"}, BookingDate: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// BASIC at 200, 14 days out, first seat of four sold
+		full := 200 * 1.25
+		if bk.Price != usd(full*0.9) {
+			t.Errorf("expected %.2f, got %s", full*0.9, bk.Price)
+		}
+		if len(bk.Promotions) != 1 || bk.Promotions[0].Code != "TENOFF" || bk.Promotions[0].Discount != usd(full*0.1) {
+			t.Errorf("unexpected promotions %+v", bk.Promotions)
+		}
+
+		_, err = svc.Book(BookingRequest{PassengerID: "P1", FlightID: "PR1", SeatClass: "Economy", PromoCodes: []string{"TENOFF"}, BookingDate: time.Now()})
+		if !errors.Is(err, promo.ErrPromoExhausted) {
+			t.Errorf("expected ErrPromoExhausted, got %v", err)
+		}
+
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if svc.Promotions.Redemptions("TENOFF") != 0 {
+			t.Errorf("expected cancellation to reverse the redemption")
+		}
+	})
+
+	t.Run("RejectedCodeReleasesSeat", func(t *testing.T) {
+		f := newFareFlight(t, "PR2", 1)
+		svc := newService(f)
+		_, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "PR2", SeatClass: "Economy", PromoCodes: []string{"BIZONLY"}, BookingDate: time.Now()})
+		if !errors.Is(err, promo.ErrPromoNotApplicable) {
+			t.Fatalf("expected ErrPromoNotApplicable, got %v", err)
+		}
+		if f.Seats["Economy"][0].IsBookedSeat() {
+			t.Errorf("expected seat to be released")
+		}
+		if family, _ := f.Fares["Economy"].Lowest(); family.Code != "BASIC" {
+			t.Errorf("expected fare to be released, got %s", family.Code)
+		}
+	})
+}
+
+func TestService_Currencies(t *testing.T) {
+	now := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 7, 20, 0, 0, 0, 0, time.UTC)
+
+	t.Run("BasePricesMustMatchSellingCurrency", func(t *testing.T) {
+		svc := NewService(nil, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		f := flight.InitializeFlight("CUR1", "BKK", "NRT", "Airbus A350", day, day.Add(6*time.Hour))
+		f.Currency = "THB"
+		f.AddSeatClass("Economy", [][]*flight.Seat{{{}}}, usd(100))
+		if err := svc.AddFlight(f); !errors.Is(err, money.ErrCurrencyMismatch) {
+			t.Errorf("expected ErrCurrencyMismatch, got %v", err)
+		}
+		f.Currency = "XYZ"
+		if err := svc.AddFlight(f); !errors.Is(err, money.ErrUnknownCurrency) {
+			t.Errorf("expected ErrUnknownCurrency, got %v", err)
+		}
+	})
+
+	t.Run("CalendarComparesAcrossCurrencies", func(t *testing.T) {
+		usdFlight := newCalendarFlight("CUR2", day.Add(2*time.Hour), 500, 4)
+		thbFlight := flight.InitializeFlight("CUR3", "BKK", "NRT", "Airbus A350", day.Add(4*time.Hour), day.Add(10*time.Hour))
+		thbFlight.Currency = "THB"
+		thbFlight.AddSeatClass("Economy", [][]*flight.Seat{{{}, {}, {}, {}}}, money.FromFloat(10000, "THB"))
+		svc := NewService(nil, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		for _, f := range []*flight.Flight{usdFlight, thbFlight} {
+			if err := svc.AddFlight(f); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if err := svc.SetRates(map[string]float64{"THB": 40, "EUR": 0.5}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// 10000 THB is 250 USD, cheaper than the 500 USD flight
+		days, err := svc.FareCalendar("BKK", "NRT", day, day, "Economy", "", now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if days[0].FlightID != "CUR3" || days[0].Price.Currency != "THB" {
+			t.Errorf("expected the THB flight in THB, got %+v", days[0])
+		}
+		days, _ = svc.FareCalendar("BKK", "NRT", day, day, "Economy", "eur", now)
+		if days[0].FlightID != "CUR3" || *days[0].Price != money.FromFloat(10000*1.25/40*0.5, "EUR") {
+			t.Errorf("expected the THB flight shown in EUR, got %s", days[0].Price)
+		}
+
+		// Rate changes invalidate cached calendars
+		if err := svc.SetRates(map[string]float64{"THB": 10, "EUR": 0.5}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		days, _ = svc.FareCalendar("BKK", "NRT", day, day, "Economy", "", now)
+		if days[0].FlightID != "CUR2" {
+			t.Errorf("expected the USD flight once THB strengthens, got %+v", days[0])
+		}
+
+		if _, err := svc.FareCalendar("BKK", "NRT", day, day, "Economy", "GBP", now); !errors.Is(err, money.ErrRateNotFound) {
+			t.Errorf("expected ErrRateNotFound, got %v", err)
+		}
+	})
+}
+
+func TestService_TaxesAndFees(t *testing.T) {
+	newService := func(f *flight.Flight) *Service {
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		for _, r := range []*tax.Rule{
+			{Code: "SEC", Kind: tax.KindSecurityFee, Amount: usd(5), Refundable: true},
+			{Code: "BOOK", Kind: tax.KindBookingFee, Amount: usd(10)},
+			{Code: "FUEL", Kind: tax.KindFuelSurcharge, Percent: 10},
+			{Code: "SEAT", Kind: tax.KindSeatSelection, Amount: usd(15)},
+		} {
+			if err := svc.Taxes.Set(r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		return svc
+	}
+
+	t.Run("ChosenSeatWithCharges", func(t *testing.T) {
+		f := newFareFlight(t, "TX1", 4)
+		svc := newService(f)
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "TX1", SeatClass: "Economy", SeatID: "A3", BookingDate: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.SeatID != "A3" {
+			t.Errorf("expected seat A3, got %s", bk.SeatID)
+		}
+		codes := make([]string, 0)
+		for _, item := range bk.Charges {
+			codes = append(codes, item.Code)
+		}
+		if len(codes) != 4 || codes[0] != "BOOK" || codes[1] != "FUEL" || codes[2] != "SEAT" || codes[3] != "SEC" {
+			t.Errorf("unexpected charges %+v", bk.Charges)
+		}
+		// BASIC at 250 plus 10 + 25 + 15 + 5
+		if bk.Total() != usd(305) {
+			t.Errorf("expected 305, got %s", bk.Total())
+		}
+		// BASIC is non-refundable; only the security fee comes back
+		if refund := svc.CalculateRefund(bk, time.Now()); refund != usd(5) {
+			t.Errorf("expected 5, got %s", refund)
+		}
+	})
+
+	t.Run("BestSeatHasNoSeatFee", func(t *testing.T) {
+		f := newFareFlight(t, "TX2", 4)
+		svc := newService(f)
+		bk, err := svc.BookSeat("P1", "TX2", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bk.Charges) != 3 || bk.Total() != usd(290) {
+			t.Errorf("expected 290 without the seat fee, got %s with %+v", bk.Total(), bk.Charges)
+		}
+	})
+
+	t.Run("TakenSeat", func(t *testing.T) {
+		f := newFareFlight(t, "TX3", 4)
+		svc := newService(f)
+		if _, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "TX3", SeatClass: "Economy", SeatID: "A1", BookingDate: time.Now()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, err := svc.Book(BookingRequest{PassengerID: "P2", FlightID: "TX3", SeatClass: "Economy", SeatID: "A1", BookingDate: time.Now()})
+		if !errors.Is(err, booking.ErrSeatUnavailable) {
+			t.Errorf("expected ErrSeatUnavailable, got %v", err)
+		}
+		if avail := f.Fares["Economy"].Availability(); avail[1].Available != 1 {
+			t.Errorf("expected the STANDARD fare to be released, got %+v", avail)
+		}
+	})
+
+	t.Run("MissingRateReleasesBooking", func(t *testing.T) {
+		f := newFareFlight(t, "TX4", 1)
+		svc := newService(f)
+		_ = svc.Taxes.Set(&tax.Rule{Code: "EU", Kind: tax.KindAirportTax, Amount: money.New(300, "EUR")})
+		_ = svc.Promotions.Add(&promo.Promo{Code: "TENOFF", Kind: promo.KindPercent, Percent: 10})
+		if err := svc.SetRates(map[string]float64{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "TX4", SeatClass: "Economy", PromoCodes: []string{"TENOFF"}, BookingDate: time.Now()})
+		if !errors.Is(err, money.ErrRateNotFound) {
+			t.Fatalf("expected ErrRateNotFound, got %v", err)
+		}
+		if f.Seats["Economy"][0].IsBookedSeat() || svc.Promotions.Redemptions("TENOFF") != 0 {
+			t.Errorf("expected the seat and promo code to be released")
+		}
+	})
+}
+
+func TestService_Quotes(t *testing.T) {
+	newService := func(f *flight.Flight) *Service {
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		_ = svc.Promotions.Add(&promo.Promo{Code: "TENOFF", Kind: promo.KindPercent, Percent: 10})
+		return svc
+	}
+	request := func(passengerID, flightID string) BookingRequest {
+		return BookingRequest{PassengerID: passengerID, FlightID: flightID, SeatClass: "Economy", BookingDate: time.Now()}
+	}
+
+	t.Run("PriceHeldWhileLoadMoves", func(t *testing.T) {
+		f := newCalendarFlight("Q1", time.Now().AddDate(0, 0, 14), 100, 4)
+		svc := newService(f)
+		req := request("P1", "Q1")
+		req.PromoCodes = []string{"TENOFF"}
+		quoted, err := svc.Quote(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// First of four seats: 125, less 10%
+		if quoted.Quote.Price != usd(125) || quoted.Price != usd(112.5) || quoted.Total != usd(112.5) {
+			t.Errorf("unexpected quote %+v", quoted)
+		}
+		if f.Seats["Economy"][0].IsBookedSeat() || svc.Promotions.Redemptions("TENOFF") != 0 {
+			t.Errorf("quoting should not take a seat or redeem a code")
+		}
+
+		if _, err := svc.BookSeat("P2", "Q1", "Economy", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		req.QuoteToken = quoted.Token
+		bk, err := svc.Book(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.Price != usd(112.5) {
+			t.Errorf("expected the quoted 112.50, got %s", bk.Price)
+		}
+		if _, err := svc.Book(req); !errors.Is(err, quote.ErrQuoteUsed) {
+			t.Errorf("expected ErrQuoteUsed, got %v", err)
+		}
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		f := newCalendarFlight("Q2", time.Now().AddDate(0, 0, 14), 100, 4)
+		svc := newService(f)
+		quoted, _ := svc.Quote(request("P1", "Q2"))
+		req := request("P2", "Q2")
+		req.QuoteToken = quoted.Token
+		if _, err := svc.Book(req); !errors.Is(err, quote.ErrQuoteMatch) {
+			t.Errorf("expected ErrQuoteMatch, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		f := newCalendarFlight("Q3", time.Now().AddDate(0, 0, 14), 100, 4)
+		svc := newService(f)
+		quoted, _ := svc.Quote(request("P1", "Q3"))
+		svc.Clock = func() time.Time { return time.Now().Add(quote.DefaultTTL) }
+		req := request("P1", "Q3")
+		req.QuoteToken = quoted.Token
+		if _, err := svc.Book(req); !errors.Is(err, quote.ErrQuoteExpired) {
+			t.Errorf("expected ErrQuoteExpired, got %v", err)
+		}
+	})
+
+	t.Run("FailedBookingReleasesQuote", func(t *testing.T) {
+		f := newCalendarFlight("Q4", time.Now().AddDate(0, 0, 14), 100, 4)
+		svc := newService(f)
+		quoted, _ := svc.Quote(request("P1", "Q4"))
+		req := request("P1", "Q4")
+		req.QuoteToken = quoted.Token
+		req.PromoCodes = []string{"NOPE"}
+		if _, err := svc.Book(req); !errors.Is(err, promo.ErrPromoNotFound) {
+			t.Fatalf("expected ErrPromoNotFound, got %v", err)
+		}
+		req.PromoCodes = nil
+		if _, err := svc.Book(req); err != nil {
+			t.Errorf("expected the quote to be usable again, got %v", err)
+		}
+	})
+
+	t.Run("HonoursClosedFare", func(t *testing.T) {
+		f := newFareFlight(t, "Q5", 4)
+		svc := newService(f)
+		quoted, err := svc.Quote(request("P1", "Q5"))
+		if err != nil || quoted.Quote.Fare != "BASIC" {
+			t.Fatalf("expected a BASIC quote, got %+v, %v", quoted, err)
+		}
+		policy := &revenue.Policy{Origin: "BKK", Destination: "SIN", Curve: []revenue.Point{{DaysBefore: 0, Load: 0}}, Step: 0.1}
+		if err := svc.SetRevenuePolicy(policy); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Seats sold outside fare inventory put the cabin ahead of the curve
+		f.Seats["Economy"][2].SetBooked(true)
+		if _, err := svc.Book(BookingRequest{PassengerID: "P2", FlightID: "Q5", SeatClass: "Economy", Fare: "BASIC", BookingDate: time.Now()}); !errors.Is(err, fare.ErrFareClosed) {
+			t.Fatalf("expected BASIC to be closed, got %v", err)
+		}
+		req := request("P1", "Q5")
+		req.QuoteToken = quoted.Token
+		bk, err := svc.Book(req)
+		if err != nil || bk.Fare != "BASIC" || bk.Price != quoted.Quote.Price {
+			t.Errorf("expected BASIC at %s, got %+v, %v", quoted.Quote.Price, bk, err)
+		}
+	})
+}
+
+func TestService_Ancillaries(t *testing.T) {
+	newService := func(f *flight.Flight) *Service {
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		for _, p := range []*ancillary.Product{
+			{Code: "BAG", Kind: ancillary.KindCheckedBag, Price: usd(25), MaxPerBooking: 2, Refundable: true},
+			{Code: "MEAL", Kind: ancillary.KindMeal, Price: usd(10), Inventory: 1},
+			{Code: "SEAT", Kind: ancillary.KindSeatSelection, Price: usd(15)},
+		} {
+			if err := svc.Ancillaries.Set(p); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		return svc
+	}
+	departure := time.Now().AddDate(0, 0, 14)
+
+	t.Run("SoldWithBookingAndRefunded", func(t *testing.T) {
+		f := newCalendarFlight("AN1", departure, 100, 4)
+		svc := newService(f)
+		bk, err := svc.Book(BookingRequest{
+			PassengerID: "P1", FlightID: "AN1", SeatClass: "Economy", BookingDate: time.Now(),
+			Ancillaries: []ancillary.Request{{Code: "BAG", Quantity: 2}, {Code: "MEAL"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 125 for the first of four seats, plus 2 x 25 and 10
+		if len(bk.Ancillaries) != 2 || bk.Total() != usd(185) {
+			t.Errorf("expected 185, got %s with %+v", bk.Total(), bk.Ancillaries)
+		}
+		// 80% of the fare and the refundable bags
+		if refund := svc.CalculateRefund(bk, time.Now()); refund != usd(150) {
+			t.Errorf("expected 150, got %s", refund)
+		}
+		if _, err := svc.BookSeat("P2", "AN1", "Economy", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		offers, _ := svc.AncillaryOffers("AN1", "Economy")
+		if len(offers) != 1 || offers[0].Code != "BAG" {
+			t.Errorf("expected only bags on sale after booking, got %+v", offers)
+		}
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		offers, _ = svc.AncillaryOffers("AN1", "Economy")
+		if len(offers) != 2 || offers[1].Code != "MEAL" || *offers[1].Remaining != 1 {
+			t.Errorf("expected the meal back on sale, got %+v", offers)
+		}
+	})
+
+	t.Run("SeatSelectionNeedsChosenSeat", func(t *testing.T) {
+		f := newCalendarFlight("AN2", departure, 100, 4)
+		svc := newService(f)
+		_, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "AN2", SeatClass: "Economy", BookingDate: time.Now(),
+			Ancillaries: []ancillary.Request{{Code: "SEAT"}}})
+		if !errors.Is(err, ancillary.ErrNotApplicable) {
+			t.Errorf("expected ErrNotApplicable, got %v", err)
+		}
+		for _, seat := range f.Seats["Economy"] {
+			if seat.IsBookedSeat() {
+				t.Errorf("expected seat %s to be released", seat.SeatID)
+			}
+		}
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "AN2", SeatClass: "Economy", SeatID: "A2",
+			BookingDate: time.Now(), Ancillaries: []ancillary.Request{{Code: "SEAT"}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.SeatID != "A2" || bk.Total() != usd(140) {
+			t.Errorf("expected A2 for 140, got %s for %s", bk.SeatID, bk.Total())
+		}
+	})
+
+	t.Run("PurchaseAfterBooking", func(t *testing.T) {
+		f := newCalendarFlight("AN3", departure, 100, 4)
+		svc := newService(f)
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "AN3", SeatClass: "Economy", BookingDate: time.Now(),
+			Ancillaries: []ancillary.Request{{Code: "BAG"}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		bought, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "BAG"}, {Code: "MEAL"}}, "", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bought.Ancillaries) != 3 || bought.Total() != usd(185) {
+			t.Errorf("expected 185, got %s with %+v", bought.Total(), bought.Ancillaries)
+		}
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "BAG"}}, "", time.Now()); !errors.Is(err, ancillary.ErrLimitExceeded) {
+			t.Errorf("expected ErrLimitExceeded, got %v", err)
+		}
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "SEAT"}}, "", time.Now()); !errors.Is(err, ancillary.ErrNotApplicable) {
+			t.Errorf("expected ErrNotApplicable, got %v", err)
+		}
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, nil, "", departure); !errors.Is(err, ErrFlightDeparted) {
+			t.Errorf("expected ErrFlightDeparted, got %v", err)
+		}
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "MEAL"}}, "", time.Now()); !errors.Is(err, ErrBookingCancelled) {
+			t.Errorf("expected ErrBookingCancelled, got %v", err)
+		}
+	})
+
+	t.Run("QuoteIncludesAncillaries", func(t *testing.T) {
+		f := newCalendarFlight("AN4", departure, 100, 4)
+		svc := newService(f)
+		quoted, err := svc.Quote(BookingRequest{PassengerID: "P1", FlightID: "AN4", SeatClass: "Economy", BookingDate: time.Now(),
+			Ancillaries: []ancillary.Request{{Code: "MEAL"}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(quoted.Ancillaries) != 1 || quoted.Total != usd(135) {
+			t.Errorf("expected 135, got %s with %+v", quoted.Total, quoted.Ancillaries)
+		}
+		offers, _ := svc.AncillaryOffers("AN4", "Economy")
+		if len(offers) != 2 || *offers[1].Remaining != 1 {
+			t.Errorf("quoting should not take the meal, got %+v", offers)
+		}
+	})
+}
+
+// lockedStorage serializes a storage for tests that book concurrently.
+type lockedStorage struct {
+	mu sync.Mutex
+	passenger.Storage
+}
+
+func (l *lockedStorage) SaveBooking(b *passenger.BookingInfo) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Storage.SaveBooking(b)
+}
+
+func (l *lockedStorage) GetBooking(id string) (*passenger.BookingInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Storage.GetBooking(id)
+}
+
+func (l *lockedStorage) ListBookingsByPassenger(pid string) ([]*passenger.BookingInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Storage.ListBookingsByPassenger(pid)
+}
+
+func (l *lockedStorage) ListBookingsByFlight(fid string) ([]*passenger.BookingInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Storage.ListBookingsByFlight(fid)
+}
+
+// newChangeFlight has four Economy seats at 100 and two Business seats at
+// 300, departing in 14 days.
+func newChangeFlight(id, destination string) *flight.Flight {
+	departure := time.Now().AddDate(0, 0, 14)
+	f := flight.InitializeFlight(id, "BKK", destination, "Airbus A320", departure, departure.Add(2*time.Hour))
+	f.AddSeatClass("Economy", [][]*flight.Seat{{{}, {}, {}, {}}}, usd(100))
+	f.AddSeatClass("Business", [][]*flight.Seat{{{}, {}}}, usd(300))
+	return f
+}
+
+// bookedSeat is a booked seat in row 1, column 1.
+func bookedSeat(id string) *flight.Seat {
+	seat := &flight.Seat{SeatID: id, Row: 1, Column: 1}
+	seat.SetBooked(true)
+	return seat
+}
+
+func bookedSeats(f *flight.Flight, class string) []string {
+	var ids []string
+	for _, seat := range f.SeatSnapshot(flight.SeatClass(class)) {
+		if seat.IsBooked {
+			ids = append(ids, seat.SeatID)
+		}
+	}
+	return ids
+}
+
+func TestService_ChangeBooking(t *testing.T) {
+	newService := func(flights ...*flight.Flight) *Service {
+		return NewService(flights, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+	}
+
+	t.Run("MoveSeat", func(t *testing.T) {
+		f := newChangeFlight("CH1", "CNX")
+		svc := newService(f)
+		bk, err := svc.BookSeat("P1", "CH1", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: "A3", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.SeatID != "A1" {
+			t.Errorf("expected the change made to a copy, got %s", bk.SeatID)
+		}
+		if moved := res.Booking; moved.SeatID != "A3" || moved.Price != usd(125) || !res.Due.IsZero() || !res.ChangeFee.IsZero() {
+			t.Errorf("expected a free move to A3, got %s for %s, due %s", moved.SeatID, moved.Price, res.Due)
+		}
+		if stored, _ := svc.Passengers.GetBooking(bk.BookingID); stored != res.Booking || stored.Status != passenger.StatusConfirmed {
+			t.Errorf("expected the changed booking stored as confirmed, got %+v", stored)
+		}
+		if got := bookedSeats(f, "Economy"); len(got) != 1 || got[0] != "A3" {
+			t.Errorf("expected only A3 booked, got %v", got)
+		}
+
+		other, _ := svc.Book(BookingRequest{PassengerID: "P2", FlightID: "CH1", SeatClass: "Economy", SeatID: "A2", BookingDate: time.Now()})
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: other.SeatID, Now: time.Now()}); !errors.Is(err, booking.ErrSeatUnavailable) {
+			t.Errorf("expected ErrSeatUnavailable, got %v", err)
+		}
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: "A3", Now: time.Now()}); !errors.Is(err, ErrInvalidChange) {
+			t.Errorf("expected ErrInvalidChange, got %v", err)
+		}
+		if stored, _ := svc.Passengers.GetBooking(bk.BookingID); stored.SeatID != "A3" || stored.Status != passenger.StatusConfirmed || len(bookedSeats(f, "Economy")) != 2 {
+			t.Errorf("expected failed moves to change nothing")
+		}
+	})
+
+	t.Run("UpgradeClass", func(t *testing.T) {
+		f := newChangeFlight("CH2", "CNX")
+		svc := newService(f)
+		bk, err := svc.BookSeat("P1", "CH2", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 300 with one of two Business seats sold is 450, up from 125
+		if up := res.Booking; up.SeatClass != "Business" || up.Price != usd(450) || res.FareDifference != usd(325) || res.Due != usd(325) {
+			t.Errorf("unexpected upgrade %+v to %s at %s", res, up.SeatClass, up.Price)
+		}
+		if len(bookedSeats(f, "Economy")) != 0 || len(bookedSeats(f, "Business")) != 1 {
+			t.Errorf("expected the Economy seat released and a Business seat taken")
+		}
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "First", Now: time.Now()}); !errors.Is(err, flight.ErrSeatClassNotFound) {
+			t.Errorf("expected ErrSeatClassNotFound, got %v", err)
+		}
+	})
+
+	t.Run("ChangeFlight", func(t *testing.T) {
+		f1, f2, other := newChangeFlight("CH3", "CNX"), newChangeFlight("CH4", "CNX"), newChangeFlight("CH5", "HKT")
+		svc := newService(f1, f2, other)
+		_ = svc.Ancillaries.Set(&ancillary.Product{Code: "MEAL", Kind: ancillary.KindMeal, Price: usd(10), Inventory: 1})
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "CH3", SeatClass: "Economy", BookingDate: time.Now(),
+			Ancillaries: []ancillary.Request{{Code: "MEAL"}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, FlightID: "CH5", Now: time.Now()}); !errors.Is(err, ErrInvalidChange) {
+			t.Errorf("expected ErrInvalidChange for another route, got %v", err)
+		}
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, FlightID: "CH4", Now: f1.Departure}); !errors.Is(err, ErrFlightDeparted) {
+			t.Errorf("expected ErrFlightDeparted, got %v", err)
+		}
+		res, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, FlightID: "CH4", SeatID: "A4", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if moved := res.Booking; moved.FlightID != "CH4" || moved.SeatID != "A4" || !res.Due.IsZero() {
+			t.Errorf("expected CH4 A4 at no cost, got %s %s due %s", moved.FlightID, moved.SeatID, res.Due)
+		}
+		if len(bookedSeats(f1, "Economy")) != 0 || len(bookedSeats(f2, "Economy")) != 1 {
+			t.Errorf("expected the seat moved from CH3 to CH4")
+		}
+		if offers, _ := svc.AncillaryOffers("CH3", "Economy"); len(offers) != 1 {
+			t.Errorf("expected the meal back on sale on CH3, got %+v", offers)
+		}
+		if offers, _ := svc.AncillaryOffers("CH4", "Economy"); len(offers) != 0 {
+			t.Errorf("expected the meal sold out on CH4, got %+v", offers)
+		}
+	})
+
+	t.Run("FareRules", func(t *testing.T) {
+		newFlight := func(id string) *flight.Flight {
+			f := newChangeFlight(id, "CNX")
+			err := f.SetFareFamilies("Economy", []fare.Family{
+				{Code: "LITE", Price: money.FromFloat(150, "USD")},
+				{Code: "SAVER", Price: money.FromFloat(200, "USD"), Rules: fare.Rules{Changeable: true, ChangeFee: money.FromFloat(50, "USD")}},
+				{Code: "FLEX", Price: money.FromFloat(500, "USD"), Rules: fare.Rules{Refundable: true, RefundPercent: 100, Changeable: true}},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return f
+		}
+		f1, f2 := newFlight("CH6"), newFlight("CH7")
+		svc := newService(f1, f2)
+		lite, _ := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "CH6", SeatClass: "Economy", BookingDate: time.Now()})
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: lite.BookingID, SeatClass: "Business", Now: time.Now()}); !errors.Is(err, ErrChangeNotAllowed) {
+			t.Errorf("expected ErrChangeNotAllowed, got %v", err)
+		}
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: lite.BookingID, SeatID: "A2", Now: time.Now()}); err != nil {
+			t.Errorf("expected a seat move on LITE to be allowed, got %v", err)
+		}
+
+		saver, _ := svc.Book(BookingRequest{PassengerID: "P2", FlightID: "CH6", SeatClass: "Economy", Fare: "SAVER", BookingDate: time.Now()})
+		// 200 with two of four seats sold
+		if saver.Price != usd(300) {
+			t.Fatalf("expected 300, got %s", saver.Price)
+		}
+		res, err := svc.ChangeBooking(ChangeRequest{BookingID: saver.BookingID, Fare: "FLEX", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// FLEX at 500 with the same two seats sold is 750: 450 more and the SAVER fee
+		if res.Booking.Fare != "FLEX" || res.FareDifference != usd(450) || res.ChangeFee != usd(50) || res.Due != usd(500) {
+			t.Errorf("unexpected change %+v", res)
+		}
+		if avail := f1.Fares["Economy"].Availability(); avail[1].Available != -1 || len(bookedSeats(f1, "Economy")) != 2 {
+			t.Errorf("expected the seat kept and SAVER released, got %+v", avail)
+		}
+
+		// A cheaper flight gives nothing back on a non-refundable fare.
+		later, _ := svc.Book(BookingRequest{PassengerID: "P3", FlightID: "CH6", SeatClass: "Economy", Fare: "SAVER", BookingDate: time.Now()})
+		res, err = svc.ChangeBooking(ChangeRequest{BookingID: later.BookingID, FlightID: "CH7", Fare: "SAVER", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 350 on CH6 against 250 on CH7, plus the 50 fee
+		if res.FareDifference != usd(-100) || !res.Due.IsZero() {
+			t.Errorf("expected no money back, got %+v", res)
+		}
+	})
+
+	t.Run("CancelledBooking", func(t *testing.T) {
+		f := newChangeFlight("CH8", "CNX")
+		svc := newService(f)
+		bk, _ := svc.BookSeat("P1", "CH8", "Economy", time.Now())
+		_ = svc.CancelBooking(bk.BookingID, time.Now())
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: "A3", Now: time.Now()}); !errors.Is(err, ErrBookingCancelled) {
+			t.Errorf("expected ErrBookingCancelled, got %v", err)
+		}
+	})
+
+	t.Run("BookingBeingChanged", func(t *testing.T) {
+		f := newChangeFlight("CH11", "CNX")
+		svc := newService(f)
+		bk, _ := svc.BookSeat("P1", "CH11", "Economy", time.Now())
+		if _, ok, _ := passenger.SwapStatus(svc.Passengers, bk.BookingID, passenger.StatusConfirmed, passenger.StatusChanging); !ok {
+			t.Fatal("expected the booking marked as changing")
+		}
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: "A3", Now: time.Now()}); !errors.Is(err, ErrBookingChanged) {
+			t.Errorf("expected ErrBookingChanged, got %v", err)
+		}
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); !errors.Is(err, ErrBookingChanged) {
+			t.Errorf("expected ErrBookingChanged, got %v", err)
+		}
+		if got := bookedSeats(f, "Economy"); len(got) != 1 || got[0] != bk.SeatID {
+			t.Errorf("expected the seat kept, got %v", got)
+		}
+	})
+
+	// Meant to be run with -race: changes and a cancellation of the same
+	// booking race, and the seats must end up matching what was stored.
+	t.Run("ConcurrentChangesAndCancel", func(t *testing.T) {
+		for round := 0; round < 20; round++ {
+			f := newChangeFlight("CH12", "CNX")
+			svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+			bk, err := svc.BookSeat("P1", "CH12", "Economy", time.Now())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var wg sync.WaitGroup
+			for _, seatID := range []string{"A2", "A3", "A4", ""} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					var err error
+					if seatID == "" {
+						err = svc.CancelBooking(bk.BookingID, time.Now())
+					} else {
+						_, err = svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: seatID, Now: time.Now()})
+					}
+					if err != nil && !errors.Is(err, ErrBookingChanged) && !errors.Is(err, ErrBookingCancelled) && !errors.Is(err, ErrInvalidChange) {
+						t.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			stored, _ := svc.Passengers.GetBooking(bk.BookingID)
+			booked := bookedSeats(f, "Economy")
+			switch stored.Status {
+			case passenger.StatusCancelled:
+				if len(booked) != 0 {
+					t.Fatalf("expected a cancelled booking to hold no seat, got %v", booked)
+				}
+			case passenger.StatusConfirmed:
+				if len(booked) != 1 || booked[0] != stored.SeatID {
+					t.Fatalf("expected only %s booked, got %v", stored.SeatID, booked)
+				}
+			default:
+				t.Fatalf("expected the booking settled, got %s", stored.Status)
+			}
+		}
+	})
+
+	t.Run("ConcurrentSwapsDoNotDeadlock", func(t *testing.T) {
+		f1, f2 := newChangeFlight("CH9", "CNX"), newChangeFlight("CH10", "CNX")
+		svc := NewService([]*flight.Flight{f1, f2}, &lockedStorage{Storage: &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}})
+		var ids []string
+		for i, id := range []string{"CH9", "CH10"} {
+			for _, class := range []string{"Economy", "Business"} {
+				bk, err := svc.BookSeat(fmt.Sprintf("P%d%s", i, class), id, class, time.Now())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				ids = append(ids, bk.BookingID)
+			}
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var wg sync.WaitGroup
+			for i, id := range ids {
+				wg.Add(1)
+				go func(i int, id string) {
+					defer wg.Done()
+					for n := 0; n < 50; n++ {
+						// Alternate between flights and classes so changes cross
+						// the same cabins in opposite directions.
+						flightID, class := "CH9", "Economy"
+						if (i+n)%2 == 0 {
+							flightID = "CH10"
+						}
+						if (i/2+n)%2 == 0 {
+							class = "Business"
+						}
+						_, _ = svc.ChangeBooking(ChangeRequest{BookingID: id, FlightID: flightID, SeatClass: class, Now: time.Now()})
+					}
+				}(i, id)
+			}
+			wg.Wait()
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("changes deadlocked")
+		}
+
+		seats := make(map[string]bool)
+		for _, id := range ids {
+			bk, _ := svc.Passengers.GetBooking(id)
+			f := svc.FindFlightByID(bk.FlightID)
+			key := bk.FlightID + "/" + bk.SeatClass + "/" + bk.SeatID
+			if seats[key] || findSeat(f, bk.SeatClass, bk.SeatID) == nil || !findSeat(f, bk.SeatClass, bk.SeatID).IsBookedSeat() {
+				t.Errorf("booking %s holds %s %s %s twice or unbooked", id, bk.FlightID, bk.SeatClass, bk.SeatID)
+			}
+			seats[key] = true
+		}
+		if n := len(bookedSeats(f1, "Economy")) + len(bookedSeats(f1, "Business")) + len(bookedSeats(f2, "Economy")) + len(bookedSeats(f2, "Business")); n != len(ids) {
+			t.Errorf("expected %d booked seats, got %d", len(ids), n)
+		}
+	})
+}
+
+func TestService_Payments(t *testing.T) {
+	newService := func(f *flight.Flight) (*Service, *payment.Fake) {
+		svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		gateway := payment.NewFake()
+		svc.Payments = gateway
+		_ = svc.Promotions.Add(&promo.Promo{Code: "TENOFF", Kind: promo.KindPercent, Percent: 10})
+		_ = svc.Ancillaries.Set(&ancillary.Product{Code: "MEAL", Kind: ancillary.KindMeal, Price: usd(10), Inventory: 1})
+		return svc, gateway
+	}
+
+	t.Run("CapturedBeforeConfirming", func(t *testing.T) {
+		f := newChangeFlight("PY1", "CNX")
+		svc, gateway := newService(f)
+		bk, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "PY1", SeatClass: "Economy", BookingDate: time.Now(), PaymentMethod: "tok_visa"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bk.Status != passenger.StatusConfirmed || len(bk.Payments) != 1 || bk.Payments[0].Amount != bk.Total() {
+			t.Errorf("expected a confirmed booking paid in full, got %s with %+v", bk.Status, bk.Payments)
+		}
+		if p, _ := gateway.Payment(bk.Payments[0].ID); p.Status != payment.StatusCaptured || p.Reference != bk.BookingID {
+			t.Errorf("unexpected payment %+v", p)
+		}
+	})
+
+	for name, method := range map[string]string{"Declined": payment.FakeDeclined, "CaptureFails": payment.FakeCaptureFail} {
+		t.Run(name+"ReleasesBooking", func(t *testing.T) {
+			f := newChangeFlight("PY2", "CNX")
+			svc, gateway := newService(f)
+			_, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "PY2", SeatClass: "Economy", BookingDate: time.Now(),
+				PromoCodes: []string{"TENOFF"}, Ancillaries: []ancillary.Request{{Code: "MEAL"}}, PaymentMethod: method})
+			if !errors.Is(err, payment.ErrDeclined) && !errors.Is(err, payment.ErrCaptureFailed) {
+				t.Fatalf("expected a payment error, got %v", err)
+			}
+			if len(bookedSeats(f, "Economy")) != 0 || svc.Promotions.Redemptions("TENOFF") != 0 {
+				t.Errorf("expected the seat and promo code released")
+			}
+			if offers, _ := svc.AncillaryOffers("PY2", "Economy"); len(offers) != 1 {
+				t.Errorf("expected the meal back on sale, got %+v", offers)
+			}
+			bookings, _ := svc.Passengers.ListBookingsByFlight("PY2")
+			if len(bookings) != 1 || bookings[0].Status != passenger.StatusFailed {
+				t.Fatalf("expected one failed booking, got %+v", bookings)
+			}
+			if err := svc.CancelBooking(bookings[0].BookingID, time.Now()); !errors.Is(err, ErrBookingNotConfirmed) {
+				t.Errorf("expected ErrBookingNotConfirmed, got %v", err)
+			}
+			if method == payment.FakeCaptureFail {
+				if p, _ := gateway.Payment("pay_1"); p.Status != payment.StatusVoided {
+					t.Errorf("expected the authorization voided, got %s", p.Status)
+				}
+			}
+		})
+	}
+
+	t.Run("CancelRefundsOnce", func(t *testing.T) {
+		f := newChangeFlight("PY3", "CNX")
+		svc, gateway := newService(f)
+		bk, err := svc.BookSeat("P1", "PY3", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		retry := *bk
+		retry.Payments = append([]passenger.Payment(nil), bk.Payments...)
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 80% of 125
+		stored, _ := svc.Passengers.GetBooking(bk.BookingID)
+		if p, _ := gateway.Payment(bk.Payments[0].ID); p.Refunded != usd(100) || stored.Payments[0].Refunded != usd(100) {
+			t.Errorf("expected 100 refunded, got %s", p.Refunded)
+		}
+		// A retry that lost the first refund's result refunds nothing more.
+		if err := svc.refund(&retry, usd(100), "cancel"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p, _ := gateway.Payment(bk.Payments[0].ID); p.Refunded != usd(100) {
+			t.Errorf("expected the retried refund to be a no-op, got %s refunded", p.Refunded)
+		}
+	})
+
+	t.Run("LaterPurchasesAndChanges", func(t *testing.T) {
+		f := newChangeFlight("PY4", "CNX")
+		svc, gateway := newService(f)
+		bk, err := svc.BookSeat("P1", "PY4", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "MEAL"}}, payment.FakeDeclined, time.Now()); !errors.Is(err, payment.ErrDeclined) {
+			t.Errorf("expected ErrDeclined, got %v", err)
+		}
+		if offers, _ := svc.AncillaryOffers("PY4", "Economy"); len(offers) != 1 || len(bk.Ancillaries) != 0 {
+			t.Errorf("expected a declined purchase to release the meal")
+		}
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "MEAL"}}, "", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", PaymentMethod: payment.FakeDeclined, Now: time.Now()}); !errors.Is(err, payment.ErrDeclined) {
+			t.Errorf("expected ErrDeclined, got %v", err)
+		}
+		if stored, _ := svc.Passengers.GetBooking(bk.BookingID); stored.SeatClass != "Economy" || stored.Status != passenger.StatusConfirmed || len(bookedSeats(f, "Business")) != 0 {
+			t.Errorf("expected a declined upgrade to change nothing")
+		}
+		up, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 125 for the seat, 10 for the meal and 325 for the upgrade
+		if payments := up.Booking.Payments; len(payments) != 3 || payments[2].Amount != usd(325) {
+			t.Fatalf("expected three payments, got %+v", payments)
+		}
+		res, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Economy", Now: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Back to 125 in Economy, refunded from the upgrade payment
+		if res.Due != usd(-325) {
+			t.Errorf("expected 325 back, got %s", res.Due)
+		}
+		if p, _ := gateway.Payment(res.Booking.Payments[2].ID); p.Refunded != usd(325) || res.Booking.Payments[2].Refunded != usd(325) {
+			t.Errorf("expected the upgrade payment refunded, got %+v", p)
+		}
+	})
+}
+
+func TestService_Policies(t *testing.T) {
+	f := newChangeFlight("PO1", "CNX")
+	svc := NewService([]*flight.Flight{f}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+	svc.Pricing = flight.Pricing{EarlyDays: 10, EarlyDiscount: 20, LateDays: 3, FrequentFlyerDiscount: 5}
+	svc.DefaultRules = fare.Rules{Refundable: true, RefundPercent: 50}
+	svc.FrequentFlyerBookings = 1
+	now := time.Now()
+	svc.QuoteTTL = time.Minute
+	svc.Clock = func() time.Time { return now }
+
+	// 20% off 14 days out, then the first of four seats: 80 * 5/4
+	first, err := svc.BookSeat("P1", "PO1", "Economy", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.Price != usd(100) {
+		t.Errorf("expected 100, got %s", first.Price)
+	}
+	if refund := svc.CalculateRefund(first, now); refund != usd(50) {
+		t.Errorf("expected half refunded, got %s", refund)
+	}
+
+	// One booking is enough for the frequent flyer discount: 80 * 6/4, less 5%
+	second, err := svc.BookSeat("P1", "PO1", "Economy", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Price != usd(114) {
+		t.Errorf("expected 114, got %s", second.Price)
+	}
+
+	quoted, err := svc.Quote(BookingRequest{PassengerID: "P2", FlightID: "PO1", SeatClass: "Economy", BookingDate: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !quoted.Quote.ExpiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the quote to expire in a minute, got %v", quoted.Quote.ExpiresAt)
+	}
+}
+
+var errSaveFailed = errors.New("save failed")
+
+// flakyOutbox cannot list its pending records while fail is set.
+type flakyOutbox struct {
+	event.Outbox
+	fail bool
+}
+
+func (o *flakyOutbox) Pending() ([]event.Record, error) {
+	if o.fail {
+		return nil, errors.New("outbox unavailable")
+	}
+	return o.Outbox.Pending()
+}
+
+// failingStorage refuses to save the bookings fail matches.
+type failingStorage struct {
+	passenger.Storage
+	fail func(*passenger.BookingInfo) bool
+}
+
+func (f *failingStorage) SaveBooking(b *passenger.BookingInfo) error {
+	if f.fail != nil && f.fail(b) {
+		return errSaveFailed
+	}
+	return f.Storage.SaveBooking(b)
+}
+
+func TestService_UnitOfWork(t *testing.T) {
+	newService := func(f *flight.Flight) (*Service, *failingStorage, *payment.Fake) {
+		storage := &failingStorage{Storage: &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}}
+		svc := NewService([]*flight.Flight{f}, storage)
+		gateway := payment.NewFake()
+		svc.Payments = gateway
+		_ = svc.Promotions.Add(&promo.Promo{Code: "TENOFF", Kind: promo.KindPercent, Percent: 10})
+		_ = svc.Ancillaries.Set(&ancillary.Product{Code: "MEAL", Kind: ancillary.KindMeal, Price: usd(10), Inventory: 1, Refundable: true})
+		return svc, storage, gateway
+	}
+	withStatus := func(status string) func(*passenger.BookingInfo) bool {
+		return func(b *passenger.BookingInfo) bool { return b.Status == status }
+	}
+	mealOnSale := func(svc *Service, flightID string) bool {
+		offers, _ := svc.AncillaryOffers(flightID, "Economy")
+		return len(offers) == 1
+	}
+
+	t.Run("BookGivesEverythingBack", func(t *testing.T) {
+		f := newChangeFlight("UW1", "CNX")
+		svc, storage, _ := newService(f)
+		storage.fail = withStatus(passenger.StatusPending)
+		_, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "UW1", SeatClass: "Economy", BookingDate: time.Now(),
+			PromoCodes: []string{"TENOFF"}, Ancillaries: []ancillary.Request{{Code: "MEAL"}}})
+		if !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		if len(bookedSeats(f, "Economy")) != 0 || svc.Promotions.Redemptions("TENOFF") != 0 || !mealOnSale(svc, "UW1") {
+			t.Errorf("expected the seat, promo code and meal released")
+		}
+		if bookings, _ := svc.Passengers.ListBookingsByFlight("UW1"); len(bookings) != 0 {
+			t.Errorf("expected nothing stored, got %+v", bookings)
+		}
+	})
+
+	t.Run("UnconfirmedBookingRefunded", func(t *testing.T) {
+		f := newChangeFlight("UW2", "CNX")
+		svc, storage, gateway := newService(f)
+		storage.fail = withStatus(passenger.StatusConfirmed)
+		if _, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "UW2", SeatClass: "Economy", BookingDate: time.Now()}); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		bookings, _ := svc.Passengers.ListBookingsByFlight("UW2")
+		if len(bookings) != 1 || bookings[0].Status != passenger.StatusFailed {
+			t.Fatalf("expected the booking stored as failed, got %+v", bookings)
+		}
+		if p, _ := gateway.Payment(bookings[0].Payments[0].ID); p.Refunded != p.Amount {
+			t.Errorf("expected the payment refunded, got %+v", p)
+		}
+		if len(bookedSeats(f, "Economy")) != 0 {
+			t.Errorf("expected the seat released")
+		}
+	})
+
+	t.Run("CancelKeepsSeatUntilStored", func(t *testing.T) {
+		f := newChangeFlight("UW3", "CNX")
+		svc, storage, gateway := newService(f)
+		bk, err := svc.BookSeat("P1", "UW3", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		storage.fail = withStatus(passenger.StatusCancelled)
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		if bk.Status != passenger.StatusConfirmed || len(bookedSeats(f, "Economy")) != 1 {
+			t.Errorf("expected the booking to keep its seat, got %s", bk.Status)
+		}
+		storage.fail = nil
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bookedSeats(f, "Economy")) != 0 {
+			t.Errorf("expected the seat released")
+		}
+		// 80% of 125, refunded once across both attempts
+		if p, _ := gateway.Payment(bk.Payments[0].ID); p.Refunded != usd(100) {
+			t.Errorf("expected 100 refunded, got %s", p.Refunded)
+		}
+	})
+
+	t.Run("PurchaseRefundedWhenNotStored", func(t *testing.T) {
+		f := newChangeFlight("UW4", "CNX")
+		svc, storage, gateway := newService(f)
+		bk, err := svc.BookSeat("P1", "UW4", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		storage.fail = func(b *passenger.BookingInfo) bool { return len(b.Ancillaries) > 0 }
+		if _, err := svc.PurchaseAncillaries(bk.BookingID, []ancillary.Request{{Code: "MEAL"}}, "", time.Now()); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		stored, _ := svc.Passengers.GetBooking(bk.BookingID)
+		if len(stored.Ancillaries) != 0 || stored.Status != passenger.StatusConfirmed || !mealOnSale(svc, "UW4") {
+			t.Errorf("expected the meal released, got %+v", stored.Ancillaries)
+		}
+		if len(stored.Payments) != 1 {
+			t.Fatalf("expected only the fare payment stored, got %+v", stored.Payments)
+		}
+		if p, _ := gateway.Payment("pay_2"); p.Amount != usd(10) || p.Refunded != usd(10) {
+			t.Errorf("expected the meal refunded, got %+v", p)
+		}
+	})
+
+	t.Run("UpgradeRefundedWhenNotStored", func(t *testing.T) {
+		f := newChangeFlight("UW5", "CNX")
+		svc, storage, gateway := newService(f)
+		bk, err := svc.BookSeat("P1", "UW5", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		storage.fail = func(b *passenger.BookingInfo) bool { return b.SeatClass == "Business" }
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()}); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		stored, _ := svc.Passengers.GetBooking(bk.BookingID)
+		if stored.SeatClass != "Economy" || stored.Status != passenger.StatusConfirmed || len(stored.Payments) != 1 {
+			t.Errorf("expected the booking left as it was, got %+v", stored)
+		}
+		if p, _ := gateway.Payment("pay_2"); p.Amount != usd(325) || p.Refunded != usd(325) {
+			t.Errorf("expected the upgrade refunded, got %+v", p)
+		}
+		if len(bookedSeats(f, "Economy")) != 1 || len(bookedSeats(f, "Business")) != 0 {
+			t.Errorf("expected the booking to keep its Economy seat only")
+		}
+		// The Economy seat is back at the version the stored booking holds.
+		storage.fail = nil
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(bookedSeats(f, "Economy")) != 0 || len(bookedSeats(f, "Business")) != 1 {
+			t.Errorf("expected the retried upgrade to move the booking to Business")
+		}
+	})
+
+	t.Run("DowngradeRefundedOnce", func(t *testing.T) {
+		f := newChangeFlight("UW6", "CNX")
+		svc, storage, gateway := newService(f)
+		bk, err := svc.BookSeat("P1", "UW6", "Business", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		storage.fail = func(b *passenger.BookingInfo) bool { return b.SeatClass == "Economy" }
+		req := ChangeRequest{BookingID: bk.BookingID, SeatClass: "Economy", Now: time.Now()}
+		if _, err := svc.ChangeBooking(req); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		storage.fail = nil
+		res, err := svc.ChangeBooking(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.Due.Amount >= 0 {
+			t.Fatalf("expected a refund, got %s due", res.Due)
+		}
+		if p, _ := gateway.Payment("pay_1"); p.Refunded.Amount != -res.Due.Amount || res.Booking.Payments[0].Refunded != p.Refunded {
+			t.Errorf("expected %s refunded once and stored, got %s and %s", res.Due, p.Refunded, res.Booking.Payments[0].Refunded)
+		}
+		// The next change is a new refund, not a replay of this one.
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res, err = svc.ChangeBooking(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stored, _ := svc.Passengers.GetBooking(bk.BookingID)
+		var refunded int64
+		for _, p := range stored.Payments {
+			got, _ := gateway.Payment(p.ID)
+			if got.Refunded != p.Refunded {
+				t.Errorf("expected %s refunded on %s as stored, got %s", p.Refunded, p.ID, got.Refunded)
+			}
+			refunded += p.Refunded.Amount
+		}
+		if refunded == 0 || stored.Changes != 3 {
+			t.Errorf("expected three changes with refunds, got %d and %d", stored.Changes, refunded)
+		}
+	})
+}
+
+// recordEvents subscribes to the events of kinds svc publishes, every event
+// when no kinds are given.
+func recordEvents(svc *Service, kinds ...event.Kind) *[]event.Record {
+	var records []event.Record
+	svc.Events.Subscribe(func(r event.Record) { records = append(records, r) }, kinds...)
+	return &records
+}
+
+func kinds(records []event.Record) []event.Kind {
+	var got []event.Kind
+	for _, r := range records {
+		got = append(got, r.Event.Kind())
+	}
+	return got
+}
+
+func TestService_Events(t *testing.T) {
+	newService := func(f *flight.Flight) (*Service, *failingStorage) {
+		storage := &failingStorage{Storage: &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}}}
+		return NewService([]*flight.Flight{f}, storage), storage
+	}
+
+	t.Run("BookAndCancel", func(t *testing.T) {
+		f := newChangeFlight("EV1", "CNX")
+		svc, _ := newService(f)
+		records := recordEvents(svc, event.KindBookingConfirmed, event.KindBookingCancelled, event.KindSeatReleased)
+		bk, err := svc.BookSeat("P1", "EV1", "Economy", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := kinds(*records); len(got) != 1 || got[0] != event.KindBookingConfirmed {
+			t.Fatalf("expected BookingConfirmed, got %v", got)
+		}
+		if e := (*records)[0].Event.(event.BookingConfirmed); e.BookingID != bk.BookingID || e.SeatID != bk.SeatID || e.Total != bk.Total() {
+			t.Errorf("expected the confirmed booking, got %+v", e)
+		}
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := kinds(*records)
+		if len(got) != 3 || got[1] != event.KindBookingCancelled || got[2] != event.KindSeatReleased {
+			t.Fatalf("expected BookingCancelled then SeatReleased, got %v", got)
+		}
+		if e := (*records)[2].Event.(event.SeatReleased); e.SeatID != bk.SeatID || e.FlightID != "EV1" {
+			t.Errorf("expected %s released, got %+v", bk.SeatID, e)
+		}
+		for i, r := range *records {
+			if r.ID != uint64(i+1) {
+				t.Errorf("expected record IDs in order, got %d at %d", r.ID, i)
+			}
+		}
+		if pending, _ := svc.Outbox.Pending(); len(pending) != 0 {
+			t.Errorf("expected the outbox to be empty, got %d", len(pending))
+		}
+	})
+
+	t.Run("CommittedWithTheBooking", func(t *testing.T) {
+		f := newChangeFlight("EV5", "CNX")
+		storage := passenger.NewInMemoryStorage()
+		svc := NewService([]*flight.Flight{f}, storage)
+		if svc.Outbox != storage.Outbox() {
+			t.Fatal("expected the service to relay the storage's outbox")
+		}
+		var seen string
+		svc.Events.Subscribe(func(r event.Record) {
+			bk, _ := storage.GetBooking(r.Event.(event.BookingConfirmed).BookingID)
+			seen = bk.Status
+		}, event.KindBookingConfirmed)
+		if _, err := svc.BookSeat("P1", "EV5", "Economy", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if seen != passenger.StatusConfirmed {
+			t.Errorf("expected the booking stored as confirmed before the event, got %q", seen)
+		}
+		if pending, _ := storage.Outbox().Pending(); len(pending) != 0 {
+			t.Errorf("expected the relay to mark the event published, got %d pending", len(pending))
+		}
+	})
+
+	t.Run("FailedRelayIsRetried", func(t *testing.T) {
+		f := newChangeFlight("EV6", "CNX")
+		svc, _ := newService(f)
+		outbox := &flakyOutbox{Outbox: event.NewInMemoryOutbox(), fail: true}
+		svc.Outbox = outbox
+		var logs bytes.Buffer
+		svc.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+		records := recordEvents(svc, event.KindBookingConfirmed)
+		if _, err := svc.BookSeat("P1", "EV6", "Economy", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(*records) != 0 || !strings.Contains(logs.String(), "relaying events failed") {
+			t.Fatalf("expected the failed relay logged and nothing published, got %v and %q", kinds(*records), logs.String())
+		}
+		outbox.fail = false
+		if err := svc.RelayEvents(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := kinds(*records); len(got) != 1 || got[0] != event.KindBookingConfirmed {
+			t.Errorf("expected the event left behind published, got %v", got)
+		}
+	})
+
+	t.Run("NothingUntilStored", func(t *testing.T) {
+		f := newChangeFlight("EV2", "CNX")
+		svc, storage := newService(f)
+		records := recordEvents(svc)
+		storage.fail = func(b *passenger.BookingInfo) bool { return b.Status == passenger.StatusConfirmed }
+		if _, err := svc.BookSeat("P1", "EV2", "Economy", time.Now()); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		if _, err := svc.Book(BookingRequest{PassengerID: "P1", FlightID: "EV2", SeatClass: "Economy", BookingDate: time.Now(), PaymentMethod: payment.FakeDeclined}); !errors.Is(err, payment.ErrDeclined) {
+			t.Fatalf("expected ErrDeclined, got %v", err)
+		}
+		storage.fail = nil
+		bk, _ := svc.BookSeat("P1", "EV2", "Economy", time.Now())
+		storage.fail = func(b *passenger.BookingInfo) bool { return b.Status == passenger.StatusCancelled }
+		if err := svc.CancelBooking(bk.BookingID, time.Now()); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		if got := kinds(*records); len(got) != 2 || got[0] != event.KindBookingConfirmed || got[1] != event.KindPriceChanged {
+			t.Errorf("expected only the stored booking announced, got %v", got)
+		}
+	})
+
+	t.Run("MoveSeat", func(t *testing.T) {
+		f := newChangeFlight("EV3", "CNX")
+		svc, _ := newService(f)
+		bk, _ := svc.BookSeat("P1", "EV3", "Economy", time.Now())
+		from := bk.SeatID
+		records := recordEvents(svc, event.KindSeatReleased)
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: "A3", Now: time.Now()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(*records) != 1 {
+			t.Fatalf("expected SeatReleased, got %v", kinds(*records))
+		}
+		if e, ok := (*records)[0].Event.(event.SeatReleased); !ok || e.SeatID != from || e.BookingID != bk.BookingID {
+			t.Errorf("expected %s released, got %+v", from, (*records)[0].Event)
+		}
+	})
+
+	t.Run("ChangeCommittedWithItsEvents", func(t *testing.T) {
+		f := newChangeFlight("EV7", "CNX")
+		svc, storage := newService(f)
+		bk, _ := svc.BookSeat("P1", "EV7", "Economy", time.Now())
+		records := recordEvents(svc, event.KindSeatReleased, event.KindPriceChanged)
+		var class string
+		svc.Events.Subscribe(func(event.Record) {
+			stored, _ := svc.Passengers.GetBooking(bk.BookingID)
+			class = stored.SeatClass
+		}, event.KindSeatReleased)
+		storage.fail = func(b *passenger.BookingInfo) bool { return b.SeatClass == "Business" }
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()}); !errors.Is(err, errSaveFailed) {
+			t.Fatalf("expected errSaveFailed, got %v", err)
+		}
+		if len(*records) != 0 {
+			t.Fatalf("expected nothing announced for an unsaved change, got %v", kinds(*records))
+		}
+		storage.fail = nil
+		if _, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatClass: "Business", Now: time.Now()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := kinds(*records)
+		if len(got) != 3 || got[0] != event.KindSeatReleased || got[1] != event.KindPriceChanged || got[2] != event.KindPriceChanged {
+			t.Fatalf("expected SeatReleased and both cabins' fares moved, got %v", got)
+		}
+		if class != "Business" {
+			t.Errorf("expected the change stored before SeatReleased, got %q", class)
+		}
+	})
+
+	t.Run("PriceChanged", func(t *testing.T) {
+		f := newChangeFlight("EV4", "CNX")
+		svc, _ := newService(f)
+		var changes []event.PriceChanged
+		svc.Events.Subscribe(func(r event.Record) { changes = append(changes, r.Event.(event.PriceChanged)) }, event.KindPriceChanged)
+		first, _ := svc.BookSeat("P1", "EV4", "Business", time.Now())
+		if len(changes) != 1 || changes[0].SeatClass != "Business" || changes[0].Price == nil || changes[0].Previous == nil ||
+			!changes[0].Previous.Less(*changes[0].Price) {
+			t.Fatalf("expected the Business fare to go up with its load, got %+v", changes)
+		}
+		if _, err := svc.BookSeat("P2", "EV4", "Business", time.Now()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(changes) != 2 || changes[1].Price != nil || *changes[1].Previous != *changes[0].Price {
+			t.Fatalf("expected Business to sell out, got %+v", changes)
+		}
+		_ = svc.CancelBooking(first.BookingID, time.Now())
+		if len(changes) != 3 || changes[2].Price == nil || changes[2].Previous != nil {
+			t.Errorf("expected Business back on sale, got %+v", changes)
+		}
+	})
+
+	t.Run("RevenuePolicy", func(t *testing.T) {
+		f := newFareFlight(t, "EV5", 4)
+		svc, _ := newService(f)
+		f.Seats["Economy"][0].SetBooked(true)
+		f.Seats["Economy"][1].SetBooked(true)
+		records := recordEvents(svc, event.KindPriceChanged)
+		err := svc.SetRevenuePolicy(&revenue.Policy{Origin: "BKK", Destination: "SIN",
+			Curve: []revenue.Point{{DaysBefore: 0, Load: 0.5}, {DaysBefore: 60, Load: 0}}, Step: 0.1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(*records) != 1 {
+			t.Fatalf("expected the Economy fare to move, got %+v", *records)
+		}
+		closed := (*records)[0].Event.(event.PriceChanged)
+		if closed.SeatClass != "Economy" || !closed.Previous.Less(*closed.Price) {
+			t.Errorf("expected closing BASIC and STANDARD to raise the Economy fare, got %+v", closed)
+		}
+		_ = svc.DeleteRevenuePolicy("bkk", "sin", "")
+		if len(*records) != 2 || *(*records)[1].Event.(event.PriceChanged).Price != *closed.Previous {
+			t.Errorf("expected the Economy fare back once the policy is removed, got %+v", *records)
+		}
+	})
+
+	t.Run("FlightRescheduled", func(t *testing.T) {
+		now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+		svc := NewService([]*flight.Flight{}, &mockPassengerStorage{bookings: map[string]*passenger.BookingInfo{}})
+		svc.ScheduleHorizon = 3 * 24 * time.Hour
+		_, _ = svc.AddSchedule(newTestSchedule(now, now.AddDate(0, 1, 0)), now)
+		records := recordEvents(svc)
+		if err := svc.AddScheduleException("TG100", schedule.Exception{Date: "2024-07-03", Departure: "10:30"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(*records) != 1 {
+			t.Fatalf("expected FlightRescheduled, got %v", kinds(*records))
+		}
+		e := (*records)[0].Event.(event.FlightRescheduled)
+		if e.Departure.Sub(e.PreviousDeparture) != 150*time.Minute {
+			t.Errorf("expected the departure moved from 08:00 to 10:30, got %v to %v", e.PreviousDeparture, e.Departure)
+		}
+	})
+}
+
+// TestService_ConcurrentInventory is meant to be run with -race: bookings
+// are made, moved and cancelled while the seat inventory is read.
+func TestService_ConcurrentInventory(t *testing.T) {
+	for _, mode := range []InventoryMode{InventoryLocked, InventoryOptimistic, InventoryActor} {
+		t.Run(string(mode), func(t *testing.T) {
+			f := newChangeFlight("RACE1", "CNX")
+			svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+			svc.Inventory = mode
+			t.Cleanup(svc.StopActors)
+			now := time.Now()
+
+			var wg sync.WaitGroup
+			for i := 0; i < 6; i++ {
+				wg.Add(1)
+				go func(passengerID string, seatID string) {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						bk, err := svc.BookSeat(passengerID, "RACE1", "Economy", now)
+						if errors.Is(err, booking.ErrNoSeatAvailable) || errors.Is(err, booking.ErrSeatConflict) {
+							continue
+						}
+						if err != nil {
+							t.Errorf("unexpected error: %v", err)
+							return
+						}
+						_, _ = svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: seatID, Now: now})
+						if err := svc.CancelBooking(bk.BookingID, now); err != nil {
+							t.Errorf("unexpected error: %v", err)
+						}
+					}
+				}(fmt.Sprintf("P%d", i), fmt.Sprintf("A%d", i%4+1))
+			}
+			for i := 0; i < 3; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						a, _ := f.Availability("Economy")
+						if a.Total != 4 || a.Booked+a.Available != 4 {
+							t.Errorf("inconsistent availability %+v", a)
+						}
+						_ = bookedSeats(f, "Economy")
+						_ = svc.FareAvailability(f, "Economy", now)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if booked := bookedSeats(f, "Economy"); len(booked) != 0 {
+				t.Errorf("expected every seat released, got %v", booked)
+			}
+		})
+	}
+}
+
+// TestService_ConcurrentCancel is meant to be run with -race: a booking of
+// the only seat is cancelled from several goroutines while another
+// passenger waits to book the seat it frees. One cancellation wins, and the
+// losers must not refund again or free the seat the next passenger took.
+func TestService_ConcurrentCancel(t *testing.T) {
+	for _, mode := range []InventoryMode{InventoryLocked, InventoryOptimistic, InventoryActor} {
+		t.Run(string(mode), func(t *testing.T) {
+			f := newBenchFlight(1, 1)
+			svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+			svc.Inventory = mode
+			t.Cleanup(svc.StopActors)
+			now := time.Now()
+			var cancelled atomic.Int32
+			svc.Events.Subscribe(func(event.Record) { cancelled.Add(1) }, event.KindBookingCancelled)
+
+			bk, err := svc.BookSeat("P0", "BENCH1", "Economy", now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for round := 1; round <= 20; round++ {
+				var (
+					wg, cancels sync.WaitGroup
+					won         atomic.Int32
+					next        *passenger.BookingInfo
+				)
+				cancelsDone := make(chan struct{})
+				for i := 0; i < 8; i++ {
+					cancels.Add(1)
+					go func() {
+						defer cancels.Done()
+						err := svc.CancelBooking(bk.BookingID, now)
+						switch {
+						case err == nil:
+							won.Add(1)
+						case !errors.Is(err, ErrBookingCancelled):
+							t.Errorf("unexpected error: %v", err)
+						}
+					}()
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					// Keep trying while the cancellations run, then once more
+					// after they are done, when the seat must be free.
+					for last := false; next == nil && !last; {
+						select {
+						case <-cancelsDone:
+							last = true
+						default:
+						}
+						rebooked, err := svc.BookSeat(fmt.Sprintf("P%d", round), "BENCH1", "Economy", now)
+						if err == nil {
+							next = rebooked
+						} else if !errors.Is(err, booking.ErrNoSeatAvailable) && !errors.Is(err, booking.ErrSeatConflict) {
+							t.Errorf("unexpected error: %v", err)
+							return
+						}
+					}
+				}()
+				cancels.Wait()
+				close(cancelsDone)
+				wg.Wait()
+
+				if won.Load() != 1 {
+					t.Fatalf("round %d: expected one cancellation to win, got %d", round, won.Load())
+				}
+				if next == nil {
+					t.Fatalf("round %d: expected the freed seat rebooked", round)
+				}
+				if booked := bookedSeats(f, "Economy"); len(booked) != 1 {
+					t.Fatalf("round %d: expected the rebooked seat to stay booked, got %v", round, booked)
+				}
+				if stored, _ := svc.Passengers.GetBooking(bk.BookingID); stored.Status != passenger.StatusCancelled {
+					t.Fatalf("round %d: expected the booking cancelled, got %s", round, stored.Status)
+				}
+				bk = next
+			}
+			if cancelled.Load() != 20 {
+				t.Errorf("expected 20 cancellations published, got %d", cancelled.Load())
+			}
+		})
+	}
+}
+
+// TestService_SellsEachSeatOnce books every seat of a cabin from more
+// goroutines than there are seats: each seat is sold exactly once.
+func TestService_SellsEachSeatOnce(t *testing.T) {
+	for _, mode := range []InventoryMode{InventoryOptimistic, InventoryActor} {
+		t.Run(string(mode), func(t *testing.T) {
+			testSellsEachSeatOnce(t, mode)
+		})
+	}
+}
+
+func testSellsEachSeatOnce(t *testing.T, mode InventoryMode) {
+	f := newBenchFlight(10, 20)
+	svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+	svc.Inventory = mode
+	t.Cleanup(svc.StopActors)
+	now := time.Now()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		sold = map[string]int{}
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				seat, _, _, err := svc.bookInClass(f, "Economy", "", "", now, false, nil)
+				if errors.Is(err, booking.ErrSeatConflict) {
+					continue
+				}
+				if err != nil {
+					if !errors.Is(err, booking.ErrNoSeatAvailable) {
+						t.Errorf("unexpected error: %v", err)
+					}
+					return
+				}
+				mu.Lock()
+				sold[seat.(*flight.Seat).SeatID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(sold) != 200 {
+		t.Errorf("expected 200 seats sold, got %d", len(sold))
+	}
+	for id, n := range sold {
+		if n != 1 {
+			t.Errorf("seat %s sold %d times", id, n)
+		}
+	}
+}
+
+func TestService_StopActors(t *testing.T) {
+	f := newChangeFlight("ACT1", "CNX")
+	svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+	svc.Inventory = InventoryActor
+	now := time.Now()
+
+	bk, err := svc.BookSeat("P1", "ACT1", "Economy", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a := svc.actor("ACT1")
+	svc.StopActors()
+	select {
+	case <-a.stopped:
+	default:
+		t.Fatalf("expected the actor stopped")
+	}
+
+	// The next command starts a new actor.
+	if err := svc.CancelBooking(bk.BookingID, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bookedSeats(f, "Economy")) != 0 || svc.actor("ACT1") == a {
+		t.Errorf("expected the seat released by a new actor")
+	}
+	svc.StopActors()
+}
+
+func TestService_ActorChange(t *testing.T) {
+	f := newChangeFlight("ACT2", "CNX")
+	svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+	svc.Inventory = InventoryActor
+	defer svc.StopActors()
+
+	bk, err := svc.BookSeat("P1", "ACT2", "Economy", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The flight's actor moves the seat, so the change does not wait for the
+	// cabin's lock.
+	f.Mutex["Economy"].Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.ChangeBooking(ChangeRequest{BookingID: bk.BookingID, SeatID: "A3", Now: time.Now()})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		f.Mutex["Economy"].Unlock()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		f.Mutex["Economy"].Unlock()
+		t.Fatalf("expected the change made without the cabin's lock")
+	}
+	if got := bookedSeats(f, "Economy"); len(got) != 1 || got[0] != "A3" {
+		t.Errorf("expected A3 booked, got %v", got)
+	}
+}
+
+// newBenchFlight has one Economy cabin of columns by rows seats.
+func newBenchFlight(columns, rows int) *flight.Flight {
+	departure := time.Now().AddDate(0, 0, 14)
+	f := flight.InitializeFlight("BENCH1", "BKK", "CNX", "Airbus A380", departure, departure.Add(time.Hour))
+	layout := make([][]*flight.Seat, columns)
+	for c := range layout {
+		layout[c] = make([]*flight.Seat, rows)
+		for r := range layout[c] {
+			layout[c][r] = &flight.Seat{}
+		}
+	}
+	f.AddSeatClass("Economy", layout, usd(100))
+	return f
+}
+
+// BenchmarkBookSeat books and frees seats of one 500-seat cabin from
+// parallel goroutines in each inventory mode. BestSeat lets every booking
+// take the best free seat, so bookings compete for the same seats;
+// ChosenSeat gives each goroutine seats of its own. Compare them with:
+//
+//	go test -run '^$' -bench BookSeat -cpu 1,4,8 ./internal/usecase
+func BenchmarkBookSeat(b *testing.B) {
+	for _, mode := range []InventoryMode{InventoryLocked, InventoryOptimistic, InventoryActor} {
+		for _, chosen := range []bool{false, true} {
+			name := string(mode) + "/BestSeat"
+			if chosen {
+				name = string(mode) + "/ChosenSeat"
+			}
+			b.Run(name, func(b *testing.B) {
+				f := newBenchFlight(10, 50)
+				svc := NewService([]*flight.Flight{f}, passenger.NewInMemoryStorage())
+				svc.Inventory = mode
+				defer svc.StopActors()
+				now := time.Now()
+				var next atomic.Int64
+				b.RunParallel(func(pb *testing.PB) {
+					seatIDs := []string{""}
+					if chosen {
+						// 25 seats a goroutine, so up to 20 never share one.
+						first := int(next.Add(1)-1) * 25 % 500
+						seatIDs = nil
+						for i := first; i < first+25; i++ {
+							seatIDs = append(seatIDs, f.Seats["Economy"][i].SeatID)
+						}
+					}
+					for i := 0; pb.Next(); i++ {
+						seat, _, _, err := svc.bookInClass(f, "Economy", "", seatIDs[i%len(seatIDs)], now, false, nil)
+						if errors.Is(err, booking.ErrSeatConflict) {
+							continue
+						}
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						svc.releaseSeat(f, "Economy", seat.(*flight.Seat).SeatID, seat.(*flight.Seat).State().Version, "")
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package usecase
+
+import (
+	"github.com/T-Prohmpossadhorn/flight-booking/internal/domain/webhook"
+)
+
+// AddWebhook registers a webhook subscription. The first one subscribes the
+// webhook dispatcher to the service's events, so events published from then
+// on are delivered.
+func (s *Service) AddWebhook(sub webhook.Subscription) (webhook.Subscription, error) {
+	created, err := s.Webhooks.Add(sub)
+	if err != nil {
+		return webhook.Subscription{}, err
+	}
+	s.webhooksOnce.Do(func() {
+		if s.Events != nil {
+			s.Events.SubscribeAsync(s.Webhooks.Handle, 0)
+		}
+	})
+	return created, nil
+}